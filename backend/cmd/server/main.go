@@ -3,12 +3,15 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
 
@@ -39,10 +42,23 @@ import (
 	// Other imports
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/ewag/gen-erics/backend/internal/anonymize"
+	"github.com/ewag/gen-erics/backend/internal/audit"
 	"github.com/ewag/gen-erics/backend/internal/api"
+	"github.com/ewag/gen-erics/backend/internal/auth"
 	"github.com/ewag/gen-erics/backend/internal/config"
+	"github.com/ewag/gen-erics/backend/internal/hl7"
+	"github.com/ewag/gen-erics/backend/internal/integrity"
+	"github.com/ewag/gen-erics/backend/internal/jobs"
+	"github.com/ewag/gen-erics/backend/internal/maintenance"
+	"github.com/ewag/gen-erics/backend/internal/mover"
 	"github.com/ewag/gen-erics/backend/internal/orthanc"
+	"github.com/ewag/gen-erics/backend/internal/prefetch"
+	"github.com/ewag/gen-erics/backend/internal/retention"
+	"github.com/ewag/gen-erics/backend/internal/retry"
 	"github.com/ewag/gen-erics/backend/internal/storage"
+	"github.com/ewag/gen-erics/backend/internal/thumbnail"
+	"github.com/ewag/gen-erics/backend/internal/tiers"
 )
 
 func initOtelProvider(ctx context.Context, serviceName, serviceVersion, otelEndpoint string) (shutdown func(context.Context) error, err error) {
@@ -107,10 +123,208 @@ func checkDatabaseSetup(ctx context.Context, db *pgxpool.Pool) error {
         }
         slog.Info("Created study_status table successfully")
     }
+
+    // Remaining tables (catalog etc.) are managed by the storage package
+    if err := storage.EnsureSchema(ctx, db); err != nil {
+        return err
+    }
     
     return nil
 }
 
+// initTierBackends registers a filesystem backend for each non-hot tier.
+// A tier whose directory can't be prepared is left unconfigured (moves to it will fail).
+func initTierBackends(cfg *config.Config, keys *tiers.Keys) *tiers.Registry {
+	registry := tiers.NewRegistry()
+	for tier, dir := range map[string]string{"cold": cfg.ColdStoragePath, "archive": cfg.ArchiveStoragePath, "trash": cfg.TrashStoragePath} {
+		fsBackend, err := tiers.NewFSBackend(tier, dir)
+		if err != nil {
+			slog.Error("Failed to initialize tier backend", "tier", tier, "path", dir, "error", err)
+			continue
+		}
+		var backend tiers.Backend = fsBackend
+		encrypted := keys != nil && slices.Contains(cfg.EncryptedTiers, tier)
+		if encrypted {
+			backend = tiers.NewEncryptedBackend(fsBackend, keys, cfg.EncryptionAllowPlaintext)
+		}
+		registry.Register(tier, backend)
+		slog.Info("Tier backend ready", "tier", tier, "path", dir, "encrypted", encrypted)
+	}
+	return registry
+}
+
+// initEncryptionKeys loads the master keyring for tier encryption. It returns
+// nil when encryption is off; a configured but unusable keyring is fatal, so
+// objects are never silently written in the clear.
+func initEncryptionKeys(cfg *config.Config, store tiers.DataKeyStore) *tiers.Keys {
+	if cfg.EncryptionKeyring == "" {
+		slog.Warn("ENCRYPTION_KEYRING_FILE is not set: tier objects are stored unencrypted")
+		return nil
+	}
+	keyring, err := tiers.NewFileKeyring(cfg.EncryptionKeyring)
+	if err != nil {
+		slog.Error("Failed to load encryption keyring", "path", cfg.EncryptionKeyring, "error", err)
+		os.Exit(1)
+	}
+	slog.Info("Tier encryption enabled", "keyring", cfg.EncryptionKeyring, "masterKeyID", keyring.CurrentKeyID(), "tiers", cfg.EncryptedTiers)
+	if cfg.EncryptionAllowPlaintext {
+		slog.Warn("ENCRYPTION_ALLOW_PLAINTEXT is set: unencrypted objects in encrypted tiers are served as they are; unset it once they are migrated")
+	}
+	return tiers.NewKeys(store, keyring)
+}
+
+// runKeyRotation re-wraps every data key with the keyring's current master
+// key and returns the process exit code. Objects are not rewritten.
+func runKeyRotation(ctx context.Context, cfg *config.Config, store tiers.DataKeyStore) int {
+	keys := initEncryptionKeys(cfg, store)
+	if keys == nil {
+		return 1
+	}
+	result, err := keys.Rotate(ctx, 500)
+	if err != nil {
+		slog.Error("Key rotation aborted", "rewrapped", result.Rewrapped, "failed", result.Failed, "error", err)
+		return 1
+	}
+	slog.Info("Key rotation finished", "rewrapped", result.Rewrapped, "failed", result.Failed)
+	if result.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// initAuth builds the authentication middleware for /api/v1, or none when auth is disabled.
+func initAuth(cfg *config.Config, apiKeys *auth.APIKeys) []gin.HandlerFunc {
+	if !cfg.AuthEnabled {
+		slog.Warn("AUTH IS DISABLED (AUTH_ENABLED=false): the API and the patient data behind it are open to anyone who can reach it")
+		return nil
+	}
+	var keySet *auth.KeySet
+	if cfg.AuthJWKSFile != "" {
+		keySet = auth.NewFileKeySet(cfg.AuthJWKSFile, cfg.AuthJWKSCacheTTL)
+		slog.Info("Using static JWKS file for token verification", "path", cfg.AuthJWKSFile)
+	} else {
+		jwksClient := &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport), Timeout: cfg.HttpClientTimeout}
+		keySet = auth.NewRemoteKeySet(jwksClient, cfg.AuthIssuer, cfg.AuthJWKSURL, cfg.AuthJWKSCacheTTL)
+		slog.Info("Using remote JWKS for token verification", "issuer", cfg.AuthIssuer, "jwksURL", cfg.AuthJWKSURL)
+	}
+	verifier := auth.NewVerifier(keySet, auth.VerifierConfig{
+		Issuer:     cfg.AuthIssuer,
+		Audience:   cfg.AuthAudience,
+		RolesClaim: cfg.AuthRolesClaim,
+		Leeway:     time.Minute,
+	})
+	return []gin.HandlerFunc{auth.Middleware(verifier, apiKeys)}
+}
+
+// initCORS returns the CORS middleware for the configured origins, or nil
+// when none are configured and browsers may only call the API same-origin.
+func initCORS(cfg *config.Config) gin.HandlerFunc {
+	if len(cfg.CORSAllowedOrigins) == 0 {
+		slog.Info("CORS_ALLOWED_ORIGINS is not set: cross-origin requests are not allowed")
+		return nil
+	}
+	if slices.Contains(cfg.CORSAllowedOrigins, "*") {
+		slog.Warn("CORS_ALLOWED_ORIGINS allows any origin: any web page can call the API with its visitors' credentials")
+	}
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOrigins = cfg.CORSAllowedOrigins
+	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization"}
+	return cors.New(corsConfig)
+}
+
+// initAuthorizer loads the role policy, falling back to the built-in matrix.
+func initAuthorizer(cfg *config.Config) (*auth.Authorizer, error) {
+	policy := auth.DefaultPolicy()
+	if cfg.AuthPolicyFile != "" {
+		var err error
+		if policy, err = auth.LoadPolicy(cfg.AuthPolicyFile); err != nil {
+			return nil, err
+		}
+		slog.Info("Loaded authorization policy", "path", cfg.AuthPolicyFile, "roles", len(policy.Roles))
+	}
+	if cfg.AuthEnabled {
+		// Every request carries a verified principal; never fall back to anonymous roles
+		return auth.NewAuthorizer(policy, nil), nil
+	}
+	slog.Warn("Unauthenticated requests are granted anonymous roles", "roles", cfg.AuthAnonymousRoles)
+	for _, role := range cfg.AuthAnonymousRoles {
+		if slices.Contains(policy.Roles[role], auth.PermAll) {
+			slog.Warn("AUTH_ANONYMOUS_ROLES grants unauthenticated callers every permission, including deletion and re-identification", "role", role)
+		}
+	}
+	return auth.NewAuthorizer(policy, cfg.AuthAnonymousRoles), nil
+}
+
+// initAuditForwarder builds the syslog forwarder, or returns nil when AUDIT_SYSLOG_ADDR is unset.
+func initAuditForwarder(cfg *config.Config) (*audit.SyslogForwarder, error) {
+	if cfg.AuditSyslogAddr == "" {
+		return nil, nil
+	}
+	if !cfg.AuditSyslogTLS {
+		slog.Warn("Audit events are forwarded over plain TCP", "address", cfg.AuditSyslogAddr)
+		return audit.NewSyslogForwarder(cfg.AuditSyslogAddr, nil, cfg.OtelServiceName), nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.AuditSyslogCAFile != "" {
+		pem, err := os.ReadFile(cfg.AuditSyslogCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit syslog CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.AuditSyslogCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.AuditSyslogCert != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.AuditSyslogCert, cfg.AuditSyslogKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load audit syslog client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return audit.NewSyslogForwarder(cfg.AuditSyslogAddr, tlsConfig, cfg.OtelServiceName), nil
+}
+
+// initThumbnails builds the thumbnail service. Without a usable cache
+// directory thumbnails are still served, just generated on every request.
+func initThumbnails(cfg *config.Config, orthancClient *orthanc.Client, store *storage.Store, registry *tiers.Registry) *thumbnail.Service {
+	var cache *thumbnail.DiskCache
+	if cfg.ThumbnailCacheDir != "" {
+		var err error
+		if cache, err = thumbnail.NewDiskCache(cfg.ThumbnailCacheDir, cfg.ThumbnailCacheMaxMB<<20); err != nil {
+			slog.Error("Failed to initialize thumbnail cache; thumbnails will not be cached", "path", cfg.ThumbnailCacheDir, "error", err)
+			cache = nil
+		}
+	}
+	return thumbnail.NewService(orthancClient, store, store, registry, cache, thumbnail.Config{
+		Size:       cfg.ThumbnailSize,
+		Quality:    cfg.ThumbnailQuality,
+		VersionTTL: cfg.ThumbnailVersionTTL,
+	})
+}
+
+// initMaintenance parses maintenance windows and per-edge bandwidth limits.
+func initMaintenance(cfg *config.Config) (*maintenance.Schedule, *maintenance.Bandwidth, error) {
+	loc := time.Local
+	if cfg.MaintenanceTimezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(cfg.MaintenanceTimezone); err != nil {
+			return nil, nil, fmt.Errorf("invalid MAINTENANCE_TIMEZONE: %w", err)
+		}
+	}
+	schedule, err := maintenance.ParseWindows(cfg.MaintenanceWindows, loc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid MAINTENANCE_WINDOWS: %w", err)
+	}
+	bandwidth, err := maintenance.ParseBandwidthLimits(cfg.EdgeBandwidthLimits)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid EDGE_BANDWIDTH_LIMITS: %w", err)
+	}
+	return schedule, bandwidth, nil
+}
+
 // --- Main Function ---
 func main() {
 	// Set basic slog handler temporarily for startup/config loading issues
@@ -155,6 +369,13 @@ func main() {
 		os.Exit(1)
 	}
 
+	// "rotate-keys" re-wraps tier data keys with the current master key and exits
+	if len(os.Args) > 1 && os.Args[1] == "rotate-keys" {
+		code := runKeyRotation(ctx, cfg, storage.NewStore(dbPool))
+		dbPool.Close()
+		os.Exit(code)
+	}
+
 	// --- Initialize OTel ---
 	otelEndpoint := cfg.OtelEndpoint
 	serviceName := cfg.OtelServiceName
@@ -175,7 +396,7 @@ func main() {
 		otelslog.WithLoggerProvider(loggerProvider),
 	)
 
-	slog.SetDefault(slog.New(otelHandler))
+	slog.SetDefault(slog.New(auth.NewLogHandler(otelHandler))) // Adds the acting user to request logs
 	slog.Info("OTel logging initialized and set as slog default (using OTLP/gRPC)")
 
 	// Handle graceful shutdown for OTel AND DB Pool
@@ -199,23 +420,141 @@ func main() {
 	store := storage.NewStore(dbPool)
 
 	// --- Setup HTTP clients ---
-	orthancBaseClient := &http.Client{Timeout: cfg.HttpClientTimeout}
-	instrumentedTransport := otelhttp.NewTransport(orthancBaseClient.Transport)
-	instrumentedClient := &http.Client{Transport: instrumentedTransport, Timeout: cfg.HttpClientTimeout}
-	orthancClient := orthanc.NewClientWithHttpClient(cfg.OrthancURL, instrumentedClient)
+	// The Orthanc client times out each call itself; a client-wide timeout would cut off long downloads
+	instrumentedClient := &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
+	orthancClient := orthanc.NewClientWithOptions(cfg.OrthancURL, instrumentedClient, orthanc.Options{
+		Timeout:          cfg.HttpClientTimeout,
+		Retry:            retry.Policy{Attempts: cfg.OrthancRetryAttempts, BaseDelay: cfg.OrthancRetryBaseDelay, MaxDelay: cfg.OrthancRetryMaxDelay},
+		BreakerThreshold: cfg.OrthancBreakerThreshold,
+		BreakerCooldown:  cfg.OrthancBreakerCooldown,
+	})
+
+	// --- Tier backends, integrity checks and the mover ---
+	tierRegistry := initTierBackends(cfg, initEncryptionKeys(cfg, store))
+	integrityMetrics := integrity.NewMetrics()
+	compression, err := mover.ParseCompressionRules(cfg.TierCompression)
+	if err != nil {
+		slog.Error("Invalid TIER_COMPRESSION", "error", err)
+		os.Exit(1)
+	}
+	retentionService := retention.NewService(store, store, store, orthancClient, tierRegistry, retention.Policy{
+		Years:        cfg.RetentionYears,
+		MajorityAge:  cfg.RetentionMajorityAge,
+		PediatricAge: cfg.RetentionPediatricAge,
+	})
+	schedule, bandwidth, err := initMaintenance(cfg)
+	if err != nil {
+		slog.Error("Invalid maintenance configuration", "error", err)
+		os.Exit(1)
+	}
+	stepRetry := retry.Policy{Attempts: cfg.MoverStepAttempts, BaseDelay: cfg.MoverStepBaseDelay, MaxDelay: cfg.MoverStepMaxDelay}
+	studyMover := mover.NewMover(orthancClient, store, store, tierRegistry, integrityMetrics, compression, retentionService, bandwidth, stepRetry)
+
+	if cfg.ScrubEnabled {
+		scrubber := integrity.NewScrubber(store, tierRegistry, integrityMetrics, integrity.ScrubberConfig{
+			Interval:       cfg.ScrubInterval,
+			MinAge:         cfg.ScrubMinAge,
+			BatchSize:      cfg.ScrubBatchSize,
+			BytesPerSecond: cfg.ScrubBytesPerSecond,
+			Tiers:          tierRegistry.Tiers(),
+		})
+		go scrubber.Run(ctx)
+	}
 	
 	// --- Create API handler ---
-	handler := api.NewAPIHandler(orthancClient, store)
+	anonymizer := anonymize.NewService(orthancClient, store)
+	authorizer, err := initAuthorizer(cfg)
+	if err != nil {
+		slog.Error("Failed to initialise authorization policy", "error", err)
+		os.Exit(1)
+	}
+	apiKeys := auth.NewAPIKeys(store)
+	auditForwarder, err := initAuditForwarder(cfg)
+	if err != nil {
+		slog.Error("Failed to initialise audit forwarding", "error", err)
+		os.Exit(1)
+	}
+	var forwarder audit.Forwarder
+	if auditForwarder != nil {
+		go auditForwarder.Run(ctx)
+		forwarder = auditForwarder
+	}
+	auditRecorder := audit.NewRecorder(store, forwarder, audit.AuditSource{ID: cfg.AuditSourceID, EnterpriseSiteID: cfg.AuditSiteID})
+	if retentionService.Policy().Enabled() {
+		sweeper := retention.NewSweeper(retentionService, auditRecorder, retention.SweeperConfig{
+			Interval:   cfg.RetentionInterval,
+			MaxDeletes: cfg.RetentionMaxDeletes,
+			Enforce:    cfg.RetentionEnforce,
+		})
+		go sweeper.Run(ctx)
+	}
+	trashBin := retention.NewTrashBin(retentionService, studyMover, store, auditRecorder, retention.TrashConfig{
+		GracePeriod:   cfg.TrashGracePeriod,
+		PurgeInterval: cfg.TrashPurgeInterval,
+	})
+	go trashBin.Run(ctx)
+	thumbnails := initThumbnails(cfg, orthancClient, store, tierRegistry)
+
+	// --- Job queue and prior-study prefetch ---
+	jobQueue := jobs.NewQueue(store, store, schedule)
+	concurrencyLimits, err := jobs.ParseConcurrencyLimits(cfg.JobConcurrencyLimits)
+	if err != nil {
+		slog.Error("Invalid JOB_CONCURRENCY_LIMITS", "error", err)
+		os.Exit(1)
+	}
+	jobWorker := jobs.NewWorker(store, store, studyMover, thumbnails, schedule, jobs.WorkerConfig{
+		ID:           cfg.JobWorkerID,
+		Workers:      cfg.JobWorkers,
+		PollInterval: cfg.JobPollInterval,
+		Lease:        cfg.JobLease,
+		Limits:       concurrencyLimits,
+		Retry:        retry.Policy{Attempts: cfg.JobMaxAttempts, BaseDelay: cfg.JobRetryBaseDelay, MaxDelay: cfg.JobRetryMaxDelay},
+	})
+	go jobWorker.Run(ctx)
+	batcher := jobs.NewBatcher(jobQueue, orthancClient, store, store, cfg.MoveBatchMaxStudies)
+	prefetchRules, err := prefetch.ParseRelevanceRules(cfg.PrefetchRules)
+	if err != nil {
+		slog.Error("Invalid PREFETCH_RULES", "error", err)
+		os.Exit(1)
+	}
+	prefetcher := prefetch.NewPrefetcher(orthancClient, store, store, jobQueue, prefetchRules, prefetch.Config{
+		MaxPriors:   cfg.PrefetchMaxPriors,
+		DefaultEdge: cfg.PrefetchDefaultEdge,
+	})
+	if cfg.PrefetchEnabled {
+		go prefetch.NewWatcher(orthancClient, store, prefetcher, cfg.PrefetchPollInterval).Run(ctx)
+	}
+	if cfg.HL7Enabled {
+		locationEdges, err := hl7.ParseLocationEdges(cfg.HL7LocationEdges)
+		if err != nil {
+			slog.Error("Invalid HL7_LOCATION_EDGES", "error", err)
+			os.Exit(1)
+		}
+		processor := hl7.NewProcessor(orthancClient, store, store, store, retentionService, prefetcher, auditRecorder, hl7.Config{
+			Sender:        hl7.Sender{Application: cfg.HL7Application, Facility: cfg.HL7Facility},
+			LocationEdges: locationEdges,
+		})
+		hl7Server := hl7.NewServer(cfg.HL7ListenAddress, processor.Handle)
+		go func() {
+			if err := hl7Server.Run(ctx); err != nil {
+				slog.Error("HL7 listener failed", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	handler := api.NewAPIHandler(orthancClient, store, store, tierRegistry, studyMover, integrityMetrics, anonymizer,
+		authorizer, apiKeys, auditRecorder, thumbnails, cfg.TranscodeSyntaxes,
+		retentionService, trashBin, jobQueue, prefetcher, store, schedule, bandwidth, batcher)
 	
 	// --- Setup Gin Router ---
-	router := gin.Default()
-	corsConfig := cors.DefaultConfig()
-	corsConfig.AllowOrigins = []string{"*"}
-	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
-	corsConfig.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization"}
-	router.Use(cors.New(corsConfig))
+	router := gin.New()
+	router.Use(gin.Logger(), api.Recovery())
+	if corsMiddleware := initCORS(cfg); corsMiddleware != nil {
+		router.Use(corsMiddleware)
+	}
 	router.Use(otelgin.Middleware(serviceName))
-	api.RegisterRoutes(router, handler)
+	api.RegisterRoutes(router, handler, initAuth(cfg, apiKeys)...)
 
 	// --- Start Server ---
 	slog.Info("Starting HTTP server", "address", cfg.ListenAddress)
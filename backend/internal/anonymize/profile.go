@@ -0,0 +1,123 @@
+// File: backend/internal/anonymize/profile.go
+package anonymize
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/ewag/gen-erics/backend/internal/orthanc"
+)
+
+// dicomVersion pins the PS3.15 Annex E table Orthanc applies, so the profile
+// doesn't change underneath a running project when Orthanc is upgraded.
+const dicomVersion = "2021b"
+
+// Options are the PS3.15 Annex E profile options we support on top of the
+// Basic Application Confidentiality Profile.
+type Options struct {
+	// RetainDates keeps full dates and times
+	// (Retain Longitudinal Temporal Information with Full Dates Option).
+	RetainDates bool `json:"retainDates"`
+	// RetainDeviceIdentity keeps station, serial numbers and device UIDs
+	// (Retain Device Identity Option).
+	RetainDeviceIdentity bool `json:"retainDeviceIdentity"`
+	// CleanDescriptors keeps descriptive free text after removing identifying
+	// values from it (Clean Descriptors Option). Without it they are removed.
+	CleanDescriptors bool `json:"cleanDescriptors"`
+}
+
+// Tags the basic profile would remove or dummy, grouped by the option that retains them.
+var (
+	dateTags = []string{
+		"StudyDate", "SeriesDate", "AcquisitionDate", "ContentDate",
+		"StudyTime", "SeriesTime", "AcquisitionTime", "ContentTime",
+		"AcquisitionDateTime", "InstanceCreationDate", "InstanceCreationTime",
+	}
+	deviceTags = []string{
+		"StationName", "DeviceSerialNumber", "DeviceUID", "DetectorID",
+		"GantryID", "PlateID", "CassetteID", "GeneratorID",
+	}
+	descriptorTags = []string{
+		"StudyDescription", "SeriesDescription", "ProtocolName",
+		"ImageComments", "AdditionalPatientHistory", "PerformedProcedureStepDescription",
+	}
+	// uidTags are remapped consistently per project rather than randomised.
+	uidTags = []string{"StudyInstanceUID", "SeriesInstanceUID", "SOPInstanceUID", "FrameOfReferenceUID"}
+)
+
+// buildRequest turns the options, the instance's original tags and the
+// project's identifier mappings into an Orthanc anonymize request.
+func buildRequest(opts Options, tags map[string]any, replacements map[string]string) orthanc.AnonymizeRequest {
+	req := orthanc.AnonymizeRequest{
+		Replace:      make(map[string]string, len(replacements)),
+		Force:        true, // Needed to set UIDs and PatientID ourselves
+		DicomVersion: dicomVersion,
+	}
+	for tag, value := range replacements {
+		req.Replace[tag] = value
+	}
+	if opts.RetainDates {
+		req.Keep = append(req.Keep, dateTags...)
+	}
+	if opts.RetainDeviceIdentity {
+		req.Keep = append(req.Keep, deviceTags...)
+	}
+	if opts.CleanDescriptors {
+		identifiers := identifyingValues(tags)
+		for _, tag := range descriptorTags {
+			value := tagString(tags, tag)
+			if value == "" {
+				continue
+			}
+			req.Replace[tag] = cleanDescriptor(value, identifiers)
+		}
+	}
+	return req
+}
+
+// identifyingValues collects values that must not survive in free text.
+func identifyingValues(tags map[string]any) []string {
+	var values []string
+	for _, tag := range []string{"PatientID", "AccessionNumber", "OtherPatientIDs", "PatientBirthDate",
+		"InstitutionName", "ReferringPhysicianName", "PerformingPhysicianName", "OperatorsName"} {
+		if v := tagString(tags, tag); v != "" {
+			values = append(values, v)
+		}
+	}
+	// Person names are stored as Family^Given^Middle; match each component on its own
+	for _, part := range strings.Split(tagString(tags, "PatientName"), "^") {
+		if part = strings.TrimSpace(part); len(part) > 1 {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+// longNumbers catches MRN/phone/date-like digit runs that slip into descriptors.
+var longNumbers = regexp.MustCompile(`\d{6,}`)
+
+// cleanDescriptor strips identifying values from a free-text descriptor.
+func cleanDescriptor(value string, identifiers []string) string {
+	cleaned := value
+	for _, id := range identifiers {
+		cleaned = replaceFold(cleaned, id, "")
+	}
+	cleaned = longNumbers.ReplaceAllString(cleaned, "")
+	return strings.Join(strings.Fields(cleaned), " ")
+}
+
+// replaceFold replaces every case-insensitive occurrence of old in s.
+func replaceFold(s, old, replacement string) string {
+	if old == "" {
+		return s
+	}
+	return regexp.MustCompile(`(?i)`+regexp.QuoteMeta(old)).ReplaceAllLiteralString(s, replacement)
+}
+
+// tagString reads a simplified tag as a string; sequences yield "".
+func tagString(tags map[string]any, name string) string {
+	if s, ok := tags[name].(string); ok {
+		return strings.TrimSpace(s)
+	}
+	return ""
+}
@@ -0,0 +1,170 @@
+// File: backend/internal/anonymize/service.go
+package anonymize
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"regexp"
+
+	models "github.com/ewag/gen-erics/backend/internal/models"
+	"github.com/ewag/gen-erics/backend/internal/orthanc"
+	"github.com/ewag/gen-erics/backend/internal/storage"
+)
+
+// ErrInvalidProject is returned for an empty or malformed project name.
+var ErrInvalidProject = errors.New("invalid anonymization project")
+
+var projectPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Instance is one source instance with everything needed to anonymize it.
+type Instance struct {
+	SourceID       string // Orthanc instance ID of the original
+	SeriesUID      string // Anonymized SeriesInstanceUID
+	InstanceNumber string
+	Modality       string
+	request        orthanc.AnonymizeRequest
+}
+
+// Plan describes an anonymized copy of a study before any data is produced.
+type Plan struct {
+	Project          string     `json:"project"`
+	SourceStudyID    string     `json:"sourceStudyId"`
+	PseudonymID      string     `json:"pseudonymPatientId"`
+	StudyInstanceUID string     `json:"anonymizedStudyInstanceUid"`
+	Instances        []Instance `json:"-"`
+}
+
+// Service produces anonymized copies of hot studies through Orthanc,
+// remapping identifiers consistently within a project.
+type Service struct {
+	orthanc *orthanc.Client
+	store   storage.AnonymizationStore
+}
+
+// NewService creates an anonymization Service.
+func NewService(orthancClient *orthanc.Client, store storage.AnonymizationStore) *Service {
+	return &Service{orthanc: orthancClient, store: store}
+}
+
+// PlanStudy reads a hot study's tags and resolves (or allocates) every
+// replacement identifier for the project. No anonymized data is produced yet.
+func (s *Service) PlanStudy(ctx context.Context, project, studyID string, opts Options) (*Plan, error) {
+	if !projectPattern.MatchString(project) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidProject, project)
+	}
+	instances, err := s.orthanc.GetStudyInstances(ctx, studyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instances to anonymize: %w", err)
+	}
+
+	plan := &Plan{Project: project, SourceStudyID: studyID}
+	mapped := make(map[string]string) // Avoid a DB round-trip for repeated UIDs
+	for _, inst := range instances {
+		tags, err := s.orthanc.GetInstanceSimplifiedTags(ctx, inst.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tags of instance %s: %w", inst.ID, err)
+		}
+
+		replacements := make(map[string]string)
+		for _, tag := range uidTags {
+			original := tagString(tags, tag)
+			if original == "" {
+				continue
+			}
+			if replacements[tag], err = s.mapOnce(ctx, mapped, project, models.AnonKindUID, original, "", newUID, ""); err != nil {
+				return nil, err
+			}
+		}
+		// Instances without a PatientID all belong to one pseudonymous patient per study
+		studyScope := tagString(tags, "StudyInstanceUID")
+		if studyScope == "" {
+			studyScope = studyID
+		}
+		pseudonym, err := s.mapOnce(ctx, mapped, project, models.AnonKindPatient, tagString(tags, "PatientID"), studyScope,
+			newPseudonym, tagString(tags, "PatientName"))
+		if err != nil {
+			return nil, err
+		}
+		replacements["PatientID"] = pseudonym
+		replacements["PatientName"] = pseudonym
+
+		plan.PseudonymID = pseudonym
+		plan.StudyInstanceUID = replacements["StudyInstanceUID"]
+		plan.Instances = append(plan.Instances, Instance{
+			SourceID:       inst.ID,
+			SeriesUID:      replacements["SeriesInstanceUID"],
+			InstanceNumber: tagString(tags, "InstanceNumber"),
+			Modality:       tagString(tags, "Modality"),
+			request:        buildRequest(opts, tags, replacements),
+		})
+	}
+
+	slog.InfoContext(ctx, "Planned anonymized study copy", "project", project, "sourceStudyID", studyID,
+		"anonymizedStudyUID", plan.StudyInstanceUID, "instances", len(plan.Instances))
+	return plan, nil
+}
+
+// Open streams the anonymized DICOM file for one planned instance.
+func (s *Service) Open(ctx context.Context, inst Instance) (io.ReadCloser, error) {
+	file, err := s.orthanc.AnonymizeInstance(ctx, inst.SourceID, inst.request)
+	if err != nil {
+		return nil, err
+	}
+	return file.Body, nil
+}
+
+// Reidentify returns the original identifier behind an anonymized one.
+func (s *Service) Reidentify(ctx context.Context, project, anonymized string) (*models.AnonymizationMapping, bool, error) {
+	return s.store.ReverseLookup(ctx, project, anonymized)
+}
+
+// mapOnce returns the project's replacement for original, allocating one with
+// generate the first time. An empty original is never let through as-is; it
+// is mapped per emptyScope (e.g. the study), so the same gap gets the same
+// replacement every time.
+func (s *Service) mapOnce(ctx context.Context, cache map[string]string, project, kind, original, emptyScope string, generate func() (string, error), label string) (string, error) {
+	if original == "" {
+		original = emptyOriginal + "|" + emptyScope
+	}
+	key := kind + "|" + original
+	if v, ok := cache[key]; ok {
+		return v, nil
+	}
+	candidate, err := generate()
+	if err != nil {
+		return "", err
+	}
+	v, err := s.store.MapValue(ctx, project, kind, original, candidate, label)
+	if err != nil {
+		return "", err
+	}
+	cache[key] = v
+	return v, nil
+}
+
+// emptyOriginal stands in for a missing original value in the mapping table.
+const emptyOriginal = "<empty>"
+
+// newUID generates a UUID-derived UID under the 2.25 root (PS3.5 B.2).
+func newUID() (string, error) {
+	n, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate UID: %w", err)
+	}
+	return "2.25." + n.String(), nil
+}
+
+// newPseudonym generates a random patient pseudonym.
+func newPseudonym() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate pseudonym: %w", err)
+	}
+	return "ANON-" + hex.EncodeToString(b), nil
+}
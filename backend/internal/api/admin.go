@@ -0,0 +1,90 @@
+// File: backend/internal/api/admin.go
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ewag/gen-erics/backend/internal/problem"
+)
+
+const (
+	defaultAPIKeyTTLDays = 90
+	maxAPIKeyTTLDays     = 365
+)
+
+// CreateAPIKeyRequest defines the expected JSON body for API key creation
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"` // Role names from the policy
+	ExpiresInDays int      `json:"expiresInDays"`                   // Defaults to 90, at most 365
+}
+
+// CreateAPIKeyHandler issues a key. The plaintext secret is only in this response.
+func (h *APIHandler) CreateAPIKeyHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Write(c, http.StatusBadRequest, "Invalid API key request", err.Error())
+		return
+	}
+	if req.ExpiresInDays == 0 {
+		req.ExpiresInDays = defaultAPIKeyTTLDays
+	}
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > maxAPIKeyTTLDays {
+		problem.Write(c, http.StatusBadRequest, fmt.Sprintf("expiresInDays must be between 1 and %d", maxAPIKeyTTLDays), "")
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !h.authz.DefinesRole(scope) {
+			problem.Write(c, http.StatusBadRequest, fmt.Sprintf("Unknown role in scopes: %s", scope), "")
+			return
+		}
+	}
+	if !h.authz.CanDelegate(c, req.Scopes) {
+		problem.Write(c, http.StatusForbidden, "Cannot issue a key with more access than the caller holds", "")
+		return
+	}
+
+	key, secret, err := h.apiKeys.Create(ctx, req.Name, req.Scopes, time.Duration(req.ExpiresInDays)*24*time.Hour)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create API key", "name", req.Name, "error", err)
+		problem.Write(c, http.StatusInternalServerError, "Failed to create API key", "")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"key":    key,
+		"secret": secret,
+		"note":   "Store this secret now; it cannot be retrieved again",
+	})
+}
+
+// ListAPIKeysHandler lists key metadata (never secrets).
+func (h *APIHandler) ListAPIKeysHandler(c *gin.Context) {
+	keys, err := h.apiKeys.List(c.Request.Context())
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, "Failed to list API keys", "")
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKeyHandler disables a key immediately.
+func (h *APIHandler) RevokeAPIKeyHandler(c *gin.Context) {
+	keyID := c.Param("keyID")
+	revoked, err := h.apiKeys.Revoke(c.Request.Context(), keyID)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, "Failed to revoke API key", "")
+		return
+	}
+	if !revoked {
+		problem.Write(c, http.StatusNotFound, fmt.Sprintf("No active API key with id %s", keyID), "")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,172 @@
+// File: backend/internal/api/anonymize.go
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ewag/gen-erics/backend/internal/anonymize"
+	"github.com/ewag/gen-erics/backend/internal/audit"
+	models "github.com/ewag/gen-erics/backend/internal/models"
+	"github.com/ewag/gen-erics/backend/internal/problem"
+)
+
+// AnonymizeRequest defines the expected JSON body for anonymize requests
+type AnonymizeRequest struct {
+	Project string            `json:"project" binding:"required"`
+	Options anonymize.Options `json:"options"`
+	Output  string            `json:"output"` // "orthanc" (default) or "archive"
+}
+
+// AnonymizeStudyHandler creates an anonymized copy of a hot study, either as a
+// new study in Orthanc or as a streamed ZIP download.
+func (h *APIHandler) AnonymizeStudyHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	studyUID := c.Param("studyUID")
+	if studyUID == "" {
+		problem.Write(c, http.StatusBadRequest, "Missing study UID", "")
+		return
+	}
+
+	var req AnonymizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Write(c, http.StatusBadRequest, "Invalid anonymize request", err.Error())
+		return
+	}
+	if req.Output == "" {
+		req.Output = "orthanc"
+	}
+	if req.Output != "orthanc" && req.Output != "archive" {
+		problem.Write(c, http.StatusBadRequest, "output must be 'orthanc' or 'archive'", "")
+		return
+	}
+	logAttrs := []any{"studyUID", studyUID, "project", req.Project, "output", req.Output, "options", req.Options}
+
+	// Orthanc does the heavy lifting, so the source must be hot
+	status, found, err := h.db.GetStatus(ctx, studyUID)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, "Failed to check study status", "")
+		return
+	}
+	if found && studyDeleted(c, status.Tier) {
+		return
+	}
+	if found && status.Tier != "hot" {
+		problem.Write(c, http.StatusConflict, fmt.Sprintf("Anonymization not available (Study status: %s)", status.Tier), "Move study to hot tier to anonymize it")
+		return
+	}
+
+	plan, err := h.anonymizer.PlanStudy(ctx, req.Project, studyUID, req.Options)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to plan anonymization", append(logAttrs, "error", err)...)
+		if errors.Is(err, anonymize.ErrInvalidProject) {
+			problem.Write(c, http.StatusBadRequest, err.Error(), "")
+			return
+		}
+		respondError(c, "Failed to prepare anonymization", err)
+		return
+	}
+	if len(plan.Instances) == 0 {
+		problem.Write(c, http.StatusNotFound, "No instances found to anonymize", "")
+		return
+	}
+
+	if req.Output == "archive" {
+		h.streamAnonymizedArchive(c, plan)
+		return
+	}
+
+	var anonymizedStudyID string
+	for _, inst := range plan.Instances {
+		result, err := h.uploadAnonymized(ctx, inst)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to store anonymized instance", append(logAttrs, "instanceID", inst.SourceID, "error", err)...)
+			respondError(c, "Failed to store anonymized study", err)
+			return
+		}
+		anonymizedStudyID = result
+	}
+
+	slog.InfoContext(ctx, "Stored anonymized study copy", append(logAttrs, "anonymizedStudyID", anonymizedStudyID)...)
+	c.JSON(http.StatusCreated, gin.H{
+		"project":                    plan.Project,
+		"sourceStudyId":              plan.SourceStudyID,
+		"anonymizedStudyId":          anonymizedStudyID,
+		"anonymizedStudyInstanceUid": plan.StudyInstanceUID,
+		"pseudonymPatientId":         plan.PseudonymID,
+		"instances":                  len(plan.Instances),
+	})
+}
+
+// uploadAnonymized pipes one anonymized instance from Orthanc back into Orthanc
+// and returns the Orthanc ID of the new study.
+func (h *APIHandler) uploadAnonymized(ctx context.Context, inst anonymize.Instance) (string, error) {
+	rc, err := h.anonymizer.Open(ctx, inst)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	result, err := h.orthancClient.UploadInstance(ctx, rc)
+	if err != nil {
+		return "", err
+	}
+	return result.ParentStudy, nil
+}
+
+// streamAnonymizedArchive writes the anonymized study as a ZIP without storing it.
+func (h *APIHandler) streamAnonymizedArchive(c *gin.Context, plan *anonymize.Plan) {
+	ctx := c.Request.Context()
+	paths := newArchivePaths()
+	items := make([]archiveItem, 0, len(plan.Instances))
+	for _, inst := range plan.Instances {
+		inst := inst
+		labels := archiveLabels{
+			patientID:        plan.PseudonymID,
+			studyDescription: plan.Project,
+			modality:         inst.Modality,
+			instanceNumber:   inst.InstanceNumber,
+		}
+		items = append(items, archiveItem{
+			path: paths.next(labels, inst.SeriesUID),
+			open: func(ctx context.Context) (io.ReadCloser, error) { return h.anonymizer.Open(ctx, inst) },
+		})
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-%s.zip\"", plan.Project, plan.PseudonymID))
+	c.Status(http.StatusOK)
+	if _, err := writeArchive(ctx, c.Writer, items); err != nil {
+		// Headers are gone; drop the connection so the client doesn't keep a truncated ZIP
+		slog.ErrorContext(ctx, "Anonymized archive streaming failed", "project", plan.Project, "studyUID", plan.SourceStudyID, "error", err)
+		abortResponse(c)
+	}
+}
+
+// ReidentifyHandler resolves an anonymized identifier (UID or pseudonym) back
+// to the original within a project. Privileged: exposes PHI.
+func (h *APIHandler) ReidentifyHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	project := c.Param("project")
+	value := c.Param("value")
+
+	mapping, found, err := h.anonymizer.Reidentify(ctx, project, value)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, "Failed to look up anonymization mapping", "")
+		return
+	}
+	if !found {
+		problem.Write(c, http.StatusNotFound, "No mapping found for value in project", "")
+		return
+	}
+	if mapping.Kind == models.AnonKindPatient {
+		audit.AddObject(c, audit.PatientObject(mapping.OriginalValue, mapping.OriginalLabel))
+	}
+	slog.InfoContext(ctx, "Re-identification lookup", "project", project, "kind", mapping.Kind)
+	c.JSON(http.StatusOK, mapping)
+}
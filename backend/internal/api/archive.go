@@ -0,0 +1,364 @@
+// File: backend/internal/api/archive.go
+package api
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ewag/gen-erics/backend/internal/integrity"
+	models "github.com/ewag/gen-erics/backend/internal/models"
+	"github.com/ewag/gen-erics/backend/internal/orthanc"
+	"github.com/ewag/gen-erics/backend/internal/problem"
+	"github.com/ewag/gen-erics/backend/internal/tiers"
+)
+
+// archiveItem is one file in a download ZIP. open is called lazily while the
+// ZIP is written so only one instance is in flight at a time.
+type archiveItem struct {
+	path  string
+	open  func(ctx context.Context) (io.ReadCloser, error)
+	entry *models.CatalogEntry // Set for tier-backed items, nil for hot
+}
+
+// archiveLabels carries the tags used to name folders in the ZIP.
+type archiveLabels struct {
+	patientID, patientName                    string
+	studyDate, studyDescription, accession    string
+	seriesNumber, modality, seriesDescription string
+	instanceNumber                            string
+}
+
+// GetStudyArchiveHandler streams a ZIP of every instance in a study.
+// ?dicomdir=true returns Orthanc's IHE PDI media layout with a DICOMDIR instead.
+// Accept: application/zip; transfer-syntax=... transcodes every instance.
+func (h *APIHandler) GetStudyArchiveHandler(c *gin.Context) {
+	h.serveArchive(c, c.Param("studyUID"), "")
+}
+
+// GetSeriesArchiveHandler streams a ZIP of one series of a study.
+func (h *APIHandler) GetSeriesArchiveHandler(c *gin.Context) {
+	h.serveArchive(c, c.Param("studyUID"), c.Param("seriesUID"))
+}
+
+func (h *APIHandler) serveArchive(c *gin.Context, studyID, seriesID string) {
+	ctx := c.Request.Context()
+	logAttrs := []any{"studyUID", studyID, "seriesUID", seriesID}
+	if studyID == "" {
+		problem.Write(c, http.StatusBadRequest, "Missing study UID", "")
+		return
+	}
+	dicomdir, _ := strconv.ParseBool(c.DefaultQuery("dicomdir", "false"))
+
+	// Studies without a status row have never left Orthanc
+	tier := "hot"
+	status, found, err := h.db.GetStatus(ctx, studyID)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, "Failed to check study status", "")
+		return
+	}
+	if found {
+		tier = status.Tier
+	}
+	if studyDeleted(c, tier) {
+		return
+	}
+	logAttrs = append(logAttrs, "tier", tier, "dicomdir", dicomdir)
+
+	accepted := acceptedTransferSyntaxes(c, "application/zip")
+	c.Header("Vary", "Accept")
+
+	if dicomdir {
+		if _, err := storedTarget(accepted, ""); err != nil {
+			notAcceptable(c, "DICOMDIR media is only served as stored", nil)
+			return
+		}
+		if tier != "hot" {
+			problem.Write(c, http.StatusConflict, "DICOMDIR media is only available for hot studies", "Download without dicomdir, or move the study to hot tier first")
+			return
+		}
+		h.proxyMediaArchive(c, studyID, seriesID)
+		return
+	}
+
+	var items []archiveItem
+	if tier == "hot" {
+		transferSyntax, ok := h.transcodeTarget(c, accepted)
+		if !ok {
+			return
+		}
+		logAttrs = append(logAttrs, "transferSyntax", transferSyntax)
+		items, err = h.hotArchiveItems(ctx, studyID, seriesID, transferSyntax)
+	} else {
+		items, err = h.tierArchiveItems(ctx, studyID, seriesID, tier, accepted)
+	}
+	if errors.Is(err, errNotStoredAs) {
+		notAcceptable(c, fmt.Sprintf("%v; transcoding is only available for hot studies", err), nil)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to build archive contents", append(logAttrs, "error", err)...)
+		respondError(c, "Failed to list study contents for archive", err)
+		return
+	}
+	if len(items) == 0 {
+		problem.Write(c, http.StatusNotFound, "No instances found for archive", "")
+		return
+	}
+
+	name := studyID
+	if seriesID != "" {
+		name = seriesID
+	}
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.zip\"", name))
+	c.Status(http.StatusOK)
+
+	slog.InfoContext(ctx, "Streaming archive", append(logAttrs, "instances", len(items))...)
+	if failed, err := writeArchive(ctx, c.Writer, items); err != nil {
+		if failed != nil && failed.entry != nil && errors.Is(err, integrity.ErrChecksumMismatch) {
+			slog.ErrorContext(ctx, "INTEGRITY ALERT: instance failed verification during archive download", append(logAttrs, "instanceID", failed.entry.InstanceID, "error", err)...)
+			h.recordRetrievalFailure(c, failed.entry, models.VerifyStatusCorrupt)
+		}
+		// Headers are gone; drop the connection so the client doesn't keep a truncated ZIP
+		slog.ErrorContext(ctx, "Archive streaming failed", append(logAttrs, "error", err)...)
+		abortResponse(c)
+	}
+	slog.InfoContext(ctx, "Archive streamed", logAttrs...)
+}
+
+// writeArchive writes items into a ZIP one at a time. Entries are stored, not
+// deflated: most DICOM pixel data is already compressed and CPU matters more.
+// On failure the offending item is returned along with the error.
+func writeArchive(ctx context.Context, w io.Writer, items []archiveItem) (*archiveItem, error) {
+	zw := zip.NewWriter(w)
+	now := time.Now()
+	for i := range items {
+		item := &items[i]
+		entry, err := zw.CreateHeader(&zip.FileHeader{Name: item.path, Method: zip.Store, Modified: now})
+		if err != nil {
+			return item, fmt.Errorf("failed to add %s to archive: %w", item.path, err)
+		}
+		rc, err := item.open(ctx)
+		if err != nil {
+			return item, fmt.Errorf("failed to open %s: %w", item.path, err)
+		}
+		_, err = io.Copy(entry, rc)
+		rc.Close()
+		if err != nil {
+			return item, fmt.Errorf("failed to write %s: %w", item.path, err)
+		}
+	}
+	return nil, zw.Close()
+}
+
+// proxyMediaArchive streams Orthanc's own DICOMDIR media ZIP through.
+func (h *APIHandler) proxyMediaArchive(c *gin.Context, studyID, seriesID string) {
+	ctx := c.Request.Context()
+	level, id := "studies", studyID
+	if seriesID != "" {
+		level, id = "series", seriesID
+	}
+
+	media, err := h.orthancClient.GetMediaArchive(ctx, level, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get media archive from Orthanc", "level", level, "id", id, "error", err)
+		respondError(c, "Failed to retrieve media archive from PACS", err)
+		return
+	}
+	defer media.Body.Close()
+
+	if err := serveStream(c, streamSource{
+		Body:        media.Body,
+		Size:        media.ContentLength,
+		ContentType: "application/zip",
+		Filename:    id + ".zip",
+	}); err != nil {
+		slog.WarnContext(ctx, "Streaming media archive interrupted", "level", level, "id", id, "error", err)
+	}
+}
+
+// hotArchiveItems lists a hot study's instances with paths built from Orthanc's
+// tags. A non-empty transferSyntax has Orthanc transcode each instance.
+func (h *APIHandler) hotArchiveItems(ctx context.Context, studyID, seriesID, transferSyntax string) ([]archiveItem, error) {
+	study, err := h.orthancClient.GetStudyDetails(ctx, studyID)
+	if err != nil {
+		return nil, err
+	}
+	instances, err := h.orthancClient.GetStudyInstances(ctx, studyID)
+	if err != nil {
+		return nil, err
+	}
+
+	series := make(map[string]*orthanc.SeriesDetails)
+	paths := newArchivePaths()
+	items := make([]archiveItem, 0, len(instances))
+	for _, inst := range instances {
+		if seriesID != "" && inst.ParentSeries != seriesID {
+			continue
+		}
+		labels := archiveLabels{
+			patientID:        study.PatientMainTags.PatientID,
+			patientName:      study.PatientMainTags.PatientName,
+			studyDate:        study.MainTags.StudyDate,
+			studyDescription: study.MainTags.StudyDescription,
+			accession:        study.MainTags.AccessionNumber,
+			instanceNumber:   inst.MainTags.InstanceNumber,
+		}
+		sd, ok := series[inst.ParentSeries]
+		if !ok {
+			if sd, err = h.orthancClient.GetSeriesDetails(ctx, inst.ParentSeries); err != nil {
+				slog.WarnContext(ctx, "Failed to get series details for archive layout", "seriesID", inst.ParentSeries, "error", err)
+			}
+			series[inst.ParentSeries] = sd
+		}
+		if sd != nil {
+			labels.seriesNumber = sd.MainTags.SeriesNumber
+			labels.modality = sd.MainTags.Modality
+			labels.seriesDescription = sd.MainTags.SeriesDescription
+		}
+
+		instanceID := inst.ID
+		items = append(items, archiveItem{
+			path: paths.next(labels, inst.ParentSeries),
+			open: func(ctx context.Context) (io.ReadCloser, error) {
+				if transferSyntax != "" {
+					file, err := h.orthancClient.TranscodeInstance(ctx, instanceID, transferSyntax)
+					if err != nil {
+						return nil, err
+					}
+					return file.Body, nil
+				}
+				file, err := h.orthancClient.GetInstanceFile(ctx, instanceID)
+				if err != nil {
+					return nil, err
+				}
+				return file.Body, nil
+			},
+		})
+	}
+	return items, nil
+}
+
+// tierArchiveItems lists a study's instances from the catalog, reading each one
+// straight from its tier backend with checksum verification. Every instance
+// must be stored in one of the accepted transfer syntaxes.
+func (h *APIHandler) tierArchiveItems(ctx context.Context, studyID, seriesID, tier string, accepted []string) ([]archiveItem, error) {
+	backend, ok := h.tiers.For(tier)
+	if !ok {
+		return nil, fmt.Errorf("no backend configured for tier %s", tier)
+	}
+	entries, err := h.catalog.ListCatalogEntries(ctx, studyID, tier)
+	if err != nil {
+		return nil, err
+	}
+	study, found, err := h.catalog.GetStudyCatalogEntry(ctx, studyID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		study = &models.StudyCatalogEntry{StudyID: studyID}
+	}
+
+	paths := newArchivePaths()
+	items := make([]archiveItem, 0, len(entries))
+	for _, entry := range entries {
+		if seriesID != "" && entry.SeriesID != seriesID {
+			continue
+		}
+		if _, err := storedTarget(accepted, entry.TransferSyntaxUID); err != nil {
+			return nil, fmt.Errorf("instance %s: %w", entry.InstanceID, err)
+		}
+		labels := archiveLabels{
+			patientID:         study.PatientID,
+			patientName:       study.PatientName,
+			studyDate:         study.StudyDate,
+			studyDescription:  study.StudyDescription,
+			accession:         study.AccessionNumber,
+			seriesNumber:      entry.SeriesNumber,
+			modality:          entry.Modality,
+			seriesDescription: entry.SeriesDescription,
+			instanceNumber:    entry.InstanceNumber,
+		}
+		entry := entry
+		items = append(items, archiveItem{
+			path:  paths.next(labels, entry.SeriesID),
+			entry: &entry,
+			open: func(ctx context.Context) (io.ReadCloser, error) {
+				rc, _, err := tiers.OpenEntry(ctx, backend, entry.ObjectKey, entry.EncryptionKeyID)
+				if err != nil {
+					return nil, err
+				}
+				return integrity.NewVerifyingReader(rc, entry.SHA256, entry.SizeBytes), nil
+			},
+		})
+	}
+	return items, nil
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// safeName turns a DICOM value into a portable folder/file name component.
+func safeName(value, fallback string) string {
+	name := strings.Trim(unsafeNameChars.ReplaceAllString(strings.TrimSpace(value), "_"), "_.")
+	if name == "" {
+		return fallback
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// archivePaths hands out unique PATIENT/STUDY/SERIES/IMAGE paths.
+type archivePaths struct {
+	used        map[string]bool
+	seriesCount map[string]int
+}
+
+func newArchivePaths() *archivePaths {
+	return &archivePaths{used: make(map[string]bool), seriesCount: make(map[string]int)}
+}
+
+func (p *archivePaths) next(l archiveLabels, seriesKey string) string {
+	patient := safeName(strings.Trim(l.patientID+"_"+l.patientName, "_"), "PATIENT")
+	studyLabel := l.studyDescription
+	if studyLabel == "" {
+		studyLabel = l.accession
+	}
+	study := safeName(strings.Trim(l.studyDate+"_"+studyLabel, "_"), "STUDY")
+	series := safeName(strings.Trim(zeroPad(l.seriesNumber, 4)+"_"+l.modality+"_"+l.seriesDescription, "_"), "SERIES")
+
+	p.seriesCount[seriesKey]++
+	image := fmt.Sprintf("IMG%05d", p.seriesCount[seriesKey])
+	if n, err := strconv.Atoi(strings.TrimSpace(l.instanceNumber)); err == nil && n >= 0 {
+		image = fmt.Sprintf("IMG%05d", n)
+	}
+
+	candidate := path.Join(patient, study, series, image+".dcm")
+	for i := 2; p.used[candidate]; i++ {
+		candidate = path.Join(patient, study, series, fmt.Sprintf("%s_%d.dcm", image, i))
+	}
+	p.used[candidate] = true
+	return candidate
+}
+
+// zeroPad left-pads numeric values so folders sort naturally; non-numbers pass through.
+func zeroPad(value string, width int) string {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return value
+	}
+	return fmt.Sprintf("%0*d", width, n)
+}
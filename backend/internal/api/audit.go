@@ -0,0 +1,72 @@
+// File: backend/internal/api/audit.go
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ewag/gen-erics/backend/internal/audit"
+	"github.com/ewag/gen-erics/backend/internal/problem"
+)
+
+// ListAuditEventsHandler returns audit events, newest first. Filters: user,
+// eventId (e.g. 110103), object (study UID or patient ID), outcome, from/to
+// (RFC 3339), before (event id, for paging) and limit.
+func (h *APIHandler) ListAuditEventsHandler(c *gin.Context) {
+	f := audit.Filter{
+		UserID:   c.Query("user"),
+		EventID:  c.Query("eventId"),
+		ObjectID: c.Query("object"),
+	}
+
+	var err error
+	if v := c.Query("outcome"); v != "" {
+		outcome, convErr := strconv.Atoi(v)
+		if convErr != nil {
+			problem.Write(c, http.StatusBadRequest, "outcome must be 0, 4, 8 or 12", "")
+			return
+		}
+		f.Outcome = &outcome
+	}
+	if f.From, err = parseAuditTime(c.Query("from")); err != nil {
+		problem.Write(c, http.StatusBadRequest, "Invalid 'from' timestamp", err.Error())
+		return
+	}
+	if f.To, err = parseAuditTime(c.Query("to")); err != nil {
+		problem.Write(c, http.StatusBadRequest, "Invalid 'to' timestamp", err.Error())
+		return
+	}
+	if v := c.Query("before"); v != "" {
+		if f.BeforeID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			problem.Write(c, http.StatusBadRequest, "before must be an event id", "")
+			return
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 1 {
+			problem.Write(c, http.StatusBadRequest, "limit must be a positive integer", "")
+			return
+		}
+	}
+
+	events, err := h.audit.Query(c.Request.Context(), f)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, "Failed to query audit log", "")
+		return
+	}
+	resp := gin.H{"events": events}
+	if n := len(events); n > 0 {
+		resp["nextBefore"] = events[n-1].ID // Pass as ?before= with the same filters for the next page
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func parseAuditTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
@@ -0,0 +1,390 @@
+// File: backend/internal/api/frames.go
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ewag/gen-erics/backend/internal/dicom"
+	"github.com/ewag/gen-erics/backend/internal/models"
+	"github.com/ewag/gen-erics/backend/internal/problem"
+	"github.com/ewag/gen-erics/backend/internal/tiers"
+	"github.com/gin-gonic/gin"
+)
+
+// maxFrameNumber bounds frame numbers in requests; no real object comes close.
+const maxFrameNumber = 1 << 16
+
+// metadataFanOut bounds concurrent Orthanc metadata and statistics lookups for one listing.
+const metadataFanOut = 8
+
+// frameInfo is the response of GET .../frames: the pixel description plus
+// the media type each frame is returned in.
+type frameInfo struct {
+	dicom.ImageInfo
+	MediaType string `json:"mediaType"`
+	Tier      string `json:"tier"`
+}
+
+// GetInstanceFramesHandler describes an instance's frames (count, size,
+// encoding) so viewers can plan retrieval before fetching pixel data.
+func (h *APIHandler) GetInstanceFramesHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	instanceUID := c.Param("instanceUID")
+	logAttrs := []any{"studyUID", c.Param("studyUID"), "instanceUID", instanceUID}
+
+	entry, ok := h.instanceLocation(c)
+	if !ok {
+		return
+	}
+	if entry == nil {
+		info, err := h.hotImageInfo(ctx, instanceUID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to read frame information from Orthanc", append(logAttrs, "error", err)...)
+			respondError(c, "Failed to read frame information from PACS", err)
+			return
+		}
+		c.JSON(http.StatusOK, frameInfo{ImageInfo: info, MediaType: dicom.FrameMediaType(info.TransferSyntax), Tier: "hot"})
+		return
+	}
+
+	ds, ok := h.loadTierDataset(c, entry, dicom.ParseOptions{SkipPixelData: true})
+	if !ok {
+		return
+	}
+	info, err := ds.ImageInfo()
+	if err != nil {
+		problem.Write(c, http.StatusUnprocessableEntity, "Instance has no frames", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, frameInfo{ImageInfo: info, MediaType: dicom.FrameMediaType(info.TransferSyntax), Tier: entry.Tier})
+}
+
+// GetInstanceFrameListHandler returns frames as stored (raw samples, or the
+// compressed bitstream for encapsulated transfer syntaxes). The frame list
+// is 1-based, e.g. "3", "1,3,5" or "1-60". A single frame is returned as
+// is; several are streamed in order as multipart/related, one part per frame,
+// each written as soon as it has been read so cine playback can start early.
+func (h *APIHandler) GetInstanceFrameListHandler(c *gin.Context) {
+	frames, err := parseFrameList(c.Param("frameList"))
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, "Invalid frame list", err.Error())
+		return
+	}
+
+	entry, ok := h.instanceLocation(c)
+	if !ok {
+		return
+	}
+	if entry == nil {
+		h.framesFromOrthanc(c, c.Param("instanceUID"), frames)
+		return
+	}
+	h.framesFromTier(c, entry, frames)
+}
+
+// framesFromOrthanc proxies Orthanc's /frames/{n}/raw, one request per frame.
+func (h *APIHandler) framesFromOrthanc(c *gin.Context, instanceUID string, frames []int) {
+	ctx := c.Request.Context()
+	logAttrs := []any{"instanceUID", instanceUID, "frames", len(frames)}
+
+	info, err := h.hotImageInfo(ctx, instanceUID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to read frame information from Orthanc", append(logAttrs, "error", err)...)
+		respondError(c, "Failed to read frame information from PACS", err)
+		return
+	}
+	if !framesInRange(c, frames, info.NumberOfFrames) {
+		return
+	}
+	mediaType := dicom.FrameMediaType(info.TransferSyntax)
+
+	if len(frames) == 1 {
+		etag := instanceETag(instanceUID, fmt.Sprintf("frame-%d", frames[0]+1))
+		if notModified(c, etag) {
+			return
+		}
+		raw, err := h.orthancClient.GetFrameRaw(ctx, instanceUID, frames[0])
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to fetch frame from Orthanc", append(logAttrs, "error", err)...)
+			respondError(c, "Failed to retrieve frame from PACS", err)
+			return
+		}
+		defer raw.Body.Close()
+		if err := serveStream(c, streamSource{Body: raw.Body, Size: raw.ContentLength, ContentType: mediaType, ETag: etag}); err != nil {
+			slog.WarnContext(ctx, "Streaming frame interrupted", append(logAttrs, "error", err)...)
+		}
+		return
+	}
+
+	mw := startMultipartFrames(c, mediaType)
+	for _, frame := range frames {
+		raw, err := h.orthancClient.GetFrameRaw(ctx, instanceUID, frame)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to fetch frame from Orthanc mid-stream", append(logAttrs, "frame", frame+1, "error", err)...)
+			return // Headers are sent; a truncated multipart body tells the client
+		}
+		err = writeFramePart(c, mw, mediaType, frame, raw.Body)
+		raw.Body.Close()
+		if err != nil {
+			slog.WarnContext(ctx, "Streaming frames interrupted", append(logAttrs, "frame", frame+1, "error", err)...)
+			return
+		}
+	}
+	mw.Close()
+}
+
+// framesFromTier reads frames out of a catalogued object in a single pass.
+// Like range requests, partial reads can't be checked against the whole-object
+// checksum; the scrubber covers stored objects.
+func (h *APIHandler) framesFromTier(c *gin.Context, entry *models.CatalogEntry, frames []int) {
+	ctx := c.Request.Context()
+	logAttrs := []any{"instanceID", entry.InstanceID, "tier", entry.Tier, "frames", len(frames)}
+
+	backend, ok := h.tiers.For(entry.Tier)
+	if !ok {
+		problem.Write(c, http.StatusServiceUnavailable, fmt.Sprintf("Tier backend %s is not configured", entry.Tier), "")
+		return
+	}
+	if len(frames) == 1 && notModified(c, storedETag(entry, fmt.Sprintf("frame-%d", frames[0]+1))) {
+		return
+	}
+	rc, _, err := tiers.OpenEntry(ctx, backend, entry.ObjectKey, entry.EncryptionKeyID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to open instance in tier backend", append(logAttrs, "error", err)...)
+		if errors.Is(err, tiers.ErrObjectNotFound) {
+			h.recordRetrievalFailure(c, entry, models.VerifyStatusMissing)
+			problem.Write(c, http.StatusNotFound, "Instance object missing from tier storage", "")
+			return
+		}
+		problem.Write(c, http.StatusBadGateway, "Failed to read instance from tier storage", "")
+		return
+	}
+	defer rc.Close()
+
+	fr, err := dicom.NewFrameReader(rc)
+	if err != nil {
+		switch {
+		case errors.Is(err, dicom.ErrNotDICOM), errors.Is(err, dicom.ErrNoPixelData), errors.Is(err, dicom.ErrUnsupportedTransferSyntax):
+			problem.Write(c, http.StatusUnprocessableEntity, "Instance frames cannot be read", err.Error())
+		default:
+			slog.ErrorContext(ctx, "Failed to read instance from tier backend", append(logAttrs, "error", err)...)
+			problem.Write(c, http.StatusBadGateway, "Failed to read instance from tier storage", "")
+		}
+		return
+	}
+	if !framesInRange(c, frames, fr.Info.NumberOfFrames) {
+		return
+	}
+	mediaType := dicom.FrameMediaType(fr.Info.TransferSyntax)
+
+	// frames is sorted, so one forward pass reaches each in turn
+	next := 0
+	read := func(frame int) ([]byte, error) {
+		for ; next < frame; next++ {
+			if err := fr.Skip(); err != nil {
+				return nil, err
+			}
+		}
+		next++
+		return fr.Next()
+	}
+
+	if len(frames) == 1 {
+		data, err := read(frames[0])
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to read frame from tier backend", append(logAttrs, "error", err)...)
+			problem.Write(c, http.StatusBadGateway, "Failed to read frame from tier storage", "")
+			return
+		}
+		c.Header("ETag", storedETag(entry, fmt.Sprintf("frame-%d", frames[0]+1)))
+		c.Data(http.StatusOK, mediaType, data)
+		return
+	}
+
+	mw := startMultipartFrames(c, mediaType)
+	for _, frame := range frames {
+		data, err := read(frame)
+		if err == nil {
+			err = writeFramePart(c, mw, mediaType, frame, bytes.NewReader(data))
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "Streaming frames interrupted", append(logAttrs, "frame", frame+1, "error", err)...)
+			return
+		}
+	}
+	mw.Close()
+}
+
+// instanceLocation resolves where an instance's study lives. It returns a nil
+// entry for hot studies, the catalog entry otherwise, and writes the error
+// response itself when it returns false.
+func (h *APIHandler) instanceLocation(c *gin.Context) (*models.CatalogEntry, bool) {
+	ctx := c.Request.Context()
+	studyUID, instanceUID := c.Param("studyUID"), c.Param("instanceUID")
+
+	status, found, err := h.db.GetStatus(ctx, studyUID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to check study status", "studyUID", studyUID, "instanceUID", instanceUID, "error", err)
+		problem.Write(c, http.StatusInternalServerError, "Failed to check study status", "")
+		return nil, false
+	}
+	// Studies without a status row have never left Orthanc
+	if !found || status.Tier == "hot" {
+		return nil, true
+	}
+	if studyDeleted(c, status.Tier) {
+		return nil, false
+	}
+
+	entry, inCatalog, err := h.catalog.GetCatalogEntry(ctx, instanceUID, status.Tier)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, "Failed to look up instance in catalog", "")
+		return nil, false
+	}
+	if !inCatalog {
+		problem.Write(c, http.StatusPreconditionFailed, fmt.Sprintf("Instance not available (status: %s)", status.Tier), "")
+		return nil, false
+	}
+	return entry, true
+}
+
+// hotImageInfo builds the pixel description of a hot instance from its
+// simplified tags and Orthanc's transfer syntax metadata.
+func (h *APIHandler) hotImageInfo(ctx context.Context, instanceUID string) (dicom.ImageInfo, error) {
+	var info dicom.ImageInfo
+	tags, err := h.orthancClient.GetInstanceSimplifiedTags(ctx, instanceUID)
+	if err != nil {
+		return info, err
+	}
+	metadata, err := h.orthancClient.GetInstanceMetadata(ctx, instanceUID)
+	if err != nil {
+		return info, err
+	}
+	tagInt := func(name string, def int) int {
+		s, _ := tags[name].(string)
+		if n, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+			return n
+		}
+		return def
+	}
+	info = dicom.ImageInfo{
+		Rows:                tagInt("Rows", 0),
+		Columns:             tagInt("Columns", 0),
+		NumberOfFrames:      tagInt("NumberOfFrames", 1),
+		SamplesPerPixel:     tagInt("SamplesPerPixel", 1),
+		BitsAllocated:       tagInt("BitsAllocated", 16),
+		PixelRepresentation: tagInt("PixelRepresentation", 0),
+		PlanarConfiguration: tagInt("PlanarConfiguration", 0),
+		TransferSyntax:      metadata["TransferSyntax"],
+	}
+	info.BitsStored = tagInt("BitsStored", info.BitsAllocated)
+	info.Photometric, _ = tags["PhotometricInterpretation"].(string)
+	if info.Rows == 0 || info.Columns == 0 {
+		return info, dicom.ErrNoPixelData
+	}
+	return info, nil
+}
+
+// transferSyntaxes looks up the transfer syntax of hot instances, a bounded
+// number at a time. Failed lookups are logged and left out.
+func (h *APIHandler) transferSyntaxes(ctx context.Context, instanceIDs []string) map[string]string {
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		out = make(map[string]string, len(instanceIDs))
+		sem = make(chan struct{}, metadataFanOut)
+	)
+	for _, id := range instanceIDs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			metadata, err := h.orthancClient.GetInstanceMetadata(ctx, id)
+			if err != nil {
+				slog.WarnContext(ctx, "Failed to get instance metadata", "instanceID", id, "error", err)
+				return
+			}
+			mu.Lock()
+			out[id] = metadata["TransferSyntax"]
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return out
+}
+
+// parseFrameList parses a 1-based frame list ("1", "1,3", "2-10") into
+// sorted, de-duplicated 0-based indexes.
+func parseFrameList(s string) ([]int, error) {
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(s, ",") {
+		lo, hi, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil || first < 1 || first > maxFrameNumber {
+			return nil, fmt.Errorf("frame numbers must be integers between 1 and %d", maxFrameNumber)
+		}
+		last := first
+		if isRange {
+			last, err = strconv.Atoi(hi)
+			if err != nil || last < first || last > maxFrameNumber {
+				return nil, fmt.Errorf("invalid frame range %q", part)
+			}
+		}
+		for n := first; n <= last; n++ {
+			seen[n-1] = true
+		}
+	}
+	frames := make([]int, 0, len(seen))
+	for n := range seen {
+		frames = append(frames, n)
+	}
+	sort.Ints(frames)
+	return frames, nil
+}
+
+// framesInRange writes a 400 and returns false when a requested frame is
+// beyond the instance's frame count. frames is sorted.
+func framesInRange(c *gin.Context, frames []int, count int) bool {
+	if last := frames[len(frames)-1]; last >= count {
+		problem.Write(c, http.StatusBadRequest, fmt.Sprintf("Frame %d out of range (instance has %d)", last+1, count), "")
+		return false
+	}
+	return true
+}
+
+// startMultipartFrames sends the headers of a multipart/related frame response.
+func startMultipartFrames(c *gin.Context, mediaType string) *multipart.Writer {
+	mw := multipart.NewWriter(c.Writer)
+	c.Header("Content-Type", fmt.Sprintf("multipart/related; type=%q; boundary=%s", mediaType, mw.Boundary()))
+	c.Status(http.StatusOK)
+	return mw
+}
+
+// writeFramePart writes one frame as a part and flushes it to the client.
+func writeFramePart(c *gin.Context, mw *multipart.Writer, mediaType string, frame int, body io.Reader) error {
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":     {mediaType},
+		"Content-Location": {fmt.Sprintf("frames/%d", frame+1)},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, body); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+	return nil
+}
@@ -2,17 +2,32 @@
 package api
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"time"
 	// "strings"
 	// "sync" // No longer needed here if using setStudyStatus helper
 
 	"github.com/gin-gonic/gin"
 	// Ensure correct import path for your project structure
+	"github.com/ewag/gen-erics/backend/internal/anonymize"
+	"github.com/ewag/gen-erics/backend/internal/audit"
+	"github.com/ewag/gen-erics/backend/internal/auth"
+	"github.com/ewag/gen-erics/backend/internal/integrity"
+	"github.com/ewag/gen-erics/backend/internal/jobs"
+	"github.com/ewag/gen-erics/backend/internal/maintenance"
+	"github.com/ewag/gen-erics/backend/internal/mover"
 	"github.com/ewag/gen-erics/backend/internal/orthanc"
 	models "github.com/ewag/gen-erics/backend/internal/models"
+	"github.com/ewag/gen-erics/backend/internal/prefetch"
+	"github.com/ewag/gen-erics/backend/internal/problem"
+	"github.com/ewag/gen-erics/backend/internal/retention"
 	"github.com/ewag/gen-erics/backend/internal/storage"
+	"github.com/ewag/gen-erics/backend/internal/thumbnail"
+	"github.com/ewag/gen-erics/backend/internal/tiers"
 )
 
 // Constants used by handlers
@@ -25,15 +40,60 @@ const (
 type APIHandler struct {
 	orthancClient 	*orthanc.Client
 	db				storage.StatusStore
-	// Add other dependencies like DB later
+	catalog			storage.CatalogStore
+	tiers			*tiers.Registry
+	mover			*mover.Mover
+	integrity		*integrity.Metrics
+	anonymizer		*anonymize.Service
+	authz			*auth.Authorizer
+	apiKeys			*auth.APIKeys
+	audit			*audit.Recorder
+	thumbnails		*thumbnail.Service
+	transcodeSyntaxes	map[string]bool // Transfer syntaxes Orthanc may transcode downloads to
+	retention		*retention.Service
+	trash			*retention.TrashBin
+	jobs			*jobs.Queue
+	prefetcher		*prefetch.Prefetcher
+	hl7Log			storage.HL7Store
+	maintenance		*maintenance.Schedule
+	bandwidth		*maintenance.Bandwidth
+	batches			*jobs.Batcher
 }
 
 // NewAPIHandler creates a new handler instance
 // DEFINED ONLY HERE
-func NewAPIHandler(orthancClient *orthanc.Client, db storage.StatusStore) *APIHandler {
+func NewAPIHandler(orthancClient *orthanc.Client, db storage.StatusStore, catalog storage.CatalogStore,
+	tierRegistry *tiers.Registry, studyMover *mover.Mover, integrityMetrics *integrity.Metrics,
+	anonymizer *anonymize.Service, authz *auth.Authorizer, apiKeys *auth.APIKeys,
+	auditRecorder *audit.Recorder, thumbnails *thumbnail.Service, transcodeSyntaxes []string,
+	retentionService *retention.Service, trashBin *retention.TrashBin, jobQueue *jobs.Queue,
+	prefetcher *prefetch.Prefetcher, hl7Log storage.HL7Store, schedule *maintenance.Schedule,
+	bandwidth *maintenance.Bandwidth, batcher *jobs.Batcher) *APIHandler {
+	syntaxes := make(map[string]bool, len(transcodeSyntaxes))
+	for _, ts := range transcodeSyntaxes {
+		syntaxes[ts] = true
+	}
 	return &APIHandler{
 		orthancClient: 	orthancClient,
 		db:				db,
+		catalog:		catalog,
+		tiers:			tierRegistry,
+		mover:			studyMover,
+		integrity:		integrityMetrics,
+		anonymizer:		anonymizer,
+		authz:			authz,
+		apiKeys:		apiKeys,
+		audit:			auditRecorder,
+		thumbnails:		thumbnails,
+		transcodeSyntaxes:	syntaxes,
+		retention:		retentionService,
+		trash:			trashBin,
+		jobs:			jobQueue,
+		prefetcher:		prefetcher,
+		hl7Log:			hl7Log,
+		maintenance:	schedule,
+		bandwidth:		bandwidth,
+		batches:		batcher,
 	}
 }
 
@@ -42,20 +102,91 @@ func NewAPIHandler(orthancClient *orthanc.Client, db storage.StatusStore) *APIHa
 type MoveRequest struct {
 	TargetTier     string `json:"targetTier" binding:"required"`
 	TargetLocation string `json:"targetLocation,omitempty"`
+
+	// Either queues the move as a job instead of running it now
+	ScheduledAt   *time.Time `json:"scheduledAt,omitempty"`   // Not before this time
+	DeferToWindow bool       `json:"deferToWindow,omitempty"` // Only inside a maintenance window of its edge and tiers
+	Priority      string     `json:"priority,omitempty" binding:"omitempty,oneof=stat routine background"` // Of the queued job; routine if empty
 }
 
-// MoveStudyHandler updates status in the database
+// MoveStudyHandler moves the study's data to the target tier (verifying checksums
+// on the way) and then updates status in the database
 func (h *APIHandler) MoveStudyHandler(c *gin.Context) {
     ctx := c.Request.Context()
     studyUID := c.Param("studyUID")
-    if studyUID == "" { /* ... handle error ... */ return }
+    if studyUID == "" {
+        problem.Write(c, http.StatusBadRequest, "Missing study UID", "")
+        return
+    }
 
     var req MoveRequest
-    if err := c.ShouldBindJSON(&req); err != nil { /* ... handle error ... */ return }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        problem.Write(c, http.StatusBadRequest, "Invalid move request", err.Error())
+        return
+    }
 
     logAttrs := []any{"studyUID", studyUID, "targetTier", req.TargetTier, "targetLocation", req.TargetLocation}
     slog.InfoContext(ctx, "Received move study request", logAttrs...)
 
+    if _, ok := h.tiers.For(req.TargetTier); !ok && req.TargetTier != mover.TierHot {
+        problem.Write(c, http.StatusBadRequest, fmt.Sprintf("Unknown or unconfigured tier: %s", req.TargetTier), "")
+        return
+    }
+
+    // The route admits recall-only callers; anything other than a recall needs the full move permission
+    if req.TargetTier != mover.TierHot && !h.authz.Can(c, auth.PermStudiesMove) {
+        auth.Forbid(c, auth.PermStudiesMove)
+        return
+    }
+
+    // Studies without a status row have never left Orthanc
+    currentStatus := models.LocationStatus{Tier: mover.TierHot}
+    current, found, err := h.db.GetStatus(ctx, studyUID)
+    if err != nil {
+        problem.Write(c, http.StatusInternalServerError, "Failed to check study status", "")
+        return
+    }
+    if found {
+        currentStatus = *current
+    }
+    currentTier := currentStatus.Tier
+    switch {
+    case currentTier == retention.TierDeleted:
+        problem.Write(c, http.StatusGone, "Study has been deleted", "")
+        return
+    case currentTier == retention.TierTrash:
+        problem.Write(c, http.StatusConflict, "Study is in the trash; restore it before moving it", "")
+        return
+    case req.TargetTier == retention.TierTrash:
+        problem.Write(c, http.StatusBadRequest, "Use DELETE on the study to move it to the trash", "")
+        return
+    }
+
+    if req.ScheduledAt != nil || req.DeferToWindow {
+        h.scheduleMove(c, studyUID, req)
+        return
+    }
+
+    edgeID := jobs.TransferEdge(currentStatus, req.TargetTier, req.TargetLocation)
+    result, err := h.mover.TransitionOnEdge(ctx, studyUID, currentTier, req.TargetTier, edgeID)
+    if err != nil {
+        slog.ErrorContext(ctx, "Tier transition failed", append(logAttrs, "currentTier", currentTier, "error", err)...)
+        statusCode := http.StatusBadGateway
+        if errors.Is(err, integrity.ErrChecksumMismatch) {
+            statusCode = http.StatusConflict // Source copy is corrupt; needs operator attention
+        }
+        if errors.Is(err, retention.ErrLegalHold) {
+            statusCode = http.StatusConflict
+        }
+        problem.Write(c, statusCode, "Failed to move study data", clientDetail(statusCode, err))
+        return
+    }
+    logAttrs = append(logAttrs, "instancesMoved", result.Instances, "bytesMoved", result.Bytes)
+    if result.StudyID != "" {
+        studyUID = result.StudyID // Recalled under the patient it was merged into
+        logAttrs = append(logAttrs, "newStudyID", studyUID)
+    }
+
     // Calculate new status struct (using models.LocationStatus)
     newStatus := models.LocationStatus{Tier: req.TargetTier}
     if req.TargetTier == "hot" && req.TargetLocation != "" {
@@ -72,19 +203,21 @@ func (h *APIHandler) MoveStudyHandler(c *gin.Context) {
     }
 
     // Set status in DB via storage layer
-    err := h.db.SetStatus(ctx, studyUID, newStatus)
+    err = h.db.SetStatus(ctx, studyUID, newStatus)
     if err != nil {
         // Error already logged in storage layer
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update study status"})
+        problem.Write(c, http.StatusInternalServerError, "Failed to update study status", "")
         return
     }
 
     logAttrs = append(logAttrs, "newStatus", newStatus)
     slog.InfoContext(ctx, "Updated status for study in DB", logAttrs...)
+    h.thumbnails.InvalidateStudy(studyUID)
 
     c.JSON(http.StatusAccepted, gin.H{
-        "message":      "Move request received and status updated.", // Updated message
+        "message":      "Study moved and status updated.",
         "currentStatus": newStatus,
+        "result":        result,
     })
 }
 
@@ -95,7 +228,7 @@ func (h *APIHandler) GetInstancePreviewHandler(c *gin.Context) {
     instanceUID := c.Param("instanceUID")
     
     if studyUID == "" || instanceUID == "" { 
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Missing study or instance UID"})
+        problem.Write(c, http.StatusBadRequest, "Missing study or instance UID", "")
         return 
     }
 
@@ -105,7 +238,7 @@ func (h *APIHandler) GetInstancePreviewHandler(c *gin.Context) {
     
     if err != nil {
         slog.ErrorContext(ctx, "Failed to check study status", append(logAttrs, "error", err)...)
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check study status"})
+        problem.Write(c, http.StatusInternalServerError, "Failed to check study status", "")
         return
     }
     
@@ -133,10 +266,11 @@ func (h *APIHandler) GetInstancePreviewHandler(c *gin.Context) {
     if !found {
         logAttrs = append(logAttrs, "status", "unknown (not in DB)")
         slog.InfoContext(ctx, "Instance preview requested but study status unknown", logAttrs...)
-        c.JSON(http.StatusPreconditionFailed, gin.H{
-            "error": "Study status unknown and could not be set",
-            "details": "The study may need to be moved to hot tier first",
-        })
+        problem.Write(c, http.StatusPreconditionFailed, "Study status unknown and could not be set", "The study may need to be moved to hot tier first")
+        return
+    }
+
+    if studyDeleted(c, status.Tier) {
         return
     }
 
@@ -144,104 +278,190 @@ func (h *APIHandler) GetInstancePreviewHandler(c *gin.Context) {
     if status.Tier != "hot" {
         logAttrs = append(logAttrs, "tier", status.Tier)
         slog.InfoContext(ctx, "Instance preview requested but study not 'hot'", logAttrs...)
-        c.JSON(http.StatusPreconditionFailed, gin.H{
-            "error": fmt.Sprintf("Preview not available (Study status: %s)", status.Tier),
-            "status": status,
-            "details": "Move study to hot tier to enable preview",
-        })
+        problem.WriteExtra(c, http.StatusPreconditionFailed, fmt.Sprintf("Preview not available (Study status: %s)", status.Tier), "Move study to hot tier to enable preview", gin.H{"studyStatus": status})
+        return
+    }
+
+    // Previews of an instance never change, so revalidation needs no PACS round-trip
+    etag := instanceETag(instanceUID, "preview")
+    if notModified(c, etag) {
         return
     }
 
     // If hot, proceed with fetching the preview...
     slog.InfoContext(ctx, "Fetching instance preview from Orthanc", logAttrs...)
-    imageData, contentType, err := h.orthancClient.GetInstancePreview(instanceUID)
+    preview, err := h.orthancClient.GetInstancePreview(ctx, instanceUID)
     
     if err != nil {
         slog.ErrorContext(ctx, "Failed to get preview from Orthanc", append(logAttrs, "error", err)...)
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "error": "Failed to retrieve preview from PACS",
-            "details": err.Error(),
-        })
+        respondError(c, "Failed to retrieve preview from PACS", err)
         return
     }
-    
-    c.Header("Content-Type", contentType)
-    c.Data(http.StatusOK, contentType, imageData)
+    defer preview.Body.Close()
+
+    if err := serveStream(c, streamSource{
+        Body:         preview.Body,
+        Size:         preview.ContentLength,
+        ContentType:  preview.ContentType,
+        ETag:         etag,
+        LastModified: preview.LastModified,
+    }); err != nil {
+        slog.WarnContext(ctx, "Streaming instance preview interrupted", append(logAttrs, "error", err)...)
+    }
 }
 
-// GetInstanceSimplifiedTagsHandler - needs modification to use DB status check
+// GetInstanceSimplifiedTagsHandler returns an instance's tags as Orthanc's
+// simplified JSON; the study must be hot.
 func (h *APIHandler) GetInstanceSimplifiedTagsHandler(c *gin.Context) {
     ctx := c.Request.Context()
     studyUID := c.Param("studyUID")
     instanceUID := c.Param("instanceUID")
-    if studyUID == "" || instanceUID == "" { /* ... handle error ... */ return }
+    if studyUID == "" || instanceUID == "" {
+        problem.Write(c, http.StatusBadRequest, "Missing study or instance UID", "")
+        return
+    }
 
     // Check status from DB
     status, found, err := h.db.GetStatus(ctx, studyUID)
     logAttrs := []any{"studyUID", studyUID, "instanceUID", instanceUID}
-     if err != nil { /* ... handle internal error ... */ return }
-     if !found { /* ... handle not found / return default ... */
-        c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Study status unknown"})
+    if err != nil {
+        slog.ErrorContext(ctx, "Failed to check study status", append(logAttrs, "error", err)...)
+        problem.Write(c, http.StatusInternalServerError, "Failed to check study status", "")
+        return
+    }
+    if !found {
+        problem.Write(c, http.StatusPreconditionFailed, "Study status unknown", "")
         return
     }
 
     logAttrs = append(logAttrs, "status", status)
     slog.DebugContext(ctx, "Checking tags status from DB", logAttrs...)
 
+    if studyDeleted(c, status.Tier) {
+        return
+    }
+
     // Only proceed if 'hot'
-    if status.Tier != "hot" { /* ... return error/message ... */ return }
+    if status.Tier != "hot" {
+        slog.InfoContext(ctx, "Instance tags requested but study not 'hot'", logAttrs...)
+        problem.WriteExtra(c, http.StatusPreconditionFailed, fmt.Sprintf("Tags not available (Study status: %s)", status.Tier),
+            "Move study to hot tier to read its tags", gin.H{"studyStatus": status})
+        return
+    }
 
     // If hot, proceed...
     slog.InfoContext(ctx, "Fetching instance tags from Orthanc", logAttrs...)
-    tags, err := h.orthancClient.GetInstanceSimplifiedTags(instanceUID)
-    // ... (rest of existing tags handler error handling and response) ...
-     if err != nil { /* ... handle Orthanc errors ... */ return }
+    tags, err := h.orthancClient.GetInstanceSimplifiedTags(ctx, instanceUID)
+    if err != nil {
+        slog.ErrorContext(ctx, "Failed to get instance tags from Orthanc", append(logAttrs, "error", err)...)
+        respondError(c, "Failed to retrieve instance tags from PACS", err)
+        return
+    }
     c.JSON(http.StatusOK, tags)
 }
 
-// GetInstanceFileHandler - needs modification to use DB status check
+// GetInstanceFileHandler streams an instance's DICOM file: from Orthanc for
+// hot studies, from the tier backend for catalogued offloaded ones.
 func (h *APIHandler) GetInstanceFileHandler(c *gin.Context) {
     ctx := c.Request.Context()
     studyUID := c.Param("studyUID")
     instanceUID := c.Param("instanceUID")
-    if studyUID == "" || instanceUID == "" { /* ... handle error ... */ return }
+    if studyUID == "" || instanceUID == "" {
+        problem.Write(c, http.StatusBadRequest, "Missing study or instance UID", "")
+        return
+    }
 
     // Check status from DB
     status, found, err := h.db.GetStatus(ctx, studyUID)
     logAttrs := []any{"studyUID", studyUID, "instanceUID", instanceUID}
-     if err != nil { /* ... handle internal error ... */ return }
-     if !found { /* ... handle not found / return default ... */
-        c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Study status unknown"})
+    if err != nil {
+        slog.ErrorContext(ctx, "Failed to check study status", append(logAttrs, "error", err)...)
+        problem.Write(c, http.StatusInternalServerError, "Failed to check study status", "")
+        return
+    }
+    if !found {
+        problem.Write(c, http.StatusPreconditionFailed, "Study status unknown", "")
         return
     }
 
     logAttrs = append(logAttrs, "status", status)
     slog.DebugContext(ctx, "Checking file request status from DB", logAttrs...)
+    if studyDeleted(c, status.Tier) {
+        return
+    }
+
+    // Accept: application/dicom; transfer-syntax=... asks for a transcoded copy
+    accepted := acceptedTransferSyntaxes(c, contentTypeDICOM)
+    c.Header("Vary", "Accept")
 
-    // Only serve file if 'hot'
+    // Non-hot studies are served straight from their tier backend, if catalogued
     if status.Tier != "hot" { // Check if tier is NOT "hot"
-    slog.InfoContext(ctx, "Instance file requested but study not 'hot'", logAttrs...) // Log the reason
-    c.JSON(http.StatusPreconditionFailed, gin.H{ // Send 412 status and JSON error body
-        "error": fmt.Sprintf("Instance file not available locally (status: %s)", status.Tier),
-    })
-    return // Stop processing the request here
+        entry, inCatalog, err := h.catalog.GetCatalogEntry(ctx, instanceUID, status.Tier)
+        if err != nil {
+            problem.Write(c, http.StatusInternalServerError, "Failed to look up instance in catalog", "")
+            return
+        }
+        if !inCatalog {
+            slog.InfoContext(ctx, "Instance file requested but study not 'hot' and not catalogued", logAttrs...) // Log the reason
+            problem.Write(c, http.StatusPreconditionFailed, fmt.Sprintf("Instance file not available locally (status: %s)", status.Tier), "")
+            return // Stop processing the request here
+        }
+        transferSyntax, ok := h.tierFileSyntax(c, entry, accepted)
+        if !ok {
+            return
+        }
+        h.serveTierFile(c, entry, transferSyntax)
+        return
+    }
+    transferSyntax, ok := h.transcodeTarget(c, accepted)
+    if !ok {
+        return
+    }
+    if transferSyntax != "" {
+        h.serveTranscodedFile(c, instanceUID, transferSyntax)
+        return
     }
 
-    // If hot, proceed...
-    slog.InfoContext(ctx, "Fetching instance file from Orthanc", logAttrs...)
-    dicomData, err := h.orthancClient.GetInstanceFile(instanceUID)
-    // ... (rest of existing file handler error handling and response) ...
-    if err != nil { /* ... handle Orthanc errors ... */ return }
-    c.Header("Content-Type", contentTypeDICOM)
-    c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.dcm\"", instanceUID))
-    c.Writer.Write(dicomData) // Use Write for []byte
+    etag := instanceETag(instanceUID, "file")
+    if notModified(c, etag) {
+        return
+    }
+
+    // If hot, proceed... A range is left to Orthanc, so only its bytes are downloaded
+    byteRange := forwardableRange(c, etag)
+    slog.InfoContext(ctx, "Fetching instance file from Orthanc", append(logAttrs, "range", byteRange)...)
+    file, err := h.orthancClient.GetInstanceFileRange(ctx, instanceUID, byteRange)
+    var upstream *orthanc.UpstreamError
+    if byteRange != "" && errors.As(err, &upstream) && upstream.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+        // Fetch it whole so serveStream can answer 416 with the file's size
+        file, err = h.orthancClient.GetInstanceFile(ctx, instanceUID)
+    }
+    if err != nil {
+        slog.ErrorContext(ctx, "Failed to get instance file from Orthanc", append(logAttrs, "error", err)...)
+        respondError(c, "Failed to retrieve instance file from PACS", err)
+        return
+    }
+    defer file.Body.Close()
+
+    // Stream straight through; large multi-frame objects never sit in memory
+    if err := serveStream(c, streamSource{
+        Body:         file.Body,
+        Size:         file.ContentLength,
+        ContentType:  contentTypeDICOM,
+        ETag:         etag,
+        LastModified: file.LastModified,
+        Filename:     instanceUID + ".dcm",
+        ContentRange: file.ContentRange,
+    }); err != nil {
+        slog.WarnContext(ctx, "Streaming instance file interrupted", append(logAttrs, "error", err)...)
+    }
 }
 func (h *APIHandler) ListStudyInstancesHandler(c *gin.Context) {
     ctx := c.Request.Context()
     studyUID := c.Param("studyUID") // This is likely the Orthanc Study ID from ListStudiesHandler result
 	if studyUID == "" {
 		slog.WarnContext(ctx, "Missing studyUID parameter for listing instances")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing studyUID parameter"})
+		problem.Write(c, http.StatusBadRequest, "Missing studyUID parameter", "")
 		return
 	}
 
@@ -252,13 +472,35 @@ func (h *APIHandler) ListStudyInstancesHandler(c *gin.Context) {
 	if err != nil {
         logAttrs = append(logAttrs, "error", err)
 		slog.ErrorContext(ctx, "Failed to list instances from Orthanc", logAttrs...)
-		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to retrieve instance list from storage"})
+		respondError(c, "Failed to retrieve instance list from storage", err)
 		return
 	}
 
+    ids := make([]string, len(instances))
+    for i, inst := range instances {
+        ids[i] = inst.ID
+    }
+    syntaxes := h.transferSyntaxes(ctx, ids)
+    enriched := make([]studyInstance, len(instances))
+    for i, inst := range instances {
+        enriched[i] = studyInstance{InstanceDetails: inst, TransferSyntaxUID: syntaxes[inst.ID]}
+        enriched[i].NumberOfFrames, enriched[i].Rows, enriched[i].Columns = inst.ImageSize()
+    }
+
     logAttrs = append(logAttrs, "count", len(instances))
 	slog.InfoContext(ctx, "Successfully retrieved instance list details", logAttrs...)
-	c.JSON(http.StatusOK, instances) // Return slice of InstanceDetails
+	c.JSON(http.StatusOK, enriched)
+}
+
+// studyInstance is Orthanc's listing of an instance plus what a client
+// needs to request its frames, as the series instance listing reports it.
+type studyInstance struct {
+	orthanc.InstanceDetails
+	// Zero or empty when unknown
+	NumberOfFrames    int    `json:"numberOfFrames,omitempty"`
+	Rows              int    `json:"rows,omitempty"`
+	Columns           int    `json:"columns,omitempty"`
+	TransferSyntaxUID string `json:"transferSyntaxUid,omitempty"`
 }
 // Update this method in your api/handlers.go file
 
@@ -267,7 +509,7 @@ func (h *APIHandler) GetStudyLocationHandler(c *gin.Context) {
     ctx := c.Request.Context()
     studyUID := c.Param("studyUID")
     if studyUID == "" { 
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Missing study UID"})
+        problem.Write(c, http.StatusBadRequest, "Missing study UID", "")
         return 
     }
 
@@ -277,7 +519,7 @@ func (h *APIHandler) GetStudyLocationHandler(c *gin.Context) {
 
     if err != nil {
         slog.ErrorContext(ctx, "Failed to retrieve study status", logAttrs...)
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve study status"})
+        problem.Write(c, http.StatusInternalServerError, "Failed to retrieve study status", "")
         return
     }
 
@@ -313,6 +555,8 @@ func (h *APIHandler) GetStudyLocationHandler(c *gin.Context) {
 }
 // Update this method in your api/handlers.go file
 
+// HealthCheckHandler reports the database and, from its circuit breaker,
+// whether Orthanc is currently failing.
 func (h *APIHandler) HealthCheckHandler(c *gin.Context) {
     ctx := c.Request.Context()
     
@@ -320,17 +564,28 @@ func (h *APIHandler) HealthCheckHandler(c *gin.Context) {
     err := h.db.Ping(ctx)
     if err != nil {
         slog.ErrorContext(ctx, "Database health check failed", "error", err)
-        c.JSON(http.StatusServiceUnavailable, gin.H{
-            "status": "error",
-            "database": "unavailable",
-            "error": err.Error(),
-        })
+        problem.WriteExtra(c, http.StatusServiceUnavailable, "Database unavailable", "", gin.H{"database": "unavailable"})
         return
     }
     
+    // A tripped Orthanc circuit degrades the service but doesn't make it
+    // unhealthy: offloaded studies and the database are still served.
+    circuit := h.orthancClient.CircuitState()
+    if circuit != orthanc.CircuitClosed {
+        c.JSON(http.StatusOK, gin.H{
+            "status": "degraded",
+            "database": "connected",
+            "orthanc": "degraded",
+            "orthancCircuit": circuit,
+        })
+        return
+    }
+
     c.JSON(http.StatusOK, gin.H{
         "status": "ok",
         "database": "connected",
+        "orthanc": "ok",
+        "orthancCircuit": circuit,
     })
 }
 // ListStudiesHandler retrieves a list of studies from Orthanc
@@ -338,10 +593,10 @@ func (h *APIHandler) ListStudiesHandler(c *gin.Context) {
 	ctx := c.Request.Context() // Use request context
 	slog.InfoContext(ctx, "Handling list studies request")
 
-	studyIDs, err := h.orthancClient.ListStudies() // Assumes this returns []string
+	studyIDs, err := h.orthancClient.ListStudies(ctx) // Assumes this returns []string
 	if err != nil {
 		slog.ErrorContext(ctx, "Failed to list study IDs from Orthanc", "error", err)
-		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to retrieve study list from storage"})
+		respondError(c, "Failed to retrieve study list from storage", err)
 		return
 	}
 
@@ -361,4 +616,100 @@ func (h *APIHandler) ListStudiesHandler(c *gin.Context) {
 
 	slog.InfoContext(ctx, "Successfully retrieved study list details", "count", len(detailedStudies))
 	c.JSON(http.StatusOK, detailedStudies) // Return the slice of detailed studies
-}
\ No newline at end of file
+}
+
+// serveTierFile streams a catalogued instance from its tier backend, verifying
+// the SHA-256 as it goes. On a mismatch the connection is aborted before the
+// final bytes are sent, so the client never gets a complete-looking corrupt file.
+// Range requests can't be verified (only part of the object is read) and are
+// served as-is; the scrubber covers those objects. A non-empty transferSyntax,
+// already matched against the stored one, labels the Content-Type.
+func (h *APIHandler) serveTierFile(c *gin.Context, entry *models.CatalogEntry, transferSyntax string) {
+    ctx := c.Request.Context()
+    logAttrs := []any{"instanceID", entry.InstanceID, "studyID", entry.StudyID, "tier", entry.Tier}
+
+    etag := storedETag(entry, "file")
+    if notModified(c, etag) {
+        return
+    }
+
+    backend, ok := h.tiers.For(entry.Tier)
+    if !ok {
+        problem.Write(c, http.StatusServiceUnavailable, fmt.Sprintf("Tier backend %s is not configured", entry.Tier), "")
+        return
+    }
+
+    rc, size, err := tiers.OpenEntry(ctx, backend, entry.ObjectKey, entry.EncryptionKeyID)
+    if err != nil {
+        slog.ErrorContext(ctx, "Failed to open instance in tier backend", append(logAttrs, "error", err)...)
+        if errors.Is(err, tiers.ErrObjectNotFound) {
+            h.recordRetrievalFailure(c, entry, models.VerifyStatusMissing)
+            problem.Write(c, http.StatusNotFound, "Instance object missing from tier storage", "")
+            return
+        }
+        problem.Write(c, http.StatusBadGateway, "Failed to read instance from tier storage", "")
+        return
+    }
+    defer rc.Close()
+
+    // Only a range actually served skips verification; anything else sends the whole object
+    verify := !wantsRange(c, etag, size)
+    var body io.Reader = rc
+    if verify {
+        body = integrity.NewVerifyingReader(rc, entry.SHA256, entry.SizeBytes)
+        size = entry.SizeBytes
+    }
+
+    slog.InfoContext(ctx, "Serving instance file from tier backend", append(logAttrs, "verify", verify)...)
+    err = serveStream(c, streamSource{
+        Body:        body,
+        Size:        size,
+        ContentType: dicomContentType(transferSyntax),
+        ETag:        etag,
+        Filename:    entry.InstanceID + ".dcm",
+    })
+    if err != nil {
+        if errors.Is(err, integrity.ErrChecksumMismatch) {
+            slog.ErrorContext(ctx, "INTEGRITY ALERT: instance failed verification on retrieval", append(logAttrs, "error", err)...)
+            h.recordRetrievalFailure(c, entry, models.VerifyStatusCorrupt)
+            abortResponse(c) // Drop the connection; headers are already sent
+        }
+        slog.WarnContext(ctx, "Streaming instance file from tier backend interrupted", append(logAttrs, "error", err)...)
+        return
+    }
+    if verify && c.Request.Method != http.MethodHead {
+        h.integrity.RecordResult(ctx, entry.Tier, "retrieval", true)
+    }
+}
+
+func (h *APIHandler) recordRetrievalFailure(c *gin.Context, entry *models.CatalogEntry, verifyStatus string) {
+    ctx := c.Request.Context()
+    h.integrity.RecordResult(ctx, entry.Tier, "retrieval", false)
+    if err := h.catalog.RecordVerification(ctx, entry.InstanceID, entry.Tier, verifyStatus, time.Now()); err != nil {
+        slog.WarnContext(ctx, "Failed to record retrieval verification failure", "instanceID", entry.InstanceID, "error", err)
+    }
+}
+
+// GetStudyHistoryHandler returns the recorded status changes for a study, including who made them
+func (h *APIHandler) GetStudyHistoryHandler(c *gin.Context) {
+    ctx := c.Request.Context()
+    studyUID := c.Param("studyUID")
+
+    history, err := h.db.GetStatusHistory(ctx, studyUID)
+    if err != nil {
+        problem.Write(c, http.StatusInternalServerError, "Failed to retrieve study history", "")
+        return
+    }
+    c.JSON(http.StatusOK, history)
+}
+
+// GetCurrentUserHandler returns the authenticated principal (useful for the frontend and for debugging tokens)
+func (h *APIHandler) GetCurrentUserHandler(c *gin.Context) {
+    permissions := h.authz.Permissions(c)
+    principal, ok := auth.PrincipalFrom(c)
+    if !ok {
+        c.JSON(http.StatusOK, gin.H{"authenticated": false, "roles": h.authz.RolesFor(c), "permissions": permissions})
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"authenticated": true, "principal": principal, "permissions": permissions})
+}
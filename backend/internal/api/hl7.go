@@ -0,0 +1,35 @@
+// File: backend/internal/api/hl7.go
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	models "github.com/ewag/gen-erics/backend/internal/models"
+	"github.com/ewag/gen-erics/backend/internal/problem"
+	"github.com/ewag/gen-erics/backend/internal/storage"
+)
+
+// ListHL7MessagesHandler lists logged HL7 messages, newest first, optionally
+// filtered by ?messageType= (e.g. ADT^A08), ?status= and ?controlId=.
+func (h *APIHandler) ListHL7MessagesHandler(c *gin.Context) {
+	limit, ok := listLimit(c)
+	if !ok {
+		return
+	}
+	messages, err := h.hl7Log.ListHL7Messages(c.Request.Context(), storage.HL7MessageFilter{
+		MessageType: c.Query("messageType"),
+		Status:      c.Query("status"),
+		ControlID:   c.Query("controlId"),
+		Limit:       limit,
+	})
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, "Failed to list HL7 messages", "")
+		return
+	}
+	if messages == nil {
+		messages = []models.HL7Message{}
+	}
+	c.JSON(http.StatusOK, messages)
+}
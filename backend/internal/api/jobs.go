@@ -0,0 +1,118 @@
+// File: backend/internal/api/jobs.go
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ewag/gen-erics/backend/internal/audit"
+	"github.com/ewag/gen-erics/backend/internal/jobs"
+	models "github.com/ewag/gen-erics/backend/internal/models"
+	"github.com/ewag/gen-erics/backend/internal/problem"
+	"github.com/ewag/gen-erics/backend/internal/storage"
+)
+
+// ListJobsHandler lists background jobs with their attempts and error
+// history, newest first, optionally filtered by ?status= (e.g. dead),
+// ?studyUID=, ?source=, ?priority= and ?batchId=.
+func (h *APIHandler) ListJobsHandler(c *gin.Context) {
+	limit, ok := listLimit(c)
+	if !ok {
+		return
+	}
+	var batchID int64
+	if v := c.Query("batchId"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			problem.Write(c, http.StatusBadRequest, "Invalid batch ID", "")
+			return
+		}
+		batchID = id
+	}
+	jobList, err := h.jobs.List(c.Request.Context(), storage.JobFilter{
+		Status:   c.Query("status"),
+		StudyID:  c.Query("studyUID"),
+		Source:   c.Query("source"),
+		Priority: c.Query("priority"),
+		BatchID:  batchID,
+		Limit:    limit,
+	})
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, "Failed to list jobs", "")
+		return
+	}
+	if jobList == nil {
+		jobList = []models.Job{}
+	}
+	c.JSON(http.StatusOK, jobList)
+}
+
+// GetJobHandler returns one background job.
+func (h *APIHandler) GetJobHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("jobID"), 10, 64)
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, "Invalid job ID", "")
+		return
+	}
+	job, found, err := h.jobs.Get(c.Request.Context(), id)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, "Failed to get job", "")
+		return
+	}
+	if !found {
+		problem.Write(c, http.StatusNotFound, "Job not found", "")
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// RetryJobHandler queues a failed or dead job again with a fresh set of
+// attempts. Its error history is kept.
+func (h *APIHandler) RetryJobHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("jobID"), 10, 64)
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, "Invalid job ID", "")
+		return
+	}
+	job, found, err := h.jobs.Requeue(c.Request.Context(), id)
+	switch {
+	case errors.Is(err, storage.ErrJobNotRetryable):
+		problem.Write(c, http.StatusConflict, "Job can't be retried", err.Error())
+		return
+	case err != nil:
+		problem.Write(c, http.StatusInternalServerError, "Failed to retry job", "")
+		return
+	case !found:
+		problem.Write(c, http.StatusNotFound, "Job not found", "")
+		return
+	}
+	audit.AddObject(c, audit.StudyObject(job.StudyID, "", ""))
+	c.JSON(http.StatusAccepted, job)
+}
+
+// PrefetchPriorsHandler runs the prior-study prefetch for a study on demand,
+// e.g. for studies that arrived before prefetching was enabled. Someone is
+// usually waiting, so the recalls are stat unless ?priority= says otherwise.
+func (h *APIHandler) PrefetchPriorsHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	studyUID := c.Param("studyUID")
+	priority := c.DefaultQuery("priority", models.JobPriorityStat)
+	if !jobs.ValidPriority(priority) {
+		problem.Write(c, http.StatusBadRequest, "priority must be one of stat, routine, background", "")
+		return
+	}
+	queued, err := h.prefetcher.PrefetchForStudy(ctx, studyUID, priority)
+	if err != nil {
+		slog.ErrorContext(ctx, "Prefetch failed", "studyUID", studyUID, "error", err)
+		respondError(c, "Failed to prefetch prior studies", err)
+		return
+	}
+	if queued == nil {
+		queued = []models.Job{}
+	}
+	c.JSON(http.StatusAccepted, gin.H{"jobs": queued})
+}
@@ -0,0 +1,70 @@
+// File: backend/internal/api/maintenance.go
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ewag/gen-erics/backend/internal/jobs"
+	"github.com/ewag/gen-erics/backend/internal/maintenance"
+	"github.com/ewag/gen-erics/backend/internal/problem"
+)
+
+// maintenanceWindowStatus is a configured window and whether it is open now.
+type maintenanceWindowStatus struct {
+	maintenance.Window
+	Open      bool       `json:"open"`
+	NextStart *time.Time `json:"nextStart,omitempty"`
+}
+
+// scheduleMove queues a move requested with scheduledAt or deferToWindow
+// as a job and answers 202 with it, or 409 with the study's active job.
+func (h *APIHandler) scheduleMove(c *gin.Context, studyUID string, req MoveRequest) {
+	ctx := c.Request.Context()
+	opts := jobs.MoveOptions{Source: jobs.SourceAPI, InWindow: req.DeferToWindow, Priority: req.Priority}
+	if req.ScheduledAt != nil {
+		opts.NotBefore = *req.ScheduledAt
+	}
+	job, created, err := h.jobs.ScheduleMove(ctx, studyUID, req.TargetTier, req.TargetLocation, opts)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to schedule move", "studyUID", studyUID, "targetTier", req.TargetTier, "error", err)
+		if errors.Is(err, jobs.ErrNoWindow) {
+			problem.Write(c, http.StatusUnprocessableEntity, "Failed to schedule move", err.Error())
+			return
+		}
+		problem.Write(c, http.StatusInternalServerError, "Failed to schedule move", "")
+		return
+	}
+	if !created {
+		problem.WriteExtra(c, http.StatusConflict, "Study already has an active job", "", gin.H{"job": job})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Move scheduled.", "job": job})
+}
+
+// ListMaintenanceWindowsHandler shows the maintenance windows, which are open
+// now, and the per-edge bandwidth limits.
+func (h *APIHandler) ListMaintenanceWindowsHandler(c *gin.Context) {
+	now := time.Now()
+	loc := h.maintenance.Location()
+	windows := []maintenanceWindowStatus{}
+	for _, w := range h.maintenance.Windows() {
+		status := maintenanceWindowStatus{
+			Window: w,
+			Open:   w.Contains(now, loc),
+		}
+		if next := w.NextStart(now, loc); !next.IsZero() {
+			status.NextStart = &next
+		}
+		windows = append(windows, status)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"timezone":        loc.String(),
+		"windows":         windows,
+		"bandwidthLimits": h.bandwidth.Limits(),
+	})
+}
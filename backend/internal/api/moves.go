@@ -0,0 +1,160 @@
+// File: backend/internal/api/moves.go
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ewag/gen-erics/backend/internal/audit"
+	"github.com/ewag/gen-erics/backend/internal/auth"
+	"github.com/ewag/gen-erics/backend/internal/jobs"
+	models "github.com/ewag/gen-erics/backend/internal/models"
+	"github.com/ewag/gen-erics/backend/internal/mover"
+	"github.com/ewag/gen-erics/backend/internal/problem"
+	"github.com/ewag/gen-erics/backend/internal/retention"
+	"github.com/ewag/gen-erics/backend/internal/storage"
+)
+
+// BulkMoveRequest defines the expected JSON body for bulk moves. Studies are
+// selected either by studyUids or by filter.
+type BulkMoveRequest struct {
+	StudyUIDs      []string            `json:"studyUids,omitempty"`
+	Filter         *models.StudyFilter `json:"filter,omitempty"`
+	TargetTier     string              `json:"targetTier" binding:"required"`
+	TargetLocation string              `json:"targetLocation,omitempty"`
+	Reason         string              `json:"reason,omitempty"`
+	ScheduledAt    *time.Time          `json:"scheduledAt,omitempty"`                                                // Jobs not started before this time
+	DeferToWindow  bool                `json:"deferToWindow,omitempty"`                                              // Jobs only started inside maintenance windows
+	Priority       string              `json:"priority,omitempty" binding:"omitempty,oneof=stat routine background"` // Background if empty
+	DryRun         bool                `json:"dryRun,omitempty"`                                                     // Only list the selected studies
+}
+
+// CreateMoveBatchHandler moves many studies to one tier: it queues a job per
+// selected study under a batch and answers 202 with the batch, whose
+// progress GetMoveBatchHandler reports. With dryRun it only lists the
+// studies the selection matches.
+func (h *APIHandler) CreateMoveBatchHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req BulkMoveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Write(c, http.StatusBadRequest, "Invalid bulk move request", err.Error())
+		return
+	}
+	if _, ok := h.tiers.For(req.TargetTier); !ok && req.TargetTier != mover.TierHot {
+		problem.Write(c, http.StatusBadRequest, fmt.Sprintf("Unknown or unconfigured tier: %s", req.TargetTier), "")
+		return
+	}
+	if req.TargetTier == retention.TierTrash {
+		problem.Write(c, http.StatusBadRequest, "Use DELETE on a study to move it to the trash", "")
+		return
+	}
+	// The route admits recall-only callers; anything other than a recall needs the full move permission
+	if req.TargetTier != mover.TierHot && !h.authz.Can(c, auth.PermStudiesMove) {
+		auth.Forbid(c, auth.PermStudiesMove)
+		return
+	}
+
+	batchReq := jobs.BatchRequest{
+		StudyIDs:       req.StudyUIDs,
+		Filter:         req.Filter,
+		TargetTier:     req.TargetTier,
+		TargetLocation: req.TargetLocation,
+		Reason:         req.Reason,
+		InWindow:       req.DeferToWindow,
+		Priority:       req.Priority,
+	}
+	if req.ScheduledAt != nil {
+		batchReq.NotBefore = *req.ScheduledAt
+	}
+
+	if req.DryRun {
+		selected, err := h.batches.Select(ctx, batchReq)
+		if err != nil {
+			h.batchFailed(c, "Failed to select studies", err)
+			return
+		}
+		if selected == nil {
+			selected = []jobs.Selection{}
+		}
+		c.JSON(http.StatusOK, gin.H{"count": len(selected), "studies": selected})
+		return
+	}
+
+	batch, err := h.batches.Create(ctx, batchReq)
+	if err != nil {
+		h.batchFailed(c, "Failed to create bulk move", err)
+		return
+	}
+	h.auditBatchStudies(c, batch)
+	c.JSON(http.StatusAccepted, batch)
+}
+
+// ListMoveBatchesHandler lists bulk moves with their progress, newest first (?limit=, default 100).
+func (h *APIHandler) ListMoveBatchesHandler(c *gin.Context) {
+	limit, ok := listLimit(c)
+	if !ok {
+		return
+	}
+	batches, err := h.batches.List(c.Request.Context(), limit)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, "Failed to list bulk moves", "")
+		return
+	}
+	c.JSON(http.StatusOK, batches)
+}
+
+// GetMoveBatchHandler returns a bulk move with its aggregated progress, the
+// studies it skipped and its failed jobs. The jobs themselves are listed by
+// GET /jobs?batchId=.
+func (h *APIHandler) GetMoveBatchHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("batchID"), 10, 64)
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, "Invalid batch ID", "")
+		return
+	}
+	batch, found, err := h.batches.Get(c.Request.Context(), id)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, "Failed to get bulk move", "")
+		return
+	}
+	if !found {
+		problem.Write(c, http.StatusNotFound, "Bulk move not found", "")
+		return
+	}
+	c.JSON(http.StatusOK, batch)
+}
+
+// auditBatchStudies records every study a bulk move queued a job for.
+func (h *APIHandler) auditBatchStudies(c *gin.Context, batch *models.MoveBatch) {
+	if batch.Progress.Total == 0 {
+		return
+	}
+	queued, err := h.jobs.List(c.Request.Context(), storage.JobFilter{BatchID: batch.ID, Limit: batch.Progress.Total})
+	if err != nil {
+		slog.WarnContext(c.Request.Context(), "Failed to list jobs of bulk move for audit", "batchID", batch.ID, "error", err)
+		return
+	}
+	for _, j := range queued {
+		audit.AddObject(c, audit.StudyObject(j.StudyID, "", ""))
+	}
+}
+
+// batchFailed maps bulk move errors to a response.
+func (h *APIHandler) batchFailed(c *gin.Context, message string, err error) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, jobs.ErrInvalidSelection):
+		statusCode = http.StatusBadRequest
+	case errors.Is(err, jobs.ErrBatchTooLarge):
+		statusCode = http.StatusUnprocessableEntity
+	default:
+		slog.ErrorContext(c.Request.Context(), message, "error", err)
+	}
+	problem.Write(c, statusCode, message, clientDetail(statusCode, err))
+}
@@ -0,0 +1,54 @@
+// File: backend/internal/api/problems.go
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ewag/gen-erics/backend/internal/orthanc"
+	"github.com/ewag/gen-erics/backend/internal/problem"
+	"github.com/ewag/gen-erics/backend/internal/tiers"
+)
+
+// respondError answers a request that failed with err in the status err
+// calls for: 404 for what Orthanc or a tier backend doesn't hold, 504 when
+// Orthanc timed out, 503 while it is unavailable, 502 for any other error
+// response from it and 500 for the rest. title says what failed; callers
+// log the error themselves, with what they know about the request. Only 4xx
+// answers carry err as the detail (see clientDetail).
+func respondError(c *gin.Context, title string, err error) {
+	ctx := c.Request.Context()
+	if errors.Is(err, context.Canceled) && ctx.Err() != nil {
+		// The client went away; there is nobody left to answer
+		slog.DebugContext(ctx, "Request cancelled by client", "path", c.FullPath(), "error", err)
+		c.Abort()
+		return
+	}
+
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, orthanc.ErrNotFound), errors.Is(err, tiers.ErrObjectNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	case errors.Is(err, orthanc.ErrUnavailable):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, orthanc.ErrUpstream):
+		status = http.StatusBadGateway
+	}
+	problem.Write(c, status, title, clientDetail(status, err))
+}
+
+// clientDetail is the problem detail for err answered with status: its text
+// for client errors, nothing for server errors, whose text says more about
+// our internals, the database or Orthanc than a client should see.
+func clientDetail(status int, err error) string {
+	if status >= http.StatusInternalServerError {
+		return ""
+	}
+	return err.Error()
+}
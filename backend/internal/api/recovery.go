@@ -0,0 +1,47 @@
+// File: backend/internal/api/recovery.go
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ewag/gen-erics/backend/internal/problem"
+)
+
+// Recovery turns handler panics into 500 problem responses. Unlike
+// gin.Recovery it lets http.ErrAbortHandler through to net/http, which then
+// drops the connection: a response whose headers are already sent can only
+// be cut short that way, and a streamed body that ends cleanly looks
+// complete to the client. A panic after the headers are sent is aborted the
+// same way.
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			if r == http.ErrAbortHandler {
+				panic(r)
+			}
+			slog.ErrorContext(c.Request.Context(), "Handler panicked", "path", c.Request.URL.Path, "panic", r, "stack", string(debug.Stack()))
+			if c.Writer.Written() {
+				panic(http.ErrAbortHandler)
+			}
+			problem.Write(c, http.StatusInternalServerError, "Internal server error", "")
+		}()
+		c.Next()
+	}
+}
+
+// abortResponse drops the connection of a response whose headers (and
+// possibly part of whose body) are already sent, so the client sees it
+// truncated rather than complete. It never returns; the router must use
+// Recovery, not gin.Recovery, for the abort to reach net/http.
+func abortResponse(c *gin.Context) {
+	c.Abort()
+	panic(http.ErrAbortHandler)
+}
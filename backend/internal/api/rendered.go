@@ -0,0 +1,414 @@
+// File: backend/internal/api/rendered.go
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ewag/gen-erics/backend/internal/dicom"
+	"github.com/ewag/gen-erics/backend/internal/integrity"
+	"github.com/ewag/gen-erics/backend/internal/jobs"
+	"github.com/ewag/gen-erics/backend/internal/models"
+	"github.com/ewag/gen-erics/backend/internal/mover"
+	"github.com/ewag/gen-erics/backend/internal/orthanc"
+	"github.com/ewag/gen-erics/backend/internal/problem"
+	"github.com/ewag/gen-erics/backend/internal/render"
+	"github.com/ewag/gen-erics/backend/internal/tiers"
+	"github.com/gin-gonic/gin"
+)
+
+// maxRenderDimension caps the requested viewport so a single request can't
+// allocate an arbitrarily large image.
+const maxRenderDimension = 4096
+
+// recallRetryAfterSeconds is the Retry-After given while a study is recalled
+// so it can be rendered.
+const recallRetryAfterSeconds = 30
+
+// GetInstanceRenderedHandler renders one frame of an instance as JPEG, PNG or
+// WebP. Query parameters:
+//
+//	frame          1-based frame number (default 1)
+//	window, level  explicit window width and center, in modality units
+//	preset         a named CT window (e.g. "lung") or "voi:N" for the object's N-th stored window
+//	quality        JPEG quality 1-100
+//	width, height  viewport; the image is scaled to fit, keeping its aspect ratio
+//	format         jpeg, png or webp; otherwise taken from Accept (default jpeg)
+//
+// It also serves .../frames/{n}/rendered, where the path names the frame.
+// Hot instances are rendered by Orthanc; catalogued instances on other tiers
+// are decoded and rendered here, unless they are stored in a syntax only
+// Orthanc decodes, in which case their study is recalled and 503 returned.
+func (h *APIHandler) GetInstanceRenderedHandler(c *gin.Context) {
+	instanceUID := c.Param("instanceUID")
+
+	params, err := parseRenderParams(c)
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, "Invalid rendering parameters", err.Error())
+		return
+	}
+
+	entry, ok := h.instanceLocation(c)
+	if !ok {
+		return
+	}
+	if entry == nil {
+		h.renderFromOrthanc(c, instanceUID, params)
+		return
+	}
+	h.renderFromTier(c, entry, params)
+}
+
+// renderFromOrthanc proxies Orthanc's /frames/{n}/rendered. Orthanc only
+// produces JPEG and PNG, so WebP is transcoded from a PNG rendering.
+func (h *APIHandler) renderFromOrthanc(c *gin.Context, instanceUID string, params render.Params) {
+	ctx := c.Request.Context()
+	logAttrs := []any{"instanceUID", instanceUID, "frame", params.Frame + 1}
+
+	etag := instanceETag(instanceUID, "rendered-orthanc-"+renderVariant(params))
+	if notModified(c, etag) {
+		return
+	}
+
+	opts := orthanc.RenderOptions{
+		Width:   params.Width,
+		Height:  params.Height,
+		Quality: params.Quality,
+		Accept:  params.Format.ContentType(),
+	}
+	if params.Format == render.FormatWebP {
+		opts.Accept = render.FormatPNG.ContentType()
+	}
+	window, err := h.orthancWindow(ctx, instanceUID, params)
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, "Invalid rendering parameters", err.Error())
+		return
+	}
+	if window != nil {
+		opts.WindowCenter, opts.WindowWidth = &window.Center, &window.Width
+	}
+
+	rendered, err := h.orthancClient.GetRenderedFrame(ctx, instanceUID, params.Frame, opts)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to render frame in Orthanc", append(logAttrs, "error", err)...)
+		respondError(c, "Failed to render image in PACS", err)
+		return
+	}
+	defer rendered.Body.Close()
+
+	if params.Format != render.FormatWebP {
+		if err := serveStream(c, streamSource{
+			Body:        rendered.Body,
+			Size:        rendered.ContentLength,
+			ContentType: rendered.ContentType,
+			ETag:        etag,
+		}); err != nil {
+			slog.WarnContext(ctx, "Streaming rendered frame interrupted", append(logAttrs, "error", err)...)
+		}
+		return
+	}
+
+	img, err := png.Decode(rendered.Body)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to decode Orthanc rendering", append(logAttrs, "error", err)...)
+		problem.Write(c, http.StatusBadGateway, "PACS returned an unreadable image", "")
+		return
+	}
+	var buf bytes.Buffer
+	if err := render.Encode(&buf, img, render.FormatWebP, params.Quality); err != nil {
+		slog.ErrorContext(ctx, "Failed to encode WebP", append(logAttrs, "error", err)...)
+		problem.Write(c, http.StatusInternalServerError, "Failed to encode image", "")
+		return
+	}
+	serveRendered(c, buf.Bytes(), render.FormatWebP, etag)
+}
+
+// orthancWindow resolves the window to ask Orthanc for. nil leaves Orthanc's
+// default (the object's first stored window) in place.
+func (h *APIHandler) orthancWindow(ctx context.Context, instanceUID string, params render.Params) (*render.Window, error) {
+	if params.Window != nil || params.Preset == "" {
+		return params.Window, nil
+	}
+	if w, ok := render.PresetWindow(params.Preset); ok {
+		return &w, nil
+	}
+	n, err := voiIndex(params.Preset)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := h.orthancClient.GetInstanceSimplifiedTags(ctx, instanceUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stored windows: %w", err)
+	}
+	centers, _ := tags["WindowCenter"].(string)
+	widths, _ := tags["WindowWidth"].(string)
+	cs, ws := strings.Split(centers, `\`), strings.Split(widths, `\`)
+	if centers == "" || n > len(cs) || n > len(ws) {
+		return nil, fmt.Errorf("%w: object does not define window %d", render.ErrUnknownPreset, n)
+	}
+	center, err1 := strconv.ParseFloat(strings.TrimSpace(cs[n-1]), 64)
+	width, err2 := strconv.ParseFloat(strings.TrimSpace(ws[n-1]), 64)
+	if err1 != nil || err2 != nil {
+		return nil, fmt.Errorf("%w: stored window %d is not numeric", render.ErrUnknownPreset, n)
+	}
+	return &render.Window{Center: center, Width: width}, nil
+}
+
+// renderFromTier decodes a catalogued instance from its tier backend and
+// renders it with the internal renderer. The object is verified against its
+// catalogued checksum while it is read.
+func (h *APIHandler) renderFromTier(c *gin.Context, entry *models.CatalogEntry, params render.Params) {
+	ctx := c.Request.Context()
+	logAttrs := []any{"instanceID", entry.InstanceID, "tier", entry.Tier, "frame", params.Frame + 1}
+
+	etag := instanceETag(entry.InstanceID, "rendered-internal-"+renderVariant(params))
+	if notModified(c, etag) {
+		return
+	}
+	if !dicom.CanDecode(entry.TransferSyntaxUID) {
+		h.recallToRender(c, entry.StudyID, fmt.Sprintf("instance %s is stored as %s", entry.InstanceID, entry.TransferSyntaxUID))
+		return
+	}
+
+	ds, ok := h.loadTierDataset(c, entry, dicom.ParseOptions{})
+	if !ok {
+		return
+	}
+	if !dicom.CanDecode(ds.TransferSyntax) {
+		h.recallToRender(c, entry.StudyID, fmt.Sprintf("instance %s is stored as %s", entry.InstanceID, ds.TransferSyntax))
+		return
+	}
+	if frames := ds.NumberOfFrames(); params.Frame >= frames {
+		problem.Write(c, http.StatusBadRequest, fmt.Sprintf("Frame %d out of range (instance has %d)", params.Frame+1, frames), "")
+		return
+	}
+
+	img, err := render.Render(ds, params)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to render instance", append(logAttrs, "error", err)...)
+		switch {
+		case errors.Is(err, render.ErrUnknownPreset):
+			problem.Write(c, http.StatusBadRequest, "Invalid rendering parameters", err.Error())
+		case errors.Is(err, dicom.ErrNoPixelData), errors.Is(err, dicom.ErrUnsupportedTransferSyntax):
+			problem.Write(c, http.StatusUnprocessableEntity, "Instance cannot be rendered", err.Error())
+		default:
+			problem.Write(c, http.StatusInternalServerError, "Failed to render instance", "")
+		}
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := render.Encode(&buf, img, params.Format, params.Quality); err != nil {
+		slog.ErrorContext(ctx, "Failed to encode rendered image", append(logAttrs, "error", err)...)
+		problem.Write(c, http.StatusInternalServerError, "Failed to encode image", "")
+		return
+	}
+	slog.InfoContext(ctx, "Rendered instance from tier backend", logAttrs...)
+	serveRendered(c, buf.Bytes(), params.Format, etag)
+}
+
+// recallToRender answers for a tier object the internal renderer can't
+// decode, such as JPEG-LS or JPEG 2000 compressed ones: it queues a stat
+// recall of the study so Orthanc, which decodes them, renders it once back,
+// and asks the client to retry.
+func (h *APIHandler) recallToRender(c *gin.Context, studyID, reason string) {
+	ctx := c.Request.Context()
+	job, created, err := h.jobs.ScheduleMove(ctx, studyID, mover.TierHot, "", jobs.MoveOptions{
+		Source:   jobs.SourceAPI,
+		Reason:   "Rendering needs Orthanc: " + reason,
+		Priority: models.JobPriorityStat,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to queue recall for rendering", "studyID", studyID, "error", err)
+		respondError(c, "Failed to queue recall for rendering", err)
+		return
+	}
+	if created {
+		slog.InfoContext(ctx, "Queued recall to render study stored in an undecodable transfer syntax", "studyID", studyID, "jobID", job.ID, "reason", reason)
+	}
+	c.Header("Retry-After", strconv.Itoa(recallRetryAfterSeconds))
+	problem.Write(c, http.StatusServiceUnavailable, "Study is being recalled for rendering",
+		fmt.Sprintf("Job %d recalls the study to Orthanc; %s", job.ID, reason))
+}
+
+// loadTierDataset reads and parses a catalogued instance from its tier
+// backend, writing the error response itself when it returns false.
+func (h *APIHandler) loadTierDataset(c *gin.Context, entry *models.CatalogEntry, opts dicom.ParseOptions) (*dicom.Dataset, bool) {
+	ctx := c.Request.Context()
+	logAttrs := []any{"instanceID", entry.InstanceID, "studyID", entry.StudyID, "tier", entry.Tier}
+
+	backend, ok := h.tiers.For(entry.Tier)
+	if !ok {
+		problem.Write(c, http.StatusServiceUnavailable, fmt.Sprintf("Tier backend %s is not configured", entry.Tier), "")
+		return nil, false
+	}
+	rc, _, err := tiers.OpenEntry(ctx, backend, entry.ObjectKey, entry.EncryptionKeyID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to open instance in tier backend", append(logAttrs, "error", err)...)
+		if errors.Is(err, tiers.ErrObjectNotFound) {
+			h.recordRetrievalFailure(c, entry, models.VerifyStatusMissing)
+			problem.Write(c, http.StatusNotFound, "Instance object missing from tier storage", "")
+			return nil, false
+		}
+		problem.Write(c, http.StatusBadGateway, "Failed to read instance from tier storage", "")
+		return nil, false
+	}
+	defer rc.Close()
+
+	// A header-only parse stops early, so the checksum can't be verified then
+	var body io.Reader = rc
+	if !opts.SkipPixelData {
+		body = integrity.NewVerifyingReader(rc, entry.SHA256, entry.SizeBytes)
+	}
+	ds, err := dicom.Parse(body, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, integrity.ErrChecksumMismatch):
+			slog.ErrorContext(ctx, "INTEGRITY ALERT: instance failed verification on retrieval", append(logAttrs, "error", err)...)
+			h.recordRetrievalFailure(c, entry, models.VerifyStatusCorrupt)
+			problem.Write(c, http.StatusConflict, "Instance failed integrity verification", err.Error())
+		case errors.Is(err, dicom.ErrNotDICOM), errors.Is(err, dicom.ErrUnsupportedTransferSyntax):
+			problem.Write(c, http.StatusUnprocessableEntity, "Instance cannot be decoded", err.Error())
+		default:
+			slog.ErrorContext(ctx, "Failed to parse instance from tier backend", append(logAttrs, "error", err)...)
+			problem.Write(c, http.StatusBadGateway, "Failed to read instance from tier storage", "")
+		}
+		return nil, false
+	}
+	if !opts.SkipPixelData && c.Request.Method != http.MethodHead {
+		h.integrity.RecordResult(ctx, entry.Tier, "retrieval", true)
+	}
+	return ds, true
+}
+
+func serveRendered(c *gin.Context, data []byte, format render.Format, etag string) {
+	if err := serveStream(c, streamSource{
+		Body:        bytes.NewReader(data),
+		Size:        int64(len(data)),
+		ContentType: format.ContentType(),
+		ETag:        etag,
+	}); err != nil {
+		slog.WarnContext(c.Request.Context(), "Writing rendered image interrupted", "error", err)
+	}
+}
+
+// parseRenderParams reads the rendering query parameters. Frame is converted
+// to the 0-based index render and Orthanc use.
+func parseRenderParams(c *gin.Context) (render.Params, error) {
+	p := render.Params{Format: render.FormatJPEG}
+
+	frame, err := intQuery(c, "frame", 1, 1, maxFrameNumber)
+	if err != nil {
+		return p, err
+	}
+	if list := c.Param("frameList"); list != "" {
+		frames, err := parseFrameList(list)
+		if err != nil {
+			return p, err
+		}
+		if len(frames) != 1 {
+			return p, fmt.Errorf("only one frame can be rendered at a time")
+		}
+		frame = frames[0] + 1
+	}
+	p.Frame = frame - 1
+	if p.Quality, err = intQuery(c, "quality", 0, 1, 100); err != nil {
+		return p, err
+	}
+	if p.Width, err = intQuery(c, "width", 0, 1, maxRenderDimension); err != nil {
+		return p, err
+	}
+	if p.Height, err = intQuery(c, "height", 0, 1, maxRenderDimension); err != nil {
+		return p, err
+	}
+
+	window, level := c.Query("window"), c.Query("level")
+	switch {
+	case window != "" && level != "":
+		width, err := strconv.ParseFloat(window, 64)
+		if err != nil || width < 1 {
+			return p, fmt.Errorf("window must be a number >= 1")
+		}
+		center, err := strconv.ParseFloat(level, 64)
+		if err != nil {
+			return p, fmt.Errorf("level must be a number")
+		}
+		p.Window = &render.Window{Center: center, Width: width}
+	case window != "" || level != "":
+		return p, fmt.Errorf("window and level must be given together")
+	}
+
+	if p.Preset = strings.ToLower(c.Query("preset")); p.Preset != "" && p.Window == nil {
+		if _, ok := render.PresetWindow(p.Preset); !ok {
+			if _, err := voiIndex(p.Preset); err != nil {
+				return p, err
+			}
+		}
+	}
+
+	if f := c.Query("format"); f != "" {
+		format, ok := render.ParseFormat(f)
+		if !ok {
+			return p, fmt.Errorf("unsupported format %q (use jpeg, png or webp)", f)
+		}
+		p.Format = format
+	} else if format, ok := acceptedFormat(c.GetHeader("Accept")); ok {
+		p.Format = format
+	}
+	return p, nil
+}
+
+// intQuery parses an optional integer query parameter within [lo, hi].
+func intQuery(c *gin.Context, name string, def, lo, hi int) (int, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < lo || n > hi {
+		return 0, fmt.Errorf("%s must be an integer between %d and %d", name, lo, hi)
+	}
+	return n, nil
+}
+
+// voiIndex parses a "voi:N" preset.
+func voiIndex(preset string) (int, error) {
+	idx, ok := strings.CutPrefix(preset, "voi:")
+	n, err := strconv.Atoi(idx)
+	if !ok || err != nil || n < 1 {
+		return 0, fmt.Errorf("%w: %s", render.ErrUnknownPreset, preset)
+	}
+	return n, nil
+}
+
+// acceptedFormat returns the first image format listed in an Accept header.
+func acceptedFormat(accept string) (render.Format, bool) {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, _ := strings.Cut(part, ";")
+		mediaType = strings.TrimSpace(mediaType)
+		if !strings.HasPrefix(mediaType, "image/") {
+			continue
+		}
+		if f, ok := render.ParseFormat(mediaType); ok {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+// renderVariant encodes the parameters that affect the rendered bytes, for the ETag.
+func renderVariant(p render.Params) string {
+	window := p.Preset
+	if p.Window != nil {
+		window = strconv.FormatFloat(p.Window.Center, 'f', -1, 64) + "_" + strconv.FormatFloat(p.Window.Width, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%d-%s-%dx%d-q%d.%s", p.Frame+1, window, p.Width, p.Height, p.Quality, p.Format)
+}
@@ -0,0 +1,361 @@
+// File: backend/internal/api/resources.go
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ewag/gen-erics/backend/internal/audit"
+	models "github.com/ewag/gen-erics/backend/internal/models"
+	"github.com/ewag/gen-erics/backend/internal/mover"
+	"github.com/ewag/gen-erics/backend/internal/orthanc"
+	"github.com/ewag/gen-erics/backend/internal/problem"
+)
+
+// ListPatientsHandler lists patients in Orthanc together with patients whose
+// studies have all been moved out of the hot tier.
+func (h *APIHandler) ListPatientsHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	patients, err := h.orthancClient.ListPatients(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list patients from Orthanc", "error", err)
+		respondError(c, "Failed to retrieve patient list from storage", err)
+		return
+	}
+	offloaded, err := h.catalog.ListOffloadedStudies(ctx, "")
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, "Failed to read study catalog", "")
+		return
+	}
+
+	byID := make(map[string]*models.PatientSummary, len(patients))
+	order := make([]string, 0, len(patients))
+	for _, p := range patients {
+		summary := &models.PatientSummary{
+			ID:               p.ID,
+			PatientID:        p.MainTags.PatientID,
+			PatientName:      p.MainTags.PatientName,
+			PatientBirthDate: p.MainTags.PatientBirthDate,
+			PatientSex:       p.MainTags.PatientSex,
+			StudyCount:       len(p.Studies),
+			Tiers:            []string{},
+		}
+		if len(p.Studies) > 0 {
+			summary.Tiers = append(summary.Tiers, mover.TierHot)
+		}
+		byID[p.ID] = summary
+		order = append(order, p.ID)
+	}
+
+	skipped := 0
+	for _, st := range offloaded {
+		if st.PatientResourceID == "" {
+			skipped++ // Catalogued before patient IDs were recorded
+			continue
+		}
+		summary, ok := byID[st.PatientResourceID]
+		if !ok {
+			summary = &models.PatientSummary{
+				ID:               st.PatientResourceID,
+				PatientID:        st.PatientID,
+				PatientName:      st.PatientName,
+				PatientBirthDate: st.PatientBirthDate,
+				PatientSex:       st.PatientSex,
+				Tiers:            []string{},
+			}
+			byID[st.PatientResourceID] = summary
+			order = append(order, st.PatientResourceID)
+		}
+		summary.StudyCount++
+		if !slices.Contains(summary.Tiers, st.Tier) {
+			summary.Tiers = append(summary.Tiers, st.Tier)
+		}
+	}
+	if skipped > 0 {
+		slog.DebugContext(ctx, "Offloaded studies without a patient ID left out of patient list", "count", skipped)
+	}
+
+	result := make([]models.PatientSummary, 0, len(order))
+	for _, id := range order {
+		result = append(result, *byID[id])
+	}
+	slog.InfoContext(ctx, "Successfully retrieved patient list", "count", len(result))
+	c.JSON(http.StatusOK, result)
+}
+
+// ListPatientStudiesHandler lists a patient's studies in every tier.
+func (h *APIHandler) ListPatientStudiesHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	patientID := c.Param("patientID")
+	logAttrs := []any{"orthancPatientID", patientID}
+
+	offloaded, err := h.catalog.ListOffloadedStudies(ctx, patientID)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, "Failed to read study catalog", "")
+		return
+	}
+
+	studies := []models.StudySummary{}
+	patient, err := h.orthancClient.GetPatientDetails(ctx, patientID)
+	if err != nil {
+		// A patient whose studies are all offloaded no longer exists in Orthanc
+		if len(offloaded) == 0 {
+			slog.ErrorContext(ctx, "Failed to get patient from Orthanc", append(logAttrs, "error", err)...)
+			respondError(c, "Failed to retrieve patient from storage", err)
+			return
+		}
+		audit.AddObject(c, audit.PatientObject(offloaded[0].PatientID, offloaded[0].PatientName))
+	} else {
+		audit.AddObject(c, audit.PatientObject(patient.MainTags.PatientID, patient.MainTags.PatientName))
+		hot, err := h.orthancClient.GetPatientStudies(ctx, patientID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to list patient studies from Orthanc", append(logAttrs, "error", err)...)
+			respondError(c, "Failed to retrieve study list from storage", err)
+			return
+		}
+		ids := make([]string, len(hot))
+		for i, st := range hot {
+			ids[i] = st.ID
+		}
+		stats := h.statistics(ctx, "studies", ids)
+		for _, st := range hot {
+			summary := models.StudySummary{
+				ID:               st.ID,
+				StudyInstanceUID: st.MainTags.StudyInstanceUID,
+				StudyDate:        st.MainTags.StudyDate,
+				StudyDescription: st.MainTags.StudyDescription,
+				AccessionNumber:  st.MainTags.AccessionNumber,
+				SeriesCount:      len(st.Series),
+				Tier:             mover.TierHot,
+			}
+			if s, ok := stats[st.ID]; ok {
+				summary.InstanceCount = s.CountInstances
+				summary.TotalSizeBytes = s.DiskSize
+			}
+			studies = append(studies, summary)
+		}
+	}
+
+	for _, st := range offloaded {
+		studies = append(studies, models.StudySummary{
+			ID:               st.StudyID,
+			StudyInstanceUID: st.StudyInstanceUID,
+			StudyDate:        st.StudyDate,
+			StudyDescription: st.StudyDescription,
+			AccessionNumber:  st.AccessionNumber,
+			SeriesCount:      st.SeriesCount,
+			InstanceCount:    st.InstanceCount,
+			TotalSizeBytes:   st.TotalSizeBytes,
+			Tier:             st.Tier,
+		})
+	}
+	// Newest first; DICOM DA dates sort lexically
+	sort.SliceStable(studies, func(i, j int) bool { return studies[i].StudyDate > studies[j].StudyDate })
+
+	slog.InfoContext(ctx, "Successfully retrieved patient studies", append(logAttrs, "count", len(studies))...)
+	c.JSON(http.StatusOK, studies)
+}
+
+// ListStudySeriesHandler lists a study's series with instance counts and sizes,
+// from Orthanc for hot studies and from the catalog otherwise.
+func (h *APIHandler) ListStudySeriesHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	studyUID := c.Param("studyUID")
+	logAttrs := []any{"studyUID", studyUID}
+
+	tier := mover.TierHot
+	status, found, err := h.db.GetStatus(ctx, studyUID)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, "Failed to check study status", "")
+		return
+	}
+	if found {
+		tier = status.Tier
+	}
+	if studyDeleted(c, tier) {
+		return
+	}
+
+	series := []models.SeriesSummary{}
+	if tier == mover.TierHot {
+		hot, err := h.orthancClient.GetStudySeries(ctx, studyUID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to list series from Orthanc", append(logAttrs, "error", err)...)
+			respondError(c, "Failed to retrieve series list from storage", err)
+			return
+		}
+		ids := make([]string, len(hot))
+		for i, s := range hot {
+			ids[i] = s.ID
+		}
+		stats := h.statistics(ctx, "series", ids)
+		for _, s := range hot {
+			summary := models.SeriesSummary{
+				ID:                s.ID,
+				StudyID:           studyUID,
+				SeriesInstanceUID: s.MainTags.SeriesInstanceUID,
+				SeriesNumber:      s.MainTags.SeriesNumber,
+				SeriesDescription: s.MainTags.SeriesDescription,
+				Modality:          s.MainTags.Modality,
+				BodyPart:          s.MainTags.BodyPartExamined,
+				InstanceCount:     len(s.Instances),
+				Tier:              mover.TierHot,
+			}
+			if st, ok := stats[s.ID]; ok {
+				summary.TotalSizeBytes = st.DiskSize
+			}
+			series = append(series, summary)
+		}
+	} else {
+		entries, err := h.catalog.ListCatalogEntries(ctx, studyUID, tier)
+		if err != nil {
+			problem.Write(c, http.StatusInternalServerError, "Failed to read study catalog", "")
+			return
+		}
+		series = seriesFromCatalog(studyUID, entries)
+	}
+	sort.SliceStable(series, func(i, j int) bool {
+		return zeroPad(series[i].SeriesNumber, 10) < zeroPad(series[j].SeriesNumber, 10)
+	})
+
+	slog.InfoContext(ctx, "Successfully retrieved study series", append(logAttrs, "tier", tier, "count", len(series))...)
+	c.JSON(http.StatusOK, series)
+}
+
+// ListSeriesInstancesHandler lists a series' instances. Catalogued series are
+// served from the catalog; anything else is looked up in Orthanc.
+func (h *APIHandler) ListSeriesInstancesHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	seriesUID := c.Param("seriesUID")
+	logAttrs := []any{"seriesUID", seriesUID}
+
+	entries, err := h.catalog.ListCatalogEntriesBySeries(ctx, seriesUID)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, "Failed to read instance catalog", "")
+		return
+	}
+
+	instances := []models.InstanceSummary{}
+	if len(entries) > 0 {
+		audit.AddObject(c, audit.StudyObject(entries[0].StudyID, seriesUID, ""))
+		tier := entries[0].Tier // A study lives in one non-hot tier at a time
+		if studyDeleted(c, tier) {
+			return
+		}
+		for _, e := range entries {
+			if e.Tier != tier {
+				continue
+			}
+			instances = append(instances, models.InstanceSummary{
+				ID:                e.InstanceID,
+				SeriesID:          seriesUID,
+				SOPInstanceUID:    e.SOPInstanceUID,
+				InstanceNumber:    e.InstanceNumber,
+				SizeBytes:         e.SizeBytes,
+				Tier:              e.Tier,
+				NumberOfFrames:    e.NumberOfFrames,
+				Rows:              e.Rows,
+				Columns:           e.Columns,
+				TransferSyntaxUID: e.TransferSyntaxUID,
+			})
+		}
+	} else {
+		hot, err := h.orthancClient.GetSeriesInstances(ctx, seriesUID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to list series instances from Orthanc", append(logAttrs, "error", err)...)
+			respondError(c, "Failed to retrieve instance list from storage", err)
+			return
+		}
+		ids := make([]string, len(hot))
+		for i, inst := range hot {
+			ids[i] = inst.ID
+		}
+		syntaxes := h.transferSyntaxes(ctx, ids)
+		for _, inst := range hot {
+			summary := models.InstanceSummary{
+				ID:                inst.ID,
+				SeriesID:          seriesUID,
+				SOPInstanceUID:    inst.MainTags.SOPInstanceUID,
+				InstanceNumber:    inst.MainTags.InstanceNumber,
+				SizeBytes:         inst.FileSize,
+				Tier:              mover.TierHot,
+				TransferSyntaxUID: syntaxes[inst.ID],
+			}
+			summary.NumberOfFrames, summary.Rows, summary.Columns = inst.ImageSize()
+			instances = append(instances, summary)
+		}
+	}
+	sort.SliceStable(instances, func(i, j int) bool {
+		return zeroPad(instances[i].InstanceNumber, 10) < zeroPad(instances[j].InstanceNumber, 10)
+	})
+
+	slog.InfoContext(ctx, "Successfully retrieved series instances", append(logAttrs, "count", len(instances))...)
+	c.JSON(http.StatusOK, instances)
+}
+
+// statistics fetches Orthanc's statistics for resources of one level
+// ("studies" or "series"), metadataFanOut at a time. Resources whose
+// statistics can't be read are logged and left out; they only cost a
+// listing its sizes.
+func (h *APIHandler) statistics(ctx context.Context, level string, ids []string) map[string]*orthanc.Statistics {
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		out = make(map[string]*orthanc.Statistics, len(ids))
+		sem = make(chan struct{}, metadataFanOut)
+	)
+	for _, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			stats, err := h.orthancClient.GetStatistics(ctx, level, id)
+			if err != nil {
+				slog.WarnContext(ctx, "Failed to get statistics", "level", level, "orthancID", id, "error", err)
+				return
+			}
+			mu.Lock()
+			out[id] = stats
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return out
+}
+
+// seriesFromCatalog aggregates catalog entries into per-series summaries.
+func seriesFromCatalog(studyID string, entries []models.CatalogEntry) []models.SeriesSummary {
+	byID := make(map[string]*models.SeriesSummary)
+	var order []string
+	for _, e := range entries {
+		s, ok := byID[e.SeriesID]
+		if !ok {
+			s = &models.SeriesSummary{
+				ID:                e.SeriesID,
+				StudyID:           studyID,
+				SeriesNumber:      e.SeriesNumber,
+				SeriesDescription: e.SeriesDescription,
+				Modality:          e.Modality,
+				BodyPart:          e.BodyPart,
+				Tier:              e.Tier,
+			}
+			byID[e.SeriesID] = s
+			order = append(order, e.SeriesID)
+		}
+		s.InstanceCount++
+		s.TotalSizeBytes += e.SizeBytes
+	}
+	series := make([]models.SeriesSummary, 0, len(order))
+	for _, id := range order {
+		series = append(series, *byID[id])
+	}
+	return series
+}
@@ -0,0 +1,263 @@
+// File: backend/internal/api/retention.go
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ewag/gen-erics/backend/internal/audit"
+	"github.com/ewag/gen-erics/backend/internal/integrity"
+	models "github.com/ewag/gen-erics/backend/internal/models"
+	"github.com/ewag/gen-erics/backend/internal/problem"
+	"github.com/ewag/gen-erics/backend/internal/retention"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// studyDeleted answers 410 for a study in the trash and reports whether it
+// did. Trashed studies are deleted as far as readers are concerned; only
+// restoring them makes their images readable again.
+func studyDeleted(c *gin.Context, tier string) bool {
+	if tier != retention.TierTrash {
+		return false
+	}
+	problem.Write(c, http.StatusGone, "Study has been deleted", "Restore the study from the trash to read it")
+	return true
+}
+
+// LegalHoldRequest defines the expected JSON body for placing a legal hold
+type LegalHoldRequest struct {
+	Scope    string `json:"scope" binding:"required,oneof=patient study"`
+	TargetID string `json:"targetId" binding:"required"` // Orthanc patient or study ID
+	Reason   string `json:"reason" binding:"required"`
+}
+
+// DeleteStudyRequest defines the optional JSON body for deleting a study
+type DeleteStudyRequest struct {
+	Reason    string `json:"reason"`
+	Permanent bool   `json:"permanent"` // Skip the trash; needs a reason
+}
+
+// PlaceLegalHoldHandler puts a patient or a study under legal hold.
+func (h *APIHandler) PlaceLegalHoldHandler(c *gin.Context) {
+	var req LegalHoldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Write(c, http.StatusBadRequest, "Invalid legal hold request", err.Error())
+		return
+	}
+	if req.Scope == models.HoldScopeStudy {
+		audit.AddObject(c, audit.StudyObject(req.TargetID, "", ""))
+	}
+	hold, err := h.retention.PlaceHold(c.Request.Context(), req.Scope, req.TargetID, req.Reason)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, "Failed to place legal hold", "")
+		return
+	}
+	c.JSON(http.StatusCreated, hold)
+}
+
+// ListLegalHoldsHandler lists legal holds, active ones only unless ?active=false.
+func (h *APIHandler) ListLegalHoldsHandler(c *gin.Context) {
+	activeOnly := c.DefaultQuery("active", "true") != "false"
+	holds, err := h.retention.ListHolds(c.Request.Context(), activeOnly)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, "Failed to list legal holds", "")
+		return
+	}
+	c.JSON(http.StatusOK, holds)
+}
+
+// ReleaseLegalHoldHandler ends a legal hold. The hold stays on record.
+func (h *APIHandler) ReleaseLegalHoldHandler(c *gin.Context) {
+	holdID, err := strconv.ParseInt(c.Param("holdID"), 10, 64)
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, "holdID must be a number", "")
+		return
+	}
+	released, err := h.retention.ReleaseHold(c.Request.Context(), holdID)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, "Failed to release legal hold", "")
+		return
+	}
+	if !released {
+		problem.Write(c, http.StatusNotFound, fmt.Sprintf("No active legal hold with id %d", holdID), "")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteStudyHandler moves a study to the trash tier, from where it can be
+// restored until the grace period ends. With "permanent" it deletes the study
+// from Orthanc and every tier backend at once and returns its tombstone.
+// Studies under legal hold are refused either way.
+func (h *APIHandler) DeleteStudyHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	studyUID := c.Param("studyUID")
+	logAttrs := []any{"studyUID", studyUID}
+
+	var req DeleteStudyRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			problem.Write(c, http.StatusBadRequest, "Invalid delete request", err.Error())
+			return
+		}
+	}
+
+	if req.Permanent {
+		if req.Reason == "" {
+			problem.Write(c, http.StatusBadRequest, "A reason is required for permanent deletion", "")
+			return
+		}
+		tombstone, err := h.retention.DeleteStudy(ctx, studyUID, req.Reason)
+		if err != nil {
+			h.deletionFailed(c, "Failed to delete study", err, logAttrs)
+			return
+		}
+		audit.AddObject(c, audit.PatientObject(tombstone.PatientID, ""))
+		h.thumbnails.InvalidateStudy(studyUID)
+		c.JSON(http.StatusOK, tombstone)
+		return
+	}
+
+	entry, result, err := h.trash.Trash(ctx, studyUID, req.Reason)
+	if err != nil {
+		h.deletionFailed(c, "Failed to move study to trash", err, logAttrs)
+		return
+	}
+	h.thumbnails.InvalidateStudy(studyUID)
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Study moved to trash.",
+		"trash":   entry,
+		"result":  result,
+	})
+}
+
+// RestoreStudyHandler moves a trashed study back to where it was deleted from.
+func (h *APIHandler) RestoreStudyHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	studyUID := c.Param("studyUID")
+
+	status, result, err := h.trash.Restore(ctx, studyUID)
+	if err != nil {
+		h.deletionFailed(c, "Failed to restore study", err, []any{"studyUID", studyUID})
+		return
+	}
+	h.thumbnails.InvalidateStudy(studyUID)
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Study restored from trash.",
+		"currentStatus": status,
+		"result":        result,
+	})
+}
+
+// ListTrashHandler lists trashed studies, those purged soonest first (?limit=, default 100).
+func (h *APIHandler) ListTrashHandler(c *gin.Context) {
+	limit, ok := listLimit(c)
+	if !ok {
+		return
+	}
+	entries, err := h.trash.List(c.Request.Context(), limit)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, "Failed to list trash", "")
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// deletionFailed maps trash and deletion errors to a response.
+func (h *APIHandler) deletionFailed(c *gin.Context, message string, err error, logAttrs []any) {
+	statusCode := http.StatusBadGateway
+	switch {
+	case errors.Is(err, retention.ErrLegalHold), errors.Is(err, retention.ErrInTrash):
+		statusCode = http.StatusConflict
+	case errors.Is(err, retention.ErrStudyNotFound), errors.Is(err, retention.ErrNotInTrash):
+		statusCode = http.StatusNotFound
+	case errors.Is(err, integrity.ErrChecksumMismatch):
+		statusCode = http.StatusConflict // Source copy is corrupt; needs operator attention
+	default:
+		slog.ErrorContext(c.Request.Context(), message, append(logAttrs, "error", err)...)
+	}
+	problem.Write(c, statusCode, message, clientDetail(statusCode, err))
+}
+
+// GetStudyRetentionHandler reports when a study expires under the retention
+// policy, the holds keeping it, its trash entry if it is in the trash, and
+// its tombstone if it was deleted.
+func (h *APIHandler) GetStudyRetentionHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	studyUID := c.Param("studyUID")
+
+	tombstone, deleted, err := h.retention.Tombstone(ctx, studyUID)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, "Failed to read study tombstone", "")
+		return
+	}
+	if deleted {
+		c.JSON(http.StatusOK, gin.H{"deleted": true, "tombstone": tombstone})
+		return
+	}
+
+	holds, err := h.retention.HoldsForStudy(ctx, studyUID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to check legal holds", "studyID", studyUID, "error", err)
+		problem.Write(c, http.StatusBadGateway, "Failed to check legal holds", "")
+		return
+	}
+	resp := gin.H{"deleted": false, "legalHolds": holds, "retentionYears": h.retention.Policy().Years}
+	if entry, trashed, err := h.trash.Entry(ctx, studyUID); err != nil {
+		problem.Write(c, http.StatusInternalServerError, "Failed to read trash entry", "")
+		return
+	} else if trashed {
+		resp["trash"] = entry
+	}
+	expires, ok, err := h.retention.Expiry(ctx, studyUID)
+	switch {
+	case errors.Is(err, retention.ErrStudyNotFound):
+		problem.Write(c, http.StatusNotFound, "Study not found", "")
+		return
+	case err != nil:
+		respondError(c, "Failed to read study", err)
+		return
+	case ok:
+		resp["expiresAt"] = expires.Format(time.DateOnly)
+		resp["expired"] = !time.Now().Before(expires)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// ListDeletedStudiesHandler lists tombstones, most recent first (?limit=, default 100).
+func (h *APIHandler) ListDeletedStudiesHandler(c *gin.Context) {
+	limit, ok := listLimit(c)
+	if !ok {
+		return
+	}
+	tombstones, err := h.retention.ListTombstones(c.Request.Context(), limit)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, "Failed to list deleted studies", "")
+		return
+	}
+	c.JSON(http.StatusOK, tombstones)
+}
+
+// listLimit reads ?limit=, writing a 400 and returning false if it is invalid.
+func listLimit(c *gin.Context) (int, bool) {
+	v := c.Query("limit")
+	if v == "" {
+		return defaultListLimit, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > maxListLimit {
+		problem.Write(c, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxListLimit), "")
+		return 0, false
+	}
+	return n, true
+}
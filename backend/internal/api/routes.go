@@ -3,12 +3,22 @@ package api
 
 import (
 	"github.com/gin-gonic/gin"
+
+	"github.com/ewag/gen-erics/backend/internal/audit"
+	"github.com/ewag/gen-erics/backend/internal/auth"
 	// Ensure correct import path
 	// "github.com/ewag/gen-erics/backend/internal/orthanc"
 )
 
-// RegisterRoutes sets up the API routes
-func RegisterRoutes(router *gin.Engine, handler *APIHandler) {
+// RegisterRoutes sets up the API routes. middleware (e.g. authentication) is
+// applied to /api/v1 only, so /healthz stays reachable for probes. Every v1
+// route except /me names the permission it requires; the role -> permission
+// mapping lives in the auth.Policy. Routes touching PHI are audited; the
+// audit middleware runs before the permission check so denials are recorded.
+func RegisterRoutes(router *gin.Engine, handler *APIHandler, middleware ...gin.HandlerFunc) {
+    require := handler.authz.Require
+    audited := handler.audit.Middleware
+
     // REMOVE: handler := NewAPIHandler(orthancClient)
     // REMOVE: InitializeMockStatus() // Should be called once in main or NewAPIHandler
 
@@ -16,23 +26,93 @@ func RegisterRoutes(router *gin.Engine, handler *APIHandler) {
     router.GET("/healthz", handler.HealthCheckHandler) // Uses the passed-in handler
 
     // API v1 group
-    v1 := router.Group("/api/v1")
+    v1 := router.Group("/api/v1", middleware...)
     {
+        v1.GET("/me", handler.GetCurrentUserHandler)
+
+        // Patient Level Routes
+        patients := v1.Group("/patients", audited(audit.EventQuery, audit.ActionExecute), require(auth.PermStudiesRead))
+        {
+            patients.GET("", handler.ListPatientsHandler)
+            patients.GET("/:patientID/studies", handler.ListPatientStudiesHandler)
+        }
+
         // Study Level Routes
         studies := v1.Group("/studies")
         {
-            studies.GET("", handler.ListStudiesHandler)
-            studies.GET("/:studyUID/location", handler.GetStudyLocationHandler)
-            studies.POST("/:studyUID/move", handler.MoveStudyHandler)
+            studies.GET("", audited(audit.EventQuery, audit.ActionExecute), require(auth.PermStudiesRead), handler.ListStudiesHandler)
+            studies.GET("/:studyUID/location", audited(audit.EventQuery, audit.ActionExecute), require(auth.PermStudiesRead), handler.GetStudyLocationHandler)
+            studies.GET("/:studyUID/history", audited(audit.EventQuery, audit.ActionExecute), require(auth.PermStudiesRead), handler.GetStudyHistoryHandler)
+            studies.GET("/:studyUID/thumbnail", audited(audit.EventInstancesAccessed, audit.ActionRead), require(auth.PermImagesView), handler.GetStudyThumbnailHandler)
+            studies.GET("/:studyUID/series", audited(audit.EventQuery, audit.ActionExecute), require(auth.PermStudiesRead), handler.ListStudySeriesHandler)
+            studies.POST("/:studyUID/move", audited(audit.EventInstancesTransferred, audit.ActionUpdate), require(auth.PermStudiesMove, auth.PermStudiesRecall), handler.MoveStudyHandler)
+            studies.GET("/:studyUID/archive", audited(audit.EventExport, audit.ActionRead), require(auth.PermImagesDownload), handler.GetStudyArchiveHandler)
+            studies.GET("/:studyUID/series/:seriesUID/archive", audited(audit.EventExport, audit.ActionRead), require(auth.PermImagesDownload), handler.GetSeriesArchiveHandler)
+            studies.POST("/:studyUID/anonymize", audited(audit.EventExport, audit.ActionCreate), require(auth.PermStudiesAnonymize), handler.AnonymizeStudyHandler)
+            studies.GET("/:studyUID/retention", audited(audit.EventQuery, audit.ActionExecute), require(auth.PermStudiesRead), handler.GetStudyRetentionHandler)
+            studies.DELETE("/:studyUID", audited(audit.EventStudyDeleted, audit.ActionDelete), require(auth.PermStudiesDelete), handler.DeleteStudyHandler)
+            studies.POST("/:studyUID/prefetch", audited(audit.EventInstancesTransferred, audit.ActionUpdate), require(auth.PermStudiesRecall), handler.PrefetchPriorsHandler)
+            studies.POST("/:studyUID/restore", audited(audit.EventInstancesTransferred, audit.ActionUpdate), require(auth.PermStudiesDelete), handler.RestoreStudyHandler)
 
             // Instance Level Routes
             instances := studies.Group("/:studyUID/instances")
             {
-                instances.GET("", handler.ListStudyInstancesHandler)
-                instances.GET("/:instanceUID/preview", handler.GetInstancePreviewHandler)
-                instances.GET("/:instanceUID/simplified-tags", handler.GetInstanceSimplifiedTagsHandler)
-                instances.GET("/:instanceUID/file", handler.GetInstanceFileHandler)
+                instances.GET("", audited(audit.EventQuery, audit.ActionExecute), require(auth.PermStudiesRead), handler.ListStudyInstancesHandler)
+                instances.GET("/:instanceUID/preview", audited(audit.EventInstancesAccessed, audit.ActionRead), require(auth.PermImagesView), handler.GetInstancePreviewHandler)
+                instances.GET("/:instanceUID/rendered", audited(audit.EventInstancesAccessed, audit.ActionRead), require(auth.PermImagesView), handler.GetInstanceRenderedHandler)
+                instances.GET("/:instanceUID/frames", audited(audit.EventInstancesAccessed, audit.ActionRead), require(auth.PermImagesView), handler.GetInstanceFramesHandler)
+                instances.GET("/:instanceUID/frames/:frameList", audited(audit.EventInstancesAccessed, audit.ActionRead), require(auth.PermImagesView), handler.GetInstanceFrameListHandler)
+                instances.GET("/:instanceUID/frames/:frameList/rendered", audited(audit.EventInstancesAccessed, audit.ActionRead), require(auth.PermImagesView), handler.GetInstanceRenderedHandler)
+                instances.GET("/:instanceUID/simplified-tags", audited(audit.EventInstancesAccessed, audit.ActionRead), require(auth.PermImagesView), handler.GetInstanceSimplifiedTagsHandler)
+                instances.GET("/:instanceUID/file", audited(audit.EventInstancesAccessed, audit.ActionRead), require(auth.PermImagesDownload), handler.GetInstanceFileHandler)
             }
         }
+
+        // Series Level Routes
+        v1.GET("/series/:seriesUID/instances", audited(audit.EventQuery, audit.ActionExecute), require(auth.PermStudiesRead), handler.ListSeriesInstancesHandler)
+        v1.GET("/series/:seriesUID/thumbnail", audited(audit.EventInstancesAccessed, audit.ActionRead), require(auth.PermImagesView), handler.GetSeriesThumbnailHandler)
+
+        // Anonymization mappings (re-identification is privileged)
+        v1.GET("/anonymization/:project/mappings/:value", audited(audit.EventPatientRecord, audit.ActionRead), require(auth.PermReidentify), handler.ReidentifyHandler)
+
+        // Legal holds, trash and deleted studies
+        holds := v1.Group("/legal-holds", audited(audit.EventPatientRecord, audit.ActionUpdate), require(auth.PermLegalHold))
+        {
+            holds.POST("", handler.PlaceLegalHoldHandler)
+            holds.GET("", handler.ListLegalHoldsHandler)
+            holds.DELETE("/:holdID", handler.ReleaseLegalHoldHandler)
+        }
+        v1.GET("/trash", audited(audit.EventQuery, audit.ActionExecute), require(auth.PermStudiesDelete), handler.ListTrashHandler)
+        v1.GET("/deleted-studies", audited(audit.EventQuery, audit.ActionExecute), require(auth.PermStudiesDelete), handler.ListDeletedStudiesHandler)
+
+        // Background jobs (moves, prefetch recalls)
+        jobs := v1.Group("/jobs", require(auth.PermStudiesRead))
+        {
+            jobs.GET("", handler.ListJobsHandler)
+            jobs.GET("/:jobID", handler.GetJobHandler)
+            jobs.POST("/:jobID/retry", audited(audit.EventInstancesTransferred, audit.ActionUpdate), require(auth.PermStudiesMove), handler.RetryJobHandler)
+        }
+        // Bulk moves, one job per study
+        moves := v1.Group("/moves")
+        {
+            moves.POST("", audited(audit.EventInstancesTransferred, audit.ActionUpdate), require(auth.PermStudiesMove, auth.PermStudiesRecall), handler.CreateMoveBatchHandler)
+            moves.GET("", require(auth.PermStudiesRead), handler.ListMoveBatchesHandler)
+            moves.GET("/:batchID", require(auth.PermStudiesRead), handler.GetMoveBatchHandler)
+        }
+        v1.GET("/maintenance-windows", require(auth.PermStudiesRead), handler.ListMaintenanceWindowsHandler)
+
+        // Inbound HL7 messages (carry PHI, so reading them is audited)
+        v1.GET("/hl7/messages", audited(audit.EventQuery, audit.ActionExecute), require(auth.PermAdmin), handler.ListHL7MessagesHandler)
+
+        // Audit trail (reading it is itself audited)
+        v1.GET("/audit", audited(audit.EventAuditLogUsed, audit.ActionRead), require(auth.PermAuditRead), handler.ListAuditEventsHandler)
+
+        // Administration
+        admin := v1.Group("/admin", audited(audit.EventSecurityAlert, audit.ActionExecute), require(auth.PermAdmin))
+        {
+            admin.POST("/apikeys", handler.CreateAPIKeyHandler)
+            admin.GET("/apikeys", handler.ListAPIKeysHandler)
+            admin.DELETE("/apikeys/:keyID", handler.RevokeAPIKeyHandler)
+        }
     }
 }
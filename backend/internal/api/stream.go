@@ -0,0 +1,206 @@
+// File: backend/internal/api/stream.go
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	models "github.com/ewag/gen-erics/backend/internal/models"
+	"github.com/ewag/gen-erics/backend/internal/problem"
+)
+
+// errRangeNotSatisfiable means the requested range lies outside the content.
+var errRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// streamSource describes a body to stream to the client without buffering it.
+type streamSource struct {
+	Body         io.Reader // If it is also an io.Seeker, ranges seek instead of discarding
+	Size         int64     // -1 if unknown; ranges need a known size
+	ContentType  string
+	ETag         string // Quoted strong ETag, empty to omit
+	LastModified string // HTTP date, empty to omit
+	Filename     string // Sent as an attachment when set
+	ContentRange string // Set when Body is already the requested range, as an upstream 206 answered it; Size is then the range's length
+}
+
+// instanceETag builds a strong ETag for instance content. Orthanc IDs are derived
+// from the DICOM UIDs and an instance's content never changes, so the ID (plus a
+// variant such as "file" or "preview") identifies the representation in Orthanc.
+func instanceETag(instanceID, variant string) string {
+	return fmt.Sprintf("\"%s-%s\"", instanceID, variant)
+}
+
+// storedETag is instanceETag for content read from a tier object. The
+// object's checksum is part of it: a tier may store an instance compressed,
+// so its bytes differ from Orthanc's and the two must not share validators.
+func storedETag(entry *models.CatalogEntry, variant string) string {
+	sum := entry.SHA256
+	if len(sum) > 16 {
+		sum = sum[:16]
+	}
+	return instanceETag(entry.InstanceID, variant+"-"+sum)
+}
+
+// notModified answers a conditional request with 304 when If-None-Match matches
+// etag. Returns true if the response was written.
+func notModified(c *gin.Context, etag string) bool {
+	inm := c.GetHeader("If-None-Match")
+	if etag == "" || inm == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(inm, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			c.Header("ETag", etag)
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// wantsRange reports whether the request carries a Range header we honour
+// for content of the given size: a single range, either served as 206 or
+// refused as 416. Malformed and multi-range headers are ignored and get the
+// full content.
+func wantsRange(c *gin.Context, etag string, size int64) bool {
+	header := c.GetHeader("Range")
+	if header == "" || size < 0 {
+		return false
+	}
+	// If-Range with a stale validator means "send the whole thing"
+	if ifRange := c.GetHeader("If-Range"); ifRange != "" && ifRange != etag {
+		return false
+	}
+	_, _, err := parseByteRange(header, size)
+	return err == nil || errors.Is(err, errRangeNotSatisfiable)
+}
+
+// forwardableRange returns the request's Range header if it is one that
+// wantsRange could honour whatever the content's size, so that an upstream
+// can serve the range itself, or "" to fetch the whole content.
+func forwardableRange(c *gin.Context, etag string) string {
+	header := c.GetHeader("Range")
+	if !wantsRange(c, etag, math.MaxInt64) {
+		return ""
+	}
+	return header
+}
+
+// serveStream copies src to the client, honouring a single byte Range.
+// Multi-range requests are answered with the full content, which RFC 9110 allows.
+// The returned error is from the copy; headers have been written by then.
+func serveStream(c *gin.Context, src streamSource) error {
+	c.Header("Content-Type", src.ContentType)
+	if src.ETag != "" {
+		c.Header("ETag", src.ETag)
+	}
+	if src.LastModified != "" {
+		c.Header("Last-Modified", src.LastModified)
+	}
+	if src.Filename != "" {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", src.Filename))
+	}
+
+	start, length, status := int64(0), src.Size, http.StatusOK
+	if src.ContentRange != "" {
+		c.Header("Accept-Ranges", "bytes")
+		c.Header("Content-Range", src.ContentRange)
+		status = http.StatusPartialContent
+	} else if src.Size >= 0 {
+		c.Header("Accept-Ranges", "bytes")
+		if wantsRange(c, src.ETag, src.Size) {
+			rStart, rLength, err := parseByteRange(c.GetHeader("Range"), src.Size)
+			switch {
+			case errors.Is(err, errRangeNotSatisfiable):
+				c.Header("Content-Range", fmt.Sprintf("bytes */%d", src.Size))
+				c.Status(http.StatusRequestedRangeNotSatisfiable)
+				return nil
+			case err == nil:
+				start, length, status = rStart, rLength, http.StatusPartialContent
+				c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+length-1, src.Size))
+			}
+		}
+	}
+
+	if start > 0 {
+		if seeker, ok := src.Body.(io.Seeker); ok {
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				problem.Write(c, http.StatusInternalServerError, "Failed to seek to requested range", "")
+				return err
+			}
+		} else if _, err := io.CopyN(io.Discard, src.Body, start); err != nil {
+			problem.Write(c, http.StatusBadGateway, "Failed to skip to requested range", "")
+			return err
+		}
+	}
+
+	if length >= 0 {
+		c.Header("Content-Length", strconv.FormatInt(length, 10))
+	}
+	c.Status(status)
+	if c.Request.Method == http.MethodHead {
+		return nil
+	}
+
+	var err error
+	if length >= 0 {
+		_, err = io.CopyN(c.Writer, src.Body, length)
+	} else {
+		_, err = io.Copy(c.Writer, src.Body)
+	}
+	return err
+}
+
+// parseByteRange parses a single "bytes=" range against a known size and returns
+// the start offset and length. Supports "a-b", "a-" and "-suffix" forms.
+func parseByteRange(header string, size int64) (int64, int64, error) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, fmt.Errorf("unsupported range %q", header)
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("malformed range %q", header)
+	}
+
+	if first == "" { // Suffix range: the last N bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, fmt.Errorf("malformed range %q", header)
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, errRangeNotSatisfiable
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, n, nil
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, fmt.Errorf("malformed range %q", header)
+	}
+	if start >= size {
+		return 0, 0, errRangeNotSatisfiable
+	}
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, fmt.Errorf("malformed range %q", header)
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end - start + 1, nil
+}
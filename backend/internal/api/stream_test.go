@@ -0,0 +1,192 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseByteRange(t *testing.T) {
+	tests := []struct {
+		header      string
+		size        int64
+		wantStart   int64
+		wantLength  int64
+		wantErr     bool
+		unsatisfied bool
+	}{
+		{header: "bytes=0-99", size: 1000, wantStart: 0, wantLength: 100},
+		{header: "bytes=100-", size: 1000, wantStart: 100, wantLength: 900},
+		{header: " bytes=10-19 ", size: 1000, wantStart: 10, wantLength: 10},
+		{header: "bytes=990-2000", size: 1000, wantStart: 990, wantLength: 10}, // End clamped to the size
+		{header: "bytes=-100", size: 1000, wantStart: 900, wantLength: 100},
+		{header: "bytes=-5000", size: 1000, wantStart: 0, wantLength: 1000}, // Suffix longer than the content
+		{header: "bytes=999-999", size: 1000, wantStart: 999, wantLength: 1},
+		{header: "bytes=1000-", size: 1000, wantErr: true, unsatisfied: true},
+		{header: "bytes=-0", size: 1000, wantErr: true, unsatisfied: true},
+		{header: "bytes=0-", size: 0, wantErr: true, unsatisfied: true},
+		{header: "bytes=-10", size: 0, wantErr: true, unsatisfied: true},
+		{header: "bytes=0-1,5-9", size: 1000, wantErr: true},
+		{header: "bytes=20-10", size: 1000, wantErr: true},
+		{header: "bytes=a-b", size: 1000, wantErr: true},
+		{header: "bytes=--5", size: 1000, wantErr: true},
+		{header: "bytes=-1-", size: 1000, wantErr: true},
+		{header: "bytes=5", size: 1000, wantErr: true},
+		{header: "items=0-9", size: 1000, wantErr: true},
+		{header: "", size: 1000, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			start, length, err := parseByteRange(tt.header, tt.size)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseByteRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := errors.Is(err, errRangeNotSatisfiable); got != tt.unsatisfied {
+				t.Errorf("parseByteRange() error = %v, want not satisfiable %v", err, tt.unsatisfied)
+			}
+			if !tt.wantErr && (start != tt.wantStart || length != tt.wantLength) {
+				t.Errorf("parseByteRange() = %d, %d, want %d, %d", start, length, tt.wantStart, tt.wantLength)
+			}
+		})
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	const etag = `"i1-file"`
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		etag        string
+		want        bool
+	}{
+		{name: "no header", etag: etag},
+		{name: "match", ifNoneMatch: etag, etag: etag, want: true},
+		{name: "match in a list", ifNoneMatch: `"other", ` + etag, etag: etag, want: true},
+		{name: "weak match", ifNoneMatch: `W/` + etag, etag: etag, want: true},
+		{name: "wildcard", ifNoneMatch: "*", etag: etag, want: true},
+		{name: "mismatch", ifNoneMatch: `"i1-preview"`, etag: etag},
+		{name: "unquoted", ifNoneMatch: "i1-file", etag: etag},
+		{name: "no etag to match", ifNoneMatch: "*"},
+	}
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.ifNoneMatch != "" {
+				c.Request.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			if got := notModified(c, tt.etag); got != tt.want {
+				t.Fatalf("notModified() = %v, want %v", got, tt.want)
+			}
+			c.Writer.WriteHeaderNow()
+			if tt.want && (w.Code != http.StatusNotModified || w.Header().Get("ETag") != tt.etag) {
+				t.Errorf("response = %d with ETag %q, want 304 with %q", w.Code, w.Header().Get("ETag"), tt.etag)
+			}
+		})
+	}
+}
+
+func TestServeStream(t *testing.T) {
+	const content = "0123456789"
+	const etag = `"i1-file"`
+	tests := []struct {
+		name             string
+		rangeHeader      string
+		ifRange          string
+		size             int64
+		contentRange     string // Set as a relayed upstream 206
+		body             string // Defaults to content
+		wantStatus       int
+		wantBody         string
+		wantContentRange string
+	}{
+		{name: "whole", size: 10, wantStatus: http.StatusOK, wantBody: content},
+		{name: "range", rangeHeader: "bytes=2-4", size: 10, wantStatus: http.StatusPartialContent, wantBody: "234",
+			wantContentRange: "bytes 2-4/10"},
+		{name: "suffix", rangeHeader: "bytes=-3", size: 10, wantStatus: http.StatusPartialContent, wantBody: "789",
+			wantContentRange: "bytes 7-9/10"},
+		{name: "past the end", rangeHeader: "bytes=10-", size: 10, wantStatus: http.StatusRequestedRangeNotSatisfiable,
+			wantContentRange: "bytes */10"},
+		{name: "multi-range gets the whole", rangeHeader: "bytes=0-1,4-5", size: 10, wantStatus: http.StatusOK, wantBody: content},
+		{name: "stale If-Range gets the whole", rangeHeader: "bytes=2-4", ifRange: `"old"`, size: 10,
+			wantStatus: http.StatusOK, wantBody: content},
+		{name: "matching If-Range", rangeHeader: "bytes=2-4", ifRange: etag, size: 10,
+			wantStatus: http.StatusPartialContent, wantBody: "234", wantContentRange: "bytes 2-4/10"},
+		{name: "unknown size ignores the range", rangeHeader: "bytes=2-4", size: -1, wantStatus: http.StatusOK, wantBody: content},
+		{name: "upstream 206 relayed", rangeHeader: "bytes=2-4", size: 3, contentRange: "bytes 2-4/10", body: "234",
+			wantStatus: http.StatusPartialContent, wantBody: "234", wantContentRange: "bytes 2-4/10"},
+	}
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.rangeHeader != "" {
+				c.Request.Header.Set("Range", tt.rangeHeader)
+			}
+			if tt.ifRange != "" {
+				c.Request.Header.Set("If-Range", tt.ifRange)
+			}
+			body := tt.body
+			if body == "" {
+				body = content
+			}
+			// A plain io.Reader, so ranges skip bytes rather than seek
+			src := streamSource{Body: struct{ *strings.Reader }{strings.NewReader(body)}, Size: tt.size,
+				ContentType: "application/dicom", ETag: etag, ContentRange: tt.contentRange}
+			if err := serveStream(c, src); err != nil {
+				t.Fatalf("serveStream() error = %v", err)
+			}
+			c.Writer.WriteHeaderNow()
+
+			if w.Code != tt.wantStatus || w.Body.String() != tt.wantBody {
+				t.Errorf("response = %d %q, want %d %q", w.Code, w.Body.String(), tt.wantStatus, tt.wantBody)
+			}
+			if got := w.Header().Get("Content-Range"); got != tt.wantContentRange {
+				t.Errorf("Content-Range = %q, want %q", got, tt.wantContentRange)
+			}
+		})
+	}
+}
+
+func TestForwardableRange(t *testing.T) {
+	const etag = `"i1-file"`
+	tests := []struct {
+		name        string
+		rangeHeader string
+		ifRange     string
+		want        string
+	}{
+		{name: "none"},
+		{name: "single", rangeHeader: "bytes=0-99", want: "bytes=0-99"},
+		{name: "suffix", rangeHeader: "bytes=-100", want: "bytes=-100"},
+		{name: "empty suffix left for a 416", rangeHeader: "bytes=-0", want: "bytes=-0"},
+		{name: "multi-range", rangeHeader: "bytes=0-1,4-5"},
+		{name: "malformed", rangeHeader: "bytes=9-1"},
+		{name: "stale If-Range", rangeHeader: "bytes=0-99", ifRange: `"old"`},
+		{name: "matching If-Range", rangeHeader: "bytes=0-99", ifRange: etag, want: "bytes=0-99"},
+	}
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.rangeHeader != "" {
+				c.Request.Header.Set("Range", tt.rangeHeader)
+			}
+			if tt.ifRange != "" {
+				c.Request.Header.Set("If-Range", tt.ifRange)
+			}
+			if got := forwardableRange(c, etag); got != tt.want {
+				t.Errorf("forwardableRange() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+// File: backend/internal/api/thumbnails.go
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/ewag/gen-erics/backend/internal/problem"
+	"github.com/ewag/gen-erics/backend/internal/render"
+	"github.com/ewag/gen-erics/backend/internal/thumbnail"
+	"github.com/gin-gonic/gin"
+)
+
+// GetStudyThumbnailHandler serves a cached JPEG thumbnail of a study's
+// representative image (the middle image of its largest image series).
+func (h *APIHandler) GetStudyThumbnailHandler(c *gin.Context) {
+	h.serveThumbnail(c, thumbnail.LevelStudy, c.Param("studyUID"))
+}
+
+// GetSeriesThumbnailHandler serves a cached JPEG thumbnail of a series' middle image.
+func (h *APIHandler) GetSeriesThumbnailHandler(c *gin.Context) {
+	h.serveThumbnail(c, thumbnail.LevelSeries, c.Param("seriesUID"))
+}
+
+func (h *APIHandler) serveThumbnail(c *gin.Context, level, id string) {
+	ctx := c.Request.Context()
+	logAttrs := []any{"level", level, "id", id}
+
+	ref, err := h.thumbnails.Resolve(ctx, level, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to resolve thumbnail", append(logAttrs, "error", err)...)
+		respondError(c, "Failed to look up "+level, err)
+		return
+	}
+	if studyDeleted(c, ref.Tier) {
+		return
+	}
+
+	// Clients may reuse a thumbnail for as long as the server trusts its version
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(h.thumbnails.MaxAge().Seconds())))
+	if notModified(c, ref.ETag) {
+		return
+	}
+
+	data, err := h.thumbnails.Fetch(ctx, ref)
+	if err != nil {
+		if errors.Is(err, thumbnail.ErrNoImage) {
+			problem.Write(c, http.StatusNotFound, fmt.Sprintf("No renderable image in %s", level), "")
+			return
+		}
+		if errors.Is(err, thumbnail.ErrNeedsRecall) {
+			h.recallToRender(c, ref.StudyID, err.Error())
+			return
+		}
+		slog.ErrorContext(ctx, "Failed to generate thumbnail", append(logAttrs, "tier", ref.Tier, "error", err)...)
+		respondError(c, "Failed to generate thumbnail", err)
+		return
+	}
+	serveRendered(c, data, render.FormatJPEG, ref.ETag)
+}
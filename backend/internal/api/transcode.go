@@ -0,0 +1,167 @@
+// File: backend/internal/api/transcode.go
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/ewag/gen-erics/backend/internal/dicom"
+	"github.com/ewag/gen-erics/backend/internal/models"
+	"github.com/ewag/gen-erics/backend/internal/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// errNotStoredAs means a tier-backed instance would need transcoding, which
+// only Orthanc can do.
+var errNotStoredAs = errors.New("instance is not stored in the requested transfer syntax")
+
+// asStored stands for "any transfer syntax" among accepted syntaxes.
+const asStored = "*"
+
+// acceptedTransferSyntaxes lists, in preference order, the transfer-syntax
+// parameters of the Accept entries for mediaType (e.g.
+// "application/dicom; transfer-syntax=1.2.840.10008.1.2.1"). An entry without
+// the parameter counts as asStored; nil means the client didn't ask.
+func acceptedTransferSyntaxes(c *gin.Context, mediaType string) []string {
+	var out []string
+	for _, part := range strings.Split(c.GetHeader("Accept"), ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		mt, params, err := mime.ParseMediaType(part)
+		if err != nil || (mt != mediaType && mt != "*/*" && mt != "application/*") {
+			continue
+		}
+		ts, ok := params["transfer-syntax"]
+		if !ok {
+			ts = asStored
+		}
+		out = append(out, ts)
+	}
+	return out
+}
+
+// transcodeTarget picks the syntax Orthanc should produce for a hot download:
+// "" to send the object as stored. It writes a 406 and returns false when
+// none of the accepted syntaxes can be produced.
+func (h *APIHandler) transcodeTarget(c *gin.Context, accepted []string) (string, bool) {
+	if len(accepted) == 0 {
+		return "", true
+	}
+	for _, ts := range accepted {
+		if ts == asStored {
+			return "", true
+		}
+		if h.transcodeSyntaxes[ts] {
+			return ts, true
+		}
+	}
+	notAcceptable(c, fmt.Sprintf("Cannot transcode to %s", strings.Join(accepted, ", ")), h.supportedTransferSyntaxes())
+	return "", false
+}
+
+// storedTarget is transcodeTarget for tier-backed objects, which can only be
+// sent as stored: one of the accepted syntaxes must be stored (or asStored).
+// It returns the syntax to label the response with.
+func storedTarget(accepted []string, stored string) (string, error) {
+	if len(accepted) == 0 {
+		return "", nil
+	}
+	for _, ts := range accepted {
+		if ts == asStored {
+			return "", nil
+		}
+		if ts == stored {
+			return ts, nil
+		}
+	}
+	return "", fmt.Errorf("%w: stored as %s, accepted %s", errNotStoredAs, orUnknown(stored), strings.Join(accepted, ", "))
+}
+
+// supportedTransferSyntaxes lists the transcoding targets, sorted.
+func (h *APIHandler) supportedTransferSyntaxes() []string {
+	out := make([]string, 0, len(h.transcodeSyntaxes))
+	for ts := range h.transcodeSyntaxes {
+		out = append(out, ts)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// notAcceptable writes a 406 explaining which transfer syntaxes can be served.
+func notAcceptable(c *gin.Context, details string, supported []string) {
+	problem.WriteExtra(c, http.StatusNotAcceptable, "Requested transfer syntax is not available", details, gin.H{"supportedTransferSyntaxes": supported})
+}
+
+// dicomContentType labels a DICOM body with its transfer syntax when known.
+func dicomContentType(transferSyntax string) string {
+	if transferSyntax == "" {
+		return contentTypeDICOM
+	}
+	return fmt.Sprintf("%s; transfer-syntax=%s", contentTypeDICOM, transferSyntax)
+}
+
+// serveTranscodedFile streams a hot instance re-encoded by Orthanc.
+func (h *APIHandler) serveTranscodedFile(c *gin.Context, instanceUID, transferSyntax string) {
+	ctx := c.Request.Context()
+	logAttrs := []any{"instanceUID", instanceUID, "transferSyntax", transferSyntax}
+
+	etag := instanceETag(instanceUID, "file-"+transferSyntax)
+	if notModified(c, etag) {
+		return
+	}
+
+	slog.InfoContext(ctx, "Transcoding instance file in Orthanc", logAttrs...)
+	file, err := h.orthancClient.TranscodeInstance(ctx, instanceUID, transferSyntax)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to transcode instance in Orthanc", append(logAttrs, "error", err)...)
+		respondError(c, "Failed to transcode instance in PACS", err)
+		return
+	}
+	defer file.Body.Close()
+
+	if err := serveStream(c, streamSource{
+		Body:        file.Body,
+		Size:        file.ContentLength,
+		ContentType: dicomContentType(transferSyntax),
+		ETag:        etag,
+		Filename:    instanceUID + ".dcm",
+	}); err != nil {
+		slog.WarnContext(ctx, "Streaming transcoded instance interrupted", append(logAttrs, "error", err)...)
+	}
+}
+
+// tierFileSyntax resolves the Accept header for a tier-backed instance,
+// reading its header when the catalog predates the transfer syntax column.
+// It writes the error response itself when it returns false.
+func (h *APIHandler) tierFileSyntax(c *gin.Context, entry *models.CatalogEntry, accepted []string) (string, bool) {
+	if len(accepted) == 0 {
+		return "", true
+	}
+	stored := entry.TransferSyntaxUID
+	if stored == "" {
+		ds, ok := h.loadTierDataset(c, entry, dicom.ParseOptions{SkipPixelData: true})
+		if !ok {
+			return "", false
+		}
+		stored = ds.TransferSyntax
+	}
+	ts, err := storedTarget(accepted, stored)
+	if err != nil {
+		notAcceptable(c, fmt.Sprintf("%v; transcoding is only available for hot studies", err), []string{stored})
+		return "", false
+	}
+	return ts, true
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
@@ -0,0 +1,179 @@
+// File: backend/internal/audit/event.go
+package audit
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"time"
+)
+
+// Event action codes (RFC 3881 EventActionCode).
+const (
+	ActionCreate  = "C"
+	ActionRead    = "R"
+	ActionUpdate  = "U"
+	ActionDelete  = "D"
+	ActionExecute = "E"
+)
+
+// Event outcome indicators (RFC 3881 EventOutcomeIndicator).
+const (
+	OutcomeSuccess        = 0
+	OutcomeMinorFailure   = 4
+	OutcomeSeriousFailure = 8
+	OutcomeMajorFailure   = 12
+)
+
+// Participant object type codes and roles used here (RFC 3881 / DICOM PS3.15 A.5).
+const (
+	ObjectTypePerson       = 1
+	ObjectTypeSystemObject = 2
+	ObjectRolePatient      = 1
+	ObjectRoleReport       = 3
+	ObjectRoleSecurity     = 13
+	ObjectRoleQuery        = 24
+)
+
+// Code is a coded value (csd-code, codeSystemName, originalText).
+type Code struct {
+	Code        string `json:"code" xml:"csd-code,attr"`
+	System      string `json:"system" xml:"codeSystemName,attr"`
+	DisplayName string `json:"displayName" xml:"originalText,attr"`
+}
+
+func dcm(code, display string) Code {
+	return Code{Code: code, System: "DCM", DisplayName: display}
+}
+
+// Event IDs from the DICOM Audit Message vocabulary (PS3.16 CID 400).
+var (
+	EventAuditLogUsed         = dcm("110101", "Audit Log Used")
+	EventInstancesAccessed    = dcm("110103", "DICOM Instances Accessed")
+	EventInstancesTransferred = dcm("110104", "DICOM Instances Transferred")
+	EventStudyDeleted         = dcm("110105", "DICOM Study Deleted")
+	EventExport               = dcm("110106", "Export")
+	EventPatientRecord        = dcm("110110", "Patient Record")
+	EventQuery                = dcm("110112", "Query")
+	EventSecurityAlert        = dcm("110113", "Security Alert")
+)
+
+// Participant object ID types.
+var (
+	IDTypePatientNumber = Code{Code: "2", System: "RFC-3881", DisplayName: "Patient Number"}
+	IDTypeStudyUID      = dcm("110180", "Study Instance UID")
+	IDTypeURI           = Code{Code: "12", System: "RFC-3881", DisplayName: "URI"}
+)
+
+// Event is a DICOM Audit Message (PS3.15 A.5, based on RFC 3881). It
+// marshals to the XML schema used for syslog forwarding and to JSON for the
+// audit table and API.
+type Event struct {
+	XMLName        xml.Name            `json:"-" xml:"AuditMessage"`
+	ID             int64               `json:"id,omitempty" xml:"-"`
+	Identification EventIdentification `json:"event" xml:"EventIdentification"`
+	Participants   []ActiveParticipant `json:"participants" xml:"ActiveParticipant"`
+	Source         AuditSource         `json:"source" xml:"AuditSourceIdentification"`
+	Objects        []ParticipantObject `json:"objects,omitempty" xml:"ParticipantObjectIdentification"`
+}
+
+// EventIdentification says what happened, when, and with what outcome.
+type EventIdentification struct {
+	EventID            Code      `json:"eventID" xml:"EventID"`
+	ActionCode         string    `json:"actionCode" xml:"EventActionCode,attr"`
+	DateTime           time.Time `json:"dateTime" xml:"EventDateTime,attr"`
+	Outcome            int       `json:"outcome" xml:"EventOutcomeIndicator,attr"`
+	OutcomeDescription string    `json:"outcomeDescription,omitempty" xml:"EventOutcomeDescription,omitempty"`
+}
+
+// ActiveParticipant is a user or process taking part in the event.
+type ActiveParticipant struct {
+	UserID                     string `json:"userID" xml:"UserID,attr"`
+	AlternativeUserID          string `json:"alternativeUserID,omitempty" xml:"AlternativeUserID,attr,omitempty"`
+	UserName                   string `json:"userName,omitempty" xml:"UserName,attr,omitempty"`
+	UserIsRequestor            bool   `json:"userIsRequestor" xml:"UserIsRequestor,attr"`
+	NetworkAccessPointID       string `json:"networkAccessPointID,omitempty" xml:"NetworkAccessPointID,attr,omitempty"`
+	NetworkAccessPointTypeCode int    `json:"networkAccessPointTypeCode,omitempty" xml:"NetworkAccessPointTypeCode,attr,omitempty"` // 2 = IP address
+	RoleIDCodes                []Code `json:"roleIDCodes,omitempty" xml:"RoleIDCode"`
+}
+
+// AuditSource identifies the system reporting the event.
+type AuditSource struct {
+	ID               string `json:"id" xml:"AuditSourceID,attr"`
+	EnterpriseSiteID string `json:"enterpriseSiteID,omitempty" xml:"AuditEnterpriseSiteID,attr,omitempty"`
+}
+
+// ParticipantObject is a patient, study or other object the event touched.
+type ParticipantObject struct {
+	TypeCode     int            `json:"typeCode" xml:"ParticipantObjectTypeCode,attr"`
+	TypeCodeRole int            `json:"typeCodeRole" xml:"ParticipantObjectTypeCodeRole,attr"`
+	IDTypeCode   Code           `json:"idTypeCode" xml:"ParticipantObjectIDTypeCode"`
+	ID           string         `json:"id" xml:"ParticipantObjectID,attr"`
+	Name         string         `json:"name,omitempty" xml:"ParticipantObjectName,omitempty"`
+	Query        Base64         `json:"query,omitempty" xml:"ParticipantObjectQuery,omitempty"`
+	Details      []ObjectDetail `json:"details,omitempty" xml:"ParticipantObjectDetail"`
+}
+
+// ObjectDetail is a free-form type/value pair on a participant object.
+type ObjectDetail struct {
+	Type  string `json:"type" xml:"type,attr"`
+	Value Base64 `json:"value" xml:"value,attr"`
+}
+
+// Base64 is binary content that the audit schema carries base64-encoded.
+type Base64 []byte
+
+// MarshalText implements encoding.TextMarshaler for both XML and JSON.
+func (b Base64) MarshalText() ([]byte, error) {
+	out := make([]byte, base64.StdEncoding.EncodedLen(len(b)))
+	base64.StdEncoding.Encode(out, b)
+	return out, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (b *Base64) UnmarshalText(text []byte) error {
+	decoded, err := base64.StdEncoding.DecodeString(string(text))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// Requestor returns the participant that initiated the event, if any.
+func (e *Event) Requestor() *ActiveParticipant {
+	for i := range e.Participants {
+		if e.Participants[i].UserIsRequestor {
+			return &e.Participants[i]
+		}
+	}
+	return nil
+}
+
+// StudyObject describes a study touched by an event. seriesID and instanceID
+// are optional and recorded as details.
+func StudyObject(studyID, seriesID, instanceID string) ParticipantObject {
+	obj := ParticipantObject{
+		TypeCode:     ObjectTypeSystemObject,
+		TypeCodeRole: ObjectRoleReport,
+		IDTypeCode:   IDTypeStudyUID,
+		ID:           studyID,
+	}
+	if seriesID != "" {
+		obj.Details = append(obj.Details, ObjectDetail{Type: "SeriesInstanceUID", Value: []byte(seriesID)})
+	}
+	if instanceID != "" {
+		obj.Details = append(obj.Details, ObjectDetail{Type: "SOPInstanceUID", Value: []byte(instanceID)})
+	}
+	return obj
+}
+
+// PatientObject describes a patient touched by an event.
+func PatientObject(patientID, patientName string) ParticipantObject {
+	return ParticipantObject{
+		TypeCode:     ObjectTypePerson,
+		TypeCodeRole: ObjectRolePatient,
+		IDTypeCode:   IDTypePatientNumber,
+		ID:           patientID,
+		Name:         patientName,
+	}
+}
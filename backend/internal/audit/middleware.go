@@ -0,0 +1,109 @@
+// File: backend/internal/audit/middleware.go
+package audit
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ewag/gen-erics/backend/internal/auth"
+)
+
+// objectsKey holds extra participant objects added by handlers.
+const objectsKey = "audit.objects"
+
+// AddObject lets a handler attach a participant object (e.g. the patient it
+// resolved) to the request's audit event.
+func AddObject(c *gin.Context, obj ParticipantObject) {
+	objs, _ := c.Get(objectsKey)
+	list, _ := objs.([]ParticipantObject)
+	c.Set(objectsKey, append(list, obj))
+}
+
+// Middleware records one audit event per request after the handler has run,
+// so the outcome reflects the response status. Study, series and instance
+// route parameters become participant objects; a query string becomes a
+// query object. A handler that panics, including one aborting a response
+// it has partly sent, is recorded as a serious failure before the panic
+// carries on to the recovery middleware.
+func (r *Recorder) Middleware(eventID Code, action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			p := recover()
+			r.Record(c.Request.Context(), requestEvent(c, eventID, action, p))
+			if p != nil {
+				panic(p)
+			}
+		}()
+		c.Next()
+	}
+}
+
+// requestEvent builds the audit event for a finished request; p is what its
+// handler panicked with, if anything.
+func requestEvent(c *gin.Context, eventID Code, action string, p any) *Event {
+	e := &Event{
+		Identification: EventIdentification{
+			EventID:    eventID,
+			ActionCode: action,
+			Outcome:    outcomeFor(c.Writer.Status()),
+		},
+		Participants: []ActiveParticipant{requestor(c)},
+	}
+	switch {
+	case p == http.ErrAbortHandler:
+		e.Identification.Outcome = OutcomeSeriousFailure
+		e.Identification.OutcomeDescription = "Response aborted"
+	case p != nil:
+		e.Identification.Outcome = OutcomeSeriousFailure
+		e.Identification.OutcomeDescription = "Handler panicked"
+	case e.Identification.Outcome != OutcomeSuccess:
+		e.Identification.OutcomeDescription = http.StatusText(c.Writer.Status())
+	}
+
+	if studyID := c.Param("studyUID"); studyID != "" {
+		e.Objects = append(e.Objects, StudyObject(studyID, c.Param("seriesUID"), c.Param("instanceUID")))
+	}
+	if objs, ok := c.Get(objectsKey); ok {
+		extra, _ := objs.([]ParticipantObject)
+		e.Objects = append(e.Objects, extra...)
+	}
+	if c.Request.URL.RawQuery != "" || len(e.Objects) == 0 {
+		e.Objects = append(e.Objects, ParticipantObject{
+			TypeCode:     ObjectTypeSystemObject,
+			TypeCodeRole: ObjectRoleQuery,
+			IDTypeCode:   IDTypeURI,
+			ID:           c.Request.URL.Path,
+			Query:        []byte(c.Request.URL.RawQuery),
+		})
+	}
+	return e
+}
+
+func requestor(c *gin.Context) ActiveParticipant {
+	p := ActiveParticipant{
+		UserID:                     auth.ActorFromContext(c.Request.Context()),
+		UserIsRequestor:            true,
+		NetworkAccessPointID:       c.ClientIP(),
+		NetworkAccessPointTypeCode: 2,
+	}
+	if principal, ok := auth.PrincipalFrom(c); ok {
+		p.UserName = principal.Name
+		p.AlternativeUserID = principal.Method
+		for _, role := range principal.Roles {
+			p.RoleIDCodes = append(p.RoleIDCodes, Code{Code: role, System: "gen-erics", DisplayName: role})
+		}
+	}
+	return p
+}
+
+func outcomeFor(status int) int {
+	switch {
+	case status < http.StatusBadRequest:
+		return OutcomeSuccess
+	case status < http.StatusInternalServerError:
+		return OutcomeMinorFailure
+	default:
+		return OutcomeSeriousFailure
+	}
+}
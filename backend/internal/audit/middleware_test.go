@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// memStore keeps appended events in memory.
+type memStore struct {
+	mu     sync.Mutex
+	events []*Event
+}
+
+func (s *memStore) AppendAuditEvent(_ context.Context, e *Event) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	return int64(len(s.events)), nil
+}
+
+func (s *memStore) QueryAuditEvents(context.Context, Filter) ([]Event, error) { return nil, nil }
+
+func TestMiddlewareOutcome(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name        string
+		handler     gin.HandlerFunc
+		wantOutcome int
+		wantDesc    string
+		wantPanic   any
+	}{
+		{name: "success", handler: func(c *gin.Context) { c.String(http.StatusOK, "ok") }, wantOutcome: OutcomeSuccess},
+		{name: "client error", handler: func(c *gin.Context) { c.Status(http.StatusNotFound) },
+			wantOutcome: OutcomeMinorFailure, wantDesc: "Not Found"},
+		{name: "server error", handler: func(c *gin.Context) { c.Status(http.StatusBadGateway) },
+			wantOutcome: OutcomeSeriousFailure, wantDesc: "Bad Gateway"},
+		{name: "stream aborted after partial body", handler: func(c *gin.Context) {
+			c.String(http.StatusOK, "partial")
+			panic(http.ErrAbortHandler)
+		}, wantOutcome: OutcomeSeriousFailure, wantDesc: "Response aborted", wantPanic: http.ErrAbortHandler},
+		{name: "handler panic", handler: func(c *gin.Context) { panic("boom") },
+			wantOutcome: OutcomeSeriousFailure, wantDesc: "Handler panicked", wantPanic: "boom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memStore{}
+			r := NewRecorder(store, nil, AuditSource{ID: "test"})
+			router := gin.New()
+			var recovered any
+			router.Use(func(c *gin.Context) {
+				defer func() { recovered = recover() }()
+				c.Next()
+			})
+			router.GET("/studies/:studyUID/file", r.Middleware(EventInstancesAccessed, ActionRead), tt.handler)
+
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/studies/s1/file", nil))
+
+			if recovered != tt.wantPanic {
+				t.Errorf("panic = %v, want %v passed on", recovered, tt.wantPanic)
+			}
+			if len(store.events) != 1 {
+				t.Fatalf("recorded %d events, want 1", len(store.events))
+			}
+			id := store.events[0].Identification
+			if id.Outcome != tt.wantOutcome || id.OutcomeDescription != tt.wantDesc {
+				t.Errorf("outcome = %d %q, want %d %q", id.Outcome, id.OutcomeDescription, tt.wantOutcome, tt.wantDesc)
+			}
+			if objs := store.events[0].Objects; len(objs) != 1 || objs[0].ID != "s1" {
+				t.Errorf("objects = %+v, want the study s1", objs)
+			}
+		})
+	}
+}
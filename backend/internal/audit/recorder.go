@@ -0,0 +1,81 @@
+// File: backend/internal/audit/recorder.go
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// writeTimeout bounds how long a request waits for its audit row.
+const writeTimeout = 5 * time.Second
+
+// Filter selects events for the audit API. Zero values match everything.
+type Filter struct {
+	UserID   string
+	EventID  string // csd-code, e.g. "110103"
+	ObjectID string // Study UID, patient ID, ...
+	Outcome  *int
+	From     time.Time
+	To       time.Time
+	BeforeID int64 // Keyset pagination: only events with a smaller ID
+	Limit    int
+}
+
+// Store persists audit events. Implementations must be append-only. It is
+// implemented by the storage package; the interface lives here so audit does
+// not depend on storage.
+type Store interface {
+	AppendAuditEvent(ctx context.Context, e *Event) (int64, error)
+	QueryAuditEvents(ctx context.Context, f Filter) ([]Event, error)
+}
+
+// Forwarder ships events to an external collector. Forward must not block.
+type Forwarder interface {
+	Forward(e *Event)
+}
+
+// Recorder stamps, persists and forwards audit events.
+type Recorder struct {
+	store     Store
+	forwarder Forwarder // may be nil
+	source    AuditSource
+}
+
+// NewRecorder creates a Recorder. forwarder may be nil.
+func NewRecorder(store Store, forwarder Forwarder, source AuditSource) *Recorder {
+	return &Recorder{store: store, forwarder: forwarder, source: source}
+}
+
+// Record persists e and forwards it. Failures are logged, never returned: the
+// request has already happened and must not be undone by an audit outage.
+func (r *Recorder) Record(ctx context.Context, e *Event) {
+	if e.Identification.DateTime.IsZero() {
+		e.Identification.DateTime = time.Now().UTC()
+	}
+	e.Source = r.source
+	e.Participants = append(e.Participants, ActiveParticipant{
+		UserID:          r.source.ID,
+		UserIsRequestor: false,
+		RoleIDCodes:     []Code{dcm("110153", "Source Role ID")},
+	})
+
+	// Keep writing even if the client hung up
+	writeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), writeTimeout)
+	defer cancel()
+	id, err := r.store.AppendAuditEvent(writeCtx, e)
+	if err != nil {
+		slog.ErrorContext(ctx, "AUDIT FAILURE: could not persist audit event",
+			"eventID", e.Identification.EventID.Code, "error", err)
+	}
+	e.ID = id
+
+	if r.forwarder != nil {
+		r.forwarder.Forward(e)
+	}
+}
+
+// Query returns events matching f, newest first.
+func (r *Recorder) Query(ctx context.Context, f Filter) ([]Event, error) {
+	return r.store.QueryAuditEvents(ctx, f)
+}
@@ -0,0 +1,151 @@
+// File: backend/internal/audit/syslog.go
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	syslogFacilityAuthPriv = 10
+	syslogSeverityWarning  = 4
+	syslogSeverityNotice   = 5
+	// syslogMsgID is the MSGID IHE ATNA prescribes for DICOM audit messages.
+	syslogMsgID = "IHE+RFC-3881"
+
+	syslogQueueSize   = 1024
+	syslogDialTimeout = 10 * time.Second
+	syslogMaxBackoff  = time.Minute
+
+	// RFC 5424 allows at most microsecond precision
+	syslogTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// SyslogForwarder sends events to a collector as RFC 5424 messages over TCP
+// or TLS (RFC 5425 octet-counted framing). Events are queued so a slow or
+// unreachable collector never blocks requests; the database stays the
+// system of record, so events dropped on overflow are only logged.
+type SyslogForwarder struct {
+	addr      string
+	tlsConfig *tls.Config // nil for plain TCP
+	appName   string
+	hostname  string
+	queue     chan []byte
+	dropped   atomic.Int64
+}
+
+// NewSyslogForwarder creates a forwarder; call Run to start delivery.
+func NewSyslogForwarder(addr string, tlsConfig *tls.Config, appName string) *SyslogForwarder {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	return &SyslogForwarder{
+		addr:      addr,
+		tlsConfig: tlsConfig,
+		appName:   appName,
+		hostname:  hostname,
+		queue:     make(chan []byte, syslogQueueSize),
+	}
+}
+
+// Forward implements Forwarder.
+func (f *SyslogForwarder) Forward(e *Event) {
+	msg, err := f.format(e)
+	if err != nil {
+		slog.Error("Failed to format audit event for syslog", "eventID", e.ID, "error", err)
+		return
+	}
+	select {
+	case f.queue <- msg:
+	default:
+		n := f.dropped.Add(1)
+		slog.Warn("Audit syslog queue full, dropping event", "eventID", e.ID, "droppedTotal", n)
+	}
+}
+
+// Run delivers queued messages until ctx is cancelled, reconnecting with
+// backoff. A message is retried until it is written.
+func (f *SyslogForwarder) Run(ctx context.Context) {
+	slog.Info("Audit syslog forwarder started", "address", f.addr, "tls", f.tlsConfig != nil)
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	backoff := time.Second
+	for {
+		var msg []byte
+		select {
+		case <-ctx.Done():
+			return
+		case msg = <-f.queue:
+		}
+
+		for {
+			if conn == nil {
+				var err error
+				if conn, err = f.dial(ctx); err != nil {
+					slog.Warn("Audit syslog collector unreachable", "address", f.addr, "retryIn", backoff, "error", err)
+					select {
+					case <-ctx.Done():
+						return
+					case <-time.After(backoff):
+					}
+					backoff = min(backoff*2, syslogMaxBackoff)
+					continue
+				}
+				backoff = time.Second
+			}
+			frame := append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+			if _, err := conn.Write(frame); err != nil {
+				slog.Warn("Audit syslog write failed, reconnecting", "address", f.addr, "error", err)
+				conn.Close()
+				conn = nil
+				continue
+			}
+			break
+		}
+	}
+}
+
+func (f *SyslogForwarder) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: syslogDialTimeout, KeepAlive: 30 * time.Second}
+	if f.tlsConfig != nil {
+		td := &tls.Dialer{NetDialer: dialer, Config: f.tlsConfig}
+		return td.DialContext(ctx, "tcp", f.addr)
+	}
+	return dialer.DialContext(ctx, "tcp", f.addr)
+}
+
+// format renders e as an RFC 5424 message with the XML audit message as MSG:
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID - BOM MSG
+func (f *SyslogForwarder) format(e *Event) ([]byte, error) {
+	body, err := xml.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	severity := syslogSeverityNotice
+	if e.Identification.Outcome != OutcomeSuccess {
+		severity = syslogSeverityWarning
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %d %s - ",
+		syslogFacilityAuthPriv*8+severity,
+		e.Identification.DateTime.UTC().Format(syslogTimeFormat),
+		f.hostname, f.appName, os.Getpid(), syslogMsgID)
+	buf.WriteString("\ufeff") // UTF-8 BOM marks the MSG as Unicode
+	buf.Write(body)
+	return buf.Bytes(), nil
+}
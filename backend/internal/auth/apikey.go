@@ -0,0 +1,154 @@
+// File: backend/internal/auth/apikey.go
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// apiKeyPrefix marks gen-erics API keys so they are easy to spot in secret scanners.
+const apiKeyPrefix = "gek"
+
+// lastUsedResolution limits last-used writes to one per key per interval.
+const lastUsedResolution = time.Minute
+
+// ErrInvalidAPIKey is returned for unknown, malformed, revoked or expired keys.
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// APIKey is the stored metadata of a machine credential. The secret itself is
+// only ever kept as a SHA-256 hash.
+type APIKey struct {
+	ID         string     `json:"id"`     // Public identifier, also embedded in the key
+	Name       string     `json:"name"`   // Human label, e.g. "nightly-export"
+	Scopes     []string   `json:"scopes"` // Roles the key acts with
+	CreatedBy  string     `json:"createdBy"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// Active reports whether the key may still authenticate at t.
+func (k *APIKey) Active(t time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || t.Before(*k.ExpiresAt))
+}
+
+// APIKeyStore persists API keys. It is implemented by the storage package;
+// the interface lives here so auth does not depend on storage.
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, key *APIKey, secretHash string) error
+	// GetAPIKey returns the key and its secret hash, found=false if the ID is unknown.
+	GetAPIKey(ctx context.Context, id string) (key *APIKey, secretHash string, found bool, err error)
+	ListAPIKeys(ctx context.Context) ([]APIKey, error)
+	// RevokeAPIKey returns false if the key does not exist or was already revoked.
+	RevokeAPIKey(ctx context.Context, id string, at time.Time) (bool, error)
+	TouchAPIKey(ctx context.Context, id string, at time.Time) error
+}
+
+// APIKeys issues and verifies API keys.
+type APIKeys struct {
+	store APIKeyStore
+	now   func() time.Time
+}
+
+// NewAPIKeys creates an API key service backed by store.
+func NewAPIKeys(store APIKeyStore) *APIKeys {
+	return &APIKeys{store: store, now: time.Now}
+}
+
+// Create issues a new key and returns its metadata and the plaintext secret.
+// The secret cannot be recovered afterwards.
+func (s *APIKeys) Create(ctx context.Context, name string, scopes []string, ttl time.Duration) (*APIKey, string, error) {
+	idBytes := make([]byte, 6)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, "", fmt.Errorf("failed to generate key id: %w", err)
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return nil, "", fmt.Errorf("failed to generate key secret: %w", err)
+	}
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+
+	key := &APIKey{
+		ID:        hex.EncodeToString(idBytes),
+		Name:      name,
+		Scopes:    scopes,
+		CreatedBy: ActorFromContext(ctx),
+		CreatedAt: s.now().UTC(),
+	}
+	if ttl > 0 {
+		expires := key.CreatedAt.Add(ttl)
+		key.ExpiresAt = &expires
+	}
+	if err := s.store.CreateAPIKey(ctx, key, hashSecret(secret)); err != nil {
+		return nil, "", err
+	}
+	slog.InfoContext(ctx, "API key created", "keyID", key.ID, "name", name, "scopes", scopes, "expiresAt", key.ExpiresAt)
+	return key, fmt.Sprintf("%s_%s_%s", apiKeyPrefix, key.ID, secret), nil
+}
+
+// List returns all keys, including revoked and expired ones.
+func (s *APIKeys) List(ctx context.Context) ([]APIKey, error) {
+	return s.store.ListAPIKeys(ctx)
+}
+
+// Revoke disables a key immediately.
+func (s *APIKeys) Revoke(ctx context.Context, id string) (bool, error) {
+	revoked, err := s.store.RevokeAPIKey(ctx, id, s.now().UTC())
+	if err == nil && revoked {
+		slog.InfoContext(ctx, "API key revoked", "keyID", id)
+	}
+	return revoked, err
+}
+
+// Verify checks a presented key and returns the principal it acts as.
+func (s *APIKeys) Verify(ctx context.Context, presented string) (*Principal, error) {
+	prefix, rest, ok := strings.Cut(presented, "_")
+	if !ok || prefix != apiKeyPrefix {
+		return nil, ErrInvalidAPIKey
+	}
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok || id == "" || secret == "" {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, storedHash, found, err := s.store.GetAPIKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !found || subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(storedHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	now := s.now().UTC()
+	if !key.Active(now) {
+		return nil, fmt.Errorf("%w: key %s is revoked or expired", ErrInvalidAPIKey, id)
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedResolution {
+		if err := s.store.TouchAPIKey(ctx, id, now); err != nil {
+			// Tracking is best effort; don't fail the request over it
+			slog.WarnContext(ctx, "Failed to record API key use", "keyID", id, "error", err)
+		}
+	}
+
+	return &Principal{
+		Subject: "apikey:" + key.ID,
+		Name:    key.Name,
+		Roles:   key.Scopes,
+		Method:  "apikey",
+	}, nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
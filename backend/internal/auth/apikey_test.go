@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memAPIKeyStore keeps API keys in memory.
+type memAPIKeyStore struct {
+	mu      sync.Mutex
+	keys    map[string]APIKey
+	hashes  map[string]string
+	touches int
+}
+
+func newMemAPIKeyStore() *memAPIKeyStore {
+	return &memAPIKeyStore{keys: make(map[string]APIKey), hashes: make(map[string]string)}
+}
+
+func (s *memAPIKeyStore) CreateAPIKey(_ context.Context, key *APIKey, secretHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key.ID] = *key
+	s.hashes[key.ID] = secretHash
+	return nil
+}
+
+func (s *memAPIKeyStore) GetAPIKey(_ context.Context, id string) (*APIKey, string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[id]
+	return &key, s.hashes[id], ok, nil
+}
+
+func (s *memAPIKeyStore) ListAPIKeys(context.Context) ([]APIKey, error) { return nil, nil }
+
+func (s *memAPIKeyStore) RevokeAPIKey(_ context.Context, id string, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[id]
+	if !ok || key.RevokedAt != nil {
+		return false, nil
+	}
+	key.RevokedAt = &at
+	s.keys[id] = key
+	return true, nil
+}
+
+func (s *memAPIKeyStore) TouchAPIKey(_ context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := s.keys[id]
+	key.LastUsedAt = &at
+	s.keys[id] = key
+	s.touches++
+	return nil
+}
+
+func TestAPIKeysVerify(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		after   time.Duration // Time between creating and presenting the key
+		revoke  bool
+		present func(secret string) string
+		wantErr bool
+	}{
+		{name: "valid", present: func(s string) string { return s }},
+		{name: "valid before expiry", ttl: time.Hour, after: 59 * time.Minute, present: func(s string) string { return s }},
+		{name: "expired", ttl: time.Hour, after: time.Hour, present: func(s string) string { return s }, wantErr: true},
+		{name: "revoked", revoke: true, present: func(s string) string { return s }, wantErr: true},
+		{name: "wrong secret", present: func(s string) string { return s[:len(s)-1] + flipLast(s) }, wantErr: true},
+		{name: "unknown id", present: func(s string) string {
+			parts := strings.SplitN(s, "_", 3)
+			return parts[0] + "_000000000000_" + parts[2]
+		}, wantErr: true},
+		{name: "wrong prefix", present: func(s string) string { return "xyz" + strings.TrimPrefix(s, apiKeyPrefix) }, wantErr: true},
+		{name: "no secret", present: func(s string) string { return s[:strings.LastIndex(s, "_")+1] }, wantErr: true},
+		{name: "not a key", present: func(string) string { return "eyJhbGciOi.eyJzdWIi.c2ln" }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemAPIKeyStore()
+			keys := NewAPIKeys(store)
+			keys.now = func() time.Time { return created }
+			key, secret, err := keys.Create(ctx, "export", []string{"viewer"}, tt.ttl)
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			if !strings.HasPrefix(secret, apiKeyPrefix+"_"+key.ID+"_") {
+				t.Fatalf("Create() secret %q does not name key %s", secret, key.ID)
+			}
+			if stored := store.hashes[key.ID]; stored == "" || strings.Contains(secret, stored) {
+				t.Fatalf("stored hash %q, want a hash of the secret", stored)
+			}
+			if tt.revoke {
+				if ok, err := keys.Revoke(ctx, key.ID); !ok || err != nil {
+					t.Fatalf("Revoke() = %v, %v", ok, err)
+				}
+			}
+
+			keys.now = func() time.Time { return created.Add(tt.after) }
+			p, err := keys.Verify(ctx, tt.present(secret))
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidAPIKey) {
+					t.Fatalf("Verify() = %+v, %v, want ErrInvalidAPIKey", p, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if p.Subject != "apikey:"+key.ID || p.Method != "apikey" || !slices.Equal(p.Roles, []string{"viewer"}) {
+				t.Errorf("Verify() = %+v", p)
+			}
+		})
+	}
+}
+
+// flipLast returns a different character than s's last.
+func flipLast(s string) string {
+	if strings.HasSuffix(s, "A") {
+		return "B"
+	}
+	return "A"
+}
+
+func TestAPIKeysRevokeTwice(t *testing.T) {
+	ctx := context.Background()
+	keys := NewAPIKeys(newMemAPIKeyStore())
+	key, _, err := keys.Create(ctx, "export", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{true, false} {
+		if got, err := keys.Revoke(ctx, key.ID); got != want || err != nil {
+			t.Errorf("Revoke() #%d = %v, %v, want %v", i+1, got, err, want)
+		}
+	}
+	if got, err := keys.Revoke(ctx, "missing"); got || err != nil {
+		t.Errorf("Revoke(missing) = %v, %v, want false", got, err)
+	}
+}
+
+func TestAPIKeysLastUsed(t *testing.T) {
+	ctx := context.Background()
+	store := newMemAPIKeyStore()
+	keys := NewAPIKeys(store)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	keys.now = func() time.Time { return now }
+	_, secret, err := keys.Create(ctx, "export", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Uses within lastUsedResolution of the recorded one aren't written
+	for _, at := range []time.Duration{0, 10 * time.Second, 59 * time.Second, time.Minute, 90 * time.Second} {
+		keys.now = func() time.Time { return now.Add(at) }
+		if _, err := keys.Verify(ctx, secret); err != nil {
+			t.Fatalf("Verify() at +%s error = %v", at, err)
+		}
+	}
+	if store.touches != 2 {
+		t.Errorf("recorded %d uses, want 2", store.touches)
+	}
+}
+
+func TestAPIKeyActive(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	before, after := now.Add(-time.Second), now.Add(time.Second)
+	tests := []struct {
+		name string
+		key  APIKey
+		want bool
+	}{
+		{name: "no expiry", key: APIKey{}, want: true},
+		{name: "expires later", key: APIKey{ExpiresAt: &after}, want: true},
+		{name: "expires now", key: APIKey{ExpiresAt: &now}},
+		{name: "expired", key: APIKey{ExpiresAt: &before}},
+		{name: "revoked", key: APIKey{RevokedAt: &before, ExpiresAt: &after}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.key.Active(now); got != tt.want {
+				t.Errorf("Active() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+// File: backend/internal/auth/jwks.go
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnknownKey is returned when a token's kid isn't in the key set.
+var ErrUnknownKey = errors.New("signing key not found in JWKS")
+
+// refreshTimeout bounds one JWKS fetch, which runs detached from the
+// requests waiting on it.
+const refreshTimeout = 30 * time.Second
+
+// jwk is a single JSON Web Key (RFC 7517); only public RSA and EC keys are used.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// KeySet resolves signing keys by kid, caching the JWKS and refreshing it
+// when it expires or when a token names a key we haven't seen yet. Fetches
+// run without the lock held, one at a time and at most once per minRefresh,
+// so a slow IdP or a stream of unknown kids never blocks requests whose keys
+// are cached.
+type KeySet struct {
+	fetch      func(ctx context.Context) ([]byte, error)
+	ttl        time.Duration
+	minRefresh time.Duration
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	inflight    chan struct{} // Closed when the running refresh ends; nil if none
+}
+
+// NewFileKeySet loads keys from a static JWKS file (tests, air-gapped sites).
+// The file is re-read when the cache expires so keys can be rotated in place.
+func NewFileKeySet(path string, ttl time.Duration) *KeySet {
+	return newKeySet(func(ctx context.Context) ([]byte, error) { return os.ReadFile(path) }, ttl)
+}
+
+// NewRemoteKeySet fetches keys from a JWKS URL. If jwksURL is empty it is
+// discovered from the issuer's /.well-known/openid-configuration.
+func NewRemoteKeySet(client *http.Client, issuer, jwksURL string, ttl time.Duration) *KeySet {
+	var discovered string
+	var discoverMu sync.Mutex
+	return newKeySet(func(ctx context.Context) ([]byte, error) {
+		url := jwksURL
+		if url == "" {
+			discoverMu.Lock()
+			defer discoverMu.Unlock()
+			if discovered == "" {
+				var err error
+				if discovered, err = discoverJWKSURL(ctx, client, issuer); err != nil {
+					return nil, err
+				}
+			}
+			url = discovered
+		}
+		return httpGet(ctx, client, url)
+	}, ttl)
+}
+
+func newKeySet(fetch func(ctx context.Context) ([]byte, error), ttl time.Duration) *KeySet {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &KeySet{fetch: fetch, ttl: ttl, minRefresh: 30 * time.Second}
+}
+
+// Key returns the public key for kid. An empty kid matches a set with a single key.
+func (ks *KeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	ks.mu.Lock()
+	key, found := ks.lookup(kid)
+	expired := time.Since(ks.fetchedAt) > ks.ttl
+	if found && !expired {
+		ks.mu.Unlock()
+		return key, nil
+	}
+	// Refresh on expiry or unknown kid, but don't let bad tokens hammer the IdP
+	done := ks.inflight
+	if done == nil && time.Since(ks.lastAttempt) >= ks.minRefresh {
+		done = make(chan struct{})
+		ks.inflight = done
+		ks.lastAttempt = time.Now()
+		go ks.refresh(context.WithoutCancel(ctx), done)
+	}
+	ks.mu.Unlock()
+
+	if found {
+		return key, nil // Expired keys stay usable while the refresh runs
+	}
+	if done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		ks.mu.Lock()
+		key, found = ks.lookup(kid)
+		ks.mu.Unlock()
+		if found {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: kid %q", ErrUnknownKey, kid)
+}
+
+func (ks *KeySet) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(ks.keys) == 1 {
+		for _, key := range ks.keys {
+			return key, true
+		}
+	}
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+// refresh fetches the JWKS and replaces the cached keys, then closes done.
+// A failed fetch keeps the keys already cached.
+func (ks *KeySet) refresh(ctx context.Context, done chan struct{}) {
+	ctx, cancel := context.WithTimeout(ctx, refreshTimeout)
+	defer cancel()
+	keys, err := ks.load(ctx)
+
+	ks.mu.Lock()
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to refresh JWKS, using cached keys", "error", err)
+	} else {
+		ks.keys = keys
+		ks.fetchedAt = time.Now()
+		slog.DebugContext(ctx, "Refreshed JWKS", "keys", len(keys))
+	}
+	ks.inflight = nil
+	ks.mu.Unlock()
+	close(done)
+}
+
+// load fetches and parses the JWKS.
+func (ks *KeySet) load(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	raw, err := ks.fetch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	var set jwkSet
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			slog.WarnContext(ctx, "Skipping unusable JWKS key", "kid", k.Kid, "error", err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS contains no usable signing keys")
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("bad modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("bad exponent: %w", err)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("bad x coordinate: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("bad y coordinate: %w", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func discoverJWKSURL(ctx context.Context, client *http.Client, issuer string) (string, error) {
+	raw, err := httpGet(ctx, client, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration")
+	if err != nil {
+		return "", fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	var doc struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil || doc.JWKSURI == "" {
+		return "", fmt.Errorf("OIDC discovery document has no jwks_uri")
+	}
+	return doc.JWKSURI, nil
+}
+
+func httpGet(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s returned status %d", url, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testdata/jwks.json holds the public halves of testdata/signing-keys.pem,
+// keys made for these tests only, plus an encryption key that must be ignored.
+const testJWKS = "testdata/jwks.json"
+
+func TestKeySetKey(t *testing.T) {
+	ks := NewFileKeySet(testJWKS, time.Hour)
+	tests := []struct {
+		kid     string
+		wantRSA bool
+		wantEC  bool
+	}{
+		{kid: "rsa-1", wantRSA: true},
+		{kid: "ec-1", wantEC: true},
+		{kid: "enc-1"}, // Not a signing key
+		{kid: "other"},
+		{kid: ""}, // Ambiguous with more than one key
+	}
+	for _, tt := range tests {
+		t.Run(tt.kid, func(t *testing.T) {
+			key, err := ks.Key(context.Background(), tt.kid)
+			if !tt.wantRSA && !tt.wantEC {
+				if !errors.Is(err, ErrUnknownKey) {
+					t.Fatalf("Key() = %T, %v, want ErrUnknownKey", key, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Key() error = %v", err)
+			}
+			_, isRSA := key.(*rsa.PublicKey)
+			_, isEC := key.(*ecdsa.PublicKey)
+			if isRSA != tt.wantRSA || isEC != tt.wantEC {
+				t.Errorf("Key() = %T", key)
+			}
+		})
+	}
+}
+
+func TestKeySetSingleKeyWithoutKid(t *testing.T) {
+	ks := newKeySet(func(context.Context) ([]byte, error) {
+		return []byte(`{"keys":[{"kty":"EC","kid":"only","crv":"P-256",` +
+			`"x":"MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4","y":"4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM"}]}`), nil
+	}, time.Hour)
+	if _, err := ks.Key(context.Background(), ""); err != nil {
+		t.Errorf("Key(\"\") error = %v, want the only key", err)
+	}
+}
+
+// fakeJWKS serves the fixture, counting fetches and holding each one until
+// released when gated.
+type fakeJWKS struct {
+	raw     []byte
+	fetches atomic.Int32
+	gate    chan struct{} // nil: fetches return at once
+}
+
+func (f *fakeJWKS) fetch(ctx context.Context) ([]byte, error) {
+	f.fetches.Add(1)
+	if f.gate != nil {
+		select {
+		case <-f.gate:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return f.raw, nil
+}
+
+func TestKeySetRefresh(t *testing.T) {
+	raw, err := os.ReadFile(testJWKS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every case starts with the keys cached by one fetch
+	setup := func(t *testing.T) (*KeySet, *fakeJWKS) {
+		t.Helper()
+		f := &fakeJWKS{raw: raw}
+		ks := newKeySet(f.fetch, time.Hour)
+		if _, err := ks.Key(context.Background(), "rsa-1"); err != nil {
+			t.Fatalf("priming Key() error = %v", err)
+		}
+		f.fetches.Store(0)
+		return ks, f
+	}
+	allowRefresh := func(ks *KeySet) {
+		ks.mu.Lock()
+		ks.lastAttempt = time.Time{}
+		ks.mu.Unlock()
+	}
+
+	tests := []struct {
+		name        string
+		run         func(t *testing.T, ks *KeySet, f *fakeJWKS)
+		wantFetches int32
+	}{
+		{
+			name: "cached key without a fetch",
+			run: func(t *testing.T, ks *KeySet, f *fakeJWKS) {
+				if _, err := ks.Key(context.Background(), "ec-1"); err != nil {
+					t.Errorf("Key() error = %v", err)
+				}
+			},
+		},
+		{
+			name: "expired keys served while a slow refresh runs",
+			run: func(t *testing.T, ks *KeySet, f *fakeJWKS) {
+				f.gate = make(chan struct{})
+				defer close(f.gate)
+				ks.mu.Lock()
+				ks.fetchedAt = time.Now().Add(-2 * time.Hour)
+				ks.mu.Unlock()
+				allowRefresh(ks)
+				for i := 0; i < 3; i++ {
+					if _, err := ks.Key(context.Background(), "rsa-1"); err != nil {
+						t.Errorf("Key() error = %v", err)
+					}
+				}
+			},
+			wantFetches: 1,
+		},
+		{
+			name: "unknown kids share one fetch",
+			run: func(t *testing.T, ks *KeySet, f *fakeJWKS) {
+				f.gate = make(chan struct{})
+				allowRefresh(ks)
+				var wg sync.WaitGroup
+				for i := 0; i < 20; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						if _, err := ks.Key(context.Background(), "rotated"); !errors.Is(err, ErrUnknownKey) {
+							t.Errorf("Key() error = %v, want ErrUnknownKey", err)
+						}
+					}()
+				}
+				// Requests for cached keys don't queue behind the fetch
+				if _, err := ks.Key(context.Background(), "ec-1"); err != nil {
+					t.Errorf("Key() error = %v", err)
+				}
+				close(f.gate)
+				wg.Wait()
+			},
+			wantFetches: 1,
+		},
+		{
+			name: "unknown kids rate limited",
+			run: func(t *testing.T, ks *KeySet, f *fakeJWKS) {
+				allowRefresh(ks)
+				for i := 0; i < 5; i++ {
+					if _, err := ks.Key(context.Background(), "rotated"); !errors.Is(err, ErrUnknownKey) {
+						t.Errorf("Key() error = %v, want ErrUnknownKey", err)
+					}
+				}
+			},
+			wantFetches: 1,
+		},
+		{
+			name: "waiter gives up with its context",
+			run: func(t *testing.T, ks *KeySet, f *fakeJWKS) {
+				f.gate = make(chan struct{})
+				defer close(f.gate)
+				allowRefresh(ks)
+				ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+				defer cancel()
+				if _, err := ks.Key(ctx, "rotated"); !errors.Is(err, context.DeadlineExceeded) {
+					t.Errorf("Key() error = %v, want context.DeadlineExceeded", err)
+				}
+			},
+			wantFetches: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks, f := setup(t)
+			tt.run(t, ks, f)
+			ks.mu.Lock()
+			done := ks.inflight
+			ks.mu.Unlock()
+			if done != nil {
+				<-done // Let a background refresh finish before counting
+			}
+			if got := f.fetches.Load(); got != tt.wantFetches {
+				t.Errorf("fetched %d times, want %d", got, tt.wantFetches)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSigningKeys reads testdata/signing-keys.pem, the private halves of the
+// keys in testdata/jwks.json, by kid.
+func testSigningKeys(t *testing.T) map[string]crypto.Signer {
+	t.Helper()
+	rest, err := os.ReadFile("testdata/signing-keys.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := make(map[string]crypto.Signer)
+	for {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			t.Fatalf("key %s: %v", block.Headers["kid"], err)
+		}
+		keys[block.Headers["kid"]] = key.(crypto.Signer)
+	}
+	return keys
+}
+
+// signJWT builds a token signed by key with alg, or left unsigned when key is nil.
+func signJWT(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	if key == nil {
+		return signed + "."
+	}
+
+	hash, err := hashForAlg(alg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if strings.HasPrefix(alg, "PS") {
+			sig, err = rsa.SignPSS(rand.Reader, k, hash, digest, nil)
+		} else {
+			sig, err = rsa.SignPKCS1v15(rand.Reader, k, hash, digest)
+		}
+	case *ecdsa.PrivateKey:
+		// JWS wants r || s, each padded to the curve size, not ASN.1
+		var r, s *big.Int
+		if r, s, err = ecdsa.Sign(rand.Reader, k, digest); err == nil {
+			size := (k.Curve.Params().BitSize + 7) / 8
+			sig = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestVerifierVerify(t *testing.T) {
+	keys := testSigningKeys(t)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	baseClaims := func() map[string]any {
+		return map[string]any{
+			"iss":   "https://idp.example",
+			"sub":   "user-1",
+			"aud":   "gen-erics",
+			"exp":   now.Add(time.Hour).Unix(),
+			"iat":   now.Unix(),
+			"name":  "Dr Test",
+			"roles": []string{"radiologist"},
+		}
+	}
+	baseConfig := VerifierConfig{Issuer: "https://idp.example", Audience: "gen-erics", Leeway: time.Minute}
+
+	tests := []struct {
+		name      string
+		alg       string
+		kid       string
+		signer    string // Kid of the private key signing the token, if not kid's own
+		unsigned  bool
+		claims    func(map[string]any)
+		config    func(*VerifierConfig)
+		token     func(string) string // Rewrites the signed token
+		wantErr   bool
+		wantRoles []string
+	}{
+		{name: "RS256", alg: "RS256", kid: "rsa-1", wantRoles: []string{"radiologist"}},
+		{name: "PS256", alg: "PS256", kid: "rsa-1", wantRoles: []string{"radiologist"}},
+		{name: "ES256", alg: "ES256", kid: "ec-1", wantRoles: []string{"radiologist"}},
+		{name: "audience in a list", alg: "RS256", kid: "rsa-1", wantRoles: []string{"radiologist"},
+			claims: func(c map[string]any) { c["aud"] = []string{"other", "gen-erics"} }},
+		{name: "audience check off", alg: "RS256", kid: "rsa-1", wantRoles: []string{"radiologist"},
+			claims: func(c map[string]any) { delete(c, "aud") }, config: func(c *VerifierConfig) { c.Audience = "" }},
+		{name: "expired within leeway", alg: "RS256", kid: "rsa-1", wantRoles: []string{"radiologist"},
+			claims: func(c map[string]any) { c["exp"] = now.Add(-30 * time.Second).Unix() }},
+		{name: "nested roles claim", alg: "RS256", kid: "rsa-1", wantRoles: []string{"storage-admin", "auditor"},
+			claims: func(c map[string]any) {
+				c["realm_access"] = map[string]any{"roles": []string{"storage-admin", "auditor"}}
+			},
+			config: func(c *VerifierConfig) { c.RolesClaim = "realm_access.roles" }},
+		{name: "space separated roles", alg: "RS256", kid: "rsa-1", wantRoles: []string{"a", "b"},
+			claims: func(c map[string]any) { c["roles"] = "a b" }},
+
+		{name: "expired", alg: "RS256", kid: "rsa-1", wantErr: true,
+			claims: func(c map[string]any) { c["exp"] = now.Add(-2 * time.Minute).Unix() }},
+		{name: "missing exp", alg: "RS256", kid: "rsa-1", wantErr: true,
+			claims: func(c map[string]any) { delete(c, "exp") }},
+		{name: "not yet valid", alg: "RS256", kid: "rsa-1", wantErr: true,
+			claims: func(c map[string]any) { c["nbf"] = now.Add(2 * time.Minute).Unix() }},
+		{name: "wrong issuer", alg: "RS256", kid: "rsa-1", wantErr: true,
+			claims: func(c map[string]any) { c["iss"] = "https://evil.example" }},
+		{name: "missing subject", alg: "RS256", kid: "rsa-1", wantErr: true,
+			claims: func(c map[string]any) { delete(c, "sub") }},
+		{name: "wrong audience", alg: "RS256", kid: "rsa-1", wantErr: true,
+			claims: func(c map[string]any) { c["aud"] = "other" }},
+		{name: "alg none", alg: "none", kid: "rsa-1", unsigned: true, wantErr: true},
+		{name: "HS256 with the public key as secret", alg: "HS256", kid: "rsa-1", unsigned: true, wantErr: true,
+			token: func(s string) string { return s + "c2lnbmF0dXJl" }},
+		{name: "alg not matching the key", alg: "ES256", kid: "rsa-1", signer: "ec-1", wantErr: true},
+		{name: "signed by another key", alg: "RS256", kid: "ec-1", signer: "rsa-1", wantErr: true},
+		{name: "encryption key", alg: "RS256", kid: "enc-1", signer: "rsa-1", wantErr: true},
+		{name: "unknown kid", alg: "RS256", kid: "rotated", signer: "rsa-1", wantErr: true},
+		{name: "tampered claims", alg: "RS256", kid: "rsa-1", wantErr: true,
+			token: func(s string) string {
+				parts := strings.Split(s, ".")
+				forged, _ := json.Marshal(map[string]any{"iss": "https://idp.example", "sub": "user-1", "aud": "gen-erics",
+					"exp": now.Add(time.Hour).Unix(), "roles": []string{"storage-admin"}})
+				return parts[0] + "." + base64.RawURLEncoding.EncodeToString(forged) + "." + parts[2]
+			}},
+		{name: "tampered signature", alg: "ES256", kid: "ec-1", wantErr: true,
+			token: func(s string) string {
+				sig, _ := base64.RawURLEncoding.DecodeString(s[strings.LastIndex(s, ".")+1:])
+				sig[len(sig)-1] ^= 1
+				return s[:strings.LastIndex(s, ".")+1] + base64.RawURLEncoding.EncodeToString(sig)
+			}},
+		{name: "malformed", alg: "RS256", kid: "rsa-1", wantErr: true,
+			token: func(s string) string { return s[:strings.LastIndex(s, ".")] }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := baseConfig
+			if tt.config != nil {
+				tt.config(&cfg)
+			}
+			v := NewVerifier(NewFileKeySet(testJWKS, time.Hour), cfg)
+			v.now = func() time.Time { return now }
+
+			claims := baseClaims()
+			if tt.claims != nil {
+				tt.claims(claims)
+			}
+			var key crypto.Signer
+			if !tt.unsigned {
+				signer := tt.signer
+				if signer == "" {
+					signer = tt.kid
+				}
+				key = keys[signer]
+			}
+			token := signJWT(t, tt.alg, tt.kid, key, claims)
+			if tt.token != nil {
+				token = tt.token(token)
+			}
+
+			p, err := v.Verify(context.Background(), token)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("Verify() = %+v, %v, want ErrInvalidToken", p, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if p.Subject != "user-1" || p.Name != "Dr Test" || p.Method != "jwt" || p.Issuer != cfg.Issuer {
+				t.Errorf("Verify() = %+v", p)
+			}
+			if !slices.Equal(p.Roles, tt.wantRoles) {
+				t.Errorf("roles = %v, want %v", p.Roles, tt.wantRoles)
+			}
+		})
+	}
+}
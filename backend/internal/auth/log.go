@@ -0,0 +1,35 @@
+// File: backend/internal/auth/log.go
+package auth
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogHandler adds the acting user to every record logged with a request context.
+type LogHandler struct {
+	slog.Handler
+}
+
+// NewLogHandler wraps next so records carry "user.id" when a principal is present.
+func NewLogHandler(next slog.Handler) *LogHandler {
+	return &LogHandler{Handler: next}
+}
+
+// Handle implements slog.Handler.
+func (h *LogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if p, ok := FromContext(ctx); ok {
+		r.AddAttrs(slog.String("user.id", p.Subject), slog.String("user.auth_method", p.Method))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LogHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler.
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	return &LogHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,81 @@
+// File: backend/internal/auth/middleware.go
+package auth
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ewag/gen-erics/backend/internal/problem"
+)
+
+// ContextKey is the Gin context key holding the *Principal.
+const ContextKey = "auth.principal"
+
+// Middleware authenticates "Authorization: Bearer <jwt>" and, when apiKeys is
+// non-nil, "Authorization: ApiKey <key>" requests. The principal is stored in
+// both the Gin context and the request context (so slog and the storage layer
+// can see who is acting). Unauthenticated requests get 401.
+func Middleware(verifier *Verifier, apiKeys *APIKeys) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		scheme, credential, _ := strings.Cut(c.GetHeader("Authorization"), " ")
+		credential = strings.TrimSpace(credential)
+		if credential == "" {
+			challenge(c, "")
+			problem.Write(c, http.StatusUnauthorized, "Missing credentials", "")
+			return
+		}
+
+		var principal *Principal
+		var err error
+		switch {
+		case strings.EqualFold(scheme, "Bearer"):
+			principal, err = verifier.Verify(ctx, credential)
+		case strings.EqualFold(scheme, "ApiKey") && apiKeys != nil:
+			principal, err = apiKeys.Verify(ctx, credential)
+		default:
+			challenge(c, "")
+			problem.Write(c, http.StatusUnauthorized, "Unsupported authorization scheme", "")
+			return
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "Rejected credentials", "scheme", scheme, "path", c.FullPath(), "error", err)
+			challenge(c, "invalid_token")
+			problem.Write(c, http.StatusUnauthorized, "Invalid or expired credentials", "")
+			return
+		}
+
+		SetPrincipal(c, principal)
+		c.Next()
+	}
+}
+
+// challenge sets WWW-Authenticate for a 401 response.
+func challenge(c *gin.Context, errorCode string) {
+	value := `Bearer realm="gen-erics"`
+	if errorCode != "" {
+		value += fmt.Sprintf(`, error="%s"`, errorCode)
+	}
+	c.Header("WWW-Authenticate", value)
+}
+
+// SetPrincipal attaches an authenticated principal to the request.
+func SetPrincipal(c *gin.Context, p *Principal) {
+	c.Set(ContextKey, p)
+	c.Request = c.Request.WithContext(WithPrincipal(c.Request.Context(), p))
+}
+
+// PrincipalFrom returns the principal set by the middleware, if any.
+func PrincipalFrom(c *gin.Context) (*Principal, bool) {
+	v, ok := c.Get(ContextKey)
+	if !ok {
+		return nil, false
+	}
+	p, ok := v.(*Principal)
+	return p, ok && p != nil
+}
@@ -0,0 +1,46 @@
+// File: backend/internal/auth/principal.go
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Subject string   `json:"subject"`        // Stable identifier ("sub" claim)
+	Name    string   `json:"name,omitempty"` // Display name, if the IdP sends one
+	Email   string   `json:"email,omitempty"`
+	Roles   []string `json:"roles,omitempty"`
+	Issuer  string   `json:"issuer,omitempty"`
+	Method  string   `json:"method"` // How the caller authenticated, e.g. "jwt"
+}
+
+// HasRole reports whether the principal carries the role.
+func (p *Principal) HasRole(role string) bool {
+	return p != nil && slices.Contains(p.Roles, role)
+}
+
+// anonymousActor is recorded when no principal is present (auth disabled, background jobs).
+const anonymousActor = "anonymous"
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the principal.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal stored in ctx, if any.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// ActorFromContext returns the subject to record in logs and history rows.
+func ActorFromContext(ctx context.Context) string {
+	if p, ok := FromContext(ctx); ok && p.Subject != "" {
+		return p.Subject
+	}
+	return anonymousActor
+}
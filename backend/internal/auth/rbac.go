@@ -0,0 +1,177 @@
+// File: backend/internal/auth/rbac.go
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ewag/gen-erics/backend/internal/problem"
+)
+
+// Permissions checked by routes. Roles are granted sets of these by the Policy.
+const (
+	PermStudiesRead      = "studies:read"      // List studies, status, history, instance lists
+	PermImagesView       = "images:view"       // Previews and tags
+	PermImagesDownload   = "images:download"   // DICOM files and ZIP archives
+	PermStudiesRecall    = "studies:recall"    // Move a study to the hot tier
+	PermStudiesMove      = "studies:move"      // Move a study to any tier
+	PermStudiesAnonymize = "studies:anonymize" // Create anonymized copies
+	PermStudiesDelete    = "studies:delete"    // Delete studies from every tier
+	PermLegalHold        = "legal:hold"        // Place and release legal holds
+	PermReidentify       = "anonymization:reidentify"
+	PermAuditRead        = "audit:read"
+	PermAdmin            = "admin" // Administrative endpoints
+
+	// PermAll in a role's list grants every permission.
+	PermAll = "*"
+)
+
+// Policy maps role names to the permissions they grant.
+type Policy struct {
+	Roles map[string][]string `json:"roles"`
+}
+
+// DefaultPolicy is used when no policy file is configured.
+func DefaultPolicy() *Policy {
+	viewer := []string{PermStudiesRead, PermImagesView}
+	return &Policy{Roles: map[string][]string{
+		"viewer":          viewer,
+		"technologist":    append(slices.Clone(viewer), PermImagesDownload, PermStudiesRecall, PermStudiesAnonymize),
+		"storage-admin":   append(slices.Clone(viewer), PermImagesDownload, PermStudiesRecall, PermStudiesMove, PermAdmin),
+		"auditor":         {PermStudiesRead, PermAuditRead},
+		"records-officer": {PermStudiesRead, PermLegalHold, PermStudiesDelete},
+		"admin":           {PermAll},
+	}}
+}
+
+// LoadPolicy reads a JSON policy file of the form {"roles": {"viewer": ["studies:read"]}}.
+func LoadPolicy(path string) (*Policy, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file %s: %w", path, err)
+	}
+	var p Policy
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file %s: %w", path, err)
+	}
+	if len(p.Roles) == 0 {
+		return nil, fmt.Errorf("policy file %s defines no roles", path)
+	}
+	return &p, nil
+}
+
+// Permissions returns the sorted, de-duplicated permissions granted by roles.
+func (p *Policy) Permissions(roles []string) []string {
+	seen := make(map[string]bool)
+	for _, role := range roles {
+		for _, perm := range p.Roles[role] {
+			seen[perm] = true
+		}
+	}
+	perms := make([]string, 0, len(seen))
+	for perm := range seen {
+		perms = append(perms, perm)
+	}
+	sort.Strings(perms)
+	return perms
+}
+
+// Allows reports whether any of roles grants perm.
+func (p *Policy) Allows(roles []string, perm string) bool {
+	for _, role := range roles {
+		for _, granted := range p.Roles[role] {
+			if granted == perm || granted == PermAll {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Authorizer enforces a Policy on routes.
+type Authorizer struct {
+	policy *Policy
+	// anonymousRoles apply to requests without a principal (auth disabled).
+	// This is also how the matrix can be exercised without an IdP.
+	anonymousRoles []string
+}
+
+// NewAuthorizer creates an Authorizer.
+func NewAuthorizer(policy *Policy, anonymousRoles []string) *Authorizer {
+	return &Authorizer{policy: policy, anonymousRoles: anonymousRoles}
+}
+
+// RolesFor returns the roles in effect for a request context.
+func (a *Authorizer) RolesFor(c *gin.Context) []string {
+	if p, ok := PrincipalFrom(c); ok {
+		return p.Roles
+	}
+	return a.anonymousRoles
+}
+
+// Can reports whether the caller holds perm.
+func (a *Authorizer) Can(c *gin.Context, perm string) bool {
+	return a.policy.Allows(a.RolesFor(c), perm)
+}
+
+// Permissions lists what the caller may do.
+func (a *Authorizer) Permissions(c *gin.Context) []string {
+	if a.policy.Allows(a.RolesFor(c), PermAll) {
+		return []string{PermAll}
+	}
+	return a.policy.Permissions(a.RolesFor(c))
+}
+
+// DefinesRole reports whether the policy knows role.
+func (a *Authorizer) DefinesRole(role string) bool {
+	_, ok := a.policy.Roles[role]
+	return ok
+}
+
+// CanDelegate reports whether the caller holds every permission the roles
+// grant, so credentials they issue can't exceed their own access.
+func (a *Authorizer) CanDelegate(c *gin.Context, roles []string) bool {
+	for _, role := range roles {
+		for _, perm := range a.policy.Roles[role] {
+			if !a.Can(c, perm) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Require returns middleware that lets the request through if the caller
+// holds any of perms, and answers 403 otherwise.
+func (a *Authorizer) Require(perms ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, perm := range perms {
+			if a.Can(c, perm) {
+				c.Next()
+				return
+			}
+		}
+		Forbid(c, perms...)
+	}
+}
+
+// Forbid aborts the request with 403 naming the missing permission(s).
+func Forbid(c *gin.Context, perms ...string) {
+	slog.WarnContext(c.Request.Context(), "Request forbidden by policy",
+		"path", c.FullPath(), "method", c.Request.Method, "required", perms, "roles", rolesForLog(c))
+	problem.WriteExtra(c, http.StatusForbidden, "Forbidden", "", gin.H{"required": perms})
+}
+
+func rolesForLog(c *gin.Context) []string {
+	if p, ok := PrincipalFrom(c); ok {
+		return p.Roles
+	}
+	return nil
+}
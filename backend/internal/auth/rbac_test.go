@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+var allPermissions = []string{
+	PermStudiesRead, PermImagesView, PermImagesDownload, PermStudiesRecall, PermStudiesMove,
+	PermStudiesAnonymize, PermStudiesDelete, PermLegalHold, PermReidentify, PermAuditRead, PermAdmin,
+}
+
+func TestDefaultPolicyMatrix(t *testing.T) {
+	policy := DefaultPolicy()
+	tests := []struct {
+		roles []string
+		want  []string // Every permission not listed must be refused
+	}{
+		{roles: []string{"viewer"}, want: []string{PermStudiesRead, PermImagesView}},
+		{roles: []string{"technologist"}, want: []string{PermStudiesRead, PermImagesView, PermImagesDownload,
+			PermStudiesRecall, PermStudiesAnonymize}},
+		{roles: []string{"storage-admin"}, want: []string{PermStudiesRead, PermImagesView, PermImagesDownload,
+			PermStudiesRecall, PermStudiesMove, PermAdmin}},
+		{roles: []string{"auditor"}, want: []string{PermStudiesRead, PermAuditRead}},
+		{roles: []string{"records-officer"}, want: []string{PermStudiesRead, PermLegalHold, PermStudiesDelete}},
+		{roles: []string{"admin"}, want: allPermissions},
+		{roles: []string{"viewer", "auditor"}, want: []string{PermStudiesRead, PermImagesView, PermAuditRead}},
+		{roles: []string{"unknown"}},
+		{roles: nil},
+	}
+	for _, tt := range tests {
+		t.Run(fmtRoles(tt.roles), func(t *testing.T) {
+			for _, perm := range allPermissions {
+				if got, want := policy.Allows(tt.roles, perm), slices.Contains(tt.want, perm); got != want {
+					t.Errorf("Allows(%s) = %v, want %v", perm, got, want)
+				}
+			}
+		})
+	}
+}
+
+func fmtRoles(roles []string) string {
+	if len(roles) == 0 {
+		return "none"
+	}
+	return strings.Join(roles, "+")
+}
+
+func TestPolicyPermissions(t *testing.T) {
+	policy := DefaultPolicy()
+	got := policy.Permissions([]string{"technologist", "viewer"})
+	want := []string{PermImagesDownload, PermImagesView, PermStudiesAnonymize, PermStudiesRead, PermStudiesRecall}
+	if !slices.Equal(got, want) {
+		t.Errorf("Permissions() = %v, want %v", got, want)
+	}
+}
+
+func TestAuthorizerRequire(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name           string
+		principal      *Principal // nil: anonymous
+		anonymousRoles []string
+		perms          []string
+		wantStatus     int
+	}{
+		{name: "role grants it", principal: &Principal{Roles: []string{"viewer"}}, perms: []string{PermImagesView},
+			wantStatus: http.StatusOK},
+		{name: "role lacks it", principal: &Principal{Roles: []string{"viewer"}}, perms: []string{PermImagesDownload},
+			wantStatus: http.StatusForbidden},
+		{name: "any of several", principal: &Principal{Roles: []string{"auditor"}}, perms: []string{PermAdmin, PermAuditRead},
+			wantStatus: http.StatusOK},
+		{name: "principal without roles", principal: &Principal{}, anonymousRoles: []string{"admin"}, perms: []string{PermStudiesRead},
+			wantStatus: http.StatusForbidden},
+		{name: "anonymous viewer", anonymousRoles: []string{"viewer"}, perms: []string{PermStudiesRead}, wantStatus: http.StatusOK},
+		{name: "anonymous viewer moving", anonymousRoles: []string{"viewer"}, perms: []string{PermStudiesMove},
+			wantStatus: http.StatusForbidden},
+		{name: "anonymous without roles", perms: []string{PermStudiesRead}, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAuthorizer(DefaultPolicy(), tt.anonymousRoles)
+			router := gin.New()
+			router.GET("/", func(c *gin.Context) {
+				if tt.principal != nil {
+					SetPrincipal(c, tt.principal)
+				}
+			}, a.Require(tt.perms...), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestAuthorizerCanDelegate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		callerRoles []string
+		roles       []string
+		want        bool
+	}{
+		{callerRoles: []string{"storage-admin"}, roles: []string{"viewer"}, want: true},
+		{callerRoles: []string{"storage-admin"}, roles: []string{"storage-admin"}, want: true},
+		{callerRoles: []string{"storage-admin"}, roles: []string{"technologist"}}, // Can't grant anonymize
+		{callerRoles: []string{"storage-admin"}, roles: []string{"admin"}},
+		{callerRoles: []string{"admin"}, roles: []string{"admin", "auditor"}, want: true},
+		{callerRoles: []string{"viewer"}, roles: nil, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(fmtRoles(tt.callerRoles)+"/"+fmtRoles(tt.roles), func(t *testing.T) {
+			a := NewAuthorizer(DefaultPolicy(), nil)
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/api-keys", nil)
+			SetPrincipal(c, &Principal{Roles: tt.callerRoles})
+			if got := a.CanDelegate(c, tt.roles); got != tt.want {
+				t.Errorf("CanDelegate(%v) = %v, want %v", tt.roles, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,10 +2,11 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
-    // "fmt"
 )
 
 // Config holds application configuration.
@@ -23,6 +24,86 @@ type Config struct {
      DBUser            string // e.g., DB_USER -> pacsuser
      DBPassword        string // e.g., DB_PASSWORD -> localdevpassword
      DBName            string // e.g., DB_NAME -> pacs_status
+     // --- TIER STORAGE / INTEGRITY FIELDS ---
+     ColdStoragePath     string        // e.g., COLD_STORAGE_PATH -> /data/cold
+     ArchiveStoragePath  string        // e.g., ARCHIVE_STORAGE_PATH -> /data/archive
+     TrashStoragePath    string        // e.g., TRASH_STORAGE_PATH -> /data/trash
+     TrashGracePeriod    time.Duration // e.g., TRASH_RETENTION_DAYS -> 30
+     TrashPurgeInterval  time.Duration // e.g., TRASH_PURGE_INTERVAL_MINUTES -> 60
+     ScrubEnabled        bool          // e.g., SCRUB_ENABLED -> true
+     ScrubInterval       time.Duration // e.g., SCRUB_INTERVAL_SECONDS -> 3600
+     ScrubMinAge         time.Duration // e.g., SCRUB_MIN_AGE_HOURS -> 168 (re-verify weekly)
+     ScrubBatchSize      int           // e.g., SCRUB_BATCH_SIZE -> 100
+     ScrubBytesPerSecond int64         // e.g., SCRUB_MAX_BYTES_PER_SECOND -> 10485760
+     TierCompression     []string      // e.g., TIER_COMPRESSION -> cold:CT|MR:jpeg-ls,archive:*:jpeg2000 (empty stores as is)
+     EncryptionKeyring   string        // e.g., ENCRYPTION_KEYRING_FILE -> /etc/gen-erics/keyring.json (empty disables encryption)
+     EncryptedTiers      []string      // e.g., ENCRYPTED_TIERS -> cold,archive,trash
+     EncryptionAllowPlaintext bool     // e.g., ENCRYPTION_ALLOW_PLAINTEXT -> false (read unencrypted objects in encrypted tiers while migrating)
+     RetentionYears      int           // e.g., RETENTION_YEARS -> 0 (never expire studies)
+     RetentionMajorityAge  int         // e.g., RETENTION_MAJORITY_AGE -> 18
+     RetentionPediatricAge int         // e.g., RETENTION_PEDIATRIC_AGE -> 21 (pediatric studies kept until this age + RETENTION_YEARS)
+     RetentionEnforce    bool          // e.g., RETENTION_ENFORCE -> false (only report expired studies)
+     RetentionInterval   time.Duration // e.g., RETENTION_SWEEP_INTERVAL_HOURS -> 24
+     RetentionMaxDeletes int           // e.g., RETENTION_MAX_DELETES_PER_SWEEP -> 100
+     // --- JOB QUEUE / PREFETCH FIELDS ---
+     JobWorkers          int           // e.g., JOB_WORKERS -> 2
+     JobPollInterval     time.Duration // e.g., JOB_POLL_INTERVAL_SECONDS -> 5
+     JobWorkerID         string        // e.g., JOB_WORKER_ID -> hostname-pid (unique per replica)
+     JobLease            time.Duration // e.g., JOB_LEASE_SECONDS -> 60 (running jobs without a heartbeat for this long are requeued)
+     JobConcurrencyLimits []string     // e.g., JOB_CONCURRENCY_LIMITS -> edge:*:2,tier:archive:1 (kind:name:max running jobs, across replicas)
+     MoveBatchMaxStudies int           // e.g., MOVE_BATCH_MAX_STUDIES -> 50000 (larger bulk moves are refused)
+     JobMaxAttempts      int           // e.g., JOB_MAX_ATTEMPTS -> 5 (runs of a job before it is dead-lettered)
+     JobRetryBaseDelay   time.Duration // e.g., JOB_RETRY_BASE_SECONDS -> 30 (doubled after each failed run, with jitter)
+     JobRetryMaxDelay    time.Duration // e.g., JOB_RETRY_MAX_SECONDS -> 3600
+     MoverStepAttempts   int           // e.g., MOVER_STEP_ATTEMPTS -> 3 (tries of each fetch/write/verify/delete step; Orthanc errors are only retried by the Orthanc client and the job)
+     MoverStepBaseDelay  time.Duration // e.g., MOVER_STEP_BASE_DELAY_MS -> 500
+     MoverStepMaxDelay   time.Duration // e.g., MOVER_STEP_MAX_DELAY_MS -> 10000
+     // --- ORTHANC CLIENT RESILIENCE FIELDS (per-call timeout is HTTP_CLIENT_TIMEOUT_SECONDS) ---
+     OrthancRetryAttempts   int           // e.g., ORTHANC_RETRY_ATTEMPTS -> 3 (tries of each GET failing with a network error or 502/503/504)
+     OrthancRetryBaseDelay  time.Duration // e.g., ORTHANC_RETRY_BASE_DELAY_MS -> 200
+     OrthancRetryMaxDelay   time.Duration // e.g., ORTHANC_RETRY_MAX_DELAY_MS -> 2000
+     OrthancBreakerThreshold int          // e.g., ORTHANC_BREAKER_THRESHOLD -> 5 (consecutive failures that open the circuit; 0 disables it)
+     OrthancBreakerCooldown time.Duration // e.g., ORTHANC_BREAKER_COOLDOWN_SECONDS -> 30
+     MaintenanceWindows  []string      // e.g., MAINTENANCE_WINDOWS -> edge:edge-a:Mon-Fri:1900-0700,tier:archive:*:2200-0600
+     MaintenanceTimezone string        // e.g., MAINTENANCE_TIMEZONE -> Europe/Zurich (Local if empty)
+     EdgeBandwidthLimits []string      // e.g., EDGE_BANDWIDTH_LIMITS -> edge-a:5242880,*:20971520 (bytes/sec; empty is unlimited)
+     PrefetchEnabled     bool          // e.g., PREFETCH_ENABLED -> false
+     PrefetchRules       []string      // e.g., PREFETCH_RULES -> MR:MR|CT:5:bodypart,*:same:3 (modality:priors:years[:bodypart])
+     PrefetchMaxPriors   int           // e.g., PREFETCH_MAX_PRIORS -> 3
+     PrefetchDefaultEdge string        // e.g., PREFETCH_DEFAULT_EDGE -> edge-1 (edge for new studies with no recorded location)
+     PrefetchPollInterval time.Duration // e.g., PREFETCH_POLL_INTERVAL_SECONDS -> 10
+     // --- HL7 FIELDS ---
+     HL7Enabled          bool          // e.g., HL7_ENABLED -> false
+     HL7ListenAddress    string        // e.g., HL7_LISTEN_ADDRESS -> :2575
+     HL7Application      string        // e.g., HL7_SENDING_APPLICATION -> GEN-ERICS (MSH-3 of our ACKs)
+     HL7Facility         string        // e.g., HL7_SENDING_FACILITY -> hospital-a
+     HL7LocationEdges    []string      // e.g., HL7_LOCATION_EDGES -> CT1:edge-a,MR2:edge-b (PV1-3 -> edge for prefetch)
+     // --- AUTH FIELDS ---
+     AuthEnabled        bool          // e.g., AUTH_ENABLED -> true
+     AuthIssuer         string        // e.g., AUTH_ISSUER -> https://keycloak.example/realms/pacs
+     AuthAudience       string        // e.g., AUTH_AUDIENCE -> gen-erics-api
+     AuthJWKSURL        string        // e.g., AUTH_JWKS_URL (optional, discovered from issuer if empty)
+     AuthJWKSFile       string        // e.g., AUTH_JWKS_FILE -> /etc/gen-erics/jwks.json (static keys, overrides URL)
+     AuthJWKSCacheTTL   time.Duration // e.g., AUTH_JWKS_CACHE_SECONDS -> 3600
+     AuthRolesClaim     string        // e.g., AUTH_ROLES_CLAIM -> realm_access.roles
+     AuthPolicyFile     string        // e.g., AUTH_POLICY_FILE -> /etc/gen-erics/policy.json (role -> permissions)
+     AuthAnonymousRoles []string      // e.g., AUTH_ANONYMOUS_ROLES -> viewer (roles granted when AUTH_ENABLED is false)
+     CORSAllowedOrigins []string      // e.g., CORS_ALLOWED_ORIGINS -> https://pacs.example,https://viewer.example (empty allows no cross-origin requests)
+     AuditSourceID      string        // e.g., AUDIT_SOURCE_ID -> gen-erics-prod
+     AuditSiteID        string        // e.g., AUDIT_ENTERPRISE_SITE_ID -> hospital-a
+     AuditSyslogAddr    string        // e.g., AUDIT_SYSLOG_ADDR -> audit-collector:6514 (empty disables forwarding)
+     AuditSyslogTLS     bool          // e.g., AUDIT_SYSLOG_TLS -> true
+     AuditSyslogCAFile  string        // e.g., AUDIT_SYSLOG_CA_FILE -> /etc/gen-erics/audit-ca.pem
+     AuditSyslogCert    string        // e.g., AUDIT_SYSLOG_CERT_FILE -> client certificate for mutual TLS
+     AuditSyslogKey     string        // e.g., AUDIT_SYSLOG_KEY_FILE
+     // --- THUMBNAIL FIELDS ---
+     ThumbnailCacheDir    string        // e.g., THUMBNAIL_CACHE_DIR -> /data/thumbnails (empty disables caching)
+     ThumbnailCacheMaxMB  int64         // e.g., THUMBNAIL_CACHE_MAX_MB -> 512
+     ThumbnailSize        int           // e.g., THUMBNAIL_SIZE -> 256 (longest edge in pixels)
+     ThumbnailQuality     int           // e.g., THUMBNAIL_QUALITY -> 80
+     ThumbnailVersionTTL  time.Duration // e.g., THUMBNAIL_VERSION_TTL_SECONDS -> 60 (also the client max-age)
+     // --- TRANSCODING FIELDS ---
+     TranscodeSyntaxes    []string      // e.g., TRANSCODE_TRANSFER_SYNTAXES -> syntaxes Orthanc's built-in codecs write (add JPEG 2000 with the GDCM plugin)
 
 }
 
@@ -58,6 +139,114 @@ func Load() (*Config, error) {
     debugStr := GetEnv("DEBUG", "false")
     cfg.Debug, _ = strconv.ParseBool(debugStr) // Ignore error, default to false
 
+    // Tier backends and background integrity scrubbing
+    cfg.ColdStoragePath = GetEnv("COLD_STORAGE_PATH", "/data/cold")
+    cfg.ArchiveStoragePath = GetEnv("ARCHIVE_STORAGE_PATH", "/data/archive")
+    cfg.TrashStoragePath = GetEnv("TRASH_STORAGE_PATH", "/data/trash")
+    cfg.TrashGracePeriod = time.Duration(GetEnvInt("TRASH_RETENTION_DAYS", 30)) * 24 * time.Hour
+    cfg.TrashPurgeInterval = time.Duration(GetEnvInt("TRASH_PURGE_INTERVAL_MINUTES", 60)) * time.Minute
+    cfg.ScrubEnabled = GetEnvBool("SCRUB_ENABLED", true)
+    cfg.ScrubInterval = time.Duration(GetEnvInt("SCRUB_INTERVAL_SECONDS", 3600)) * time.Second
+    cfg.ScrubMinAge = time.Duration(GetEnvInt("SCRUB_MIN_AGE_HOURS", 168)) * time.Hour
+    cfg.ScrubBatchSize = GetEnvInt("SCRUB_BATCH_SIZE", 100)
+    cfg.ScrubBytesPerSecond = int64(GetEnvInt("SCRUB_MAX_BYTES_PER_SECOND", 10*1024*1024))
+    // Lossless re-encoding of uncompressed instances when they leave Orthanc.
+    // The internal renderer only decodes RLE, so rendering JPEG-LS or JPEG 2000
+    // objects recalls their study first; recalls restore the original syntax.
+    cfg.TierCompression = GetEnvList("TIER_COMPRESSION", nil)
+    // Envelope encryption of tier objects with per-study data keys
+    cfg.EncryptionKeyring = GetEnv("ENCRYPTION_KEYRING_FILE", "")
+    cfg.EncryptedTiers = GetEnvList("ENCRYPTED_TIERS", []string{"cold", "archive", "trash"})
+    cfg.EncryptionAllowPlaintext = GetEnvBool("ENCRYPTION_ALLOW_PLAINTEXT", false)
+    // Retention schedule and policy-driven deletion
+    cfg.RetentionYears = GetEnvInt("RETENTION_YEARS", 0)
+    cfg.RetentionMajorityAge = GetEnvInt("RETENTION_MAJORITY_AGE", 18)
+    cfg.RetentionPediatricAge = GetEnvInt("RETENTION_PEDIATRIC_AGE", 21)
+    cfg.RetentionEnforce = GetEnvBool("RETENTION_ENFORCE", false)
+    cfg.RetentionInterval = time.Duration(GetEnvInt("RETENTION_SWEEP_INTERVAL_HOURS", 24)) * time.Hour
+    cfg.RetentionMaxDeletes = GetEnvInt("RETENTION_MAX_DELETES_PER_SWEEP", 100)
+
+    // Background job queue and prior-study prefetch
+    cfg.JobWorkers = GetEnvInt("JOB_WORKERS", 2)
+    cfg.JobPollInterval = time.Duration(GetEnvInt("JOB_POLL_INTERVAL_SECONDS", 5)) * time.Second
+    cfg.JobWorkerID = GetEnv("JOB_WORKER_ID", "")
+    cfg.JobLease = time.Duration(GetEnvInt("JOB_LEASE_SECONDS", 60)) * time.Second
+    cfg.JobConcurrencyLimits = GetEnvList("JOB_CONCURRENCY_LIMITS", nil)
+    cfg.MoveBatchMaxStudies = GetEnvInt("MOVE_BATCH_MAX_STUDIES", 50000)
+    cfg.JobMaxAttempts = GetEnvInt("JOB_MAX_ATTEMPTS", 5)
+    cfg.JobRetryBaseDelay = time.Duration(GetEnvInt("JOB_RETRY_BASE_SECONDS", 30)) * time.Second
+    cfg.JobRetryMaxDelay = time.Duration(GetEnvInt("JOB_RETRY_MAX_SECONDS", 3600)) * time.Second
+    cfg.MoverStepAttempts = GetEnvInt("MOVER_STEP_ATTEMPTS", 3)
+    cfg.MoverStepBaseDelay = time.Duration(GetEnvInt("MOVER_STEP_BASE_DELAY_MS", 500)) * time.Millisecond
+    cfg.MoverStepMaxDelay = time.Duration(GetEnvInt("MOVER_STEP_MAX_DELAY_MS", 10000)) * time.Millisecond
+    cfg.OrthancRetryAttempts = GetEnvInt("ORTHANC_RETRY_ATTEMPTS", 3)
+    cfg.OrthancRetryBaseDelay = time.Duration(GetEnvInt("ORTHANC_RETRY_BASE_DELAY_MS", 200)) * time.Millisecond
+    cfg.OrthancRetryMaxDelay = time.Duration(GetEnvInt("ORTHANC_RETRY_MAX_DELAY_MS", 2000)) * time.Millisecond
+    cfg.OrthancBreakerThreshold = GetEnvInt("ORTHANC_BREAKER_THRESHOLD", 5)
+    cfg.OrthancBreakerCooldown = time.Duration(GetEnvInt("ORTHANC_BREAKER_COOLDOWN_SECONDS", 30)) * time.Second
+    cfg.MaintenanceWindows = GetEnvList("MAINTENANCE_WINDOWS", nil)
+    cfg.MaintenanceTimezone = GetEnv("MAINTENANCE_TIMEZONE", "")
+    cfg.EdgeBandwidthLimits = GetEnvList("EDGE_BANDWIDTH_LIMITS", nil)
+    cfg.PrefetchEnabled = GetEnvBool("PREFETCH_ENABLED", false)
+    cfg.PrefetchRules = GetEnvList("PREFETCH_RULES", []string{"*:same:3:bodypart"})
+    cfg.PrefetchMaxPriors = GetEnvInt("PREFETCH_MAX_PRIORS", 3)
+    cfg.PrefetchDefaultEdge = GetEnv("PREFETCH_DEFAULT_EDGE", "")
+    cfg.PrefetchPollInterval = time.Duration(GetEnvInt("PREFETCH_POLL_INTERVAL_SECONDS", 10)) * time.Second
+
+    // HL7 v2 over MLLP from the RIS (orders drive prefetch, ADT updates patients)
+    cfg.HL7Enabled = GetEnvBool("HL7_ENABLED", false)
+    cfg.HL7ListenAddress = GetEnv("HL7_LISTEN_ADDRESS", ":2575")
+    cfg.HL7Application = GetEnv("HL7_SENDING_APPLICATION", "GEN-ERICS")
+    cfg.HL7Facility = GetEnv("HL7_SENDING_FACILITY", "")
+    cfg.HL7LocationEdges = GetEnvList("HL7_LOCATION_EDGES", nil)
+
+    // Authentication (on by default: the API serves PHI; local setups without
+    // an IdP set AUTH_ENABLED=false explicitly)
+    cfg.AuthEnabled = GetEnvBool("AUTH_ENABLED", true)
+    cfg.AuthIssuer = GetEnv("AUTH_ISSUER", "")
+    cfg.AuthAudience = GetEnv("AUTH_AUDIENCE", "")
+    cfg.AuthJWKSURL = GetEnv("AUTH_JWKS_URL", "")
+    cfg.AuthJWKSFile = GetEnv("AUTH_JWKS_FILE", "")
+    cfg.AuthJWKSCacheTTL = time.Duration(GetEnvInt("AUTH_JWKS_CACHE_SECONDS", 3600)) * time.Second
+    cfg.AuthRolesClaim = GetEnv("AUTH_ROLES_CLAIM", "roles")
+    cfg.AuthPolicyFile = GetEnv("AUTH_POLICY_FILE", "")
+    cfg.AuthAnonymousRoles = GetEnvList("AUTH_ANONYMOUS_ROLES", []string{"viewer"})
+    // No cross-origin access unless origins are listed
+    cfg.CORSAllowedOrigins = GetEnvList("CORS_ALLOWED_ORIGINS", nil)
+
+    // Audit trail (always persisted; syslog forwarding is optional)
+    cfg.AuditSourceID = GetEnv("AUDIT_SOURCE_ID", "gen-erics")
+    cfg.AuditSiteID = GetEnv("AUDIT_ENTERPRISE_SITE_ID", "")
+    cfg.AuditSyslogAddr = GetEnv("AUDIT_SYSLOG_ADDR", "")
+    cfg.AuditSyslogTLS = GetEnvBool("AUDIT_SYSLOG_TLS", true)
+    cfg.AuditSyslogCAFile = GetEnv("AUDIT_SYSLOG_CA_FILE", "")
+    cfg.AuditSyslogCert = GetEnv("AUDIT_SYSLOG_CERT_FILE", "")
+    cfg.AuditSyslogKey = GetEnv("AUDIT_SYSLOG_KEY_FILE", "")
+
+    // Thumbnails for study and series lists
+    cfg.ThumbnailCacheDir = GetEnv("THUMBNAIL_CACHE_DIR", "/data/thumbnails")
+    cfg.ThumbnailCacheMaxMB = int64(GetEnvInt("THUMBNAIL_CACHE_MAX_MB", 512))
+    cfg.ThumbnailSize = GetEnvInt("THUMBNAIL_SIZE", 256)
+    cfg.ThumbnailQuality = GetEnvInt("THUMBNAIL_QUALITY", 80)
+    cfg.ThumbnailVersionTTL = time.Duration(GetEnvInt("THUMBNAIL_VERSION_TTL_SECONDS", 60)) * time.Second
+
+    // Transfer syntaxes downloads may be transcoded to
+    cfg.TranscodeSyntaxes = GetEnvList("TRANSCODE_TRANSFER_SYNTAXES", []string{
+        "1.2.840.10008.1.2",      // Implicit VR Little Endian
+        "1.2.840.10008.1.2.1",    // Explicit VR Little Endian
+        "1.2.840.10008.1.2.4.50", // JPEG Baseline
+        "1.2.840.10008.1.2.4.51", // JPEG Extended
+        "1.2.840.10008.1.2.4.57", // JPEG Lossless
+        "1.2.840.10008.1.2.4.70", // JPEG Lossless SV1
+        "1.2.840.10008.1.2.4.80", // JPEG-LS Lossless
+        "1.2.840.10008.1.2.4.81", // JPEG-LS Near-Lossless
+        "1.2.840.10008.1.2.5",    // RLE Lossless
+    })
+
+    if cfg.AuthEnabled && cfg.AuthIssuer == "" {
+        return nil, fmt.Errorf("AUTH_ISSUER is required when AUTH_ENABLED is true")
+    }
+
     return cfg, nil // Assuming no other fatal errors during load
 }
 
@@ -68,4 +257,37 @@ func GetEnv(key, fallback string) string {
         return value
     }
     return fallback
-}
\ No newline at end of file
+}
+
+// GetEnvInt retrieves an integer environment variable, falling back on a missing or invalid value.
+func GetEnvInt(key string, fallback int) int {
+    value, err := strconv.Atoi(GetEnv(key, ""))
+    if err != nil {
+        return fallback
+    }
+    return value
+}
+
+// GetEnvBool retrieves a boolean environment variable, falling back on a missing or invalid value.
+func GetEnvBool(key string, fallback bool) bool {
+    value, err := strconv.ParseBool(GetEnv(key, ""))
+    if err != nil {
+        return fallback
+    }
+    return value
+}
+
+// GetEnvList retrieves a comma separated environment variable as a slice.
+func GetEnvList(key string, fallback []string) []string {
+    value := GetEnv(key, "")
+    if value == "" {
+        return fallback
+    }
+    var items []string
+    for _, item := range strings.Split(value, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            items = append(items, item)
+        }
+    }
+    return items
+}
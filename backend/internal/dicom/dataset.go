@@ -0,0 +1,211 @@
+// File: backend/internal/dicom/dataset.go
+package dicom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Tag is a DICOM (group, element) pair packed as 0xGGGGEEEE.
+type Tag uint32
+
+// NewTag builds a Tag from its group and element.
+func NewTag(group, element uint16) Tag {
+	return Tag(uint32(group)<<16 | uint32(element))
+}
+
+// Group returns the tag's group number.
+func (t Tag) Group() uint16 { return uint16(t >> 16) }
+
+// String formats the tag as (GGGG,EEEE).
+func (t Tag) String() string {
+	return fmt.Sprintf("(%04X,%04X)", uint16(t>>16), uint16(t))
+}
+
+// Tags used by this package and its callers.
+const (
+	TagTransferSyntaxUID          Tag = 0x00020010
+	TagSOPClassUID                Tag = 0x00080016
+	TagSOPInstanceUID             Tag = 0x00080018
+	TagModality                   Tag = 0x00080060
+	TagSamplesPerPixel            Tag = 0x00280002
+	TagPhotometricInterpretation  Tag = 0x00280004
+	TagPlanarConfiguration        Tag = 0x00280006
+	TagNumberOfFrames             Tag = 0x00280008
+	TagRows                       Tag = 0x00280010
+	TagColumns                    Tag = 0x00280011
+	TagBitsAllocated              Tag = 0x00280100
+	TagBitsStored                 Tag = 0x00280101
+	TagHighBit                    Tag = 0x00280102
+	TagPixelRepresentation        Tag = 0x00280103
+	TagWindowCenter               Tag = 0x00281050
+	TagWindowWidth                Tag = 0x00281051
+	TagRescaleIntercept           Tag = 0x00281052
+	TagRescaleSlope               Tag = 0x00281053
+	TagWindowExplanation          Tag = 0x00281055
+	TagFrameVOILUTSequence        Tag = 0x00289132
+	TagPixelValueTransformationSq Tag = 0x00289145
+	TagSharedFunctionalGroups     Tag = 0x52009229
+	TagPerFrameFunctionalGroups   Tag = 0x52009230
+	TagPixelData                  Tag = 0x7FE00010
+
+	tagItem              Tag = 0xFFFEE000
+	tagItemDelimitation  Tag = 0xFFFEE00D
+	tagSequenceDelimiter Tag = 0xFFFEE0DD
+)
+
+// Element is one data element. Exactly one of Value, Items or Fragments is
+// meaningful: Items for sequences, Fragments for encapsulated pixel data.
+type Element struct {
+	Tag       Tag
+	VR        string // Empty for implicit VR elements not in the small built-in dictionary
+	Value     []byte
+	Items     []*Dataset
+	Fragments [][]byte // Encapsulated pixel data, excluding the Basic Offset Table
+	Offsets   []uint32 // Basic Offset Table, if present
+}
+
+// Dataset is a parsed DICOM data set.
+type Dataset struct {
+	TransferSyntax string // From the file meta information
+	Elements       map[Tag]*Element
+}
+
+func newDataset() *Dataset {
+	return &Dataset{Elements: make(map[Tag]*Element)}
+}
+
+// Get returns the element for tag.
+func (d *Dataset) Get(tag Tag) (*Element, bool) {
+	e, ok := d.Elements[tag]
+	return e, ok
+}
+
+// String returns the trimmed string value of tag, or "".
+func (d *Dataset) String(tag Tag) string {
+	e, ok := d.Elements[tag]
+	if !ok {
+		return ""
+	}
+	return strings.TrimRight(string(e.Value), " \x00")
+}
+
+// Strings returns the backslash-separated values of tag.
+func (d *Dataset) Strings(tag Tag) []string {
+	s := d.String(tag)
+	if s == "" {
+		return nil
+	}
+	parts := strings.Split(s, `\`)
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
+
+// Int returns the first integer value of a US, SS, UL, SL or IS element.
+func (d *Dataset) Int(tag Tag) (int, bool) {
+	e, ok := d.Elements[tag]
+	if !ok || len(e.Value) == 0 {
+		return 0, false
+	}
+	switch e.VR {
+	case "US":
+		if len(e.Value) >= 2 {
+			return int(binary.LittleEndian.Uint16(e.Value)), true
+		}
+	case "SS":
+		if len(e.Value) >= 2 {
+			return int(int16(binary.LittleEndian.Uint16(e.Value))), true
+		}
+	case "UL":
+		if len(e.Value) >= 4 {
+			return int(binary.LittleEndian.Uint32(e.Value)), true
+		}
+	case "SL":
+		if len(e.Value) >= 4 {
+			return int(int32(binary.LittleEndian.Uint32(e.Value))), true
+		}
+	default: // IS
+		values := d.Strings(tag)
+		if len(values) > 0 {
+			n, err := strconv.Atoi(values[0])
+			return n, err == nil
+		}
+	}
+	return 0, false
+}
+
+// Floats returns the values of a DS (or FD/FL) element.
+func (d *Dataset) Floats(tag Tag) []float64 {
+	e, ok := d.Elements[tag]
+	if !ok {
+		return nil
+	}
+	switch e.VR {
+	case "FD":
+		var out []float64
+		for i := 0; i+8 <= len(e.Value); i += 8 {
+			out = append(out, math.Float64frombits(binary.LittleEndian.Uint64(e.Value[i:])))
+		}
+		return out
+	case "FL":
+		var out []float64
+		for i := 0; i+4 <= len(e.Value); i += 4 {
+			out = append(out, float64(math.Float32frombits(binary.LittleEndian.Uint32(e.Value[i:]))))
+		}
+		return out
+	}
+	var out []float64
+	for _, s := range d.Strings(tag) {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Float returns the first value of a numeric string element.
+func (d *Dataset) Float(tag Tag) (float64, bool) {
+	values := d.Floats(tag)
+	if len(values) == 0 {
+		return 0, false
+	}
+	return values[0], true
+}
+
+// Sequence returns the items of a sequence element.
+func (d *Dataset) Sequence(tag Tag) []*Dataset {
+	if e, ok := d.Elements[tag]; ok {
+		return e.Items
+	}
+	return nil
+}
+
+// implicitVRs gives the VR of tags this package reads when the transfer
+// syntax is implicit VR. Everything else is kept as raw bytes.
+var implicitVRs = map[Tag]string{
+	TagSamplesPerPixel:            "US",
+	TagPhotometricInterpretation:  "CS",
+	TagPlanarConfiguration:        "US",
+	TagNumberOfFrames:             "IS",
+	TagRows:                       "US",
+	TagColumns:                    "US",
+	TagBitsAllocated:              "US",
+	TagBitsStored:                 "US",
+	TagHighBit:                    "US",
+	TagPixelRepresentation:        "US",
+	TagWindowCenter:               "DS",
+	TagWindowWidth:                "DS",
+	TagRescaleIntercept:           "DS",
+	TagRescaleSlope:               "DS",
+	TagWindowExplanation:          "LO",
+	TagFrameVOILUTSequence:        "SQ",
+	TagPixelValueTransformationSq: "SQ",
+	TagSharedFunctionalGroups:     "SQ",
+	TagPerFrameFunctionalGroups:   "SQ",
+	TagPixelData:                  "OW",
+}
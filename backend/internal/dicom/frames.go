@@ -0,0 +1,222 @@
+// File: backend/internal/dicom/frames.go
+package dicom
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// FrameMediaType returns the media type of a frame's stored bytes in a
+// transfer syntax, following the DICOMweb (PS3.18) conventions.
+func FrameMediaType(transferSyntax string) string {
+	switch transferSyntax {
+	case JPEGBaseline, JPEGExtended, JPEGLossless, JPEGLosslessSV1:
+		return "image/jpeg"
+	case JPEGLSLossless, JPEGLSNearLossless:
+		return "image/jls"
+	case JPEG2000Lossless, JPEG2000:
+		return "image/jp2"
+	case RLELossless:
+		return "image/x-dicom-rle"
+	}
+	return "application/octet-stream"
+}
+
+// FrameReader reads the frames of a Part 10 stream one at a time, so long
+// cine loops can be served as they are read instead of after the whole
+// object has been loaded.
+type FrameReader struct {
+	Dataset *Dataset // Every element before Pixel Data
+	Info    ImageInfo
+
+	d         *decoder
+	next      int   // Index of the next frame
+	frameSize int   // Native only
+	remaining int64 // Native only: bytes left in the Pixel Data value
+
+	// Encapsulated only
+	encapsulated bool
+	offsets      []uint32 // Basic Offset Table
+	pos          uint32   // Offset of the next fragment item from the first one
+	pending      []byte   // Fragment read ahead that starts the next frame
+	pendingPos   uint32   // Its offset, for the offset table comparison
+	ended        bool     // Sequence delimiter reached
+}
+
+// NewFrameReader parses everything up to Pixel Data and prepares to read frames.
+func NewFrameReader(r io.Reader) (*FrameReader, error) {
+	ds, d, err := parse(r, ParseOptions{SkipPixelData: true})
+	if err != nil {
+		return nil, err
+	}
+	if !d.stopped {
+		return nil, ErrNoPixelData
+	}
+	info, err := ds.ImageInfo()
+	if err != nil {
+		return nil, err
+	}
+	_, length, err := d.readVRLength(TagPixelData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pixel data header: %w", err)
+	}
+
+	f := &FrameReader{Dataset: ds, Info: info, d: d}
+	if length != undefinedLength {
+		if info.BitsAllocated%8 != 0 {
+			return nil, fmt.Errorf("%w: %d bits allocated", ErrUnsupportedTransferSyntax, info.BitsAllocated)
+		}
+		f.frameSize = info.Rows * info.Columns * info.SamplesPerPixel * info.BitsAllocated / 8
+		if f.frameSize <= 0 {
+			// Frames of no bytes would let a corrupt frame count stream empty frames forever
+			return nil, fmt.Errorf("invalid image size %dx%d with %d samples", info.Rows, info.Columns, info.SamplesPerPixel)
+		}
+		f.remaining = int64(length)
+		return f, nil
+	}
+
+	f.encapsulated = true
+	bot, ok, err := f.readFragment()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read basic offset table: %w", err)
+	}
+	if !ok {
+		f.ended = true
+	}
+	for i := 0; i+4 <= len(bot); i += 4 {
+		f.offsets = append(f.offsets, uint32(bot[i])|uint32(bot[i+1])<<8|uint32(bot[i+2])<<16|uint32(bot[i+3])<<24)
+	}
+	f.pos = 0 // Offsets count from the first fragment item after the table
+	return f, nil
+}
+
+// Next returns the stored bytes of the next frame (raw samples or the
+// compressed bitstream), or io.EOF after the last frame.
+func (f *FrameReader) Next() ([]byte, error) {
+	if f.next >= f.Info.NumberOfFrames {
+		return nil, io.EOF
+	}
+	var data []byte
+	var err error
+	if f.encapsulated {
+		data, err = f.nextEncapsulated()
+	} else {
+		if int64(f.frameSize) > f.remaining {
+			return nil, fmt.Errorf("pixel data too short for frame %d", f.next+1)
+		}
+		data, err = f.d.read(uint32(f.frameSize))
+		f.remaining -= int64(f.frameSize)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read frame %d: %w", f.next+1, err)
+	}
+	f.next++
+	return data, nil
+}
+
+// Skip advances past the next frame without keeping it.
+func (f *FrameReader) Skip() error {
+	if f.encapsulated || f.next >= f.Info.NumberOfFrames {
+		_, err := f.Next()
+		return err
+	}
+	if int64(f.frameSize) > f.remaining {
+		return fmt.Errorf("pixel data too short for frame %d", f.next+1)
+	}
+	n, err := io.CopyN(io.Discard, f.d.r, int64(f.frameSize))
+	f.d.pos += n
+	if err != nil {
+		return fmt.Errorf("failed to skip frame %d: %w", f.next+1, err)
+	}
+	f.remaining -= int64(f.frameSize)
+	f.next++
+	return nil
+}
+
+// nextEncapsulated gathers the fragments of the next frame. Frames are
+// delimited by the Basic Offset Table when it has one entry per frame,
+// otherwise by codestream start markers, otherwise one fragment per frame.
+// The last frame takes every remaining fragment.
+func (f *FrameReader) nextEncapsulated() ([]byte, error) {
+	last := f.next == f.Info.NumberOfFrames-1
+	var out [][]byte
+	for {
+		start, frag := f.pendingPos, f.pending
+		if frag != nil {
+			f.pending = nil
+		} else {
+			if f.ended {
+				break
+			}
+			start = f.pos
+			var ok bool
+			var err error
+			if frag, ok, err = f.readFragment(); err != nil {
+				return nil, err
+			}
+			if !ok {
+				f.ended = true
+				break
+			}
+		}
+		if len(out) > 0 && !last && f.startsNextFrame(frag, start, out[0]) {
+			f.pending, f.pendingPos = frag, start
+			break
+		}
+		out = append(out, frag)
+	}
+	switch len(out) {
+	case 0:
+		return nil, io.ErrUnexpectedEOF
+	case 1:
+		return out[0], nil
+	}
+	return bytes.Join(out, nil), nil
+}
+
+// startsNextFrame decides whether a fragment at offset start belongs to the
+// frame after the one that began with first.
+func (f *FrameReader) startsNextFrame(frag []byte, start uint32, first []byte) bool {
+	if len(f.offsets) == f.Info.NumberOfFrames {
+		return start >= f.offsets[f.next+1]
+	}
+	if startsCodestream(first) {
+		return startsCodestream(frag)
+	}
+	return true
+}
+
+// readFragment reads one item of encapsulated pixel data. ok is false at
+// the sequence delimiter.
+func (f *FrameReader) readFragment() ([]byte, bool, error) {
+	tag, err := f.d.readTag()
+	if err != nil {
+		return nil, false, err
+	}
+	length, err := f.d.readU32()
+	if err != nil {
+		return nil, false, err
+	}
+	if tag == tagSequenceDelimiter {
+		return nil, false, nil
+	}
+	if tag != tagItem {
+		return nil, false, fmt.Errorf("expected fragment item, found %s", tag)
+	}
+	data, err := f.d.read(length)
+	if err != nil {
+		return nil, false, err
+	}
+	f.pos += 8 + length
+	return data, true, nil
+}
+
+// startsCodestream reports whether a fragment begins a JPEG, JPEG-LS or
+// JPEG 2000 codestream.
+func startsCodestream(frag []byte) bool {
+	if len(frag) < 2 || frag[0] != 0xFF {
+		return false
+	}
+	return frag[1] == 0xD8 || frag[1] == 0x4F // SOI or SOC
+}
@@ -0,0 +1,141 @@
+package dicom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestFrameReader(t *testing.T) {
+	// 2x2 pixels, 8 bits
+	header := func(frames string, extra ...[]byte) [][]byte {
+		return append([][]byte{
+			elem(TagSamplesPerPixel, "US", us(1)),
+			elem(TagNumberOfFrames, "IS", text(frames)),
+			elem(TagRows, "US", us(2)),
+			elem(TagColumns, "US", us(2)),
+			elem(TagBitsAllocated, "US", us(8)),
+		}, extra...)
+	}
+	native := func(frames string, pixels []byte) []byte {
+		return part10(ExplicitVRLittleEndian, append(header(frames), elem(TagPixelData, "OB", pixels))...)
+	}
+	encapsulated := func(frames string, offsets []uint32, fragments ...[]byte) []byte {
+		var bot []byte
+		for _, o := range offsets {
+			bot = binary.LittleEndian.AppendUint32(bot, o)
+		}
+		parts := header(frames, elemHeader(TagPixelData, "OB", undefinedLength), item(tagItem, uint32(len(bot)), bot))
+		for _, frag := range fragments {
+			parts = append(parts, item(tagItem, uint32(len(frag)), frag))
+		}
+		return part10(JPEGBaseline, append(parts, item(tagSequenceDelimiter, 0, nil))...)
+	}
+	jpeg := func(b byte) []byte { return []byte{0xFF, 0xD8, b, b} }
+
+	tests := []struct {
+		name       string
+		data       []byte
+		wantNewErr bool
+		wantFrames [][]byte
+		wantErr    bool // From the Next after wantFrames; io.EOF otherwise
+	}{
+		{name: "native", data: native("2", []byte{1, 2, 3, 4, 5, 6, 7, 8}),
+			wantFrames: [][]byte{{1, 2, 3, 4}, {5, 6, 7, 8}}},
+		{name: "native short of a frame", data: native("3", []byte{1, 2, 3, 4, 5, 6, 7, 8}),
+			wantFrames: [][]byte{{1, 2, 3, 4}, {5, 6, 7, 8}}, wantErr: true},
+		{name: "native value cut short", data: func() []byte {
+			data := native("2", []byte{1, 2, 3, 4, 5, 6, 7, 8})
+			return data[:len(data)-3]
+		}(), wantFrames: [][]byte{{1, 2, 3, 4}}, wantErr: true},
+		{name: "native empty frames", wantNewErr: true, data: part10(ExplicitVRLittleEndian,
+			elem(TagNumberOfFrames, "IS", text("1000000")), elem(TagRows, "US", us(0)), elem(TagColumns, "US", us(2)),
+			elem(TagBitsAllocated, "US", us(8)), elem(TagPixelData, "OB", nil))},
+		{name: "native 12 bits allocated", wantNewErr: true, data: part10(ExplicitVRLittleEndian,
+			elem(TagRows, "US", us(2)), elem(TagColumns, "US", us(2)), elem(TagBitsAllocated, "US", us(12)),
+			elem(TagPixelData, "OB", make([]byte, 6)))},
+		{name: "no pixel data", wantNewErr: true, data: part10(ExplicitVRLittleEndian, header("1")...)},
+		{name: "no image description", wantNewErr: true, data: part10(ExplicitVRLittleEndian, elem(TagPixelData, "OB", make([]byte, 4)))},
+		{name: "not DICOM", wantNewErr: true, data: []byte("GIF89a")},
+
+		{name: "fragment per frame", data: encapsulated("2", nil, jpeg(1), jpeg(2)),
+			wantFrames: [][]byte{jpeg(1), jpeg(2)}},
+		{name: "frames split by codestream markers", data: encapsulated("2", nil, jpeg(1), []byte{9, 9}, jpeg(2), []byte{8, 8}),
+			wantFrames: [][]byte{append(jpeg(1), 9, 9), append(jpeg(2), 8, 8)}},
+		{name: "frames split by the offset table", data: encapsulated("2", []uint32{0, 20}, []byte{1, 1, 1, 1}, []byte{2, 2, 2, 2},
+			[]byte{3, 3}), wantFrames: [][]byte{{1, 1, 1, 1, 2, 2, 2, 2}, {3, 3}}},
+		{name: "last frame takes the rest", data: encapsulated("1", nil, jpeg(1), jpeg(2)),
+			wantFrames: [][]byte{append(jpeg(1), jpeg(2)...)}},
+		{name: "fewer fragments than frames", data: encapsulated("3", nil, jpeg(1), jpeg(2)),
+			wantFrames: [][]byte{jpeg(1), jpeg(2)}, wantErr: true},
+		{name: "no fragments", data: encapsulated("1", nil), wantErr: true},
+		{name: "fragment cut short", data: func() []byte {
+			data := encapsulated("2", nil, jpeg(1), jpeg(2))
+			return data[:len(data)-8-2] // Drops the delimiter and half the last fragment
+		}(), wantErr: true}, // Frame 1 only ends where the next codestream starts
+		{name: "fragment without item tag", data: func() []byte {
+			data := encapsulated("2", nil, jpeg(1), jpeg(2))
+			copy(data[len(data)-8-12:], elem(TagRows, "US", us(2)))
+			return data
+		}(), wantErr: true},
+		{name: "absurd fragment length", data: func() []byte {
+			data := encapsulated("1", nil)
+			return append(data[:len(data)-8], item(tagItem, 0x7FFFFFF0, nil)...) // In place of the delimiter
+		}(), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFrameReader(bytes.NewReader(tt.data))
+			if tt.wantNewErr {
+				if err == nil {
+					t.Fatal("NewFrameReader() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewFrameReader() error = %v", err)
+			}
+			for i, want := range tt.wantFrames {
+				got, err := f.Next()
+				if err != nil {
+					t.Fatalf("Next() #%d error = %v", i+1, err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("frame %d = %x, want %x", i+1, got, want)
+				}
+			}
+			_, err = f.Next()
+			if tt.wantErr {
+				if err == nil || err == io.EOF {
+					t.Errorf("Next() after the frames error = %v, want a failure", err)
+				}
+			} else if err != io.EOF {
+				t.Errorf("Next() after the frames error = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestFrameReaderSkip(t *testing.T) {
+	data := part10(ExplicitVRLittleEndian,
+		elem(TagNumberOfFrames, "IS", text("3")), elem(TagRows, "US", us(1)), elem(TagColumns, "US", us(2)),
+		elem(TagBitsAllocated, "US", us(8)), elem(TagPixelData, "OB", []byte{1, 1, 2, 2, 3, 3}))
+	f, err := NewFrameReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Skip(); err != nil {
+		t.Fatalf("Skip() error = %v", err)
+	}
+	if got, err := f.Next(); err != nil || !bytes.Equal(got, []byte{2, 2}) {
+		t.Errorf("Next() after Skip = %v, %v, want the second frame", got, err)
+	}
+	if err := f.Skip(); err != nil {
+		t.Fatalf("Skip() error = %v", err)
+	}
+	if err := f.Skip(); !errors.Is(err, io.EOF) {
+		t.Errorf("Skip() past the end error = %v, want io.EOF", err)
+	}
+}
@@ -0,0 +1,321 @@
+// File: backend/internal/dicom/parser.go
+package dicom
+
+import (
+	"bufio"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Transfer syntax UIDs.
+const (
+	ImplicitVRLittleEndian         = "1.2.840.10008.1.2"
+	ExplicitVRLittleEndian         = "1.2.840.10008.1.2.1"
+	DeflatedExplicitVRLittleEndian = "1.2.840.10008.1.2.1.99"
+	ExplicitVRBigEndian            = "1.2.840.10008.1.2.2"
+	JPEGBaseline                   = "1.2.840.10008.1.2.4.50"
+	JPEGExtended                   = "1.2.840.10008.1.2.4.51"
+	JPEGLossless                   = "1.2.840.10008.1.2.4.57"
+	JPEGLosslessSV1                = "1.2.840.10008.1.2.4.70"
+	JPEGLSLossless                 = "1.2.840.10008.1.2.4.80"
+	JPEGLSNearLossless             = "1.2.840.10008.1.2.4.81"
+	JPEG2000Lossless               = "1.2.840.10008.1.2.4.90"
+	JPEG2000                       = "1.2.840.10008.1.2.4.91"
+	RLELossless                    = "1.2.840.10008.1.2.5"
+)
+
+var (
+	// ErrNotDICOM is returned when the input lacks the Part 10 "DICM" prefix.
+	ErrNotDICOM = errors.New("not a DICOM Part 10 file")
+	// ErrUnsupportedTransferSyntax is returned for syntaxes this package can't read or decode.
+	ErrUnsupportedTransferSyntax = errors.New("unsupported transfer syntax")
+)
+
+const (
+	undefinedLength = 0xFFFFFFFF
+	// maxElementLength guards against allocating absurd buffers for corrupt lengths.
+	maxElementLength = 1 << 30
+)
+
+// ParseOptions controls how much of a file is read.
+type ParseOptions struct {
+	// SkipPixelData stops parsing at the Pixel Data element, which is
+	// enough for header-only work and avoids reading large frames.
+	SkipPixelData bool
+}
+
+// Parse reads a DICOM Part 10 stream (preamble, file meta information and
+// data set). Only little endian transfer syntaxes are supported.
+func Parse(r io.Reader, opts ParseOptions) (*Dataset, error) {
+	ds, _, err := parse(r, opts)
+	return ds, err
+}
+
+// parse does the work of Parse and also returns the decoder, positioned just
+// after the Pixel Data tag when opts.SkipPixelData stopped it there.
+func parse(r io.Reader, opts ParseOptions) (*Dataset, *decoder, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	preamble := make([]byte, 132)
+	if _, err := io.ReadFull(br, preamble); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrNotDICOM, err)
+	}
+	if string(preamble[128:]) != "DICM" {
+		return nil, nil, ErrNotDICOM
+	}
+
+	ds := newDataset()
+	d := &decoder{r: br, explicit: true, opts: opts}
+
+	// File meta information is always explicit VR little endian (group 0002)
+	for {
+		peek, err := br.Peek(2)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read file meta information: %w", err)
+		}
+		if binary.LittleEndian.Uint16(peek) != 0x0002 {
+			break
+		}
+		if _, err := d.readElement(ds); err != nil {
+			return nil, nil, fmt.Errorf("failed to read file meta information: %w", err)
+		}
+	}
+	ds.TransferSyntax = ds.String(TagTransferSyntaxUID)
+
+	switch ds.TransferSyntax {
+	case ImplicitVRLittleEndian:
+		d.explicit = false
+	case DeflatedExplicitVRLittleEndian:
+		d.r = bufio.NewReaderSize(flate.NewReader(br), 64*1024)
+	case ExplicitVRBigEndian:
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnsupportedTransferSyntax, ds.TransferSyntax)
+	case "":
+		return nil, nil, fmt.Errorf("%w: missing transfer syntax", ErrNotDICOM)
+	}
+
+	if err := d.readElements(ds, -1, false); err != nil {
+		return nil, nil, err
+	}
+	return ds, d, nil
+}
+
+// IsEncapsulated reports whether a transfer syntax stores pixel data as fragments.
+func IsEncapsulated(transferSyntax string) bool {
+	switch transferSyntax {
+	case ImplicitVRLittleEndian, ExplicitVRLittleEndian, DeflatedExplicitVRLittleEndian, ExplicitVRBigEndian:
+		return false
+	}
+	return strings.HasPrefix(transferSyntax, "1.2.840.10008.1.2.")
+}
+
+type decoder struct {
+	r        *bufio.Reader
+	pos      int64
+	explicit bool
+	opts     ParseOptions
+	depth    int  // Sequence nesting; Pixel Data only ends the data set at depth 0
+	stopped  bool // Reached Pixel Data with SkipPixelData
+}
+
+func (d *decoder) read(n uint32) ([]byte, error) {
+	if n > maxElementLength {
+		return nil, fmt.Errorf("element length %d exceeds limit", n)
+	}
+	buf := make([]byte, n)
+	read, err := io.ReadFull(d.r, buf)
+	d.pos += int64(read)
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (d *decoder) readU16() (uint16, error) {
+	b, err := d.read(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(b), nil
+}
+
+func (d *decoder) readU32() (uint32, error) {
+	b, err := d.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+func (d *decoder) readTag() (Tag, error) {
+	b, err := d.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return NewTag(binary.LittleEndian.Uint16(b), binary.LittleEndian.Uint16(b[2:])), nil
+}
+
+// readElements reads elements into ds until end (a stream offset), an item
+// delimiter (inItem) or EOF (top level, end < 0).
+func (d *decoder) readElements(ds *Dataset, end int64, inItem bool) error {
+	for !d.stopped {
+		if end >= 0 && d.pos >= end {
+			return nil
+		}
+		if end < 0 && !inItem {
+			if _, err := d.r.Peek(1); err == io.EOF {
+				return nil
+			}
+		}
+		delimited, err := d.readElement(ds)
+		if err != nil {
+			return err
+		}
+		if delimited {
+			return nil
+		}
+	}
+	return nil
+}
+
+// readElement reads one element into ds. It returns true when it consumed an
+// item delimitation item instead.
+func (d *decoder) readElement(ds *Dataset) (bool, error) {
+	tag, err := d.readTag()
+	if err != nil {
+		return false, fmt.Errorf("failed to read tag: %w", err)
+	}
+	if tag == tagItemDelimitation {
+		_, err := d.readU32()
+		return true, err
+	}
+	if tag == TagPixelData && d.opts.SkipPixelData && d.depth == 0 {
+		d.stopped = true
+		return false, nil
+	}
+
+	vr, length, err := d.readVRLength(tag)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s header: %w", tag, err)
+	}
+	elem := &Element{Tag: tag, VR: vr}
+
+	switch {
+	case tag == TagPixelData && length == undefinedLength:
+		if err := d.readFragments(elem); err != nil {
+			return false, fmt.Errorf("failed to read encapsulated pixel data: %w", err)
+		}
+	case vr == "SQ" || length == undefinedLength:
+		// Undefined-length UN is a sequence encoded as implicit VR
+		explicit := d.explicit
+		if vr == "UN" {
+			d.explicit = false
+		}
+		elem.VR = "SQ"
+		elem.Items, err = d.readSequence(length)
+		d.explicit = explicit
+		if err != nil {
+			return false, fmt.Errorf("failed to read sequence %s: %w", tag, err)
+		}
+	default:
+		if elem.Value, err = d.read(length); err != nil {
+			return false, fmt.Errorf("failed to read %s value: %w", tag, err)
+		}
+	}
+	ds.Elements[tag] = elem
+	return false, nil
+}
+
+func (d *decoder) readVRLength(tag Tag) (string, uint32, error) {
+	if !d.explicit && tag.Group() != 0x0002 {
+		length, err := d.readU32()
+		return implicitVRs[tag], length, err
+	}
+	b, err := d.read(2)
+	if err != nil {
+		return "", 0, err
+	}
+	vr := string(b)
+	switch vr {
+	case "OB", "OD", "OF", "OL", "OV", "OW", "SQ", "SV", "UC", "UN", "UR", "UT", "UV":
+		if _, err := d.read(2); err != nil { // Reserved
+			return "", 0, err
+		}
+		length, err := d.readU32()
+		return vr, length, err
+	default:
+		length, err := d.readU16()
+		return vr, uint32(length), err
+	}
+}
+
+func (d *decoder) readSequence(length uint32) ([]*Dataset, error) {
+	end := int64(-1)
+	if length != undefinedLength {
+		end = d.pos + int64(length)
+	}
+	d.depth++
+	defer func() { d.depth-- }()
+	var items []*Dataset
+	for end < 0 || d.pos < end {
+		tag, err := d.readTag()
+		if err != nil {
+			return nil, err
+		}
+		itemLength, err := d.readU32()
+		if err != nil {
+			return nil, err
+		}
+		if tag == tagSequenceDelimiter {
+			break
+		}
+		if tag != tagItem {
+			return nil, fmt.Errorf("expected item, found %s", tag)
+		}
+		item := newDataset()
+		itemEnd := int64(-1)
+		if itemLength != undefinedLength {
+			itemEnd = d.pos + int64(itemLength)
+		}
+		if err := d.readElements(item, itemEnd, true); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (d *decoder) readFragments(elem *Element) error {
+	first := true
+	for {
+		tag, err := d.readTag()
+		if err != nil {
+			return err
+		}
+		length, err := d.readU32()
+		if err != nil {
+			return err
+		}
+		if tag == tagSequenceDelimiter {
+			return nil
+		}
+		if tag != tagItem {
+			return fmt.Errorf("expected fragment item, found %s", tag)
+		}
+		data, err := d.read(length)
+		if err != nil {
+			return err
+		}
+		if first {
+			// The first item is the Basic Offset Table (possibly empty)
+			for i := 0; i+4 <= len(data); i += 4 {
+				elem.Offsets = append(elem.Offsets, binary.LittleEndian.Uint32(data[i:]))
+			}
+			first = false
+			continue
+		}
+		elem.Fragments = append(elem.Fragments, data)
+	}
+}
@@ -0,0 +1,180 @@
+package dicom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// Builders for small Part 10 streams. Elements are explicit VR little endian
+// unless built with implicitElem.
+
+func elem(tag Tag, vr string, value []byte) []byte {
+	var b []byte
+	b = binary.LittleEndian.AppendUint16(b, tag.Group())
+	b = binary.LittleEndian.AppendUint16(b, uint16(tag))
+	b = append(b, vr...)
+	switch vr {
+	case "OB", "OW", "SQ", "UN", "UT":
+		b = append(b, 0, 0)
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(value)))
+	default:
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(value)))
+	}
+	return append(b, value...)
+}
+
+// elemHeader is an element header with an explicit (possibly wrong or
+// undefined) length and no value.
+func elemHeader(tag Tag, vr string, length uint32) []byte {
+	b := elem(tag, vr, nil)
+	binary.LittleEndian.PutUint32(b[len(b)-4:], length)
+	return b
+}
+
+func implicitElem(tag Tag, value []byte) []byte {
+	var b []byte
+	b = binary.LittleEndian.AppendUint16(b, tag.Group())
+	b = binary.LittleEndian.AppendUint16(b, uint16(tag))
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(value)))
+	return append(b, value...)
+}
+
+// item is an item or delimiter tag with a length, followed by data.
+func item(tag Tag, length uint32, data []byte) []byte {
+	var b []byte
+	b = binary.LittleEndian.AppendUint16(b, tag.Group())
+	b = binary.LittleEndian.AppendUint16(b, uint16(tag))
+	b = binary.LittleEndian.AppendUint32(b, length)
+	return append(b, data...)
+}
+
+func us(v uint16) []byte { return binary.LittleEndian.AppendUint16(nil, v) }
+
+// text pads s to an even length as string VRs require.
+func text(s string) []byte {
+	if len(s)%2 == 1 {
+		s += " "
+	}
+	return []byte(s)
+}
+
+func uid(s string) []byte {
+	if len(s)%2 == 1 {
+		return append([]byte(s), 0)
+	}
+	return []byte(s)
+}
+
+// part10 builds a stream with the given transfer syntax ("" to omit it) and data set.
+func part10(transferSyntax string, dataset ...[]byte) []byte {
+	b := make([]byte, 128, 1024)
+	b = append(b, "DICM"...)
+	b = append(b, elem(0x00020002, "UI", uid("1.2.840.10008.5.1.4.1.1.7"))...)
+	if transferSyntax != "" {
+		b = append(b, elem(TagTransferSyntaxUID, "UI", uid(transferSyntax))...)
+	}
+	for _, part := range dataset {
+		b = append(b, part...)
+	}
+	return b
+}
+
+func TestParse(t *testing.T) {
+	image := [][]byte{
+		elem(TagModality, "CS", text("CT")),
+		elem(TagRows, "US", us(2)),
+		elem(TagColumns, "US", us(3)),
+	}
+	tests := []struct {
+		name    string
+		data    []byte
+		opts    ParseOptions
+		wantErr error // nil with wantAny false: must parse
+		wantAny bool  // Any error will do
+	}{
+		{name: "explicit VR", data: part10(ExplicitVRLittleEndian, append(image, elem(TagPixelData, "OW", make([]byte, 12)))...)},
+		{name: "implicit VR", data: part10(ImplicitVRLittleEndian,
+			implicitElem(TagModality, text("CT")), implicitElem(TagRows, us(2)), implicitElem(TagColumns, us(3)))},
+		{name: "empty data set", data: part10(ExplicitVRLittleEndian, image...)},
+		{name: "sequence", data: part10(ExplicitVRLittleEndian, append(image,
+			elemHeader(TagSharedFunctionalGroups, "SQ", undefinedLength),
+			item(tagItem, undefinedLength, elem(TagWindowCenter, "DS", text("40"))),
+			item(tagItemDelimitation, 0, nil),
+			item(tagSequenceDelimiter, 0, nil))...)},
+		{name: "pixel data skipped", opts: ParseOptions{SkipPixelData: true},
+			data: part10(ExplicitVRLittleEndian, append(image, elemHeader(TagPixelData, "OW", 1<<20))...)},
+
+		{name: "empty", data: nil, wantErr: ErrNotDICOM},
+		{name: "preamble only", data: make([]byte, 132), wantErr: ErrNotDICOM},
+		{name: "no DICM prefix", data: append(make([]byte, 128), "DICX"...), wantErr: ErrNotDICOM},
+		{name: "missing transfer syntax", data: part10("", image...), wantErr: ErrNotDICOM},
+		{name: "big endian", data: part10(ExplicitVRBigEndian, image...), wantErr: ErrUnsupportedTransferSyntax},
+		{name: "meta information cut short", data: part10(ExplicitVRLittleEndian)[:150], wantAny: true},
+		{name: "tag cut short", data: append(part10(ExplicitVRLittleEndian, image...), 0x28, 0x00), wantAny: true},
+		{name: "value cut short", data: part10(ExplicitVRLittleEndian, elemHeader(TagPixelData, "OW", 100), make([]byte, 10)),
+			wantAny: true},
+		{name: "absurd length", data: part10(ExplicitVRLittleEndian, elemHeader(TagPixelData, "OB", 0x7FFFFFF0)), wantAny: true},
+		{name: "sequence without items", data: part10(ExplicitVRLittleEndian,
+			elemHeader(TagSharedFunctionalGroups, "SQ", undefinedLength), elem(TagRows, "US", us(2))), wantAny: true},
+		{name: "sequence never closed", data: part10(ExplicitVRLittleEndian,
+			elemHeader(TagSharedFunctionalGroups, "SQ", undefinedLength),
+			item(tagItem, undefinedLength, elem(TagRows, "US", us(2)))), wantAny: true},
+		{name: "fragment without item tag", data: part10(JPEGBaseline, append(image,
+			elemHeader(TagPixelData, "OB", undefinedLength), item(tagItem, 0, nil), elem(TagRows, "US", us(2)))...), wantAny: true},
+		{name: "fragments never closed", data: part10(JPEGBaseline, append(image,
+			elemHeader(TagPixelData, "OB", undefinedLength), item(tagItem, 0, nil), item(tagItem, 4, []byte{0xFF, 0xD8, 0, 0}))...),
+			wantAny: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds, err := Parse(bytes.NewReader(tt.data), tt.opts)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantAny:
+				if err == nil {
+					t.Fatal("Parse() succeeded, want an error")
+				}
+			case err != nil:
+				t.Fatalf("Parse() error = %v", err)
+			default:
+				if rows, ok := ds.Int(TagRows); !ok || rows != 2 {
+					t.Errorf("Rows = %d, %v, want 2", rows, ok)
+				}
+				if got := ds.String(TagModality); got != "CT" {
+					t.Errorf("Modality = %q, want CT", got)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSequenceItems(t *testing.T) {
+	data := part10(ExplicitVRLittleEndian,
+		elemHeader(TagPerFrameFunctionalGroups, "SQ", undefinedLength),
+		item(tagItem, undefinedLength, elem(TagWindowCenter, "DS", text("40"))),
+		item(tagItemDelimitation, 0, nil),
+		item(tagItem, 10, elem(TagWindowCenter, "DS", text("50"))), // Defined length
+		item(tagSequenceDelimiter, 0, nil),
+		elem(TagRows, "US", us(2)))
+	ds, err := Parse(bytes.NewReader(data), ParseOptions{})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	items := ds.Sequence(TagPerFrameFunctionalGroups)
+	if len(items) != 2 {
+		t.Fatalf("sequence has %d items, want 2", len(items))
+	}
+	for i, want := range []float64{40, 50} {
+		if got, ok := items[i].Float(TagWindowCenter); !ok || got != want {
+			t.Errorf("item %d WindowCenter = %v, %v, want %v", i, got, ok, want)
+		}
+	}
+	if _, ok := ds.Int(TagRows); !ok {
+		t.Error("element after the sequence was lost")
+	}
+}
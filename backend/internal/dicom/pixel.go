@@ -0,0 +1,325 @@
+// File: backend/internal/dicom/pixel.go
+package dicom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+)
+
+// ErrNoPixelData is returned for objects without an image (SR, KO, PR...).
+var ErrNoPixelData = errors.New("object has no pixel data")
+
+// ImageInfo describes the Image Pixel module of a data set.
+type ImageInfo struct {
+	Rows                int    `json:"rows"`
+	Columns             int    `json:"columns"`
+	NumberOfFrames      int    `json:"numberOfFrames"`
+	SamplesPerPixel     int    `json:"samplesPerPixel"`
+	BitsAllocated       int    `json:"bitsAllocated"`
+	BitsStored          int    `json:"bitsStored"`
+	PixelRepresentation int    `json:"pixelRepresentation"` // 1 = signed
+	PlanarConfiguration int    `json:"planarConfiguration"`
+	Photometric         string `json:"photometricInterpretation"`
+	TransferSyntax      string `json:"transferSyntax"`
+}
+
+// ImageInfo returns the pixel description, or ErrNoPixelData.
+func (d *Dataset) ImageInfo() (ImageInfo, error) {
+	info := ImageInfo{TransferSyntax: d.TransferSyntax, Photometric: d.String(TagPhotometricInterpretation)}
+	var ok bool
+	if info.Rows, ok = d.Int(TagRows); !ok {
+		return info, ErrNoPixelData
+	}
+	if info.Columns, ok = d.Int(TagColumns); !ok {
+		return info, ErrNoPixelData
+	}
+	info.NumberOfFrames = d.NumberOfFrames()
+	if info.SamplesPerPixel, ok = d.Int(TagSamplesPerPixel); !ok {
+		info.SamplesPerPixel = 1
+	}
+	if info.BitsAllocated, ok = d.Int(TagBitsAllocated); !ok {
+		info.BitsAllocated = 16
+	}
+	if info.BitsStored, ok = d.Int(TagBitsStored); !ok {
+		info.BitsStored = info.BitsAllocated
+	}
+	info.PixelRepresentation, _ = d.Int(TagPixelRepresentation)
+	info.PlanarConfiguration, _ = d.Int(TagPlanarConfiguration)
+	return info, nil
+}
+
+// NumberOfFrames returns the frame count, 1 for single-frame objects.
+func (d *Dataset) NumberOfFrames() int {
+	if n, ok := d.Int(TagNumberOfFrames); ok && n > 0 {
+		return n
+	}
+	return 1
+}
+
+// FrameData returns the stored bytes of frame i (0-based): raw samples for
+// native syntaxes, the compressed bitstream for encapsulated ones.
+func (d *Dataset) FrameData(i int) ([]byte, error) {
+	elem, ok := d.Elements[TagPixelData]
+	if !ok {
+		return nil, ErrNoPixelData
+	}
+	info, err := d.ImageInfo()
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 || i >= info.NumberOfFrames {
+		return nil, fmt.Errorf("frame %d out of range (object has %d)", i+1, info.NumberOfFrames)
+	}
+
+	if elem.Fragments == nil {
+		frameSize := info.Rows * info.Columns * info.SamplesPerPixel * info.BitsAllocated / 8
+		start, end := i*frameSize, (i+1)*frameSize
+		if frameSize == 0 || end > len(elem.Value) {
+			return nil, fmt.Errorf("pixel data too short for frame %d", i+1)
+		}
+		return elem.Value[start:end], nil
+	}
+	return frameFragments(elem, i, info.NumberOfFrames)
+}
+
+// frameFragments joins the fragments that make up frame i.
+func frameFragments(elem *Element, i, frames int) ([]byte, error) {
+	switch {
+	case len(elem.Fragments) == frames:
+		return elem.Fragments[i], nil
+	case frames == 1:
+		return bytes.Join(elem.Fragments, nil), nil
+	case len(elem.Offsets) == frames:
+		// Offsets count from the first fragment item, including 8-byte item headers
+		var out []byte
+		var pos uint32
+		for _, frag := range elem.Fragments {
+			inFrame := pos >= elem.Offsets[i] && (i+1 == frames || pos < elem.Offsets[i+1])
+			if inFrame {
+				out = append(out, frag...)
+			}
+			pos += 8 + uint32(len(frag))
+		}
+		return out, nil
+	}
+	// No usable offset table: JPEG family frames start with an SOI marker
+	frame := -1
+	var out []byte
+	for _, frag := range elem.Fragments {
+		if len(frag) >= 2 && frag[0] == 0xFF && frag[1] == 0xD8 {
+			frame++
+		}
+		if frame == i {
+			out = append(out, frag...)
+		} else if frame > i {
+			break
+		}
+	}
+	if out == nil {
+		return nil, fmt.Errorf("cannot locate frame %d among %d fragments", i+1, len(elem.Fragments))
+	}
+	return out, nil
+}
+
+// Frame is a decoded frame. Data holds samples interleaved per pixel
+// (R,G,B,R,G,B... for color), sign-extended and masked to BitsStored.
+type Frame struct {
+	Width           int
+	Height          int
+	SamplesPerPixel int
+	BitsStored      int
+	Signed          bool
+	Photometric     string // MONOCHROME1, MONOCHROME2 or RGB after decoding
+	Data            []int32
+}
+
+// CanDecode reports whether DecodeFrame reads pixel data in transferSyntax.
+func CanDecode(transferSyntax string) bool {
+	return !IsEncapsulated(transferSyntax) || transferSyntax == RLELossless || transferSyntax == JPEGBaseline
+}
+
+// DecodeFrame decodes frame i (0-based) for native, RLE and JPEG baseline data.
+func (d *Dataset) DecodeFrame(i int) (*Frame, error) {
+	info, err := d.ImageInfo()
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.FrameData(i)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case !IsEncapsulated(d.TransferSyntax):
+		return decodeNative(info, data)
+	case d.TransferSyntax == RLELossless:
+		native, err := decodeRLE(info, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode RLE frame: %w", err)
+		}
+		info.PlanarConfiguration = 0 // decodeRLE interleaves samples
+		return decodeNative(info, native)
+	case d.TransferSyntax == JPEGBaseline:
+		return decodeJPEG(info, data)
+	}
+	return nil, fmt.Errorf("%w: cannot decode pixel data in %s", ErrUnsupportedTransferSyntax, d.TransferSyntax)
+}
+
+func decodeNative(info ImageInfo, data []byte) (*Frame, error) {
+	if info.BitsAllocated != 8 && info.BitsAllocated != 16 && info.BitsAllocated != 32 {
+		return nil, fmt.Errorf("%w: %d bits allocated", ErrUnsupportedTransferSyntax, info.BitsAllocated)
+	}
+	pixels := info.Rows * info.Columns
+	n := pixels * info.SamplesPerPixel
+	bytesPer := info.BitsAllocated / 8
+	if len(data) < n*bytesPer {
+		return nil, fmt.Errorf("pixel data too short: have %d bytes, need %d", len(data), n*bytesPer)
+	}
+
+	f := &Frame{
+		Width:           info.Columns,
+		Height:          info.Rows,
+		SamplesPerPixel: info.SamplesPerPixel,
+		BitsStored:      info.BitsStored,
+		Signed:          info.PixelRepresentation == 1,
+		Photometric:     info.Photometric,
+		Data:            make([]int32, n),
+	}
+	mask := uint32(1)<<uint(info.BitsStored) - 1
+	if info.BitsStored >= 32 {
+		mask = 0xFFFFFFFF
+	}
+	signBit := uint32(1) << uint(info.BitsStored-1)
+
+	for s := 0; s < n; s++ {
+		// Planar configuration 1 stores each color plane in turn
+		src := s
+		if info.PlanarConfiguration == 1 && info.SamplesPerPixel > 1 {
+			src = (s%info.SamplesPerPixel)*pixels + s/info.SamplesPerPixel
+		}
+		var v uint32
+		switch bytesPer {
+		case 1:
+			v = uint32(data[src])
+		case 2:
+			v = uint32(binary.LittleEndian.Uint16(data[src*2:]))
+		case 4:
+			v = binary.LittleEndian.Uint32(data[src*4:])
+		}
+		v &= mask
+		if f.Signed && v&signBit != 0 {
+			v |= ^mask
+		}
+		f.Data[s] = int32(v)
+	}
+
+	switch info.Photometric {
+	case "MONOCHROME1", "MONOCHROME2", "RGB":
+	case "YBR_FULL":
+		ybrToRGB(f.Data)
+		f.Photometric = "RGB"
+	default:
+		return nil, fmt.Errorf("%w: photometric interpretation %s", ErrUnsupportedTransferSyntax, info.Photometric)
+	}
+	return f, nil
+}
+
+// decodeRLE expands DICOM RLE (PS3.5 Annex G) into interleaved little-endian samples.
+func decodeRLE(info ImageInfo, data []byte) ([]byte, error) {
+	if len(data) < 64 {
+		return nil, errors.New("RLE header truncated")
+	}
+	segments := int(binary.LittleEndian.Uint32(data))
+	bytesPer := info.BitsAllocated / 8
+	if segments != info.SamplesPerPixel*bytesPer || segments > 15 {
+		return nil, fmt.Errorf("unexpected RLE segment count %d", segments)
+	}
+	pixels := info.Rows * info.Columns
+	out := make([]byte, pixels*segments)
+
+	for seg := 0; seg < segments; seg++ {
+		start := int(binary.LittleEndian.Uint32(data[4+seg*4:]))
+		end := len(data)
+		if seg+1 < segments {
+			end = int(binary.LittleEndian.Uint32(data[4+(seg+1)*4:]))
+		}
+		if start > end || end > len(data) {
+			return nil, fmt.Errorf("RLE segment %d out of bounds", seg)
+		}
+		plane := unpackBits(data[start:end], pixels)
+
+		// Segments hold the most significant byte first for each sample
+		sample, byteIndex := seg/bytesPer, bytesPer-1-seg%bytesPer
+		for p := 0; p < len(plane); p++ {
+			out[(p*info.SamplesPerPixel+sample)*bytesPer+byteIndex] = plane[p]
+		}
+	}
+	return out, nil
+}
+
+func unpackBits(src []byte, size int) []byte {
+	out := make([]byte, 0, size)
+	for i := 0; i < len(src) && len(out) < size; {
+		n := int(int8(src[i]))
+		i++
+		switch {
+		case n >= 0:
+			end := min(i+n+1, len(src))
+			out = append(out, src[i:end]...)
+			i = end
+		case n != -128 && i < len(src):
+			for j := 0; j < 1-n; j++ {
+				out = append(out, src[i])
+			}
+			i++
+		}
+	}
+	return out
+}
+
+func decodeJPEG(info ImageInfo, data []byte) (*Frame, error) {
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JPEG frame: %w", err)
+	}
+	b := img.Bounds()
+	f := &Frame{Width: b.Dx(), Height: b.Dy(), BitsStored: 8, Photometric: info.Photometric}
+
+	if gray, ok := img.(*image.Gray); ok {
+		f.SamplesPerPixel = 1
+		f.Data = make([]int32, 0, f.Width*f.Height)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				f.Data = append(f.Data, int32(gray.GrayAt(x, y).Y))
+			}
+		}
+		if f.Photometric != "MONOCHROME1" {
+			f.Photometric = "MONOCHROME2"
+		}
+		return f, nil
+	}
+
+	f.SamplesPerPixel = 3
+	f.Photometric = "RGB"
+	f.Data = make([]int32, 0, f.Width*f.Height*3)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			f.Data = append(f.Data, int32(c.R), int32(c.G), int32(c.B))
+		}
+	}
+	return f, nil
+}
+
+// ybrToRGB converts interleaved YBR_FULL samples to RGB in place.
+func ybrToRGB(data []int32) {
+	for i := 0; i+2 < len(data); i += 3 {
+		r, g, b := color.YCbCrToRGB(uint8(data[i]), uint8(data[i+1]), uint8(data[i+2]))
+		data[i], data[i+1], data[i+2] = int32(r), int32(g), int32(b)
+	}
+}
@@ -0,0 +1,51 @@
+// File: backend/internal/hl7/ack.go
+package hl7
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Acknowledgment codes (MSA-1).
+const (
+	AckAccept = "AA"
+	AckError  = "AE"
+	AckReject = "AR"
+)
+
+// Sender identifies us in the MSH of generated acknowledgments.
+type Sender struct {
+	Application string
+	Facility    string
+}
+
+// ACK builds the original-mode acknowledgment of msg, which may be nil when
+// the input could not be parsed.
+func (s Sender) ACK(msg *Message, code, text string, now time.Time) []byte {
+	var sendingApp, sendingFacility, trigger, controlID, processingID, version string
+	if msg != nil {
+		sendingApp, sendingFacility = msg.Get("MSH", 3, 0), msg.Get("MSH", 4, 0)
+		_, trigger = msg.Type()
+		controlID = msg.ControlID()
+		processingID, version = msg.Get("MSH", 11, 0), msg.Get("MSH", 12, 0)
+	}
+	if processingID == "" {
+		processingID = "P"
+	}
+	if version == "" {
+		version = "2.5"
+	}
+	messageType := "ACK"
+	if trigger != "" {
+		messageType = "ACK^" + escapeText(trigger) + "^ACK"
+	}
+	msh := []string{"MSH", `^~\&`, escapeText(s.Application), escapeText(s.Facility),
+		escapeText(sendingApp), escapeText(sendingFacility), now.Format("20060102150405"), "",
+		messageType, strconv.FormatInt(now.UnixNano(), 10), escapeText(processingID), escapeText(version)}
+	msa := []string{"MSA", code, escapeText(controlID)}
+	if text != "" {
+		msa = append(msa, escapeText(text))
+	}
+	return []byte(strings.Join(msh, "|") + "\r" + strings.Join(msa, "|") + "\r")
+}
@@ -0,0 +1,74 @@
+package hl7
+
+import (
+	"testing"
+	"time"
+)
+
+// ackField is a value expected at a field and component of a parsed ACK.
+type ackField struct {
+	seg       string
+	field     int
+	component int
+	want      string
+}
+
+func TestACK(t *testing.T) {
+	sender := Sender{Application: "GEN-ERICS", Facility: "RAD"}
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	orig, err := Parse([]byte(adtA08))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		msg    *Message
+		code   string
+		text   string
+		fields []ackField
+	}{
+		{
+			name: "accept",
+			msg:  orig,
+			code: AckAccept,
+			fields: []ackField{
+				{"MSH", 3, 0, "GEN-ERICS"}, {"MSH", 4, 0, "RAD"}, {"MSH", 5, 0, "ADT"}, {"MSH", 6, 0, "HOSP"},
+				{"MSH", 7, 0, "20240102030405"}, {"MSH", 9, 1, "ACK"}, {"MSH", 9, 2, "A08"}, {"MSH", 9, 3, "ACK"},
+				{"MSH", 11, 0, "P"}, {"MSH", 12, 0, "2.5"},
+				{"MSA", 1, 0, AckAccept}, {"MSA", 2, 0, "MSG0001"}, {"MSA", 3, 0, ""},
+			},
+		},
+		{
+			name: "error text is escaped",
+			msg:  orig,
+			code: AckError,
+			text: "bad PID|3^1",
+			fields: []ackField{
+				{"MSA", 1, 0, AckError}, {"MSA", 2, 0, "MSG0001"}, {"MSA", 3, 0, "bad PID|3^1"},
+			},
+		},
+		{
+			name: "unparseable input",
+			code: AckReject,
+			text: "invalid HL7 message",
+			fields: []ackField{
+				{"MSH", 5, 0, ""}, {"MSH", 9, 0, "ACK"}, {"MSH", 11, 0, "P"}, {"MSH", 12, 0, "2.5"},
+				{"MSA", 1, 0, AckReject}, {"MSA", 2, 0, ""}, {"MSA", 3, 0, "invalid HL7 message"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ack, err := Parse(sender.ACK(tt.msg, tt.code, tt.text, now))
+			if err != nil {
+				t.Fatalf("ACK doesn't parse: %v", err)
+			}
+			for _, f := range tt.fields {
+				if got := ack.Get(f.seg, f.field, f.component); got != f.want {
+					t.Errorf("%s-%d.%d = %q, want %q", f.seg, f.field, f.component, got, f.want)
+				}
+			}
+		})
+	}
+}
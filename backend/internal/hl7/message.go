@@ -0,0 +1,165 @@
+// File: backend/internal/hl7/message.go
+package hl7
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalidMessage is returned for input that is not an HL7 v2 message.
+var ErrInvalidMessage = errors.New("invalid HL7 message")
+
+// Message is a parsed HL7 v2 message. Only the first repetition of a field
+// is used.
+type Message struct {
+	Segments []Segment
+	fieldSep string
+	compSep  string
+	repSep   string
+	escape   string
+	subSep   string
+}
+
+// Segment is one segment's fields; index 0 is the segment name and index n
+// is field n, also for MSH (MSH-1 is the field separator itself).
+type Segment []string
+
+// Parse splits an HL7 v2 message into segments and fields using the
+// delimiters declared in MSH. Segments may end in CR, LF or CRLF.
+func Parse(raw []byte) (*Message, error) {
+	text := strings.ReplaceAll(string(raw), "\r\n", "\r")
+	text = strings.ReplaceAll(text, "\n", "\r")
+	text = strings.TrimLeft(text, "\r")
+	if len(text) < 8 || !strings.HasPrefix(text, "MSH") {
+		return nil, ErrInvalidMessage
+	}
+	m := &Message{fieldSep: text[3:4]}
+	encoding := text[4:]
+	if i := strings.Index(encoding, m.fieldSep); i >= 0 {
+		encoding = encoding[:i]
+	}
+	if len(encoding) < 2 {
+		return nil, ErrInvalidMessage
+	}
+	if len(encoding) < 4 {
+		encoding += `^~\&`[len(encoding):] // Fill in separators old senders omit
+	}
+	m.compSep, m.repSep, m.escape, m.subSep = encoding[0:1], encoding[1:2], encoding[2:3], encoding[3:4]
+
+	for _, line := range strings.Split(text, "\r") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, m.fieldSep)
+		if fields[0] == "MSH" {
+			fields = append([]string{"MSH", m.fieldSep}, fields[1:]...)
+		}
+		m.Segments = append(m.Segments, Segment(fields))
+	}
+	return m, nil
+}
+
+// Segment returns the first segment with the given name.
+func (m *Message) Segment(name string) (Segment, bool) {
+	for _, seg := range m.Segments {
+		if seg[0] == name {
+			return seg, true
+		}
+	}
+	return nil, false
+}
+
+// Get returns component (1-based) of field in the first segment named seg,
+// unescaped; "" if absent. Component 0 returns the whole field.
+func (m *Message) Get(seg string, field, component int) string {
+	s, ok := m.Segment(seg)
+	if !ok {
+		return ""
+	}
+	return m.Value(s, field, component)
+}
+
+// Value returns component (1-based) of field in s, unescaped. Component 0
+// returns the whole field.
+func (m *Message) Value(s Segment, field, component int) string {
+	if field >= len(s) {
+		return ""
+	}
+	value := s[field]
+	if s[0] == "MSH" && field <= 2 {
+		return value // Separators, not data
+	}
+	value, _, _ = strings.Cut(value, m.repSep)
+	if component > 0 {
+		parts := strings.Split(value, m.compSep)
+		if component > len(parts) {
+			return ""
+		}
+		value = parts[component-1]
+	}
+	return m.unescape(value)
+}
+
+// Components returns the components of field in s, unescaped.
+func (m *Message) Components(s Segment, field int) []string {
+	whole := ""
+	if field < len(s) {
+		whole, _, _ = strings.Cut(s[field], m.repSep)
+	}
+	parts := strings.Split(whole, m.compSep)
+	for i := range parts {
+		parts[i] = m.unescape(parts[i])
+	}
+	return parts
+}
+
+// Type returns the message type and trigger event from MSH-9, e.g. "ADT", "A08".
+func (m *Message) Type() (string, string) {
+	return m.Get("MSH", 9, 1), m.Get("MSH", 9, 2)
+}
+
+// ControlID returns MSH-10.
+func (m *Message) ControlID() string {
+	return m.Get("MSH", 10, 0)
+}
+
+// unescape resolves the delimiter escapes (\F\ \S\ \R\ \T\ \E\); others
+// such as formatting commands are dropped.
+func (m *Message) unescape(value string) string {
+	if !strings.Contains(value, m.escape) {
+		return value
+	}
+	var b strings.Builder
+	for {
+		start := strings.Index(value, m.escape)
+		if start < 0 {
+			b.WriteString(value)
+			return b.String()
+		}
+		end := strings.Index(value[start+1:], m.escape)
+		if end < 0 {
+			b.WriteString(value)
+			return b.String()
+		}
+		b.WriteString(value[:start])
+		switch value[start+1 : start+1+end] {
+		case "F":
+			b.WriteString(m.fieldSep)
+		case "S":
+			b.WriteString(m.compSep)
+		case "R":
+			b.WriteString(m.repSep)
+		case "T":
+			b.WriteString(m.subSep)
+		case "E":
+			b.WriteString(m.escape)
+		}
+		value = value[start+end+2:]
+	}
+}
+
+// escapeText escapes HL7 delimiters in free text using the default
+// encoding characters.
+func escapeText(text string) string {
+	return strings.NewReplacer(`\`, `\E\`, `|`, `\F\`, `^`, `\S\`, `~`, `\R\`, `&`, `\T\`, "\r", " ", "\n", " ").Replace(text)
+}
@@ -0,0 +1,146 @@
+package hl7
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+const adtA08 = "MSH|^~\\&|ADT|HOSP|PACS|RAD|20240102030405||ADT^A08|MSG0001|P|2.5\r" +
+	"EVN|A08|20240102030405\r" +
+	"PID|1||12345^^^HOSP~67890^^^OTHER||Doe^John^Q^Jr^Dr||19800101|M\r"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		wantErr  bool
+		segments []string
+	}{
+		{name: "CR separated", raw: adtA08, segments: []string{"MSH", "EVN", "PID"}},
+		{name: "LF separated", raw: "MSH|^~\\&|A\nPID|1\n", segments: []string{"MSH", "PID"}},
+		{name: "CRLF separated", raw: "MSH|^~\\&|A\r\nPID|1\r\n", segments: []string{"MSH", "PID"}},
+		{name: "leading blank lines", raw: "\r\rMSH|^~\\&|A\rPID|1", segments: []string{"MSH", "PID"}},
+		{name: "two encoding characters", raw: "MSH|^~|A\rPID|1", segments: []string{"MSH", "PID"}},
+		{name: "not MSH", raw: "PID|1||12345\r", wantErr: true},
+		{name: "too short", raw: "MSH|^", wantErr: true},
+		{name: "empty", raw: "", wantErr: true},
+		{name: "one encoding character", raw: "MSH|^|A|B\r", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := Parse([]byte(tt.raw))
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidMessage) {
+					t.Fatalf("Parse() error = %v, want ErrInvalidMessage", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			var names []string
+			for _, seg := range msg.Segments {
+				names = append(names, seg[0])
+			}
+			if !slices.Equal(names, tt.segments) {
+				t.Errorf("segments = %v, want %v", names, tt.segments)
+			}
+		})
+	}
+}
+
+func TestMessageGet(t *testing.T) {
+	msg, err := Parse([]byte(adtA08))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	tests := []struct {
+		seg       string
+		field     int
+		component int
+		want      string
+	}{
+		{"MSH", 1, 0, "|"},
+		{"MSH", 2, 0, "^~\\&"},
+		{"MSH", 3, 0, "ADT"},
+		{"MSH", 9, 1, "ADT"},
+		{"MSH", 9, 2, "A08"},
+		{"MSH", 10, 0, "MSG0001"},
+		{"PID", 3, 1, "12345"}, // First repetition only
+		{"PID", 3, 0, "12345^^^HOSP"},
+		{"PID", 5, 2, "John"},
+		{"PID", 5, 9, ""},  // Missing component
+		{"PID", 30, 0, ""}, // Missing field
+		{"ZZZ", 1, 0, ""},  // Missing segment
+	}
+	for _, tt := range tests {
+		if got := msg.Get(tt.seg, tt.field, tt.component); got != tt.want {
+			t.Errorf("Get(%s, %d, %d) = %q, want %q", tt.seg, tt.field, tt.component, got, tt.want)
+		}
+	}
+	if typ, trigger := msg.Type(); typ != "ADT" || trigger != "A08" {
+		t.Errorf("Type() = %q, %q, want ADT, A08", typ, trigger)
+	}
+	if got := msg.ControlID(); got != "MSG0001" {
+		t.Errorf("ControlID() = %q, want MSG0001", got)
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	tests := []struct {
+		name  string
+		field string
+		want  string
+	}{
+		{name: "plain", field: "Doe", want: "Doe"},
+		{name: "field separator", field: `A\F\B`, want: "A|B"},
+		{name: "component separator", field: `A\S\B`, want: "A^B"},
+		{name: "repetition separator", field: `A\R\B`, want: "A~B"},
+		{name: "subcomponent separator", field: `A\T\B`, want: "A&B"},
+		{name: "escape", field: `A\E\B`, want: `A\B`},
+		{name: "formatting dropped", field: `A\.br\B`, want: "AB"},
+		{name: "unterminated", field: `A\F`, want: `A\F`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := Parse([]byte("MSH|^~\\&|A\rNTE|1||" + tt.field))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := msg.Get("NTE", 3, 0); got != tt.want {
+				t.Errorf("Get() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscapeTextRoundTrip(t *testing.T) {
+	for _, text := range []string{"plain", `a|b^c~d&e\f`, "line\rbreak"} {
+		msg, err := Parse([]byte("MSH|^~\\&|A\rNTE|1||" + escapeText(text)))
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		want := text
+		if text == "line\rbreak" {
+			want = "line break" // Segment terminators can't be escaped
+		}
+		if got := msg.Get("NTE", 3, 0); got != want {
+			t.Errorf("round trip of %q = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestComponents(t *testing.T) {
+	msg, err := Parse([]byte(adtA08))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	pid, _ := msg.Segment("PID")
+	if got, want := msg.Components(pid, 5), []string{"Doe", "John", "Q", "Jr", "Dr"}; !slices.Equal(got, want) {
+		t.Errorf("Components(PID-5) = %v, want %v", got, want)
+	}
+	if got := msg.Components(pid, 40); !slices.Equal(got, []string{""}) {
+		t.Errorf("Components(missing) = %v, want [\"\"]", got)
+	}
+}
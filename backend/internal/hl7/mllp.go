@@ -0,0 +1,147 @@
+// File: backend/internal/hl7/mllp.go
+package hl7
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// MLLP block characters.
+const (
+	startBlock = 0x0b
+	endBlock   = 0x1c
+	carriage   = 0x0d
+)
+
+const (
+	// maxMessageSize bounds a single framed message.
+	maxMessageSize = 1 << 20
+	// idleTimeout closes connections that send nothing for this long.
+	idleTimeout = 5 * time.Minute
+)
+
+// HandlerFunc processes one framed message and returns the acknowledgment
+// to send back.
+type HandlerFunc func(ctx context.Context, raw []byte, remoteAddr string) []byte
+
+// Server accepts HL7 v2 over MLLP: each message is wrapped in <VT> ... <FS><CR>
+// and answered with an acknowledgment in the same framing.
+type Server struct {
+	addr    string
+	handler HandlerFunc
+}
+
+// NewServer creates a Server; call Run to start it.
+func NewServer(addr string, handler HandlerFunc) *Server {
+	return &Server{addr: addr, handler: handler}
+}
+
+// Run listens until ctx is cancelled, serving each connection in its own
+// goroutine; messages on one connection are handled in order.
+func (s *Server) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for HL7 on %s: %w", s.addr, err)
+	}
+	slog.InfoContext(ctx, "HL7 MLLP listener started", "address", listener.Addr().String())
+
+	var wg sync.WaitGroup
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				wg.Wait()
+				slog.Info("HL7 MLLP listener stopped")
+				return nil
+			}
+			slog.ErrorContext(ctx, "Failed to accept HL7 connection", "error", err)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serve(ctx, conn)
+		}()
+	}
+}
+
+func (s *Server) serve(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	remote := conn.RemoteAddr().String()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	reader := bufio.NewReader(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		raw, err := readFrame(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) && ctx.Err() == nil {
+				slog.WarnContext(ctx, "Closing HL7 connection", "remoteAddr", remote, "error", err)
+			}
+			return
+		}
+		ack := s.handler(ctx, raw, remote)
+		conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+		if _, err := conn.Write(frame(ack)); err != nil {
+			slog.WarnContext(ctx, "Failed to send HL7 acknowledgment", "remoteAddr", remote, "error", err)
+			return
+		}
+	}
+}
+
+// readFrame returns the payload of the next MLLP block, skipping anything
+// before the start block. Both the skipped bytes and the payload are bounded
+// by maxMessageSize.
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	skipped := 0
+	for {
+		chunk, err := r.ReadSlice(startBlock)
+		skipped += len(chunk)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, err
+		}
+		if skipped > maxMessageSize {
+			return nil, fmt.Errorf("no HL7 start block within %d bytes", maxMessageSize)
+		}
+	}
+	var payload []byte
+	for {
+		chunk, err := r.ReadSlice(endBlock)
+		payload = append(payload, chunk...)
+		if len(payload) > maxMessageSize {
+			return nil, fmt.Errorf("HL7 message exceeds %d bytes", maxMessageSize)
+		}
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, err
+		}
+	}
+	// The trailing CR is skipped along with anything else before the next
+	// start block, so senders that omit it don't stall the connection
+	return bytes.TrimSuffix(payload, []byte{endBlock}), nil
+}
+
+func frame(payload []byte) []byte {
+	out := make([]byte, 0, len(payload)+3)
+	out = append(out, startBlock)
+	out = append(out, payload...)
+	return append(out, endBlock, carriage)
+}
@@ -0,0 +1,52 @@
+package hl7
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReadFrame(t *testing.T) {
+	framed := func(payload string) string { return string(frame([]byte(payload))) }
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr string // From the read after the frames in want
+	}{
+		{name: "one frame", input: framed("MSH|a"), want: []string{"MSH|a"}, wantErr: "EOF"},
+		{name: "two frames", input: framed("MSH|a") + framed("MSH|b"), want: []string{"MSH|a", "MSH|b"}, wantErr: "EOF"},
+		{name: "noise before start block", input: "junk\r\n" + framed("MSH|a"), want: []string{"MSH|a"}, wantErr: "EOF"},
+		{name: "missing trailing CR", input: "\x0bMSH|a\x1c\x0bMSH|b\x1c\r", want: []string{"MSH|a", "MSH|b"}, wantErr: "EOF"},
+		{name: "payload larger than the read buffer", input: framed(strings.Repeat("x", 10000)), want: []string{strings.Repeat("x", 10000)}, wantErr: "EOF"},
+		{name: "unterminated frame", input: "\x0bMSH|a", wantErr: "EOF"},
+		{name: "empty input", input: "", wantErr: "EOF"},
+		{name: "oversized payload", input: "\x0b" + strings.Repeat("x", maxMessageSize+1) + "\x1c\r", wantErr: "exceeds"},
+		{name: "endless noise", input: strings.Repeat("x", 2*maxMessageSize) + framed("MSH|a"), wantErr: "no HL7 start block"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(tt.input))
+			for i, want := range tt.want {
+				got, err := readFrame(r)
+				if err != nil {
+					t.Fatalf("frame %d: readFrame() error = %v", i, err)
+				}
+				if string(got) != want {
+					t.Fatalf("frame %d: readFrame() = %.40q, want %.40q", i, got, want)
+				}
+			}
+			if _, err := readFrame(r); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("readFrame() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFrame(t *testing.T) {
+	got := frame([]byte("MSH|a"))
+	if want := []byte("\x0bMSH|a\x1c\r"); !bytes.Equal(got, want) {
+		t.Errorf("frame() = %q, want %q", got, want)
+	}
+}
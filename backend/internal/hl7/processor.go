@@ -0,0 +1,419 @@
+// File: backend/internal/hl7/processor.go
+package hl7
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ewag/gen-erics/backend/internal/audit"
+	"github.com/ewag/gen-erics/backend/internal/auth"
+	models "github.com/ewag/gen-erics/backend/internal/models"
+	"github.com/ewag/gen-erics/backend/internal/mover"
+	"github.com/ewag/gen-erics/backend/internal/orthanc"
+	"github.com/ewag/gen-erics/backend/internal/prefetch"
+	"github.com/ewag/gen-erics/backend/internal/storage"
+)
+
+// hl7Actor is recorded as the user behind changes driven by HL7 messages.
+const hl7Actor = "hl7"
+
+// Config controls message handling.
+type Config struct {
+	Sender        Sender
+	LocationEdges map[string]string // PV1-3 point of care -> edge ID for prefetched priors
+}
+
+// ParseLocationEdges parses "location:edge" pairs, e.g. "CT1:edge-a".
+func ParseLocationEdges(specs []string) (map[string]string, error) {
+	edges := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		location, edge, ok := strings.Cut(strings.TrimSpace(spec), ":")
+		if !ok || location == "" || edge == "" {
+			return nil, fmt.Errorf("invalid HL7 location mapping %q (want location:edge)", spec)
+		}
+		edges[location] = edge
+	}
+	return edges, nil
+}
+
+// Result is what handling a message did; it is stored in the message log.
+type Result struct {
+	PatientID       string   `json:"patientId,omitempty"`
+	PriorPatientIDs []string `json:"priorPatientIds,omitempty"` // A40 merges
+	OrthancPatients int      `json:"orthancPatients,omitempty"` // Patients rewritten in Orthanc
+	CatalogStudies  int64    `json:"catalogStudies,omitempty"`  // Offloaded studies updated in the catalog
+	RekeyedStudies  int      `json:"rekeyedStudies,omitempty"`  // Hot studies whose Orthanc ID changed
+	Jobs            []int64  `json:"jobs,omitempty"`            // Prefetch recalls queued
+	Note            string   `json:"note,omitempty"`
+
+	ignored bool
+}
+
+// Processor handles ORM^O01 orders, by prefetching the patient's priors,
+// and ADT^A08/A40 updates and merges, by rewriting the patient in Orthanc
+// and the catalog. Every message is logged with its outcome.
+//
+// Offloaded studies are updated in the catalog only: the objects in the
+// tiers keep the demographics they were stored with, and the mover rewrites
+// them from the catalog when it recalls the study.
+type Processor struct {
+	orthanc    *orthanc.Client
+	patients   storage.PatientStore
+	catalog    storage.CatalogStore
+	log        storage.HL7Store
+	holds      mover.HoldChecker
+	prefetcher *prefetch.Prefetcher
+	audit      *audit.Recorder
+	cfg        Config
+}
+
+// NewProcessor creates a Processor.
+func NewProcessor(orthancClient *orthanc.Client, patients storage.PatientStore, catalog storage.CatalogStore,
+	log storage.HL7Store, holds mover.HoldChecker, prefetcher *prefetch.Prefetcher, recorder *audit.Recorder, cfg Config) *Processor {
+	return &Processor{orthanc: orthancClient, patients: patients, catalog: catalog, log: log, holds: holds,
+		prefetcher: prefetcher, audit: recorder, cfg: cfg}
+}
+
+// Handle processes one message and returns its acknowledgment. It
+// implements HandlerFunc.
+func (p *Processor) Handle(ctx context.Context, raw []byte, remoteAddr string) []byte {
+	ctx = auth.WithPrincipal(ctx, &auth.Principal{Subject: hl7Actor, Method: "system"})
+	now := time.Now()
+	entry := &models.HL7Message{ReceivedAt: now, RemoteAddr: remoteAddr, Raw: string(raw)}
+
+	msg, err := Parse(raw)
+	if err != nil {
+		entry.Status, entry.AckCode, entry.Error = models.HL7StatusRejected, AckReject, err.Error()
+		p.record(ctx, entry)
+		return p.cfg.Sender.ACK(nil, AckReject, err.Error(), now)
+	}
+	messageType, trigger := msg.Type()
+	entry.ControlID = msg.ControlID()
+	entry.MessageType = messageType + "^" + trigger
+	logAttrs := []any{"controlID", entry.ControlID, "messageType", entry.MessageType, "remoteAddr", remoteAddr}
+
+	var result *Result
+	switch entry.MessageType {
+	case "ORM^O01":
+		result, err = p.handleOrder(ctx, msg)
+	case "ADT^A08":
+		result, err = p.handleUpdate(ctx, msg, remoteAddr)
+	case "ADT^A40":
+		result, err = p.handleMerge(ctx, msg, remoteAddr)
+	default:
+		result = &Result{Note: "message type not handled", ignored: true}
+	}
+
+	ackText := ""
+	switch {
+	case err != nil:
+		entry.Status, entry.AckCode, entry.Error = models.HL7StatusFailed, AckError, err.Error()
+		ackText = err.Error()
+		slog.ErrorContext(ctx, "Failed to process HL7 message", append(logAttrs, "error", err)...)
+	case result.ignored:
+		entry.Status, entry.AckCode = models.HL7StatusIgnored, AckAccept
+		slog.DebugContext(ctx, "Ignored HL7 message", append(logAttrs, "note", result.Note)...)
+	default:
+		entry.Status, entry.AckCode = models.HL7StatusProcessed, AckAccept
+		slog.InfoContext(ctx, "Processed HL7 message", append(logAttrs, "result", result)...)
+	}
+	if result != nil {
+		entry.Result, _ = json.Marshal(result)
+	}
+	p.record(ctx, entry)
+	return p.cfg.Sender.ACK(msg, entry.AckCode, ackText, now)
+}
+
+// record logs a message; the acknowledgment goes out even if this fails.
+func (p *Processor) record(ctx context.Context, entry *models.HL7Message) {
+	logCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	_ = p.log.LogHL7Message(logCtx, entry) // Error already logged in storage layer
+}
+
+// handleOrder prefetches priors for a new or replaced order (ORC-1 NW/XO),
+// matched on the ordered modality (OBR-24) and scheduled date (OBR-36,
+// else OBR-7), to the edge mapped from the order's location (PV1-3). Stat
+// and ASAP orders (ORC-7.6, else OBR-27.6) get stat recalls.
+func (p *Processor) handleOrder(ctx context.Context, msg *Message) (*Result, error) {
+	if control := msg.Get("ORC", 1, 0); control != "NW" && control != "XO" {
+		return &Result{Note: fmt.Sprintf("order control %q not handled", control), ignored: true}, nil
+	}
+	patientID := msg.Get("PID", 3, 1)
+	if patientID == "" {
+		return nil, fmt.Errorf("PID-3 patient identifier is missing")
+	}
+	result := &Result{PatientID: patientID}
+
+	modality := strings.ToUpper(msg.Get("OBR", 24, 0))
+	if modality == "" {
+		result.Note = "order has no modality (OBR-24)"
+		result.ignored = true
+		return result, nil
+	}
+	scheduled, _ := parseTS(msg.Get("OBR", 36, 1))
+	if scheduled.IsZero() {
+		scheduled, _ = parseTS(msg.Get("OBR", 7, 1))
+	}
+	order := msg.Get("ORC", 2, 1)
+	if order == "" {
+		order = msg.Get("OBR", 2, 1)
+	}
+
+	resourceIDs, err := p.patients.PatientResourceIDs(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if len(resourceIDs) == 0 {
+		result.Note = "patient has no offloaded studies"
+		return result, nil
+	}
+	for _, resourceID := range resourceIDs {
+		queued, err := p.prefetcher.Prefetch(ctx, prefetch.Trigger{
+			PatientResourceID: resourceID,
+			Modalities:        []string{modality},
+			StudyDate:         scheduled,
+			EdgeID:            p.cfg.LocationEdges[msg.Get("PV1", 3, 1)],
+			Reason:            "HL7 order " + order,
+			Priority:          orderPriority(msg),
+		})
+		for _, job := range queued {
+			result.Jobs = append(result.Jobs, job.ID)
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// handleUpdate applies updated demographics (ADT^A08) to the patient.
+func (p *Processor) handleUpdate(ctx context.Context, msg *Message, remoteAddr string) (*Result, error) {
+	pid, ok := msg.Segment("PID")
+	if !ok {
+		return nil, fmt.Errorf("PID segment is missing")
+	}
+	patientID := msg.Value(pid, 3, 1)
+	if patientID == "" {
+		return nil, fmt.Errorf("PID-3 patient identifier is missing")
+	}
+	d := demographics(msg, pid)
+	result := &Result{PatientID: patientID}
+	if d == (models.PatientDemographics{}) {
+		result.Note = "no demographics to update"
+		result.ignored = true
+		return result, nil
+	}
+
+	err := p.updatePatient(ctx, result, patientID, patientID, d)
+	p.recordPatientUpdate(ctx, msg, remoteAddr, patientID, d.PatientName, err)
+	return result, err
+}
+
+// handleMerge merges each prior patient (MRG-1) into the surviving patient
+// of the PID segment before it (ADT^A40).
+func (p *Processor) handleMerge(ctx context.Context, msg *Message, remoteAddr string) (*Result, error) {
+	result := &Result{}
+	var pid Segment
+	for _, seg := range msg.Segments {
+		switch seg[0] {
+		case "PID":
+			pid = seg
+		case "MRG":
+			if pid == nil {
+				return result, fmt.Errorf("MRG segment without a PID segment")
+			}
+			survivingID, priorID := msg.Value(pid, 3, 1), msg.Value(seg, 1, 1)
+			if survivingID == "" || priorID == "" {
+				return result, fmt.Errorf("merge needs PID-3 and MRG-1 patient identifiers")
+			}
+			result.PatientID = survivingID
+			if priorID == survivingID {
+				continue
+			}
+			result.PriorPatientIDs = append(result.PriorPatientIDs, priorID)
+			d := demographics(msg, pid)
+			err := p.updatePatient(ctx, result, priorID, survivingID, d)
+			p.recordPatientUpdate(ctx, msg, remoteAddr, survivingID, d.PatientName, err)
+			if err != nil {
+				return result, err
+			}
+		}
+	}
+	if len(result.PriorPatientIDs) == 0 {
+		return nil, fmt.Errorf("no MRG segment with a prior patient")
+	}
+	return result, nil
+}
+
+// updatePatient rewrites patientID in Orthanc and the catalog with the new
+// demographics and, when it differs, the surviving patient ID. Nothing is
+// changed if any of the patient's studies is under legal hold.
+func (p *Processor) updatePatient(ctx context.Context, result *Result, patientID, survivingID string, d models.PatientDemographics) error {
+	orthancIDs, err := p.orthanc.LookupPatients(ctx, patientID)
+	if err != nil {
+		return err
+	}
+	hot := make(map[string][]orthanc.StudyDetails, len(orthancIDs))
+	for _, id := range orthancIDs {
+		studies, err := p.orthanc.GetPatientStudies(ctx, id)
+		if err != nil {
+			return err
+		}
+		hot[id] = studies
+		for _, study := range studies {
+			if err := p.holds.CheckHold(ctx, study.ID); err != nil {
+				return err
+			}
+		}
+	}
+	if err := p.checkOffloadedHolds(ctx, patientID); err != nil {
+		return err
+	}
+
+	replace := map[string]string{"PatientID": survivingID}
+	for tag, value := range map[string]string{
+		"PatientName":      d.PatientName,
+		"PatientBirthDate": d.PatientBirthDate,
+		"PatientSex":       d.PatientSex,
+	} {
+		if value != "" {
+			replace[tag] = value
+		}
+	}
+	keepSource := false
+	for _, id := range orthancIDs {
+		if _, err := p.orthanc.ModifyPatient(ctx, id, orthanc.ModifyRequest{
+			Replace:    replace,
+			Keep:       []string{"StudyInstanceUID", "SeriesInstanceUID", "SOPInstanceUID"},
+			Force:      true,
+			KeepSource: &keepSource,
+		}); err != nil {
+			return fmt.Errorf("failed to update patient %s in Orthanc: %w", id, err)
+		}
+		result.OrthancPatients++
+		if survivingID == patientID {
+			continue
+		}
+		// Orthanc study IDs hash the PatientID, so merged studies get new ones
+		for _, study := range hot[id] {
+			newID := orthanc.StudyResourceID(survivingID, study.MainTags.StudyInstanceUID)
+			if err := p.patients.RekeyStudy(ctx, study.ID, newID); err != nil {
+				return err
+			}
+			result.RekeyedStudies++
+		}
+	}
+
+	var updated int64
+	if survivingID == patientID {
+		updated, err = p.patients.UpdatePatientDemographics(ctx, patientID, d)
+	} else {
+		updated, err = p.patients.MergePatient(ctx, patientID, survivingID, orthanc.PatientResourceID(survivingID), d)
+	}
+	if err != nil {
+		return err
+	}
+	result.CatalogStudies += updated
+	return nil
+}
+
+func (p *Processor) checkOffloadedHolds(ctx context.Context, patientID string) error {
+	resourceIDs, err := p.patients.PatientResourceIDs(ctx, patientID)
+	if err != nil {
+		return err
+	}
+	for _, resourceID := range resourceIDs {
+		studies, err := p.catalog.ListOffloadedStudies(ctx, resourceID)
+		if err != nil {
+			return err
+		}
+		for _, study := range studies {
+			if err := p.holds.CheckHold(ctx, study.StudyID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// recordPatientUpdate audits a demographics update or merge.
+func (p *Processor) recordPatientUpdate(ctx context.Context, msg *Message, remoteAddr, patientID, patientName string, err error) {
+	host, _, splitErr := net.SplitHostPort(remoteAddr)
+	if splitErr != nil {
+		host = remoteAddr
+	}
+	e := &audit.Event{
+		Identification: audit.EventIdentification{
+			EventID:    audit.EventPatientRecord,
+			ActionCode: audit.ActionUpdate,
+			Outcome:    audit.OutcomeSuccess,
+		},
+		Participants: []audit.ActiveParticipant{{
+			UserID:                     hl7Actor,
+			AlternativeUserID:          msg.Get("MSH", 3, 0),
+			UserIsRequestor:            true,
+			NetworkAccessPointID:       host,
+			NetworkAccessPointTypeCode: 2,
+		}},
+		Objects: []audit.ParticipantObject{audit.PatientObject(patientID, patientName)},
+	}
+	if err != nil {
+		e.Identification.Outcome = audit.OutcomeSeriousFailure
+		e.Identification.OutcomeDescription = err.Error()
+	}
+	p.audit.Record(ctx, e)
+}
+
+// demographics converts PID-5/7/8 to DICOM patient tags.
+func demographics(msg *Message, pid Segment) models.PatientDemographics {
+	// XPN is family^given^middle^suffix^prefix; PN is family^given^middle^prefix^suffix
+	name := msg.Components(pid, 5)
+	for len(name) < 5 {
+		name = append(name, "")
+	}
+	d := models.PatientDemographics{
+		PatientName: strings.TrimRight(strings.Join([]string{name[0], name[1], name[2], name[4], name[3]}, "^"), "^"),
+	}
+	if birth := msg.Value(pid, 7, 1); len(birth) >= 8 {
+		if _, ok := parseTS(birth[:8]); ok {
+			d.PatientBirthDate = birth[:8]
+		}
+	}
+	switch sex := strings.ToUpper(msg.Value(pid, 8, 0)); sex {
+	case "M", "F", "O":
+		d.PatientSex = sex
+	}
+	return d
+}
+
+// orderPriority maps an order's quantity/timing priority to a job priority.
+func orderPriority(msg *Message) string {
+	priority := msg.Get("ORC", 7, 6)
+	if priority == "" {
+		priority = msg.Get("OBR", 27, 6)
+	}
+	switch strings.ToUpper(priority) {
+	case "S", "A":
+		return models.JobPriorityStat
+	default:
+		return models.JobPriorityRoutine
+	}
+}
+
+// parseTS parses the date part of an HL7 TS/DTM value (YYYYMMDD...).
+func parseTS(value string) (time.Time, bool) {
+	if len(value) < 8 {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,99 @@
+// File: backend/internal/integrity/checksum.go
+package integrity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// ErrChecksumMismatch is returned when object content doesn't match its recorded SHA-256.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// HashingReader computes a SHA-256 over everything read through it.
+type HashingReader struct {
+	r io.Reader
+	h hash.Hash
+	n int64
+}
+
+// NewHashingReader wraps r so its content is hashed as it is consumed.
+func NewHashingReader(r io.Reader) *HashingReader {
+	return &HashingReader{r: r, h: sha256.New()}
+}
+
+func (hr *HashingReader) Read(p []byte) (int, error) {
+	n, err := hr.r.Read(p)
+	hr.h.Write(p[:n])
+	hr.n += int64(n)
+	return n, err
+}
+
+// Sum returns the hex encoded SHA-256 of the bytes read so far.
+func (hr *HashingReader) Sum() string { return hex.EncodeToString(hr.h.Sum(nil)) }
+
+// BytesRead returns the number of bytes read so far.
+func (hr *HashingReader) BytesRead() int64 { return hr.n }
+
+// SumReader drains r and returns its hex encoded SHA-256 and length.
+func SumReader(r io.Reader) (string, int64, error) {
+	hr := NewHashingReader(r)
+	if _, err := io.Copy(io.Discard, hr); err != nil {
+		return "", hr.BytesRead(), err
+	}
+	return hr.Sum(), hr.BytesRead(), nil
+}
+
+// verifyingReader checks content against an expected checksum while it is
+// being streamed. The final chunk is withheld until the checksum matches, so
+// a consumer never receives a complete-looking copy of a corrupt object.
+type verifyingReader struct {
+	rc       io.ReadCloser
+	hr       *HashingReader
+	expected string
+	size     int64
+	done     bool
+	err      error
+}
+
+// NewVerifyingReader wraps rc; reads fail with ErrChecksumMismatch instead of
+// returning the last bytes if the content doesn't hash to expected.
+// size is the expected length, or -1 if unknown.
+func NewVerifyingReader(rc io.ReadCloser, expected string, size int64) io.ReadCloser {
+	return &verifyingReader{rc: rc, hr: NewHashingReader(rc), expected: expected, size: size}
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	if v.err != nil {
+		return 0, v.err
+	}
+	if v.done {
+		return 0, io.EOF
+	}
+
+	n, err := v.hr.Read(p)
+	atEnd := errors.Is(err, io.EOF) || (v.size >= 0 && v.hr.BytesRead() >= v.size)
+	if err != nil && !errors.Is(err, io.EOF) {
+		v.err = err
+		return n, err
+	}
+	if !atEnd {
+		return n, nil
+	}
+
+	if v.size >= 0 && v.hr.BytesRead() != v.size {
+		v.err = fmt.Errorf("%w: expected %d bytes, read %d", ErrChecksumMismatch, v.size, v.hr.BytesRead())
+		return 0, v.err
+	}
+	if sum := v.hr.Sum(); sum != v.expected {
+		v.err = fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, v.expected, sum)
+		return 0, v.err
+	}
+	v.done = true
+	return n, nil
+}
+
+func (v *verifyingReader) Close() error { return v.rc.Close() }
@@ -0,0 +1,67 @@
+// File: backend/internal/integrity/metrics.go
+package integrity
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const meterName = "github.com/ewag/gen-erics/backend/internal/integrity"
+
+// Metrics holds the OTel instruments for integrity checks.
+// Alert on gen_erics.integrity.corrupt > 0 in SigNoz.
+type Metrics struct {
+	verified metric.Int64Counter
+	corrupt  metric.Int64Counter
+	scrubbed metric.Int64Counter
+}
+
+// NewMetrics registers the integrity instruments on the global meter provider.
+// Call after OTel is initialised.
+func NewMetrics() *Metrics {
+	meter := otel.Meter(meterName)
+	m := &Metrics{}
+	var err error
+	if m.verified, err = meter.Int64Counter("gen_erics.integrity.verified",
+		metric.WithDescription("Objects whose checksum was verified successfully")); err != nil {
+		slog.Warn("Failed to create integrity metric", "metric", "verified", "error", err)
+	}
+	if m.corrupt, err = meter.Int64Counter("gen_erics.integrity.corrupt",
+		metric.WithDescription("Objects that failed checksum verification or were missing")); err != nil {
+		slog.Warn("Failed to create integrity metric", "metric", "corrupt", "error", err)
+	}
+	if m.scrubbed, err = meter.Int64Counter("gen_erics.integrity.scrubbed_bytes",
+		metric.WithDescription("Bytes re-read by the background scrubber"), metric.WithUnit("By")); err != nil {
+		slog.Warn("Failed to create integrity metric", "metric", "scrubbed_bytes", "error", err)
+	}
+	return m
+}
+
+// RecordResult counts one verification outcome for a tier.
+// source is where the check happened: "scrub", "transition" or "retrieval".
+func (m *Metrics) RecordResult(ctx context.Context, tier, source string, ok bool) {
+	if m == nil {
+		return
+	}
+	attrs := metric.WithAttributes(attribute.String("tier", tier), attribute.String("source", source))
+	if ok {
+		if m.verified != nil {
+			m.verified.Add(ctx, 1, attrs)
+		}
+		return
+	}
+	if m.corrupt != nil {
+		m.corrupt.Add(ctx, 1, attrs)
+	}
+}
+
+func (m *Metrics) recordScrubbedBytes(ctx context.Context, tier string, n int64) {
+	if m == nil || m.scrubbed == nil {
+		return
+	}
+	m.scrubbed.Add(ctx, n, metric.WithAttributes(attribute.String("tier", tier)))
+}
@@ -0,0 +1,46 @@
+// File: backend/internal/integrity/ratelimit.go
+package integrity
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// rateLimitedReader paces reads so throughput stays at or below bytesPerSec.
+// A non-positive limit disables pacing.
+type rateLimitedReader struct {
+	ctx         context.Context
+	r           io.Reader
+	bytesPerSec int64
+	start       time.Time
+	read        int64
+}
+
+func newRateLimitedReader(ctx context.Context, r io.Reader, bytesPerSec int64) io.Reader {
+	if bytesPerSec <= 0 {
+		return r
+	}
+	return &rateLimitedReader{ctx: ctx, r: r, bytesPerSec: bytesPerSec, start: time.Now()}
+}
+
+func (rl *rateLimitedReader) Read(p []byte) (int, error) {
+	// Cap each read at a tenth of a second's budget to keep pacing smooth.
+	if max := rl.bytesPerSec / 10; max > 0 && int64(len(p)) > max {
+		p = p[:max]
+	}
+	n, err := rl.r.Read(p)
+	rl.read += int64(n)
+
+	allowedAt := rl.start.Add(time.Duration(float64(rl.read) / float64(rl.bytesPerSec) * float64(time.Second)))
+	if wait := time.Until(allowedAt); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-rl.ctx.Done():
+			return n, rl.ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,135 @@
+// File: backend/internal/integrity/scrubber.go
+package integrity
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	models "github.com/ewag/gen-erics/backend/internal/models"
+	"github.com/ewag/gen-erics/backend/internal/storage"
+	"github.com/ewag/gen-erics/backend/internal/tiers"
+)
+
+// ScrubberConfig controls how aggressively cold/archive objects are re-read.
+type ScrubberConfig struct {
+	Interval       time.Duration // Pause between scrub passes
+	MinAge         time.Duration // Re-verify objects not verified for this long
+	BatchSize      int           // Entries fetched per pass
+	BytesPerSecond int64         // Read throughput cap; 0 disables pacing
+	Tiers          []string      // Tiers to scrub, e.g. "cold", "archive"
+}
+
+// Scrubber periodically re-reads objects in tier backends and checks them
+// against the SHA-256 recorded in the catalog.
+type Scrubber struct {
+	catalog storage.CatalogStore
+	tiers   *tiers.Registry
+	metrics *Metrics
+	cfg     ScrubberConfig
+}
+
+// NewScrubber creates a Scrubber; call Run to start it.
+func NewScrubber(catalog storage.CatalogStore, registry *tiers.Registry, metrics *Metrics, cfg ScrubberConfig) *Scrubber {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Hour
+	}
+	return &Scrubber{catalog: catalog, tiers: registry, metrics: metrics, cfg: cfg}
+}
+
+// Run scrubs until ctx is cancelled.
+func (s *Scrubber) Run(ctx context.Context) {
+	slog.InfoContext(ctx, "Integrity scrubber started", "tiers", s.cfg.Tiers, "interval", s.cfg.Interval,
+		"minAge", s.cfg.MinAge, "bytesPerSecond", s.cfg.BytesPerSecond)
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.ScrubOnce(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			slog.ErrorContext(ctx, "Integrity scrub pass failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("Integrity scrubber stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ScrubOnce verifies one batch of the least recently verified objects.
+func (s *Scrubber) ScrubOnce(ctx context.Context) error {
+	entries, err := s.catalog.ListEntriesToScrub(ctx, s.cfg.Tiers, time.Now().Add(-s.cfg.MinAge), s.cfg.BatchSize)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		slog.DebugContext(ctx, "Integrity scrub found nothing due for verification")
+		return nil
+	}
+
+	var ok, failed int
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if s.verifyEntry(ctx, entry) {
+			ok++
+		} else {
+			failed++
+		}
+	}
+	slog.InfoContext(ctx, "Integrity scrub pass complete", "checked", len(entries), "ok", ok, "failed", failed)
+	return nil
+}
+
+// verifyEntry re-reads one object and records the outcome. Returns true if it is intact.
+func (s *Scrubber) verifyEntry(ctx context.Context, entry models.CatalogEntry) bool {
+	logAttrs := []any{"instanceID", entry.InstanceID, "studyID", entry.StudyID, "tier", entry.Tier, "objectKey", entry.ObjectKey}
+
+	backend, found := s.tiers.For(entry.Tier)
+	if !found {
+		slog.WarnContext(ctx, "No backend configured for catalogued tier, skipping", logAttrs...)
+		return true
+	}
+
+	status := models.VerifyStatusOK
+	rc, _, err := tiers.OpenEntry(ctx, backend, entry.ObjectKey, entry.EncryptionKeyID)
+	switch {
+	case errors.Is(err, tiers.ErrObjectNotFound):
+		status = models.VerifyStatusMissing
+	case err != nil:
+		// Backend unreachable isn't corruption; try again next pass.
+		slog.WarnContext(ctx, "Failed to open object for scrubbing", append(logAttrs, "error", err)...)
+		return true
+	default:
+		sum, n, readErr := SumReader(newRateLimitedReader(ctx, rc, s.cfg.BytesPerSecond))
+		rc.Close()
+		s.metrics.recordScrubbedBytes(ctx, entry.Tier, n)
+		if readErr != nil {
+			if ctx.Err() == nil {
+				slog.WarnContext(ctx, "Failed to read object for scrubbing", append(logAttrs, "error", readErr)...)
+			}
+			return true
+		}
+		if sum != entry.SHA256 || n != entry.SizeBytes {
+			status = models.VerifyStatusCorrupt
+			logAttrs = append(logAttrs, "expectedSHA256", entry.SHA256, "actualSHA256", sum,
+				"expectedSize", entry.SizeBytes, "actualSize", n)
+		}
+	}
+
+	intact := status == models.VerifyStatusOK
+	s.metrics.RecordResult(ctx, entry.Tier, "scrub", intact)
+	if !intact {
+		slog.ErrorContext(ctx, "INTEGRITY ALERT: stored object failed verification", append(logAttrs, "verifyStatus", status)...)
+	}
+	if err := s.catalog.RecordVerification(ctx, entry.InstanceID, entry.Tier, status, time.Now()); err != nil {
+		slog.WarnContext(ctx, "Failed to record scrub result", append(logAttrs, "error", err)...)
+	}
+	return intact
+}
@@ -0,0 +1,331 @@
+// File: backend/internal/jobs/batch.go
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ewag/gen-erics/backend/internal/auth"
+	models "github.com/ewag/gen-erics/backend/internal/models"
+	"github.com/ewag/gen-erics/backend/internal/mover"
+	"github.com/ewag/gen-erics/backend/internal/orthanc"
+	"github.com/ewag/gen-erics/backend/internal/retention"
+	"github.com/ewag/gen-erics/backend/internal/storage"
+)
+
+// Errors returned when selecting studies for a bulk move.
+var (
+	ErrInvalidSelection = errors.New("invalid study selection")
+	ErrBatchTooLarge    = errors.New("too many studies selected")
+)
+
+// BatchRequest asks for many studies to be moved to one tier. Studies are
+// selected either by StudyIDs or by Filter.
+type BatchRequest struct {
+	StudyIDs       []string
+	Filter         *models.StudyFilter
+	TargetTier     string
+	TargetLocation string // Edge ID for hot targets
+	Reason         string
+	NotBefore      time.Time // As in MoveOptions
+	InWindow       bool
+	Priority       string // Of the jobs; background if empty, so bulk work yields to single moves
+}
+
+// findPageSize is how many Orthanc matches hotStudies reads per request.
+const findPageSize = 1000
+
+// Selection is a study picked for a bulk move and where it is now.
+type Selection struct {
+	StudyID string                `json:"studyId"`
+	Current models.LocationStatus `json:"currentStatus"`
+}
+
+// Batcher runs bulk moves: it resolves the selection to studies and queues
+// one move job per study under a batch whose progress is read from its jobs.
+type Batcher struct {
+	queue      *Queue
+	orthanc    *orthanc.Client
+	search     storage.SearchStore
+	batches    storage.BatchStore
+	maxStudies int
+}
+
+// NewBatcher creates a Batcher. A selection of more than maxStudies studies
+// is refused rather than truncated.
+func NewBatcher(queue *Queue, orthancClient *orthanc.Client, search storage.SearchStore, batches storage.BatchStore, maxStudies int) *Batcher {
+	return &Batcher{queue: queue, orthanc: orthancClient, search: search, batches: batches, maxStudies: maxStudies}
+}
+
+// Select resolves req to the studies it would move, without queueing anything.
+func (b *Batcher) Select(ctx context.Context, req BatchRequest) ([]Selection, error) {
+	switch {
+	case len(req.StudyIDs) > 0 && req.Filter != nil:
+		return nil, fmt.Errorf("%w: give either study IDs or a filter, not both", ErrInvalidSelection)
+	case len(req.StudyIDs) > 0:
+		return b.selectStudies(ctx, req.StudyIDs)
+	case req.Filter != nil:
+		return b.selectByFilter(ctx, *req.Filter)
+	default:
+		return nil, fmt.Errorf("%w: give study IDs or a filter", ErrInvalidSelection)
+	}
+}
+
+// Create selects the studies of req and queues a move job for each under a
+// new batch. Studies that are deleted, trashed, already where they should go
+// or already have an active job are recorded as skipped.
+func (b *Batcher) Create(ctx context.Context, req BatchRequest) (*models.MoveBatch, error) {
+	selected, err := b.Select(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := &models.MoveBatch{
+		TargetTier:     req.TargetTier,
+		TargetLocation: req.TargetLocation,
+		StudyIDs:       req.StudyIDs,
+		Filter:         req.Filter,
+		Reason:         req.Reason,
+		RequestedBy:    auth.ActorFromContext(ctx),
+		CreatedAt:      time.Now(),
+	}
+	if err := b.batches.CreateBatch(ctx, batch); err != nil {
+		return nil, err
+	}
+	// Queueing a large selection takes a while; a client that stops waiting
+	// must not leave the batch half queued
+	ctx = context.WithoutCancel(ctx)
+
+	priority := req.Priority
+	if priority == "" {
+		priority = models.JobPriorityBackground
+	}
+	opts := MoveOptions{
+		Source:    SourceBatch,
+		Priority:  priority,
+		Reason:    req.Reason,
+		NotBefore: req.NotBefore,
+		InWindow:  req.InWindow,
+		BatchID:   batch.ID,
+	}
+	skipped := []models.BatchSkip{}
+	queued := 0
+	for _, s := range selected {
+		if reason := skipReason(s.Current, req.TargetTier, req.TargetLocation); reason != "" {
+			skipped = append(skipped, models.BatchSkip{StudyID: s.StudyID, Reason: reason})
+			continue
+		}
+		job, created, err := b.queue.ScheduleMove(ctx, s.StudyID, req.TargetTier, req.TargetLocation, opts)
+		switch {
+		case err != nil:
+			slog.WarnContext(ctx, "Failed to queue move of batch", "batchID", batch.ID, "studyID", s.StudyID, "error", err)
+			skipped = append(skipped, models.BatchSkip{StudyID: s.StudyID, Reason: err.Error()})
+		case !created:
+			skipped = append(skipped, models.BatchSkip{StudyID: s.StudyID, Reason: "study already has an active job", JobID: job.ID})
+		default:
+			queued++
+		}
+	}
+	if len(skipped) > 0 {
+		if err := b.batches.SetBatchSkipped(ctx, batch.ID, skipped); err != nil {
+			return nil, err
+		}
+	}
+	slog.InfoContext(ctx, "Bulk move queued", "batchID", batch.ID, "targetTier", req.TargetTier,
+		"selected", len(selected), "queued", queued, "skipped", len(skipped))
+
+	stored, found, err := b.batches.GetBatch(ctx, batch.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("batch %d vanished after creation", batch.ID)
+	}
+	return stored, nil
+}
+
+// Get returns a batch with its progress and failures.
+func (b *Batcher) Get(ctx context.Context, id int64) (*models.MoveBatch, bool, error) {
+	return b.batches.GetBatch(ctx, id)
+}
+
+// List returns batches with their progress, newest first.
+func (b *Batcher) List(ctx context.Context, limit int) ([]models.MoveBatch, error) {
+	return b.batches.ListBatches(ctx, limit)
+}
+
+// skipReason says why a study is not moved, or "" if it is.
+func skipReason(current models.LocationStatus, tier, location string) string {
+	switch {
+	case current.Tier == retention.TierDeleted:
+		return "study has been deleted"
+	case current.Tier == retention.TierTrash:
+		return "study is in the trash"
+	case current.Tier != tier:
+		return ""
+	case tier != mover.TierHot || location == "" || current.Edge() == location:
+		return "study is already in the target tier"
+	}
+	return ""
+}
+
+// selectStudies looks up where explicitly listed studies are.
+func (b *Batcher) selectStudies(ctx context.Context, studyIDs []string) ([]Selection, error) {
+	seen := make(map[string]bool, len(studyIDs))
+	var ids []string
+	for _, id := range studyIDs {
+		if id == "" {
+			return nil, fmt.Errorf("%w: empty study ID", ErrInvalidSelection)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > b.maxStudies {
+		return nil, fmt.Errorf("%w: %d studies given, at most %d per batch", ErrBatchTooLarge, len(ids), b.maxStudies)
+	}
+	return b.withStatuses(ctx, ids)
+}
+
+// selectByFilter finds the studies matching f: hot ones through Orthanc
+// (and, for f.EdgeID, the status table), offloaded ones through the catalog.
+func (b *Batcher) selectByFilter(ctx context.Context, f models.StudyFilter) ([]Selection, error) {
+	if err := normalizeFilter(&f); err != nil {
+		return nil, err
+	}
+	wantHot := f.Tier == "" || f.Tier == mover.TierHot
+	wantOffloaded := f.Tier != mover.TierHot && f.EdgeID == ""
+	limit := b.maxStudies + 1 // One more to tell a full selection from too large a one
+
+	var selected []Selection
+	if wantHot {
+		ids, err := b.hotStudies(ctx, f, limit)
+		if err != nil {
+			return nil, err
+		}
+		hot, err := b.withStatuses(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		selected = append(selected, hot...)
+	}
+	if wantOffloaded && len(selected) <= b.maxStudies {
+		studies, err := b.search.SearchOffloadedStudies(ctx, f, limit-len(selected))
+		if err != nil {
+			return nil, err
+		}
+		for _, st := range studies {
+			selected = append(selected, Selection{StudyID: st.StudyID, Current: TargetStatus(st.Tier, "")})
+		}
+	}
+	if len(selected) > b.maxStudies {
+		return nil, fmt.Errorf("%w: the filter matches more than %d studies; narrow it", ErrBatchTooLarge, b.maxStudies)
+	}
+	return selected, nil
+}
+
+// hotStudies returns the IDs of Orthanc studies matching f.
+func (b *Batcher) hotStudies(ctx context.Context, f models.StudyFilter, limit int) ([]string, error) {
+	query := map[string]string{}
+	if f.DateFrom != "" || f.DateTo != "" {
+		query["StudyDate"] = f.DateFrom + "-" + f.DateTo
+	}
+	if f.PatientID != "" {
+		query["PatientID"] = f.PatientID
+	}
+	if f.Modality != "" {
+		query["ModalitiesInStudy"] = f.Modality
+	}
+
+	var onEdge map[string]bool
+	if f.EdgeID != "" {
+		ids, err := b.search.HotStudiesOnEdge(ctx, f.EdgeID)
+		if err != nil {
+			return nil, err
+		}
+		if len(query) == 0 {
+			return capIDs(ids, limit), nil
+		}
+		// Orthanc can't filter by edge, so intersect with its matches
+		onEdge = make(map[string]bool, len(ids))
+		for _, id := range ids {
+			onEdge[id] = true
+		}
+	}
+
+	// Page through the matches so a large selection isn't one huge response
+	var ids []string
+	for since := 0; len(ids) < limit; since += findPageSize {
+		studies, err := b.orthanc.FindStudies(ctx, query, since, findPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search Orthanc: %w", err)
+		}
+		for _, st := range studies {
+			if onEdge == nil || onEdge[st.ID] {
+				ids = append(ids, st.ID)
+			}
+		}
+		if len(studies) < findPageSize {
+			break
+		}
+	}
+	return capIDs(ids, limit), nil
+}
+
+// withStatuses pairs study IDs with their status; studies without a status
+// row have never left Orthanc.
+func (b *Batcher) withStatuses(ctx context.Context, ids []string) ([]Selection, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	statuses, err := b.search.GetStatuses(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	selected := make([]Selection, 0, len(ids))
+	for _, id := range ids {
+		current, ok := statuses[id]
+		if !ok {
+			current = models.LocationStatus{Tier: mover.TierHot}
+		}
+		selected = append(selected, Selection{StudyID: id, Current: current})
+	}
+	return selected, nil
+}
+
+// normalizeFilter checks f and turns its dates into DICOM DA.
+func normalizeFilter(f *models.StudyFilter) error {
+	if *f == (models.StudyFilter{}) {
+		return fmt.Errorf("%w: the filter needs at least one criterion", ErrInvalidSelection)
+	}
+	if f.EdgeID != "" && f.Tier != "" && f.Tier != mover.TierHot {
+		return fmt.Errorf("%w: edgeId only applies to hot studies", ErrInvalidSelection)
+	}
+	for _, d := range []*string{&f.DateFrom, &f.DateTo} {
+		if *d == "" {
+			continue
+		}
+		da := strings.ReplaceAll(*d, "-", "")
+		if _, err := time.Parse("20060102", da); err != nil {
+			return fmt.Errorf("%w: date %q (want YYYYMMDD or YYYY-MM-DD)", ErrInvalidSelection, *d)
+		}
+		*d = da
+	}
+	if f.DateFrom != "" && f.DateTo != "" && f.DateFrom > f.DateTo {
+		return fmt.Errorf("%w: dateFrom is after dateTo", ErrInvalidSelection)
+	}
+	return nil
+}
+
+// capIDs returns at most limit of ids.
+func capIDs(ids []string, limit int) []string {
+	if len(ids) > limit {
+		return ids[:limit]
+	}
+	return ids
+}
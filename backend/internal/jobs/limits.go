@@ -0,0 +1,80 @@
+// File: backend/internal/jobs/limits.go
+package jobs
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ewag/gen-erics/backend/internal/maintenance"
+	models "github.com/ewag/gen-erics/backend/internal/models"
+)
+
+// Priorities lists the job priorities, most urgent first.
+var Priorities = []string{models.JobPriorityStat, models.JobPriorityRoutine, models.JobPriorityBackground}
+
+// ValidPriority reports whether p is a job priority.
+func ValidPriority(p string) bool {
+	for _, known := range Priorities {
+		if p == known {
+			return true
+		}
+	}
+	return false
+}
+
+// ConcurrencyLimits caps how many jobs may run at once on an edge or a tier
+// backend, across all replicas. Keys are "kind:name"; a name of "*" caps
+// each edge or tier of that kind without a cap of its own.
+type ConcurrencyLimits map[string]int
+
+// ParseConcurrencyLimits parses "kind:name:max" specs, kind being "edge" or
+// "tier", e.g. "edge:*:2" or "tier:archive:1".
+func ParseConcurrencyLimits(specs []string) (ConcurrencyLimits, error) {
+	limits := make(ConcurrencyLimits)
+	for _, spec := range specs {
+		parts := strings.Split(strings.TrimSpace(spec), ":")
+		if len(parts) != 3 || parts[1] == "" {
+			return nil, fmt.Errorf("invalid concurrency limit %q (want kind:name:max)", spec)
+		}
+		if parts[0] != maintenance.KindEdge && parts[0] != maintenance.KindTier {
+			return nil, fmt.Errorf("invalid concurrency limit %q (kind must be %s or %s)", spec, maintenance.KindEdge, maintenance.KindTier)
+		}
+		n, err := strconv.Atoi(parts[2])
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid concurrency limit %q (max must be a positive number)", spec)
+		}
+		limits[parts[0]+":"+parts[1]] = n
+	}
+	return limits, nil
+}
+
+// String lists the limits in a stable order, for logs.
+func (l ConcurrencyLimits) String() string {
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		keys[i] = fmt.Sprintf("%s:%d", k, l[k])
+	}
+	return strings.Join(keys, ",")
+}
+
+// resourceKeys renders resources as the "kind:name" keys jobs are capped by,
+// each once.
+func resourceKeys(resources []maintenance.Resource) []string {
+	keys := []string{}
+	seen := make(map[string]bool, len(resources))
+	for _, r := range resources {
+		key := r.Kind + ":" + r.Name
+		if r.Name == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,84 @@
+package jobs
+
+import (
+	"slices"
+	"testing"
+
+	models "github.com/ewag/gen-erics/backend/internal/models"
+)
+
+func TestParseConcurrencyLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		want    ConcurrencyLimits
+		wantErr bool
+	}{
+		{name: "none", want: ConcurrencyLimits{}},
+		{name: "edge wildcard and a tier", specs: []string{"edge:*:2", " tier:archive:1 "},
+			want: ConcurrencyLimits{"edge:*": 2, "tier:archive": 1}},
+		{name: "later spec wins", specs: []string{"tier:cold:4", "tier:cold:2"}, want: ConcurrencyLimits{"tier:cold": 2}},
+		{name: "unknown kind", specs: []string{"site:a:1"}, wantErr: true},
+		{name: "missing name", specs: []string{"edge::1"}, wantErr: true},
+		{name: "missing max", specs: []string{"edge:a"}, wantErr: true},
+		{name: "zero max", specs: []string{"edge:a:0"}, wantErr: true},
+		{name: "negative max", specs: []string{"edge:a:-1"}, wantErr: true},
+		{name: "not a number", specs: []string{"edge:a:two"}, wantErr: true},
+		{name: "too many parts", specs: []string{"edge:a:1:2"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseConcurrencyLimits(tt.specs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseConcurrencyLimits() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.String() != tt.want.String() {
+				t.Errorf("ParseConcurrencyLimits() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoveResourceKeys(t *testing.T) {
+	edge := "edge-a"
+	hotOnEdge := models.LocationStatus{Tier: "hot", EdgeID: &edge}
+	tests := []struct {
+		name     string
+		current  models.LocationStatus
+		tier     string
+		location string
+		want     []string
+	}{
+		{name: "hot to cold loads the source edge", current: hotOnEdge, tier: "cold",
+			want: []string{"tier:hot", "tier:cold", "edge:edge-a"}},
+		{name: "recall loads the target edge", current: models.LocationStatus{Tier: "archive"}, tier: "hot", location: "edge-b",
+			want: []string{"tier:archive", "tier:hot", "edge:edge-b"}},
+		{name: "between cloud tiers", current: models.LocationStatus{Tier: "cold"}, tier: "archive",
+			want: []string{"tier:cold", "tier:archive"}},
+		{name: "same tier counted once", current: models.LocationStatus{Tier: "cold"}, tier: "cold",
+			want: []string{"tier:cold"}},
+		{name: "hot without an edge", current: models.LocationStatus{Tier: "hot"}, tier: "cold",
+			want: []string{"tier:hot", "tier:cold"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resourceKeys(Resources(tt.current, tt.tier, tt.location))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("resource keys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidPriority(t *testing.T) {
+	for _, p := range Priorities {
+		if !ValidPriority(p) {
+			t.Errorf("ValidPriority(%q) = false", p)
+		}
+	}
+	for _, p := range []string{"", "urgent", "STAT"} {
+		if ValidPriority(p) {
+			t.Errorf("ValidPriority(%q) = true", p)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// File: backend/internal/jobs/queue.go
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ewag/gen-erics/backend/internal/auth"
+	"github.com/ewag/gen-erics/backend/internal/maintenance"
+	models "github.com/ewag/gen-erics/backend/internal/models"
+	"github.com/ewag/gen-erics/backend/internal/mover"
+	"github.com/ewag/gen-erics/backend/internal/storage"
+)
+
+// Job sources.
+const (
+	SourceAPI      = "api"
+	SourcePrefetch = "prefetch"
+	SourceBatch    = "batch"
+)
+
+// ErrNoWindow is returned for window-bound moves whose edges and tiers
+// never share an open maintenance window.
+var ErrNoWindow = errors.New("no common maintenance window")
+
+// Queue enqueues and looks up background jobs.
+type Queue struct {
+	store    storage.JobStore
+	status   storage.StatusStore
+	schedule *maintenance.Schedule
+}
+
+// NewQueue creates a Queue. schedule may be nil when no maintenance windows
+// are configured.
+func NewQueue(store storage.JobStore, status storage.StatusStore, schedule *maintenance.Schedule) *Queue {
+	return &Queue{store: store, status: status, schedule: schedule}
+}
+
+// MoveOptions describe a queued move beyond its study and target.
+type MoveOptions struct {
+	Source    string // What asks for the move, e.g. SourceAPI
+	Reason    string
+	Priority  string    // models.JobPriority*; routine if empty
+	NotBefore time.Time // Not started before this time; zero for now
+	// InWindow only starts the move inside a maintenance window of every
+	// edge and tier it touches; the job's NotBefore is then set to the next
+	// such window.
+	InWindow bool
+	BatchID  int64 // Bulk move the job belongs to, if any
+}
+
+// ScheduleMove queues a move of studyID to tier (and, for hot, the edge
+// location). A study has at most one active job: if one is already queued
+// or running it is returned with created=false. The job records the edges
+// and tiers the move loads, so the workers can cap how many run on each.
+func (q *Queue) ScheduleMove(ctx context.Context, studyID, tier, location string, opts MoveOptions) (*models.Job, bool, error) {
+	priority := opts.Priority
+	if priority == "" {
+		priority = models.JobPriorityRoutine
+	}
+	if !ValidPriority(priority) {
+		return nil, false, fmt.Errorf("unknown job priority %q", priority)
+	}
+	current, err := currentStatus(ctx, q.status, studyID)
+	if err != nil {
+		return nil, false, err
+	}
+	resources := Resources(current, tier, location)
+
+	notBefore := opts.NotBefore
+	if opts.InWindow {
+		start := notBefore
+		if start.IsZero() {
+			start = time.Now()
+		}
+		next, ok := q.schedule.NextOpen(resources, start)
+		if !ok {
+			return nil, false, fmt.Errorf("%w for moving study %s to %s", ErrNoWindow, studyID, tier)
+		}
+		if next.After(time.Now()) {
+			notBefore = next
+		}
+	}
+	job := &models.Job{
+		Kind:           models.JobKindMove,
+		StudyID:        studyID,
+		TargetTier:     tier,
+		TargetLocation: location,
+		Source:         opts.Source,
+		Reason:         opts.Reason,
+		Priority:       priority,
+		Resources:      resourceKeys(resources),
+		RequestedBy:    auth.ActorFromContext(ctx),
+		CreatedAt:      time.Now(),
+		WindowRequired: opts.InWindow,
+	}
+	if !notBefore.IsZero() {
+		job.NotBefore = &notBefore
+	}
+	if opts.BatchID != 0 {
+		job.BatchID = &opts.BatchID
+	}
+	created, err := q.store.EnqueueJob(ctx, job)
+	if err != nil {
+		return nil, false, err
+	}
+	return job, created, nil
+}
+
+// Get returns a job by ID.
+func (q *Queue) Get(ctx context.Context, id int64) (*models.Job, bool, error) {
+	return q.store.GetJob(ctx, id)
+}
+
+// Requeue queues a failed or dead job again with a fresh set of attempts.
+func (q *Queue) Requeue(ctx context.Context, id int64) (*models.Job, bool, error) {
+	return q.store.RequeueJob(ctx, id)
+}
+
+// List returns jobs matching f, newest first.
+func (q *Queue) List(ctx context.Context, f storage.JobFilter) ([]models.Job, error) {
+	return q.store.ListJobs(ctx, f)
+}
+
+// Resources lists the edges and tier backends a move from current to tier
+// (and location, for hot) transfers over.
+func Resources(current models.LocationStatus, tier, location string) []maintenance.Resource {
+	resources := []maintenance.Resource{
+		{Kind: maintenance.KindTier, Name: current.Tier},
+		{Kind: maintenance.KindTier, Name: tier},
+	}
+	if edge := TransferEdge(current, tier, location); edge != "" {
+		resources = append(resources, maintenance.Resource{Kind: maintenance.KindEdge, Name: edge})
+	}
+	return resources
+}
+
+// TransferEdge is the edge whose uplink a move loads: the edge a hot study
+// leaves, or the one it is recalled to.
+func TransferEdge(current models.LocationStatus, tier, location string) string {
+	if current.Tier == mover.TierHot {
+		return current.Edge()
+	}
+	if tier == mover.TierHot {
+		return location
+	}
+	return ""
+}
+
+// currentStatus returns where a study is; studies without a status row have
+// never left Orthanc.
+func currentStatus(ctx context.Context, status storage.StatusStore, studyID string) (models.LocationStatus, error) {
+	current, found, err := status.GetStatus(ctx, studyID)
+	if err != nil {
+		return models.LocationStatus{}, err
+	}
+	if !found {
+		return models.LocationStatus{Tier: mover.TierHot}, nil
+	}
+	return *current, nil
+}
+
+// TargetStatus is the status a study has once moved to tier: on the given
+// edge for hot, in the cloud otherwise.
+func TargetStatus(tier, location string) models.LocationStatus {
+	switch {
+	case tier == mover.TierHot && location != "":
+		edgeID := location
+		return models.LocationStatus{Tier: tier, LocationType: "edge", EdgeID: &edgeID}
+	case tier == mover.TierHot:
+		return models.LocationStatus{Tier: tier, LocationType: "unknown"}
+	default:
+		return models.LocationStatus{Tier: tier, LocationType: "cloud"}
+	}
+}
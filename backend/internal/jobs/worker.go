@@ -0,0 +1,326 @@
+// File: backend/internal/jobs/worker.go
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ewag/gen-erics/backend/internal/auth"
+	"github.com/ewag/gen-erics/backend/internal/maintenance"
+	models "github.com/ewag/gen-erics/backend/internal/models"
+	"github.com/ewag/gen-erics/backend/internal/mover"
+	"github.com/ewag/gen-erics/backend/internal/retention"
+	"github.com/ewag/gen-erics/backend/internal/retry"
+	"github.com/ewag/gen-erics/backend/internal/storage"
+	"github.com/ewag/gen-erics/backend/internal/thumbnail"
+)
+
+// WorkerConfig controls the job worker pool.
+type WorkerConfig struct {
+	ID           string        // Names this replica's pool in claimed jobs; must be unique per replica
+	Workers      int           // Jobs run concurrently by this replica
+	PollInterval time.Duration // Pause when no job can be claimed
+	// Lease is how long a running job survives without a heartbeat before
+	// another replica requeues it. Heartbeats are sent every Lease/3.
+	Lease  time.Duration
+	Limits ConcurrencyLimits // Caps on running jobs per edge and tier, across replicas
+	// Retry sets how often a job is run before it is dead-lettered and the
+	// backoff between runs. Jobs failing for good aren't retried at all.
+	Retry retry.Policy
+}
+
+// errDeferred reports that a job was put back in the queue rather than run.
+var errDeferred = errors.New("job deferred")
+
+// Worker runs queued jobs.
+type Worker struct {
+	store      storage.JobStore
+	status     storage.StatusStore
+	mover      *mover.Mover
+	thumbnails *thumbnail.Service
+	schedule   *maintenance.Schedule
+	cfg        WorkerConfig
+}
+
+// NewWorker creates a Worker; call Run to start it. schedule may be nil when
+// no maintenance windows are configured.
+func NewWorker(store storage.JobStore, status storage.StatusStore, studyMover *mover.Mover, thumbnails *thumbnail.Service,
+	schedule *maintenance.Schedule, cfg WorkerConfig) *Worker {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 2
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 5 * time.Second
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = time.Minute
+	}
+	if cfg.ID == "" {
+		cfg.ID = DefaultWorkerID()
+	}
+	return &Worker{store: store, status: status, mover: studyMover, thumbnails: thumbnails, schedule: schedule, cfg: cfg}
+}
+
+// DefaultWorkerID names a replica by host and process.
+func DefaultWorkerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// Run works the queue until ctx is cancelled. Alongside the workers it
+// requeues jobs whose lease expired, i.e. whose replica died mid-job.
+func (w *Worker) Run(ctx context.Context) {
+	slog.InfoContext(ctx, "Job workers started", "workerID", w.cfg.ID, "workers", w.cfg.Workers,
+		"pollInterval", w.cfg.PollInterval, "lease", w.cfg.Lease, "limits", w.cfg.Limits.String())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.reapExpired(ctx)
+	}()
+	for i := 0; i < w.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(ctx)
+		}()
+	}
+	wg.Wait()
+	slog.Info("Job workers stopped")
+}
+
+// reapExpired requeues jobs whose lease expired, at startup and then every
+// half lease. An expired lease counts as a failed attempt: a job that keeps
+// crashing or hanging its worker is dead-lettered like any other.
+func (w *Worker) reapExpired(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.Lease / 2)
+	defer ticker.Stop()
+	for {
+		expired, err := w.store.RequeueExpiredJobs(ctx, time.Now().Add(-w.cfg.Lease), w.cfg.Retry.Attempts)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			slog.ErrorContext(ctx, "Failed to requeue expired jobs", "error", err)
+		}
+		for i := range expired {
+			job := &expired[i]
+			logAttrs := []any{"jobID", job.ID, "studyID", job.StudyID, "attempts", job.Attempts}
+			if job.Status != models.JobStatusDead {
+				slog.WarnContext(ctx, "Requeued job whose worker stopped sending heartbeats", logAttrs...)
+				continue
+			}
+			slog.ErrorContext(ctx, "Job's worker stopped sending heartbeats and it has no attempts left", logAttrs...)
+			if job.Kind == models.JobKindMove {
+				w.abandonMove(ctx, job)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) loop(ctx context.Context) {
+	claim := storage.ClaimOptions{WorkerID: w.cfg.ID, Limits: w.cfg.Limits}
+	for ctx.Err() == nil {
+		job, found, err := w.store.ClaimJob(ctx, claim)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			slog.ErrorContext(ctx, "Failed to claim job", "error", err)
+		}
+		if !found {
+			select {
+			case <-ctx.Done():
+			case <-time.After(w.cfg.PollInterval):
+			}
+			continue
+		}
+		w.runJob(ctx, job)
+	}
+}
+
+// runJob executes one claimed job and records its outcome. Work is done on
+// behalf of whoever enqueued the job, so status history names them. The
+// job's lease is renewed while it runs; if it is lost the job is abandoned
+// to whichever worker took it over. A job interrupted by shutdown goes back
+// in the queue without counting as an attempt.
+func (w *Worker) runJob(ctx context.Context, job *models.Job) {
+	shutdown := ctx
+	ctx = auth.WithPrincipal(ctx, &auth.Principal{Subject: job.RequestedBy, Method: "job"})
+	logAttrs := []any{"jobID", job.ID, "kind", job.Kind, "studyID", job.StudyID, "targetTier", job.TargetTier,
+		"source", job.Source, "priority", job.Priority, "attempt", job.Attempts + 1}
+	slog.InfoContext(ctx, "Job started", logAttrs...)
+
+	ctx, abandon := context.WithCancelCause(ctx)
+	defer abandon(nil)
+	heartbeatDone := make(chan struct{})
+	defer close(heartbeatDone)
+	go w.heartbeat(ctx, job.ID, abandon, heartbeatDone)
+
+	var result any
+	var err error
+	switch job.Kind {
+	case models.JobKindMove:
+		result, err = w.runMove(ctx, job)
+	default:
+		err = retry.Permanent(fmt.Errorf("unknown job kind %q", job.Kind))
+	}
+	if errors.Is(err, errDeferred) {
+		return
+	}
+	if cause := context.Cause(ctx); errors.Is(cause, storage.ErrLeaseLost) {
+		slog.WarnContext(ctx, "Abandoned job after losing its lease", append(logAttrs, "error", err)...)
+		return
+	}
+
+	// Record the outcome even if we are shutting down mid-job
+	finishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	switch {
+	case err == nil:
+		slog.InfoContext(ctx, "Job succeeded", logAttrs...)
+		var raw []byte
+		if result != nil {
+			raw, _ = json.Marshal(result)
+		}
+		if err := w.store.FinishJob(finishCtx, job.ID, w.cfg.ID, models.JobStatusSucceeded, raw, nil); err != nil {
+			slog.ErrorContext(ctx, "Failed to record job outcome", append(logAttrs, "error", err)...)
+		}
+	case shutdown.Err() != nil:
+		if err := w.store.DeferJob(finishCtx, job.ID, w.cfg.ID, time.Now()); err != nil {
+			slog.ErrorContext(ctx, "Failed to requeue job interrupted by shutdown", append(logAttrs, "error", err)...)
+		} else {
+			slog.InfoContext(ctx, "Requeued job interrupted by shutdown", logAttrs...)
+		}
+	default:
+		w.failed(finishCtx, job, err, logAttrs)
+	}
+}
+
+// failed records a failed run of job. Transient failures are retried with
+// backoff until the job's attempts run out; then the job is dead and what
+// its moves left in the target is removed. Anything else fails it for good.
+func (w *Worker) failed(ctx context.Context, job *models.Job, err error, logAttrs []any) {
+	attempt := models.JobAttempt{Attempt: job.Attempts + 1, At: time.Now(), Step: mover.FailedStep(err), Error: err.Error()}
+	logAttrs = append(logAttrs, "step", attempt.Step, "error", err)
+	retryable := mover.Retryable(err)
+
+	if retryable && attempt.Attempt < w.cfg.Retry.Attempts {
+		notBefore := attempt.At.Add(w.cfg.Retry.Delay(attempt.Attempt))
+		if err := w.store.RetryJob(ctx, job.ID, w.cfg.ID, notBefore, attempt); err != nil {
+			slog.ErrorContext(ctx, "Failed to requeue job for retry", append(logAttrs, "retryError", err)...)
+			return
+		}
+		slog.WarnContext(ctx, "Job failed, will retry", append(logAttrs, "notBefore", notBefore)...)
+		return
+	}
+
+	status := models.JobStatusFailed
+	if retryable {
+		status = models.JobStatusDead
+		slog.ErrorContext(ctx, "Job failed and has no attempts left", logAttrs...)
+	} else {
+		slog.ErrorContext(ctx, "Job failed", logAttrs...)
+	}
+	if err := w.store.FinishJob(ctx, job.ID, w.cfg.ID, status, nil, &attempt); err != nil {
+		slog.ErrorContext(ctx, "Failed to record job outcome", append(logAttrs, "finishError", err)...)
+		return
+	}
+	if status == models.JobStatusDead && job.Kind == models.JobKindMove {
+		w.abandonMove(ctx, job)
+	}
+}
+
+// abandonMove removes the partial copy a dead move job left in its target.
+func (w *Worker) abandonMove(ctx context.Context, job *models.Job) {
+	current, err := currentStatus(ctx, w.status, job.StudyID)
+	if err == nil {
+		err = w.mover.Abandon(ctx, job.StudyID, current.Tier, job.TargetTier)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to remove partial copy of dead move job", "jobID", job.ID, "studyID", job.StudyID, "error", err)
+	}
+}
+
+// heartbeat renews a running job's lease until done is closed, cancelling
+// the job with ErrLeaseLost if another worker has taken it over. Transient
+// errors are retried on the next beat; the lease outlasts two missed beats.
+func (w *Worker) heartbeat(ctx context.Context, jobID int64, abandon context.CancelCauseFunc, done <-chan struct{}) {
+	ticker := time.NewTicker(w.cfg.Lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := w.store.HeartbeatJob(ctx, jobID, w.cfg.ID)
+		switch {
+		case errors.Is(err, storage.ErrLeaseLost):
+			abandon(err)
+			return
+		case err != nil && ctx.Err() == nil:
+			slog.WarnContext(ctx, "Failed to renew job lease", "jobID", jobID, "error", err)
+		}
+	}
+}
+
+// runMove moves a study between tiers like the move API does, resuming after
+// whatever an earlier attempt copied. A window-bound move claimed outside its
+// window (e.g. after a backlog or a restart) goes back in the queue until the
+// next one; once started it runs to completion.
+func (w *Worker) runMove(ctx context.Context, job *models.Job) (*mover.Result, error) {
+	current, err := currentStatus(ctx, w.status, job.StudyID)
+	if err != nil {
+		return nil, err
+	}
+	currentTier := current.Tier
+	switch currentTier {
+	case retention.TierDeleted:
+		return nil, retry.Permanent(fmt.Errorf("study %s has been deleted", job.StudyID))
+	case retention.TierTrash:
+		return nil, retry.Permanent(fmt.Errorf("study %s is in the trash", job.StudyID))
+	}
+
+	if job.WindowRequired {
+		resources := Resources(current, job.TargetTier, job.TargetLocation)
+		now := time.Now()
+		next, ok := w.schedule.NextOpen(resources, now)
+		if !ok {
+			return nil, retry.Permanent(fmt.Errorf("%w for moving study %s to %s", ErrNoWindow, job.StudyID, job.TargetTier))
+		}
+		if next.After(now) {
+			if err := w.store.DeferJob(ctx, job.ID, w.cfg.ID, next); err != nil {
+				return nil, err
+			}
+			slog.InfoContext(ctx, "Deferred job to the next maintenance window", "jobID", job.ID, "studyID", job.StudyID, "notBefore", next)
+			return nil, errDeferred
+		}
+	}
+
+	edgeID := TransferEdge(current, job.TargetTier, job.TargetLocation)
+	result, err := w.mover.ResumeTransition(ctx, job.StudyID, currentTier, job.TargetTier, edgeID)
+	if err != nil {
+		return nil, err
+	}
+	studyID := job.StudyID
+	if result.StudyID != "" {
+		studyID = result.StudyID
+	}
+	if err := w.status.SetStatus(ctx, studyID, TargetStatus(job.TargetTier, job.TargetLocation)); err != nil {
+		return result, err
+	}
+	w.thumbnails.InvalidateStudy(studyID)
+	return result, nil
+}
@@ -0,0 +1,132 @@
+// File: backend/internal/maintenance/bandwidth.go
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bandwidth caps transfer throughput per edge. Every transfer on an edge
+// shares its budget, however many run at once. A nil Bandwidth is unlimited.
+type Bandwidth struct {
+	limits       map[string]int64 // Edge ID -> bytes/sec
+	defaultLimit int64            // For edges without their own limit; 0 is unlimited
+
+	mu       sync.Mutex
+	limiters map[string]*limiter
+}
+
+// ParseBandwidthLimits parses "edge:bytesPerSecond" pairs; the edge "*"
+// sets a limit for every edge not listed, each with its own budget.
+func ParseBandwidthLimits(specs []string) (*Bandwidth, error) {
+	b := &Bandwidth{limits: make(map[string]int64), limiters: make(map[string]*limiter)}
+	for _, spec := range specs {
+		edge, value, ok := strings.Cut(strings.TrimSpace(spec), ":")
+		rate, err := strconv.ParseInt(value, 10, 64)
+		if !ok || edge == "" || err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid bandwidth limit %q (want edge:bytesPerSecond)", spec)
+		}
+		if edge == "*" {
+			b.defaultLimit = rate
+		} else {
+			b.limits[edge] = rate
+		}
+	}
+	return b, nil
+}
+
+// Limit returns the bytes/sec cap of an edge, 0 if unlimited.
+func (b *Bandwidth) Limit(edgeID string) int64 {
+	if b == nil || edgeID == "" {
+		return 0
+	}
+	if rate, ok := b.limits[edgeID]; ok {
+		return rate
+	}
+	return b.defaultLimit
+}
+
+// Limits returns the configured per-edge caps; "*" is the default.
+func (b *Bandwidth) Limits() map[string]int64 {
+	out := make(map[string]int64)
+	if b == nil {
+		return out
+	}
+	for edge, rate := range b.limits {
+		out[edge] = rate
+	}
+	if b.defaultLimit > 0 {
+		out["*"] = b.defaultLimit
+	}
+	return out
+}
+
+// Reader paces reads from r against the edge's budget. Without a limit r
+// is returned as is.
+func (b *Bandwidth) Reader(ctx context.Context, edgeID string, r io.Reader) io.Reader {
+	rate := b.Limit(edgeID)
+	if rate <= 0 {
+		return r
+	}
+	b.mu.Lock()
+	l, ok := b.limiters[edgeID]
+	if !ok {
+		l = &limiter{rate: rate}
+		b.limiters[edgeID] = l
+	}
+	b.mu.Unlock()
+	return &limitedReader{ctx: ctx, r: r, limiter: l}
+}
+
+// limiter hands out send times so the bytes it has admitted never exceed
+// rate per second on average.
+type limiter struct {
+	rate int64
+
+	mu   sync.Mutex
+	next time.Time // When the budget is next free
+}
+
+// reserve admits n bytes and returns when they may be considered sent.
+func (l *limiter) reserve(n int) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / float64(l.rate) * float64(time.Second)))
+	return l.next
+}
+
+type limitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *limiter
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	// Cap each read at a tenth of a second's budget to keep pacing smooth.
+	if max := lr.limiter.rate / 10; max > 0 && int64(len(p)) > max {
+		p = p[:max]
+	}
+	n, err := lr.r.Read(p)
+	if n == 0 {
+		return n, err
+	}
+	if wait := time.Until(lr.limiter.reserve(n)); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-lr.ctx.Done():
+			return n, lr.ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,115 @@
+package maintenance
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseBandwidthLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		wantErr bool
+		limits  map[string]int64
+		checks  map[string]int64 // Edge -> expected Limit
+	}{
+		{name: "none", limits: map[string]int64{}, checks: map[string]int64{"edge-a": 0, "": 0}},
+		{name: "per edge", specs: []string{"edge-a:1000", " edge-b:2000 "},
+			limits: map[string]int64{"edge-a": 1000, "edge-b": 2000},
+			checks: map[string]int64{"edge-a": 1000, "edge-b": 2000, "edge-c": 0}},
+		{name: "default", specs: []string{"*:500", "edge-a:1000"},
+			limits: map[string]int64{"*": 500, "edge-a": 1000},
+			checks: map[string]int64{"edge-a": 1000, "edge-c": 500, "": 0}},
+		{name: "missing rate", specs: []string{"edge-a"}, wantErr: true},
+		{name: "missing edge", specs: []string{":1000"}, wantErr: true},
+		{name: "zero rate", specs: []string{"edge-a:0"}, wantErr: true},
+		{name: "negative rate", specs: []string{"edge-a:-5"}, wantErr: true},
+		{name: "not a number", specs: []string{"edge-a:1MB"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := ParseBandwidthLimits(tt.specs)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseBandwidthLimits() = %v, want an error", b.Limits())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseBandwidthLimits() error = %v", err)
+			}
+			if got := b.Limits(); !maps.Equal(got, tt.limits) {
+				t.Errorf("Limits() = %v, want %v", got, tt.limits)
+			}
+			for edge, want := range tt.checks {
+				if got := b.Limit(edge); got != want {
+					t.Errorf("Limit(%q) = %d, want %d", edge, got, want)
+				}
+			}
+		})
+	}
+
+	var none *Bandwidth
+	if got := none.Limit("edge-a"); got != 0 {
+		t.Errorf("nil Bandwidth Limit() = %d, want 0", got)
+	}
+}
+
+func TestBandwidthReader(t *testing.T) {
+	const rate = 100_000 // bytes/sec
+	b, err := ParseBandwidthLimits([]string{"edge-a:100000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		edge    string
+		readers int
+		size    int // Per reader
+		minTime time.Duration
+		maxTime time.Duration
+	}{
+		{name: "unlimited edge", edge: "edge-b", readers: 1, size: 1 << 20, maxTime: 100 * time.Millisecond},
+		{name: "one transfer", edge: "edge-a", readers: 1, size: 30_000, minTime: 250 * time.Millisecond, maxTime: 2 * time.Second},
+		{name: "transfers share the budget", edge: "edge-a", readers: 2, size: 15_000, minTime: 250 * time.Millisecond, maxTime: 2 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			var wg sync.WaitGroup
+			for i := 0; i < tt.readers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					n, err := io.Copy(io.Discard, b.Reader(context.Background(), tt.edge, bytes.NewReader(make([]byte, tt.size))))
+					if err != nil || n != int64(tt.size) {
+						t.Errorf("copied %d bytes, error %v; want %d bytes", n, err, tt.size)
+					}
+				}()
+			}
+			wg.Wait()
+			if elapsed := time.Since(start); elapsed < tt.minTime || elapsed > tt.maxTime {
+				t.Errorf("transfer took %v, want between %v and %v at %d bytes/sec", elapsed, tt.minTime, tt.maxTime, rate)
+			}
+		})
+	}
+}
+
+func TestBandwidthReaderCancelled(t *testing.T) {
+	b, err := ParseBandwidthLimits([]string{"edge-a:1000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = io.Copy(io.Discard, b.Reader(ctx, "edge-a", bytes.NewReader(make([]byte, 10_000))))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("io.Copy() error = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,249 @@
+// File: backend/internal/maintenance/windows.go
+package maintenance
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Resource kinds a window can apply to.
+const (
+	KindEdge = "edge"
+	KindTier = "tier"
+)
+
+// Resource is something a transfer loads: an edge uplink or a tier backend.
+type Resource struct {
+	Kind string
+	Name string
+}
+
+// Window is a recurring period during which bulk transfers may run. It
+// starts on each selected weekday and may run past midnight.
+type Window struct {
+	Kind  string `json:"kind"`
+	Name  string `json:"name"`
+	Days  string `json:"days"`  // As configured, e.g. "Mon-Fri"
+	Start string `json:"start"` // HH:MM
+	End   string `json:"end"`   // HH:MM
+
+	weekdays [7]bool
+	startMin int
+	length   time.Duration
+}
+
+// Schedule holds the configured windows. A resource without windows is
+// always open. A nil Schedule is always open.
+type Schedule struct {
+	windows  []Window
+	location *time.Location
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseWindows parses windows of the form "kind:name:days:HHMM-HHMM", e.g.
+// "edge:edge-a:Mon-Fri:1900-0700" or "tier:archive:*:2200-0600". days is
+// "*" or a "|" list of days and day ranges ("Mon-Fri|Sun"). Times are in loc.
+func ParseWindows(specs []string, loc *time.Location) (*Schedule, error) {
+	s := &Schedule{location: loc}
+	for _, spec := range specs {
+		parts := strings.Split(strings.TrimSpace(spec), ":")
+		if len(parts) != 4 || parts[1] == "" {
+			return nil, fmt.Errorf("invalid maintenance window %q (want kind:name:days:HHMM-HHMM)", spec)
+		}
+		w := Window{Kind: strings.ToLower(parts[0]), Name: parts[1], Days: parts[2]}
+		if w.Kind != KindEdge && w.Kind != KindTier {
+			return nil, fmt.Errorf("maintenance window %q: kind must be %s or %s", spec, KindEdge, KindTier)
+		}
+		var err error
+		if w.weekdays, err = parseDays(parts[2]); err != nil {
+			return nil, fmt.Errorf("maintenance window %q: %w", spec, err)
+		}
+		from, to, ok := strings.Cut(parts[3], "-")
+		startMin, err1 := parseClock(from)
+		endMin, err2 := parseClock(to)
+		if !ok || err1 != nil || err2 != nil || startMin == endMin {
+			return nil, fmt.Errorf("maintenance window %q: invalid time range %q", spec, parts[3])
+		}
+		w.startMin = startMin
+		w.Start, w.End = formatClock(startMin), formatClock(endMin)
+		if endMin < startMin {
+			endMin += 24 * 60
+		}
+		w.length = time.Duration(endMin-startMin) * time.Minute
+		s.windows = append(s.windows, w)
+	}
+	return s, nil
+}
+
+func parseDays(spec string) ([7]bool, error) {
+	var days [7]bool
+	if spec == "*" {
+		return [7]bool{true, true, true, true, true, true, true}, nil
+	}
+	for _, part := range strings.Split(spec, "|") {
+		from, to, isRange := strings.Cut(strings.ToLower(strings.TrimSpace(part)), "-")
+		first, ok1 := weekdayNames[from]
+		last, ok2 := first, true
+		if isRange {
+			last, ok2 = weekdayNames[to]
+		}
+		if !ok1 || !ok2 {
+			return days, fmt.Errorf("invalid days %q", part)
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseClock(hhmm string) (int, error) {
+	if len(hhmm) != 4 {
+		return 0, fmt.Errorf("invalid time %q", hhmm)
+	}
+	h, err1 := strconv.Atoi(hhmm[:2])
+	m, err2 := strconv.Atoi(hhmm[2:])
+	if err1 != nil || err2 != nil || h > 23 || m > 59 {
+		return 0, fmt.Errorf("invalid time %q", hhmm)
+	}
+	return h*60 + m, nil
+}
+
+func formatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// Windows returns the configured windows.
+func (s *Schedule) Windows() []Window {
+	if s == nil {
+		return nil
+	}
+	return s.windows
+}
+
+// Location returns the time zone windows are evaluated in.
+func (s *Schedule) Location() *time.Location {
+	if s == nil || s.location == nil {
+		return time.Local
+	}
+	return s.location
+}
+
+// Constrained reports whether any window is configured for r.
+func (s *Schedule) Constrained(r Resource) bool {
+	return len(s.windowsFor(r)) > 0
+}
+
+func (s *Schedule) windowsFor(r Resource) []Window {
+	if s == nil {
+		return nil
+	}
+	var matched []Window
+	for _, w := range s.windows {
+		if w.Kind == r.Kind && w.Name == r.Name {
+			matched = append(matched, w)
+		}
+	}
+	return matched
+}
+
+// Open reports whether every resource is inside one of its windows at t.
+func (s *Schedule) Open(resources []Resource, t time.Time) bool {
+	for _, r := range resources {
+		windows := s.windowsFor(r)
+		if len(windows) == 0 {
+			continue
+		}
+		open := false
+		for _, w := range windows {
+			if w.Contains(t, s.Location()) {
+				open = true
+				break
+			}
+		}
+		if !open {
+			return false
+		}
+	}
+	return true
+}
+
+// NextOpen returns the first time at or after t when every resource is
+// inside a window, and false if their windows never overlap.
+func (s *Schedule) NextOpen(resources []Resource, t time.Time) (time.Time, bool) {
+	if s.Open(resources, t) {
+		return t, true
+	}
+	// Windows repeat weekly, so any overlap begins at a window start
+	// within the next eight days
+	var next time.Time
+	for _, r := range resources {
+		for _, w := range s.windowsFor(r) {
+			for _, start := range w.startsWithin(t, 8*24*time.Hour, s.Location()) {
+				if (next.IsZero() || start.Before(next)) && s.Open(resources, start) {
+					next = start
+				}
+			}
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// NextStart returns when the window next begins after t.
+func (w Window) NextStart(t time.Time, loc *time.Location) time.Time {
+	starts := w.startsWithin(t, 8*24*time.Hour, loc)
+	for _, start := range starts {
+		if start.After(t) {
+			return start
+		}
+	}
+	return time.Time{}
+}
+
+// Contains reports whether t falls inside an occurrence of w.
+func (w Window) Contains(t time.Time, loc *time.Location) bool {
+	_, inside := w.occurrence(t, loc)
+	return inside
+}
+
+// occurrence returns the start of the occurrence of w containing t.
+func (w Window) occurrence(t time.Time, loc *time.Location) (time.Time, bool) {
+	local := t.In(loc)
+	// An occurrence containing t started today or, past midnight, yesterday
+	for back := 0; back <= 1; back++ {
+		day := local.AddDate(0, 0, -back)
+		if !w.weekdays[day.Weekday()] {
+			continue
+		}
+		start := time.Date(day.Year(), day.Month(), day.Day(), w.startMin/60, w.startMin%60, 0, 0, loc)
+		if !t.Before(start) && t.Before(start.Add(w.length)) {
+			return start, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// startsWithin lists the starts of w from the day before t until t+span.
+func (w Window) startsWithin(t time.Time, span time.Duration, loc *time.Location) []time.Time {
+	local := t.In(loc)
+	var starts []time.Time
+	for day := local.AddDate(0, 0, -1); day.Before(t.Add(span)); day = day.AddDate(0, 0, 1) {
+		if !w.weekdays[day.Weekday()] {
+			continue
+		}
+		start := time.Date(day.Year(), day.Month(), day.Day(), w.startMin/60, w.startMin%60, 0, 0, loc)
+		if !start.Before(t) {
+			starts = append(starts, start)
+		}
+	}
+	return starts
+}
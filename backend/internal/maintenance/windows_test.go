@@ -0,0 +1,167 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+)
+
+// at returns a time in UTC in the week of Monday 2024-01-01.
+func at(day, hhmm string) time.Time {
+	t, err := time.Parse("2006-01-02 1504", day+" "+hhmm)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseWindows(t *testing.T) {
+	tests := []struct {
+		spec      string
+		wantErr   bool
+		wantStart string
+		wantEnd   string
+		wantDays  [7]bool // Sunday first
+	}{
+		{spec: "edge:edge-a:Mon-Fri:1900-0700", wantStart: "19:00", wantEnd: "07:00",
+			wantDays: [7]bool{false, true, true, true, true, true, false}},
+		{spec: "tier:archive:*:2200-0600", wantStart: "22:00", wantEnd: "06:00",
+			wantDays: [7]bool{true, true, true, true, true, true, true}},
+		{spec: "EDGE:edge-b:Fri-Mon|Wed:0000-2359", wantStart: "00:00", wantEnd: "23:59",
+			wantDays: [7]bool{true, true, false, true, false, true, true}},
+		{spec: " edge:edge-c:sun:0100-0200 ", wantStart: "01:00", wantEnd: "02:00",
+			wantDays: [7]bool{true, false, false, false, false, false, false}},
+		{spec: "edge:edge-a:Mon-Fri", wantErr: true},
+		{spec: "edge::Mon:0100-0200", wantErr: true},
+		{spec: "site:x:Mon:0100-0200", wantErr: true},
+		{spec: "edge:x:Funday:0100-0200", wantErr: true},
+		{spec: "edge:x:Mon-Someday:0100-0200", wantErr: true},
+		{spec: "edge:x:Mon:0100", wantErr: true},
+		{spec: "edge:x:Mon:2400-0100", wantErr: true},
+		{spec: "edge:x:Mon:0160-0200", wantErr: true},
+		{spec: "edge:x:Mon:100-0200", wantErr: true},
+		{spec: "edge:x:Mon:0100-0100", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := ParseWindows([]string{tt.spec}, time.UTC)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseWindows() = %+v, want an error", s.Windows())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseWindows() error = %v", err)
+			}
+			w := s.Windows()[0]
+			if w.Start != tt.wantStart || w.End != tt.wantEnd || w.weekdays != tt.wantDays {
+				t.Errorf("window = %s-%s on %v, want %s-%s on %v", w.Start, w.End, w.weekdays, tt.wantStart, tt.wantEnd, tt.wantDays)
+			}
+		})
+	}
+}
+
+func TestScheduleOpen(t *testing.T) {
+	s, err := ParseWindows([]string{
+		"edge:edge-a:Mon-Fri:1900-0700",
+		"edge:edge-a:Sat|Sun:0000-2359",
+		"tier:archive:*:2200-0600",
+	}, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edgeA := Resource{Kind: KindEdge, Name: "edge-a"}
+	archive := Resource{Kind: KindTier, Name: "archive"}
+	edgeB := Resource{Kind: KindEdge, Name: "edge-b"}
+
+	tests := []struct {
+		name      string
+		resources []Resource
+		at        time.Time
+		want      bool
+	}{
+		{name: "unconstrained resource", resources: []Resource{edgeB}, at: at("2024-01-01", "1200"), want: true},
+		{name: "no resources", at: at("2024-01-01", "1200"), want: true},
+		{name: "inside evening window", resources: []Resource{edgeA}, at: at("2024-01-01", "2000"), want: true},
+		{name: "at window start", resources: []Resource{edgeA}, at: at("2024-01-01", "1900"), want: true},
+		{name: "past midnight", resources: []Resource{edgeA}, at: at("2024-01-02", "0659"), want: true},
+		{name: "at window end", resources: []Resource{edgeA}, at: at("2024-01-02", "0700"), want: false},
+		{name: "working hours", resources: []Resource{edgeA}, at: at("2024-01-02", "1200"), want: false},
+		{name: "Monday morning belongs to Sunday", resources: []Resource{edgeA}, at: at("2024-01-01", "0300"), want: false},
+		{name: "Saturday morning after Friday night", resources: []Resource{edgeA}, at: at("2024-01-06", "0300"), want: true},
+		{name: "weekend midday", resources: []Resource{edgeA}, at: at("2024-01-06", "1200"), want: true},
+		{name: "both open", resources: []Resource{edgeA, archive}, at: at("2024-01-01", "2300"), want: true},
+		{name: "only one open", resources: []Resource{edgeA, archive}, at: at("2024-01-01", "2000"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Open(tt.resources, tt.at); got != tt.want {
+				t.Errorf("Open() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	var none *Schedule
+	if !none.Open([]Resource{edgeA}, at("2024-01-01", "1200")) {
+		t.Error("nil Schedule is closed, want always open")
+	}
+}
+
+func TestScheduleNextOpen(t *testing.T) {
+	s, err := ParseWindows([]string{
+		"edge:edge-a:Mon-Fri:1900-0700",
+		"tier:archive:*:2200-0600",
+		"tier:cold:Sat:1000-1200",
+		"edge:edge-b:Wed:0100-0300",
+	}, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edgeA := Resource{Kind: KindEdge, Name: "edge-a"}
+	edgeB := Resource{Kind: KindEdge, Name: "edge-b"}
+	archive := Resource{Kind: KindTier, Name: "archive"}
+	cold := Resource{Kind: KindTier, Name: "cold"}
+
+	tests := []struct {
+		name      string
+		resources []Resource
+		from      time.Time
+		want      time.Time
+		wantOK    bool
+	}{
+		{name: "already open", resources: []Resource{edgeA}, from: at("2024-01-01", "2000"), want: at("2024-01-01", "2000"), wantOK: true},
+		{name: "later today", resources: []Resource{edgeA}, from: at("2024-01-01", "1200"), want: at("2024-01-01", "1900"), wantOK: true},
+		{name: "over the weekend", resources: []Resource{edgeA}, from: at("2024-01-06", "1200"), want: at("2024-01-08", "1900"), wantOK: true},
+		{name: "overlap starts at the later window", resources: []Resource{edgeA, archive}, from: at("2024-01-01", "1200"), want: at("2024-01-01", "2200"), wantOK: true},
+		{name: "next week", resources: []Resource{cold}, from: at("2024-01-06", "1300"), want: at("2024-01-13", "1000"), wantOK: true},
+		{name: "overlap past midnight", resources: []Resource{edgeA, edgeB}, from: at("2024-01-01", "1200"), want: at("2024-01-03", "0100"), wantOK: true},
+		{name: "never overlapping", resources: []Resource{edgeA, cold}, from: at("2024-01-01", "1200")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := s.NextOpen(tt.resources, tt.from)
+			if ok != tt.wantOK || !got.Equal(tt.want) {
+				t.Errorf("NextOpen() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestWindowInTimeZone(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	s, err := ParseWindows([]string{"edge:edge-a:Mon:2200-2300"}, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edgeA := Resource{Kind: KindEdge, Name: "edge-a"}
+	// 22:30 on Monday in UTC+2 is 20:30 UTC
+	if !s.Open([]Resource{edgeA}, at("2024-01-01", "2030")) {
+		t.Error("Open() at 22:30 local = false, want true")
+	}
+	if s.Open([]Resource{edgeA}, at("2024-01-01", "2230")) {
+		t.Error("Open() at 00:30 local on Tuesday = true, want false")
+	}
+	if got, want := s.Windows()[0].NextStart(at("2024-01-01", "2030"), loc), at("2024-01-08", "2000"); !got.Equal(want) {
+		t.Errorf("NextStart() = %v, want %v", got, want)
+	}
+}
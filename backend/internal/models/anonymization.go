@@ -0,0 +1,21 @@
+// File: internal/models/anonymization.go
+package models
+
+import "time"
+
+// Kinds of values remapped during anonymization.
+const (
+	AnonKindUID     = "uid"     // Study/Series/SOP/FrameOfReference UIDs
+	AnonKindPatient = "patient" // PatientID -> pseudonym
+)
+
+// AnonymizationMapping links an original identifier to its replacement within
+// a research project. Only privileged users may read these.
+type AnonymizationMapping struct {
+	Project         string    `json:"project"`
+	Kind            string    `json:"kind"`
+	OriginalValue   string    `json:"originalValue"`
+	AnonymizedValue string    `json:"anonymizedValue"`
+	OriginalLabel   string    `json:"originalLabel,omitempty"` // e.g. PatientName for patient mappings
+	CreatedAt       time.Time `json:"createdAt"`
+}
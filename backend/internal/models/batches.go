@@ -0,0 +1,84 @@
+// File: internal/models/batches.go
+package models
+
+import "time"
+
+// Move batch states, derived from the states of its jobs.
+const (
+	BatchStatusQueued          = "queued"
+	BatchStatusRunning         = "running"
+	BatchStatusSucceeded       = "succeeded"
+	BatchStatusPartiallyFailed = "partially_failed"
+	BatchStatusFailed          = "failed"
+)
+
+// StudyFilter selects studies for a bulk move. Zero values match everything;
+// dates are DICOM DA (YYYYMMDD) and inclusive.
+type StudyFilter struct {
+	DateFrom  string `json:"dateFrom,omitempty"`
+	DateTo    string `json:"dateTo,omitempty"`
+	Modality  string `json:"modality,omitempty"`
+	PatientID string `json:"patientId,omitempty"` // DICOM PatientID
+	Tier      string `json:"tier,omitempty"`      // Current tier
+	EdgeID    string `json:"edgeId,omitempty"`    // Edge a hot study is on
+}
+
+// BatchSkip is a selected study the batch queued no job for.
+type BatchSkip struct {
+	StudyID string `json:"studyId"`
+	Reason  string `json:"reason"`
+	JobID   int64  `json:"jobId,omitempty"` // The study's active job, if that is why
+}
+
+// BatchFailure is a job of the batch that failed.
+type BatchFailure struct {
+	JobID   int64  `json:"jobId"`
+	StudyID string `json:"studyId"`
+	Error   string `json:"error"`
+}
+
+// BatchProgress counts a batch's jobs by state and what they moved.
+type BatchProgress struct {
+	Total          int     `json:"total"` // Jobs queued by the batch
+	Queued         int     `json:"queued"`
+	Running        int     `json:"running"`
+	Succeeded      int     `json:"succeeded"`
+	Failed         int     `json:"failed"`
+	Skipped        int     `json:"skipped"`
+	PercentDone    float64 `json:"percentDone"`
+	InstancesMoved int     `json:"instancesMoved"`
+	BytesMoved     int64   `json:"bytesMoved"`
+}
+
+// MoveBatch is a bulk move of many studies to one tier, run as one job per study.
+type MoveBatch struct {
+	ID             int64          `json:"id"`
+	TargetTier     string         `json:"targetTier"`
+	TargetLocation string         `json:"targetLocation,omitempty"`
+	StudyIDs       []string       `json:"studyIds,omitempty"` // Explicit selection
+	Filter         *StudyFilter   `json:"filter,omitempty"`   // Selection by query
+	Reason         string         `json:"reason,omitempty"`
+	RequestedBy    string         `json:"requestedBy"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	Status         string         `json:"status"`
+	Progress       BatchProgress  `json:"progress"`
+	Skipped        []BatchSkip    `json:"skipped,omitempty"`
+	Failures       []BatchFailure `json:"failures,omitempty"`
+}
+
+// Status derives a batch's state from its progress: queued until a job
+// starts, running until none is left, then by how many failed.
+func (p BatchProgress) Status() string {
+	switch {
+	case p.Queued+p.Running > 0 && p.Running+p.Succeeded+p.Failed == 0:
+		return BatchStatusQueued
+	case p.Queued+p.Running > 0:
+		return BatchStatusRunning
+	case p.Failed > 0 && p.Succeeded == 0:
+		return BatchStatusFailed
+	case p.Failed > 0:
+		return BatchStatusPartiallyFailed
+	default:
+		return BatchStatusSucceeded
+	}
+}
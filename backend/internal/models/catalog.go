@@ -0,0 +1,58 @@
+// File: internal/models/catalog.go
+package models
+
+import "time"
+
+// Verification states for a catalog entry.
+const (
+	VerifyStatusUnverified = "unverified"
+	VerifyStatusOK         = "ok"
+	VerifyStatusCorrupt    = "corrupt"
+	VerifyStatusMissing    = "missing"
+)
+
+// CatalogEntry records one instance stored in a non-hot tier backend,
+// together with the checksum taken when it was written there.
+type CatalogEntry struct {
+	InstanceID     string `json:"instanceId"` // Orthanc instance ID
+	StudyID        string `json:"studyId"`    // Orthanc study ID
+	SeriesID       string `json:"seriesId,omitempty"`
+	SOPInstanceUID string `json:"sopInstanceUid,omitempty"`
+	InstanceNumber string `json:"instanceNumber,omitempty"`
+	// Series-level tags, kept so a study can be described once it has left Orthanc
+	SeriesNumber      string `json:"seriesNumber,omitempty"`
+	SeriesDescription string `json:"seriesDescription,omitempty"`
+	Modality          string `json:"modality,omitempty"`
+	BodyPart          string `json:"bodyPart,omitempty"`
+	// Image Pixel module, for viewers deciding how to fetch frames
+	NumberOfFrames    int    `json:"numberOfFrames,omitempty"`
+	Rows              int    `json:"rows,omitempty"`
+	Columns           int    `json:"columns,omitempty"`
+	TransferSyntaxUID string `json:"transferSyntaxUid,omitempty"`
+	// Set when the instance was re-encoded losslessly on its way into the tier
+	OriginalTransferSyntaxUID string     `json:"originalTransferSyntaxUid,omitempty"`
+	EncryptionKeyID           string     `json:"encryptionKeyId,omitempty"` // Data key protecting the object, if encrypted
+	Tier                      string     `json:"tier"`                      // e.g., "cold", "archive"
+	ObjectKey                 string     `json:"objectKey"`                 // Key in the tier backend
+	SizeBytes                 int64      `json:"sizeBytes"`
+	SHA256                    string     `json:"sha256"` // Hex encoded
+	StoredAt                  time.Time  `json:"storedAt"`
+	LastVerifiedAt            *time.Time `json:"lastVerifiedAt,omitempty"`
+	VerifyStatus              string     `json:"verifyStatus"`
+}
+
+// StudyCatalogEntry keeps the patient and study level tags of a study that has
+// been moved out of Orthanc, so it can still be listed, searched and exported.
+type StudyCatalogEntry struct {
+	StudyID           string    `json:"studyId"` // Orthanc study ID
+	StudyInstanceUID  string    `json:"studyInstanceUid,omitempty"`
+	PatientResourceID string    `json:"patientResourceId,omitempty"` // Orthanc patient ID
+	PatientID         string    `json:"patientId,omitempty"`
+	PatientName       string    `json:"patientName,omitempty"`
+	PatientBirthDate  string    `json:"patientBirthDate,omitempty"` // DICOM DA (YYYYMMDD)
+	PatientSex        string    `json:"patientSex,omitempty"`
+	StudyDate         string    `json:"studyDate,omitempty"` // DICOM DA (YYYYMMDD)
+	StudyDescription  string    `json:"studyDescription,omitempty"`
+	AccessionNumber   string    `json:"accessionNumber,omitempty"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,37 @@
+// File: internal/models/hl7.go
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// HL7 message log states.
+const (
+	HL7StatusProcessed = "processed"
+	HL7StatusIgnored   = "ignored"  // Valid but not a message type we act on
+	HL7StatusFailed    = "failed"   // Acknowledged with AE
+	HL7StatusRejected  = "rejected" // Unparsable; acknowledged with AR
+)
+
+// HL7Message is one inbound HL7 v2 message and what was done with it.
+type HL7Message struct {
+	ID          int64           `json:"id"`
+	ReceivedAt  time.Time       `json:"receivedAt"`
+	RemoteAddr  string          `json:"remoteAddr"`
+	ControlID   string          `json:"controlId,omitempty"`   // MSH-10
+	MessageType string          `json:"messageType,omitempty"` // MSH-9, e.g. "ADT^A08"
+	Status      string          `json:"status"`
+	AckCode     string          `json:"ackCode"` // AA, AE or AR
+	Error       string          `json:"error,omitempty"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	Raw         string          `json:"raw"`
+}
+
+// PatientDemographics are patient-level tags in DICOM form. Empty fields
+// are left unchanged by updates.
+type PatientDemographics struct {
+	PatientName      string `json:"patientName,omitempty"`      // DICOM PN
+	PatientBirthDate string `json:"patientBirthDate,omitempty"` // DICOM DA (YYYYMMDD)
+	PatientSex       string `json:"patientSex,omitempty"`       // M, F or O
+}
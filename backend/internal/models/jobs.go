@@ -0,0 +1,66 @@
+// File: internal/models/jobs.go
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Job states.
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed" // Failed for good, e.g. the data is corrupt
+	JobStatusDead      = "dead"   // Kept failing until its attempts ran out
+)
+
+// Job priorities, most urgent first. Stat is for someone waiting on the
+// result, background for bulk work that may take days.
+const (
+	JobPriorityStat       = "stat"
+	JobPriorityRoutine    = "routine"
+	JobPriorityBackground = "background"
+)
+
+// Job kinds.
+const (
+	JobKindMove = "move"
+)
+
+// Job is a unit of background work, e.g. moving one study to another tier.
+type Job struct {
+	ID             int64           `json:"id"`
+	Kind           string          `json:"kind"`
+	StudyID        string          `json:"studyId"` // Orthanc study ID
+	TargetTier     string          `json:"targetTier"`
+	TargetLocation string          `json:"targetLocation,omitempty"` // Edge ID for hot targets
+	Status         string          `json:"status"`
+	Priority       string          `json:"priority"`
+	Source         string          `json:"source"`           // What asked for it, e.g. "api", "prefetch"
+	Reason         string          `json:"reason,omitempty"` // Free text, e.g. the study a prior was fetched for
+	RequestedBy    string          `json:"requestedBy"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	StartedAt      *time.Time      `json:"startedAt,omitempty"`
+	FinishedAt     *time.Time      `json:"finishedAt,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	Result         json.RawMessage `json:"result,omitempty"`
+	NotBefore      *time.Time      `json:"notBefore,omitempty"`      // Not started before this time
+	WindowRequired bool            `json:"windowRequired,omitempty"` // Only started inside a maintenance window
+	BatchID        *int64          `json:"batchId,omitempty"`        // Bulk move the job belongs to
+	// Edges and tier backends the job loads, as "kind:name", for concurrency caps
+	Resources   []string   `json:"resources,omitempty"`
+	ClaimedBy   string     `json:"claimedBy,omitempty"`   // Worker running the job
+	HeartbeatAt *time.Time `json:"heartbeatAt,omitempty"` // Last sign of life of that worker
+	// Failed runs so far and why each failed, oldest first
+	Attempts     int          `json:"attempts"`
+	ErrorHistory []JobAttempt `json:"errorHistory,omitempty"`
+}
+
+// JobAttempt records a failed run of a job.
+type JobAttempt struct {
+	Attempt int       `json:"attempt"`
+	At      time.Time `json:"at"`
+	Step    string    `json:"step,omitempty"` // Transfer step that failed, e.g. "fetch"
+	Error   string    `json:"error"`
+}
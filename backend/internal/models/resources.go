@@ -0,0 +1,66 @@
+// File: internal/models/resources.go
+package models
+
+// PatientSummary is a patient in the /patients resource. ID is the Orthanc
+// patient ID, which stays stable when the patient's studies change tier.
+type PatientSummary struct {
+	ID               string   `json:"id"`
+	PatientID        string   `json:"patientId,omitempty"`
+	PatientName      string   `json:"patientName,omitempty"`
+	PatientBirthDate string   `json:"patientBirthDate,omitempty"`
+	PatientSex       string   `json:"patientSex,omitempty"`
+	StudyCount       int      `json:"studyCount"`
+	Tiers            []string `json:"tiers"` // Tiers holding at least one of the patient's studies
+}
+
+// StudySummary is a study in the /patients/{id}/studies resource.
+type StudySummary struct {
+	ID               string `json:"id"` // Orthanc study ID
+	StudyInstanceUID string `json:"studyInstanceUid,omitempty"`
+	StudyDate        string `json:"studyDate,omitempty"`
+	StudyDescription string `json:"studyDescription,omitempty"`
+	AccessionNumber  string `json:"accessionNumber,omitempty"`
+	SeriesCount      int    `json:"seriesCount"`
+	InstanceCount    int    `json:"instanceCount"`
+	TotalSizeBytes   int64  `json:"totalSizeBytes"`
+	Tier             string `json:"tier"`
+}
+
+// SeriesSummary is a series in the /studies/{uid}/series resource.
+type SeriesSummary struct {
+	ID                string `json:"id"` // Orthanc series ID
+	StudyID           string `json:"studyId"`
+	SeriesInstanceUID string `json:"seriesInstanceUid,omitempty"`
+	SeriesNumber      string `json:"seriesNumber,omitempty"`
+	SeriesDescription string `json:"seriesDescription,omitempty"`
+	Modality          string `json:"modality,omitempty"`
+	BodyPart          string `json:"bodyPart,omitempty"`
+	InstanceCount     int    `json:"instanceCount"`
+	TotalSizeBytes    int64  `json:"totalSizeBytes"`
+	Tier              string `json:"tier"`
+}
+
+// InstanceSummary is an instance in the /series/{uid}/instances resource.
+type InstanceSummary struct {
+	ID             string `json:"id"` // Orthanc instance ID
+	SeriesID       string `json:"seriesId"`
+	SOPInstanceUID string `json:"sopInstanceUid,omitempty"`
+	InstanceNumber string `json:"instanceNumber,omitempty"`
+	SizeBytes      int64  `json:"sizeBytes"`
+	Tier           string `json:"tier"`
+	// Zero or empty when unknown, e.g. for instances catalogued before these were recorded
+	NumberOfFrames    int    `json:"numberOfFrames,omitempty"`
+	Rows              int    `json:"rows,omitempty"`
+	Columns           int    `json:"columns,omitempty"`
+	TransferSyntaxUID string `json:"transferSyntaxUid,omitempty"`
+}
+
+// OffloadedStudy is a catalogued study that is no longer in Orthanc, with
+// its tier and instance totals.
+type OffloadedStudy struct {
+	StudyCatalogEntry
+	Tier           string `json:"tier"`
+	SeriesCount    int    `json:"seriesCount"`
+	InstanceCount  int    `json:"instanceCount"`
+	TotalSizeBytes int64  `json:"totalSizeBytes"`
+}
@@ -0,0 +1,50 @@
+// File: internal/models/retention.go
+package models
+
+import "time"
+
+// Legal hold scopes.
+const (
+	HoldScopePatient = "patient"
+	HoldScopeStudy   = "study"
+)
+
+// LegalHold blocks deletion and tier moves of a study, or of every study of
+// a patient, until it is released.
+type LegalHold struct {
+	ID         int64      `json:"id"`
+	Scope      string     `json:"scope"`    // HoldScopePatient or HoldScopeStudy
+	TargetID   string     `json:"targetId"` // Orthanc patient or study ID
+	Reason     string     `json:"reason"`
+	CreatedBy  string     `json:"createdBy"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ReleasedBy string     `json:"releasedBy,omitempty"`
+	ReleasedAt *time.Time `json:"releasedAt,omitempty"`
+}
+
+// Tombstone records a deleted study: what it was, why and by whom it was
+// deleted, and how much data went with it. It holds identifiers only.
+type Tombstone struct {
+	StudyID           string    `json:"studyId"` // Orthanc study ID
+	StudyInstanceUID  string    `json:"studyInstanceUid,omitempty"`
+	PatientResourceID string    `json:"patientResourceId,omitempty"`
+	PatientID         string    `json:"patientId,omitempty"`
+	StudyDate         string    `json:"studyDate,omitempty"` // DICOM DA (YYYYMMDD)
+	Reason            string    `json:"reason"`
+	DeletedBy         string    `json:"deletedBy"`
+	DeletedAt         time.Time `json:"deletedAt"`
+	Tiers             []string  `json:"tiers"` // Where data was removed from
+	Instances         int       `json:"instances"`
+	Bytes             int64     `json:"bytes"`
+}
+
+// TrashEntry records a soft-deleted study: where it was before it went to
+// the trash tier, and when it will be purged for good.
+type TrashEntry struct {
+	StudyID        string         `json:"studyId"` // Orthanc study ID
+	PreviousStatus LocationStatus `json:"previousStatus"`
+	Reason         string         `json:"reason,omitempty"`
+	TrashedBy      string         `json:"trashedBy"`
+	TrashedAt      time.Time      `json:"trashedAt"`
+	PurgeAfter     time.Time      `json:"purgeAfter"`
+}
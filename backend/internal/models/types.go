@@ -1,10 +1,27 @@
 // File: internal/models/types.go
 package models
 
+import "time"
+
 // LocationStatus defines where a study might be.
 // Used by both API and Storage layers.
 type LocationStatus struct {
 	LocationType string  `json:"locationType"`         // e.g., "edge", "cloud", "unknown"
 	EdgeID       *string `json:"edgeId,omitempty"`       // Use pointer for nullable DB field
 	Tier         string  `json:"tier"`                   // e.g., "hot", "cold", "archive"
-}
\ No newline at end of file
+}
+
+// Edge returns the edge ID, or "" when the study is not on an edge.
+func (s LocationStatus) Edge() string {
+	if s.EdgeID == nil {
+		return ""
+	}
+	return *s.EdgeID
+}
+
+// StatusHistoryEntry is one recorded change of a study's LocationStatus.
+type StatusHistoryEntry struct {
+	LocationStatus
+	ChangedBy string    `json:"changedBy"` // Subject of the principal, or "anonymous"
+	ChangedAt time.Time `json:"changedAt"`
+}
@@ -0,0 +1,98 @@
+// File: backend/internal/mover/compression.go
+package mover
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ewag/gen-erics/backend/internal/dicom"
+)
+
+// losslessSyntaxes are the compression targets a rule may name, by alias.
+// The internal renderer only decodes RLE; rendering objects stored in the
+// others recalls their study to Orthanc first.
+var losslessSyntaxes = map[string]string{
+	"jpeg-ls":       dicom.JPEGLSLossless,
+	"jpeg2000":      dicom.JPEG2000Lossless,
+	"jpeg-lossless": dicom.JPEGLosslessSV1,
+	"rle":           dicom.RLELossless,
+}
+
+// CompressionPolicy decides which lossless transfer syntax, if any, an
+// uncompressed instance is re-encoded to when it is offloaded into a tier.
+// A nil policy never compresses.
+type CompressionPolicy struct {
+	rules []compressionRule
+}
+
+type compressionRule struct {
+	tier       string
+	modalities map[string]bool // nil matches every modality
+	syntax     string
+}
+
+// ParseCompressionRules parses rules of the form "tier:modalities:syntax",
+// e.g. "cold:CT|MR|CR:jpeg-ls" or "archive:*:jpeg2000". The syntax is an
+// alias from losslessSyntaxes or one of their UIDs. The first matching rule
+// wins, so specific modalities go before "*".
+func ParseCompressionRules(specs []string) (*CompressionPolicy, error) {
+	p := &CompressionPolicy{}
+	for _, spec := range specs {
+		parts := strings.Split(strings.TrimSpace(spec), ":")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid compression rule %q (want tier:modalities:syntax)", spec)
+		}
+		rule := compressionRule{tier: parts[0]}
+		if parts[1] != "*" {
+			rule.modalities = make(map[string]bool)
+			for _, modality := range strings.Split(parts[1], "|") {
+				rule.modalities[strings.ToUpper(strings.TrimSpace(modality))] = true
+			}
+		}
+		syntax, ok := losslessSyntax(parts[2])
+		if !ok {
+			return nil, fmt.Errorf("compression rule %q: %q is not a supported lossless transfer syntax", spec, parts[2])
+		}
+		rule.syntax = syntax
+		p.rules = append(p.rules, rule)
+	}
+	return p, nil
+}
+
+func losslessSyntax(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if uid, ok := losslessSyntaxes[name]; ok {
+		return uid, true
+	}
+	for _, uid := range losslessSyntaxes {
+		if uid == name {
+			return uid, true
+		}
+	}
+	return "", false
+}
+
+// Target returns the transfer syntax to compress to for an instance of
+// modality stored as storedSyntax and moving into tier, or "" to copy it as is.
+// Only uncompressed instances are re-encoded; anything already compressed,
+// lossy or not, is left alone.
+func (p *CompressionPolicy) Target(tier, modality, storedSyntax string) string {
+	if p == nil || !isUncompressed(storedSyntax) {
+		return ""
+	}
+	modality = strings.ToUpper(modality)
+	for _, rule := range p.rules {
+		if rule.tier == tier && (rule.modalities == nil || rule.modalities[modality]) {
+			return rule.syntax
+		}
+	}
+	return ""
+}
+
+func isUncompressed(syntax string) bool {
+	switch syntax {
+	case dicom.ImplicitVRLittleEndian, dicom.ExplicitVRLittleEndian, dicom.ExplicitVRBigEndian, dicom.DeflatedExplicitVRLittleEndian:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,758 @@
+// File: backend/internal/mover/mover.go
+package mover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/ewag/gen-erics/backend/internal/integrity"
+	"github.com/ewag/gen-erics/backend/internal/maintenance"
+	models "github.com/ewag/gen-erics/backend/internal/models"
+	"github.com/ewag/gen-erics/backend/internal/orthanc"
+	"github.com/ewag/gen-erics/backend/internal/retry"
+	"github.com/ewag/gen-erics/backend/internal/storage"
+	"github.com/ewag/gen-erics/backend/internal/tiers"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// TierHot is the tier served directly by Orthanc.
+const TierHot = "hot"
+
+// ErrNoBackend is returned when a transition targets a tier without a configured backend.
+var ErrNoBackend = errors.New("no backend configured for tier")
+
+// HoldChecker reports whether a study may leave its tier; it returns an
+// error for studies under legal hold.
+type HoldChecker interface {
+	CheckHold(ctx context.Context, studyID string) error
+}
+
+// Result summarises a completed tier transition.
+type Result struct {
+	Instances int   `json:"instances"`
+	Bytes     int64 `json:"bytes"`
+	// Instances re-encoded to a lossless syntax on the way, and the bytes that saved
+	Compressed int   `json:"compressed,omitempty"`
+	SavedBytes int64 `json:"savedBytes,omitempty"`
+	// Instances already in place from an earlier, interrupted attempt
+	Resumed int `json:"resumed,omitempty"`
+	// The study's new Orthanc ID, when a recall moved it to the patient the
+	// catalog now files it under (e.g. after a merge); its records follow it
+	StudyID string `json:"studyId,omitempty"`
+}
+
+// Mover copies study data between Orthanc (hot) and the tier backends,
+// verifying SHA-256 checksums on every hop before the source is removed.
+type Mover struct {
+	orthanc     *orthanc.Client
+	catalog     storage.CatalogStore
+	patients    storage.PatientStore
+	tiers       *tiers.Registry
+	metrics     *integrity.Metrics
+	compression *CompressionPolicy
+	holds       HoldChecker
+	bandwidth   *maintenance.Bandwidth
+	stepRetry   retry.Policy
+	savedBytes  metric.Int64Counter
+}
+
+// NewMover creates a Mover. compression may be nil to store every instance as
+// is; holds may be nil when nothing is ever held; bandwidth may be nil to
+// leave edge transfers unthrottled. stepRetry governs how each step of an
+// instance's transfer is retried.
+func NewMover(orthancClient *orthanc.Client, catalog storage.CatalogStore, patients storage.PatientStore, registry *tiers.Registry,
+	metrics *integrity.Metrics, compression *CompressionPolicy, holds HoldChecker, bandwidth *maintenance.Bandwidth, stepRetry retry.Policy) *Mover {
+	m := &Mover{orthanc: orthancClient, catalog: catalog, patients: patients, tiers: registry, metrics: metrics, compression: compression,
+		holds: holds, bandwidth: bandwidth, stepRetry: stepRetry}
+	var err error
+	if m.savedBytes, err = otel.Meter(meterName).Int64Counter("gen_erics.mover.compression_saved_bytes",
+		metric.WithDescription("Bytes saved by compressing instances on tier transition"), metric.WithUnit("By")); err != nil {
+		slog.Warn("Failed to create mover metric", "metric", "compression_saved_bytes", "error", err)
+	}
+	return m
+}
+
+const meterName = "github.com/ewag/gen-erics/backend/internal/mover"
+
+// Transition moves a study's data from one tier to another. Moving within the
+// same tier (e.g. between edges in hot) is a no-op for the data. Studies under
+// legal hold stay where they are.
+func (m *Mover) Transition(ctx context.Context, studyID, fromTier, toTier string) (*Result, error) {
+	return m.TransitionOnEdge(ctx, studyID, fromTier, toTier, "")
+}
+
+// TransitionOnEdge is Transition for a hot study on, or recalled to, edgeID:
+// traffic to and from Orthanc counts against that edge's bandwidth limit.
+// Instances an earlier attempt left verified in the target are kept; on
+// failure everything written to the target is rolled back.
+func (m *Mover) TransitionOnEdge(ctx context.Context, studyID, fromTier, toTier, edgeID string) (*Result, error) {
+	return m.transition(ctx, studyID, fromTier, toTier, edgeID, transitionOptions{})
+}
+
+// ResumeTransition is TransitionOnEdge for callers that retry: after a
+// transient failure the instances already copied and verified stay in the
+// target, so the next attempt resumes after the last of them. Call Abandon
+// when giving up.
+func (m *Mover) ResumeTransition(ctx context.Context, studyID, fromTier, toTier, edgeID string) (*Result, error) {
+	return m.transition(ctx, studyID, fromTier, toTier, edgeID, transitionOptions{keepPartial: true})
+}
+
+// TransitionIgnoringHolds is TransitionOnEdge for moves that preserve a
+// study rather than put it at risk, such as restoring it from the trash or
+// undoing a move: legal holds, which exist to preserve data, don't stop them.
+func (m *Mover) TransitionIgnoringHolds(ctx context.Context, studyID, fromTier, toTier, edgeID string) (*Result, error) {
+	return m.transition(ctx, studyID, fromTier, toTier, edgeID, transitionOptions{ignoreHold: true})
+}
+
+// transitionOptions vary how a transition treats failures and holds.
+type transitionOptions struct {
+	keepPartial bool // Keep verified copies in the target on failure, for a resume
+	ignoreHold  bool // Move studies under legal hold too
+}
+
+func (m *Mover) transition(ctx context.Context, studyID, fromTier, toTier, edgeID string, opts transitionOptions) (*Result, error) {
+	if fromTier == toTier {
+		return &Result{}, nil
+	}
+	if m.holds != nil && !opts.ignoreHold {
+		if err := m.holds.CheckHold(ctx, studyID); err != nil {
+			return nil, retry.Permanent(err)
+		}
+	}
+	switch {
+	case fromTier == TierHot:
+		return m.offload(ctx, studyID, toTier, edgeID, opts.keepPartial)
+	case toTier == TierHot:
+		return m.recall(ctx, studyID, fromTier, edgeID)
+	default:
+		return m.relocate(ctx, studyID, fromTier, toTier, opts.keepPartial)
+	}
+}
+
+// Abandon removes what failed attempts of a move left in the target, once
+// nobody will resume it. It only does so while the source copy is still
+// complete, so the study never loses its last copy.
+func (m *Mover) Abandon(ctx context.Context, studyID, fromTier, toTier string) error {
+	switch {
+	case fromTier == toTier:
+		return nil
+	case toTier == TierHot:
+		source, err := m.catalog.ListCatalogEntries(ctx, studyID, fromTier)
+		if err != nil {
+			return err
+		}
+		if len(source) == 0 {
+			return fmt.Errorf("study %s has no copy in %s; keeping the one in Orthanc", studyID, fromTier)
+		}
+		if err := m.orthanc.DeleteStudy(ctx, studyID); err != nil && !errors.Is(err, orthanc.ErrNotFound) {
+			return fmt.Errorf("failed to remove partial recall of study %s: %w", studyID, err)
+		}
+	default:
+		if fromTier == TierHot {
+			if _, err := m.orthanc.GetStudyInstances(ctx, studyID); err != nil {
+				return fmt.Errorf("study %s is not in Orthanc any more; keeping its copy in %s: %w", studyID, toTier, err)
+			}
+		} else if source, err := m.catalog.ListCatalogEntries(ctx, studyID, fromTier); err != nil {
+			return err
+		} else if len(source) == 0 {
+			return fmt.Errorf("study %s has no copy in %s; keeping the one in %s", studyID, fromTier, toTier)
+		}
+		backend, ok := m.tiers.For(toTier)
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrNoBackend, toTier)
+		}
+		partial, err := m.catalog.ListCatalogEntries(ctx, studyID, toTier)
+		if err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(partial))
+		for _, e := range partial {
+			keys = append(keys, e.ObjectKey)
+		}
+		m.rollback(ctx, backend, studyID, toTier, keys)
+	}
+	slog.InfoContext(ctx, "Removed partial copy of abandoned move", "studyID", studyID, "fromTier", fromTier, "toTier", toTier)
+	return nil
+}
+
+// offload copies every instance of a hot study from Orthanc into a tier backend,
+// then deletes the study from Orthanc.
+func (m *Mover) offload(ctx context.Context, studyID, toTier, edgeID string, keepPartial bool) (*Result, error) {
+	backend, ok := m.tiers.For(toTier)
+	if !ok {
+		return nil, retry.Permanent(fmt.Errorf("%w: %s", ErrNoBackend, toTier))
+	}
+	logAttrs := []any{"studyID", studyID, "toTier", toTier}
+
+	var instances []orthanc.InstanceDetails
+	if err := m.step(ctx, StepFetch, func() error {
+		var err error
+		instances, err = m.orthanc.GetStudyInstances(ctx, studyID)
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("failed to list instances for offload: %w", err)
+	}
+	done, err := m.verifiedEntries(ctx, studyID, toTier)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the descriptive tags; once offloaded Orthanc can't answer for this study
+	if err := m.catalogStudy(ctx, studyID); err != nil {
+		return nil, err
+	}
+	series := make(map[string]*orthanc.SeriesDetails)
+
+	result := &Result{}
+	written := make([]string, 0, len(instances))
+	for _, inst := range instances {
+		key := tiers.ObjectKey(studyID, inst.ID)
+		if prev, ok := done[inst.ID]; ok {
+			written = append(written, key)
+			result.Instances++
+			result.Bytes += prev.SizeBytes
+			result.Resumed++
+			continue
+		}
+
+		sd := m.seriesDetails(ctx, series, inst.ParentSeries)
+		storedSyntax := ""
+		if meta, err := m.orthanc.GetInstanceMetadata(ctx, inst.ID); err == nil {
+			storedSyntax = meta["TransferSyntax"]
+		} else {
+			slog.WarnContext(ctx, "Failed to get instance metadata for catalog", "instanceID", inst.ID, "error", err)
+		}
+		modality := ""
+		if sd != nil {
+			modality = sd.MainTags.Modality
+		}
+
+		var file *orthanc.FileResponse
+		var compressedTo string
+		fetch := func() error {
+			var err error
+			file, compressedTo, err = m.fetchForTier(ctx, inst.ID, m.compression.Target(toTier, modality, storedSyntax))
+			return err
+		}
+		if err := m.step(ctx, StepFetch, fetch); err != nil {
+			m.abort(ctx, backend, studyID, toTier, written, keepPartial, err)
+			return nil, fmt.Errorf("failed to fetch instance %s from Orthanc: %w", inst.ID, err)
+		}
+
+		var n int64
+		var sum string
+		err := m.step(ctx, StepWrite, func() error {
+			if file == nil { // A failed write consumed the stream
+				if err := fetch(); err != nil {
+					return err
+				}
+			}
+			hr := integrity.NewHashingReader(m.bandwidth.Reader(ctx, edgeID, file.Body))
+			var putErr error
+			n, putErr = backend.Put(ctx, key, hr)
+			file.Body.Close()
+			file = nil
+			sum = hr.Sum()
+			return putErr
+		})
+		written = append(written, key)
+		if err != nil {
+			m.abort(ctx, backend, studyID, toTier, written, keepPartial, err)
+			return nil, fmt.Errorf("failed to write instance %s to %s: %w", inst.ID, toTier, err)
+		}
+
+		if err := m.step(ctx, StepVerify, func() error {
+			return m.verifyStored(ctx, backend, key, toTier, sum, n)
+		}); err != nil {
+			m.abort(ctx, backend, studyID, toTier, written, keepPartial, err)
+			return nil, fmt.Errorf("instance %s failed verification after write: %w", inst.ID, err)
+		}
+
+		now := time.Now()
+		entry := models.CatalogEntry{
+			InstanceID:        inst.ID,
+			StudyID:           studyID,
+			SeriesID:          inst.ParentSeries,
+			SOPInstanceUID:    inst.MainTags.SOPInstanceUID,
+			InstanceNumber:    inst.MainTags.InstanceNumber,
+			TransferSyntaxUID: storedSyntax,
+			Tier:              toTier,
+			ObjectKey:         key,
+			SizeBytes:         n,
+			SHA256:            sum,
+			LastVerifiedAt:    &now,
+			VerifyStatus:      models.VerifyStatusOK,
+		}
+		if sd != nil {
+			entry.SeriesNumber = sd.MainTags.SeriesNumber
+			entry.SeriesDescription = sd.MainTags.SeriesDescription
+			entry.Modality = sd.MainTags.Modality
+			entry.BodyPart = sd.MainTags.BodyPartExamined
+		}
+		entry.NumberOfFrames, entry.Rows, entry.Columns = inst.ImageSize()
+		entry.EncryptionKeyID = m.encryptionKeyID(ctx, backend, key)
+		if compressedTo != "" {
+			entry.OriginalTransferSyntaxUID = storedSyntax
+			entry.TransferSyntaxUID = compressedTo
+			result.Compressed++
+			// Compression can grow an instance slightly; it saves nothing then
+			if saved := inst.FileSize - n; saved > 0 {
+				result.SavedBytes += saved
+			}
+		}
+		if err := m.catalog.PutCatalogEntry(ctx, entry); err != nil {
+			m.abort(ctx, backend, studyID, toTier, written, keepPartial, err)
+			return nil, err
+		}
+		result.Instances++
+		result.Bytes += n
+	}
+
+	if err := m.step(ctx, StepDelete, func() error { return m.orthanc.DeleteStudy(ctx, studyID) }); err != nil {
+		// Data is safely in the tier; a leftover hot copy only costs space.
+		slog.WarnContext(ctx, "Offload complete but failed to delete study from Orthanc", append(logAttrs, "error", err)...)
+	}
+
+	if result.Compressed > 0 && m.savedBytes != nil {
+		m.savedBytes.Add(ctx, result.SavedBytes, metric.WithAttributes(attribute.String("tier", toTier)))
+	}
+	slog.InfoContext(ctx, "Offloaded study to tier backend", append(logAttrs, "instances", result.Instances, "bytes", result.Bytes,
+		"compressed", result.Compressed, "savedBytes", result.SavedBytes, "resumed", result.Resumed)...)
+	return result, nil
+}
+
+// encryptionKeyID names the data key an object written to backend is
+// protected by, or "" for unencrypted backends.
+func (m *Mover) encryptionKeyID(ctx context.Context, backend tiers.Backend, key string) string {
+	enc, ok := backend.(tiers.KeyIdentifier)
+	if !ok {
+		return ""
+	}
+	id, err := enc.KeyID(ctx, key)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to look up data key for catalog", "key", key, "error", err)
+	}
+	return id
+}
+
+// fetchForTier streams an instance out of Orthanc, transcoded to syntax when
+// that is non-empty. A failed transcode falls back to the stored encoding,
+// since compression only saves space; the returned syntax is "" then.
+func (m *Mover) fetchForTier(ctx context.Context, instanceID, syntax string) (*orthanc.FileResponse, string, error) {
+	if syntax != "" {
+		file, err := m.orthanc.TranscodeInstance(ctx, instanceID, syntax)
+		if err == nil {
+			return file, syntax, nil
+		}
+		slog.WarnContext(ctx, "Failed to compress instance for tier, storing it as is", "instanceID", instanceID, "transferSyntax", syntax, "error", err)
+	}
+	file, err := m.orthanc.GetInstanceFile(ctx, instanceID)
+	return file, "", err
+}
+
+// catalogStudy records the patient/study tags of a hot study in the study catalog.
+func (m *Mover) catalogStudy(ctx context.Context, studyID string) error {
+	details, err := m.orthanc.GetStudyDetails(ctx, studyID)
+	if err != nil {
+		return fmt.Errorf("failed to get study details for catalog: %w", err)
+	}
+	return m.catalog.PutStudyCatalogEntry(ctx, models.StudyCatalogEntry{
+		StudyID:           studyID,
+		StudyInstanceUID:  details.MainTags.StudyInstanceUID,
+		PatientResourceID: details.ParentPatient,
+		PatientID:         details.PatientMainTags.PatientID,
+		PatientName:       details.PatientMainTags.PatientName,
+		PatientBirthDate:  details.PatientMainTags.PatientBirthDate,
+		PatientSex:        details.PatientMainTags.PatientSex,
+		StudyDate:         details.MainTags.StudyDate,
+		StudyDescription:  details.MainTags.StudyDescription,
+		AccessionNumber:   details.MainTags.AccessionNumber,
+	})
+}
+
+// seriesDetails fetches series tags once per series. Missing details only cost
+// us descriptive catalog fields, so failures are logged and skipped.
+func (m *Mover) seriesDetails(ctx context.Context, cache map[string]*orthanc.SeriesDetails, seriesID string) *orthanc.SeriesDetails {
+	if seriesID == "" {
+		return nil
+	}
+	if sd, ok := cache[seriesID]; ok {
+		return sd
+	}
+	sd, err := m.orthanc.GetSeriesDetails(ctx, seriesID)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to get series details for catalog", "seriesID", seriesID, "error", err)
+	}
+	cache[seriesID] = sd
+	return sd
+}
+
+// recall uploads a study's instances from a tier backend back into Orthanc,
+// verifying each one on the way, then removes the tier copy. Instances
+// already in Orthanc, e.g. from an interrupted recall, are not uploaded again.
+// The objects keep the patient tags they were stored with, so the study is
+// then brought in line with its catalog entry, which HL7 updates and merges
+// keep current.
+func (m *Mover) recall(ctx context.Context, studyID, fromTier, edgeID string) (*Result, error) {
+	backend, ok := m.tiers.For(fromTier)
+	if !ok {
+		return nil, retry.Permanent(fmt.Errorf("%w: %s", ErrNoBackend, fromTier))
+	}
+	logAttrs := []any{"studyID", studyID, "fromTier", fromTier}
+
+	entries, err := m.catalog.ListCatalogEntries(ctx, studyID, fromTier)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		slog.WarnContext(ctx, "No catalogued objects for study in tier, treating move as status-only", logAttrs...)
+		return &Result{}, nil
+	}
+	present, err := m.hotInstances(ctx, studyID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	for _, entry := range entries {
+		if present[entry.InstanceID] {
+			result.Resumed++
+		} else if err := m.step(ctx, StepWrite, func() error {
+			return m.copyVerified(ctx, backend, entry, func(r io.Reader) error {
+				_, err := m.orthanc.UploadInstance(ctx, m.bandwidth.Reader(ctx, edgeID, r))
+				return err
+			})
+		}); err != nil {
+			return nil, fmt.Errorf("failed to recall instance %s: %w", entry.InstanceID, err)
+		}
+		if entry.OriginalTransferSyntaxUID != "" && entry.OriginalTransferSyntaxUID != entry.TransferSyntaxUID {
+			if err := m.step(ctx, StepWrite, func() error { return m.restoreSyntax(ctx, entry) }); err != nil {
+				return nil, fmt.Errorf("failed to restore transfer syntax of recalled instance %s: %w", entry.InstanceID, err)
+			}
+		}
+		result.Instances++
+		result.Bytes += entry.SizeBytes
+	}
+
+	m.purge(ctx, backend, studyID, fromTier, entries)
+	// The study is back either way; one left with stale patient tags is
+	// still better than a recall that reports failure for data in Orthanc
+	newID, err := m.restorePatient(ctx, studyID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Recalled study keeps the patient tags it was offloaded with", append(logAttrs, "error", err)...)
+	}
+	result.StudyID = newID
+	slog.InfoContext(ctx, "Recalled study from tier backend", append(logAttrs, "instances", result.Instances, "bytes", result.Bytes,
+		"resumed", result.Resumed, "newStudyID", newID)...)
+	return result, nil
+}
+
+// restorePatient rewrites the patient tags of a recalled study in Orthanc
+// to those in its catalog entry. When that changes the PatientID the study
+// gets a new Orthanc ID, which its records are moved to and which is
+// returned; otherwise it returns "".
+func (m *Mover) restorePatient(ctx context.Context, studyID string) (string, error) {
+	catalogued, found, err := m.catalog.GetStudyCatalogEntry(ctx, studyID)
+	if err != nil || !found || catalogued.PatientID == "" {
+		return "", err
+	}
+	details, err := m.orthanc.GetStudyDetails(ctx, studyID)
+	if err != nil {
+		return "", err
+	}
+	replace := map[string]string{}
+	for tag, values := range map[string][2]string{
+		"PatientID":        {catalogued.PatientID, details.PatientMainTags.PatientID},
+		"PatientName":      {catalogued.PatientName, details.PatientMainTags.PatientName},
+		"PatientBirthDate": {catalogued.PatientBirthDate, details.PatientMainTags.PatientBirthDate},
+		"PatientSex":       {catalogued.PatientSex, details.PatientMainTags.PatientSex},
+	} {
+		if values[0] != "" && values[0] != values[1] {
+			replace[tag] = values[0]
+		}
+	}
+	if len(replace) == 0 {
+		return "", nil
+	}
+	// Orthanc needs the PatientID in every patient-level rewrite
+	replace["PatientID"] = catalogued.PatientID
+
+	keepSource := false
+	modified, err := m.orthanc.ModifyStudy(ctx, studyID, orthanc.ModifyRequest{
+		Replace:    replace,
+		Keep:       []string{"StudyInstanceUID", "SeriesInstanceUID", "SOPInstanceUID"},
+		Force:      true,
+		KeepSource: &keepSource,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to rewrite patient tags in Orthanc: %w", err)
+	}
+	if modified.ID == "" || modified.ID == studyID {
+		return "", nil
+	}
+	if err := m.patients.RekeyStudy(ctx, studyID, modified.ID); err != nil {
+		return "", err
+	}
+	slog.InfoContext(ctx, "Moved recalled study to its catalogued patient", "studyID", studyID, "newStudyID", modified.ID,
+		"patientID", catalogued.PatientID)
+	return modified.ID, nil
+}
+
+// restoreSyntax re-encodes a recalled instance that was compressed when it
+// was offloaded back into the transfer syntax it had in Orthanc, so a round
+// trip through the tiers leaves Orthanc's copy as it was. Orthanc only
+// transcodes what it holds, so the compressed copy is uploaded first and
+// then replaced. An instance an earlier attempt already restored is left
+// alone; one lost between delete and upload is uploaded again on resume,
+// as the tier copy is only purged once every instance is back.
+func (m *Mover) restoreSyntax(ctx context.Context, entry models.CatalogEntry) error {
+	original := entry.OriginalTransferSyntaxUID
+	metadata, err := m.orthanc.GetInstanceMetadata(ctx, entry.InstanceID)
+	if err != nil {
+		return err
+	}
+	if metadata["TransferSyntax"] == original {
+		return nil
+	}
+
+	file, err := m.orthanc.TranscodeInstance(ctx, entry.InstanceID, original)
+	if err != nil {
+		return err
+	}
+	defer file.Body.Close()
+	// Orthanc won't overwrite an instance it has; spool the decoded copy
+	// while the compressed one is deleted
+	spool, err := os.CreateTemp("", "recall-*.dcm")
+	if err != nil {
+		return fmt.Errorf("failed to create spool file: %w", err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+	if _, err := io.Copy(spool, file.Body); err != nil {
+		return fmt.Errorf("failed to read transcoded instance: %w", err)
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if err := m.orthanc.DeleteInstance(ctx, entry.InstanceID); err != nil {
+		return err
+	}
+	if _, err := m.orthanc.UploadInstance(ctx, spool); err != nil {
+		return err
+	}
+	slog.DebugContext(ctx, "Restored transfer syntax of recalled instance", "instanceID", entry.InstanceID,
+		"from", entry.TransferSyntaxUID, "to", original)
+	return nil
+}
+
+// hotInstances returns the IDs of the instances of a study Orthanc has.
+func (m *Mover) hotInstances(ctx context.Context, studyID string) (map[string]bool, error) {
+	var instances []orthanc.InstanceDetails
+	err := m.step(ctx, StepFetch, func() error {
+		var err error
+		instances, err = m.orthanc.GetStudyInstances(ctx, studyID)
+		return err
+	})
+	if errors.Is(err, orthanc.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instances already recalled: %w", err)
+	}
+	present := make(map[string]bool, len(instances))
+	for _, inst := range instances {
+		present[inst.ID] = true
+	}
+	return present, nil
+}
+
+// relocate moves a study between two tier backends (e.g. cold -> archive).
+func (m *Mover) relocate(ctx context.Context, studyID, fromTier, toTier string, keepPartial bool) (*Result, error) {
+	src, ok := m.tiers.For(fromTier)
+	if !ok {
+		return nil, retry.Permanent(fmt.Errorf("%w: %s", ErrNoBackend, fromTier))
+	}
+	dst, ok := m.tiers.For(toTier)
+	if !ok {
+		return nil, retry.Permanent(fmt.Errorf("%w: %s", ErrNoBackend, toTier))
+	}
+	logAttrs := []any{"studyID", studyID, "fromTier", fromTier, "toTier", toTier}
+
+	entries, err := m.catalog.ListCatalogEntries(ctx, studyID, fromTier)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		slog.WarnContext(ctx, "No catalogued objects for study in tier, treating move as status-only", logAttrs...)
+		return &Result{}, nil
+	}
+	done, err := m.verifiedEntries(ctx, studyID, toTier)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	written := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if prev, ok := done[entry.InstanceID]; ok && prev.SHA256 == entry.SHA256 {
+			written = append(written, entry.ObjectKey)
+			result.Instances++
+			result.Bytes += prev.SizeBytes
+			result.Resumed++
+			continue
+		}
+
+		var n int64
+		err := m.step(ctx, StepWrite, func() error {
+			return m.copyVerified(ctx, src, entry, func(r io.Reader) error {
+				var putErr error
+				n, putErr = dst.Put(ctx, entry.ObjectKey, r)
+				return putErr
+			})
+		})
+		written = append(written, entry.ObjectKey)
+		if err != nil {
+			m.abort(ctx, dst, studyID, toTier, written, keepPartial, err)
+			return nil, fmt.Errorf("failed to copy instance %s to %s: %w", entry.InstanceID, toTier, err)
+		}
+		if err := m.step(ctx, StepVerify, func() error {
+			return m.verifyStored(ctx, dst, entry.ObjectKey, toTier, entry.SHA256, n)
+		}); err != nil {
+			m.abort(ctx, dst, studyID, toTier, written, keepPartial, err)
+			return nil, fmt.Errorf("instance %s failed verification after write: %w", entry.InstanceID, err)
+		}
+
+		now := time.Now()
+		moved := entry
+		moved.Tier = toTier
+		moved.LastVerifiedAt = &now
+		moved.VerifyStatus = models.VerifyStatusOK
+		moved.EncryptionKeyID = m.encryptionKeyID(ctx, dst, entry.ObjectKey)
+		if err := m.catalog.PutCatalogEntry(ctx, moved); err != nil {
+			m.abort(ctx, dst, studyID, toTier, written, keepPartial, err)
+			return nil, err
+		}
+		result.Instances++
+		result.Bytes += n
+	}
+
+	m.purge(ctx, src, studyID, fromTier, entries)
+	slog.InfoContext(ctx, "Relocated study between tier backends", append(logAttrs, "instances", result.Instances, "bytes", result.Bytes,
+		"resumed", result.Resumed)...)
+	return result, nil
+}
+
+// verifiedEntries returns the catalogued instances of a study already in
+// tier and verified there, by instance ID: what an interrupted move left.
+func (m *Mover) verifiedEntries(ctx context.Context, studyID, tier string) (map[string]models.CatalogEntry, error) {
+	entries, err := m.catalog.ListCatalogEntries(ctx, studyID, tier)
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[string]models.CatalogEntry, len(entries))
+	for _, e := range entries {
+		if e.VerifyStatus == models.VerifyStatusOK {
+			done[e.InstanceID] = e
+		}
+	}
+	return done, nil
+}
+
+// copyVerified opens a catalogued object and passes a verifying reader to copy.
+// A checksum mismatch is recorded against the catalog entry before returning.
+func (m *Mover) copyVerified(ctx context.Context, backend tiers.Backend, entry models.CatalogEntry, copy func(io.Reader) error) error {
+	rc, _, err := tiers.OpenEntry(ctx, backend, entry.ObjectKey, entry.EncryptionKeyID)
+	if err != nil {
+		if errors.Is(err, tiers.ErrObjectNotFound) {
+			m.recordFailure(ctx, entry, models.VerifyStatusMissing, err)
+		}
+		return &StepError{Step: StepFetch, Err: err}
+	}
+	vr := integrity.NewVerifyingReader(rc, entry.SHA256, entry.SizeBytes)
+	defer vr.Close()
+
+	err = copy(vr)
+	if errors.Is(err, integrity.ErrChecksumMismatch) {
+		m.recordFailure(ctx, entry, models.VerifyStatusCorrupt, err)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	m.metrics.RecordResult(ctx, entry.Tier, "transition", true)
+	return nil
+}
+
+// verifyStored re-reads a freshly written object and compares it to the source checksum.
+func (m *Mover) verifyStored(ctx context.Context, backend tiers.Backend, key, tier, expected string, size int64) error {
+	rc, _, err := backend.Open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	sum, n, err := integrity.SumReader(rc)
+	if err != nil {
+		return err
+	}
+	ok := sum == expected && n == size
+	m.metrics.RecordResult(ctx, tier, "transition", ok)
+	if !ok {
+		return fmt.Errorf("%w: wrote %s (%d bytes), read back %s (%d bytes)", integrity.ErrChecksumMismatch, expected, size, sum, n)
+	}
+	return nil
+}
+
+func (m *Mover) recordFailure(ctx context.Context, entry models.CatalogEntry, status string, cause error) {
+	m.metrics.RecordResult(ctx, entry.Tier, "transition", false)
+	slog.ErrorContext(ctx, "INTEGRITY ALERT: object failed verification during tier transition",
+		"instanceID", entry.InstanceID, "studyID", entry.StudyID, "tier", entry.Tier, "verifyStatus", status, "error", cause)
+	if err := m.catalog.RecordVerification(ctx, entry.InstanceID, entry.Tier, status, time.Now()); err != nil {
+		slog.WarnContext(ctx, "Failed to record verification failure", "instanceID", entry.InstanceID, "error", err)
+	}
+}
+
+// abort cleans up after a failed transition: a transient failure of a move
+// that will be resumed keeps what was written, anything else rolls it back.
+func (m *Mover) abort(ctx context.Context, backend tiers.Backend, studyID, tier string, keys []string, keepPartial bool, cause error) {
+	if keepPartial && Retryable(cause) {
+		slog.InfoContext(ctx, "Keeping partial copy for the move to resume", "studyID", studyID, "tier", tier, "objects", len(keys))
+		return
+	}
+	m.rollback(ctx, backend, studyID, tier, keys)
+}
+
+// rollback removes objects written during a failed transition.
+func (m *Mover) rollback(ctx context.Context, backend tiers.Backend, studyID, tier string, keys []string) {
+	// Use a fresh context so cleanup still runs if the request was cancelled.
+	cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	for _, key := range keys {
+		if err := backend.Delete(cleanupCtx, key); err != nil {
+			slog.WarnContext(cleanupCtx, "Failed to remove object during rollback", "objectKey", key, "tier", tier, "error", err)
+		}
+	}
+	if err := m.catalog.DeleteCatalogEntries(cleanupCtx, studyID, tier); err != nil {
+		slog.WarnContext(cleanupCtx, "Failed to remove catalog entries during rollback", "studyID", studyID, "tier", tier, "error", err)
+	}
+}
+
+// purge deletes the source copy after a successful transition.
+func (m *Mover) purge(ctx context.Context, backend tiers.Backend, studyID, tier string, entries []models.CatalogEntry) {
+	for _, entry := range entries {
+		if err := m.step(ctx, StepDelete, func() error { return backend.Delete(ctx, entry.ObjectKey) }); err != nil {
+			slog.WarnContext(ctx, "Failed to delete source object after transition", "objectKey", entry.ObjectKey, "tier", tier, "error", err)
+		}
+	}
+	if err := m.catalog.DeleteCatalogEntries(ctx, studyID, tier); err != nil {
+		slog.WarnContext(ctx, "Failed to delete source catalog entries after transition", "studyID", studyID, "tier", tier, "error", err)
+	}
+}
@@ -0,0 +1,77 @@
+// File: backend/internal/mover/steps.go
+package mover
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ewag/gen-erics/backend/internal/integrity"
+	"github.com/ewag/gen-erics/backend/internal/orthanc"
+	"github.com/ewag/gen-erics/backend/internal/retry"
+	"github.com/ewag/gen-erics/backend/internal/tiers"
+)
+
+// Steps of an instance's transfer, each retried on its own.
+const (
+	StepFetch  = "fetch"  // Reading the instance from its source
+	StepWrite  = "write"  // Writing it to the target
+	StepVerify = "verify" // Reading it back and checking its checksum
+	StepDelete = "delete" // Removing the source copy
+)
+
+// StepError is the error of a transfer step that failed for good.
+type StepError struct {
+	Step string
+	Err  error
+}
+
+func (e *StepError) Error() string { return e.Step + ": " + e.Err.Error() }
+func (e *StepError) Unwrap() error { return e.Err }
+
+// FailedStep returns the step err failed in, or "" if it didn't come from one.
+func FailedStep(err error) string {
+	var se *StepError
+	if errors.As(err, &se) {
+		return se.Step
+	}
+	return ""
+}
+
+// Retryable reports whether err may go away on its own, i.e. whether the
+// transition is worth trying again later. Missing or corrupt data, missing
+// backends and errors marked permanent are not. A cancelled context is left
+// to the caller, who knows whether it was its own.
+func Retryable(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case retry.IsPermanent(err),
+		errors.Is(err, integrity.ErrChecksumMismatch),
+		errors.Is(err, tiers.ErrObjectNotFound),
+		errors.Is(err, orthanc.ErrNotFound),
+		errors.Is(err, ErrNoBackend):
+		return false
+	}
+	return true
+}
+
+// stepRetryable is Retryable for a single step. Orthanc errors are left to
+// the Orthanc client, which already retried them, and to the job's retries
+// with their longer backoff: repeating them here too would multiply the
+// calls made to an Orthanc that is already failing.
+func stepRetryable(err error) bool {
+	if errors.Is(err, orthanc.ErrUnavailable) || errors.Is(err, orthanc.ErrUpstream) {
+		return false
+	}
+	return Retryable(err)
+}
+
+// step runs one transfer step under the step retry policy. The error it
+// returns names the step, unless fn's error already names one.
+func (m *Mover) step(ctx context.Context, step string, fn func() error) error {
+	err := retry.Do(ctx, m.stepRetry, stepRetryable, fn)
+	if err == nil || FailedStep(err) != "" {
+		return err
+	}
+	return &StepError{Step: step, Err: err}
+}
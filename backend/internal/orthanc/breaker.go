@@ -0,0 +1,110 @@
+// File: backend/internal/orthanc/breaker.go
+package orthanc
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states, as reported by Client.CircuitState.
+const (
+	CircuitClosed   = "closed"    // Calls go through
+	CircuitOpen     = "open"      // Calls fail fast until the cooldown is over
+	CircuitHalfOpen = "half-open" // One probe call is let through
+)
+
+// breaker stops calls to Orthanc after threshold consecutive failures, for
+// cooldown; then a single probe decides whether it closes again. A zero
+// threshold disables it.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow reports whether a call may go ahead. In the half-open state it
+// admits one probe at a time; the caller must report its outcome.
+func (b *breaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.stateLocked() {
+	case CircuitClosed:
+		return true
+	case CircuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return false
+	}
+}
+
+// record reports the outcome of an allowed call.
+func (b *breaker) record(ok bool) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	wasOpen := b.failures >= b.threshold
+	b.probing = false
+	if ok {
+		if wasOpen {
+			slog.Info("Orthanc is reachable again, closing circuit")
+		}
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		if !wasOpen {
+			slog.Warn("Orthanc keeps failing, opening circuit", "failures", b.failures, "cooldown", b.cooldown)
+		}
+		b.openedAt = time.Now()
+	}
+}
+
+// release ends an allowed call whose outcome says nothing about Orthanc,
+// e.g. one cancelled by its caller.
+func (b *breaker) release() {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+func (b *breaker) state() string {
+	if b.threshold <= 0 {
+		return CircuitClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stateLocked()
+}
+
+func (b *breaker) stateLocked() string {
+	switch {
+	case b.failures < b.threshold:
+		return CircuitClosed
+	case time.Since(b.openedAt) < b.cooldown:
+		return CircuitOpen
+	default:
+		return CircuitHalfOpen
+	}
+}
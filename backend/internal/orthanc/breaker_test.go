@@ -0,0 +1,81 @@
+package orthanc
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestBreaker runs each case as a sequence of steps, checking the state
+// after each one:
+//
+//	allow / deny  a call is (not) allowed
+//	ok / fail     an allowed call succeeded / failed
+//	release       an allowed call ended without telling anything
+//	cool          the cooldown passes
+func TestBreaker(t *testing.T) {
+	const cooldown = time.Minute
+	tests := []struct {
+		name      string
+		threshold int
+		steps     string // "step:state ..."
+	}{
+		{
+			name:      "stays closed below the threshold",
+			threshold: 3,
+			steps:     "allow:closed fail:closed allow:closed fail:closed allow:closed ok:closed allow:closed fail:closed allow:closed fail:closed",
+		},
+		{
+			name:      "opens at the threshold and fails fast",
+			threshold: 2,
+			steps:     "allow:closed fail:closed allow:closed fail:open deny:open deny:open",
+		},
+		{
+			name:      "successful probe closes",
+			threshold: 1,
+			steps:     "allow:closed fail:open deny:open cool:half-open allow:half-open deny:half-open ok:closed allow:closed",
+		},
+		{
+			name:      "failed probe reopens for another cooldown",
+			threshold: 1,
+			steps:     "allow:closed fail:open cool:half-open allow:half-open fail:open deny:open cool:half-open allow:half-open",
+		},
+		{
+			name:      "released probe lets another one through",
+			threshold: 1,
+			steps:     "allow:closed fail:open cool:half-open allow:half-open deny:half-open release:half-open allow:half-open ok:closed",
+		},
+		{
+			name:      "disabled",
+			threshold: 0,
+			steps:     "allow:closed fail:closed fail:closed allow:closed release:closed allow:closed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBreaker(tt.threshold, cooldown)
+			for i, step := range strings.Fields(tt.steps) {
+				action, want, _ := strings.Cut(step, ":")
+				switch action {
+				case "allow", "deny":
+					if got := b.allow(); got != (action == "allow") {
+						t.Fatalf("step %d (%s): allow() = %v", i, step, got)
+					}
+				case "ok", "fail":
+					b.record(action == "ok")
+				case "release":
+					b.release()
+				case "cool":
+					b.mu.Lock()
+					b.openedAt = b.openedAt.Add(-cooldown)
+					b.mu.Unlock()
+				default:
+					t.Fatalf("unknown step %q", step)
+				}
+				if got := b.state(); got != want {
+					t.Fatalf("step %d (%s): state = %s, want %s", i, step, got, want)
+				}
+			}
+		})
+	}
+}
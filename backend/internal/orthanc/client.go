@@ -1,14 +1,18 @@
 package orthanc
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 	"io"
 	"context"
 	"log/slog"
-	// "errors"
+
+	"github.com/ewag/gen-erics/backend/internal/retry"
 )
 
 const (
@@ -19,11 +23,14 @@ const (
 type Client struct {
 	BaseURL    string
 	httpClient *http.Client
+	timeout    time.Duration
+	retry      retry.Policy
+	breaker    *breaker
 }
 
 // NewClient creates a new Orthanc API client with a default HTTP client
 func NewClient(baseURL string, timeout time.Duration) *Client {
-	return NewClientWithHttpClient(baseURL, &http.Client{Timeout: timeout})
+	return NewClientWithOptions(baseURL, &http.Client{}, Options{Timeout: timeout})
 }
 
 // NewClientWithHttpClient creates a new Orthanc API client with a specific *http.Client
@@ -32,32 +39,44 @@ func NewClientWithHttpClient(baseURL string, client *http.Client) *Client {
 	if client == nil { // Basic default if nil is passed
 		client = &http.Client{Timeout: 15 * time.Second}
 	}
+	return NewClientWithOptions(baseURL, client, Options{})
+}
+
+// NewClientWithOptions creates a new Orthanc API client that retries, times
+// out and fails fast as opts say. client should have no Timeout of its own,
+// which would cut off long downloads; opts.Timeout replaces it.
+func NewClientWithOptions(baseURL string, client *http.Client, opts Options) *Client {
+	if client == nil {
+		client = &http.Client{}
+	}
 	return &Client{
 		BaseURL:    baseURL,
 		httpClient: client,
+		timeout:    opts.Timeout,
+		retry:      opts.Retry,
+		breaker:    newBreaker(opts.BreakerThreshold, opts.BreakerCooldown),
 	}
 }
 
 // ListStudies retrieves a list of study IDs from Orthanc
 // Returns slice of strings (IDs) or an error
-func (c *Client) ListStudies() ([]string, error) {
+func (c *Client) ListStudies(ctx context.Context) ([]string, error) {
 	targetURL := fmt.Sprintf("%s/studies", c.BaseURL)
 
-	req, err := http.NewRequest("GET", targetURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", targetURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request to %s: %w", targetURL, err)
 	}
 	// Add authentication headers later if needed
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get studies from %s: %w", targetURL, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		// TODO: Read body for more error details from Orthanc if available
-		return nil, fmt.Errorf("received non-OK status code %d from %s", resp.StatusCode, targetURL)
+		return nil, statusError(ctx, resp, "studies")
 	}
 
 	var studies []string // Orthanc /studies endpoint returns a JSON array of strings
@@ -69,67 +88,48 @@ func (c *Client) ListStudies() ([]string, error) {
 }
 
 // GetInstancePreview retrieves a rendered preview image (e.g., PNG) for a specific instance.
-// Returns image bytes, content type string, and error.
-func (c *Client) GetInstancePreview(instanceUID string) ([]byte, string, error) {
+// The body is streamed; the caller must close FileResponse.Body.
+func (c *Client) GetInstancePreview(ctx context.Context, instanceUID string) (*FileResponse, error) {
 	// Orthanc uses /instances/{id}/preview endpoint
 	targetURL := fmt.Sprintf("%s/instances/%s/preview", c.BaseURL, instanceUID)
-	req, err := http.NewRequest("GET", targetURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", targetURL, nil)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to create preview request for instance %s: %w", instanceUID, err)
+		return nil, fmt.Errorf("failed to create preview request for instance %s: %w", instanceUID, err)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.doStream(req)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to get preview for instance %s: %w", instanceUID, err)
+		return nil, fmt.Errorf("failed to get preview for instance %s: %w", instanceUID, err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		// Handle specific errors like Not Found
-		if resp.StatusCode == http.StatusNotFound {
-			return nil, "", fmt.Errorf("instance %s not found (404)", instanceUID) // Consider a custom error type
-		}
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, "", fmt.Errorf("received non-OK status code %d getting preview for instance %s: %s", resp.StatusCode, instanceUID, string(bodyBytes))
-	}
-
-	imageData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to read preview response body for instance %s: %w", instanceUID, err)
-	}
-
-	contentType := resp.Header.Get("Content-Type")
-	if contentType == "" {
-		contentType = "application/octet-stream" // Default if not specified
+		defer resp.Body.Close()
+		return nil, statusError(ctx, resp, "preview of instance "+instanceUID)
 	}
 
-	return imageData, contentType, nil
+	return newFileResponse(resp, "application/octet-stream"), nil // Default if not specified
 }
 
 // GetInstanceSimplifiedTags retrieves simplified DICOM tags for an instance as JSON.
-func (c *Client) GetInstanceSimplifiedTags(instanceUID string) (map[string]string, error) {
+func (c *Client) GetInstanceSimplifiedTags(ctx context.Context, instanceUID string) (map[string]any, error) {
 	// Orthanc uses /instances/{id}/simplified-tags endpoint
 	targetURL := fmt.Sprintf("%s/instances/%s/simplified-tags", c.BaseURL, instanceUID)
-	req, err := http.NewRequest("GET", targetURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", targetURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create simplified-tags request for instance %s: %w", instanceUID, err)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get simplified-tags for instance %s: %w", instanceUID, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		if resp.StatusCode == http.StatusNotFound {
-			return nil, fmt.Errorf("instance %s not found (404)", instanceUID) // Consider a custom error type
-		}
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("received non-OK status code %d getting simplified-tags for instance %s: %s", resp.StatusCode, instanceUID, string(bodyBytes))
+		return nil, statusError(ctx, resp, "simplified tags of instance "+instanceUID)
 	}
 
-	var tags map[string]string
+	var tags map[string]any // Values are strings, or arrays for sequences
 	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
 		return nil, fmt.Errorf("failed to decode simplified-tags response for instance %s: %w", instanceUID, err)
 	}
@@ -137,40 +137,39 @@ func (c *Client) GetInstanceSimplifiedTags(instanceUID string) (map[string]strin
 	return tags, nil
 }
 
-// GetInstanceFile retrieves the raw DICOM file content for a specific instance. 
-// look to use io.ReadCloser for streaming later
-func (c *Client) GetInstanceFile(instanceUID string) ([]byte, error) {
+// GetInstanceFile retrieves the raw DICOM file content for a specific instance.
+// The body is streamed; the caller must close FileResponse.Body.
+func (c *Client) GetInstanceFile(ctx context.Context, instanceUID string) (*FileResponse, error) {
+	return c.GetInstanceFileRange(ctx, instanceUID, "")
+}
+
+// GetInstanceFileRange is GetInstanceFile passing byteRange, a Range header
+// value, on to Orthanc unless it is empty. Orthanc may ignore the range and
+// send the whole file; FileResponse.ContentRange is only set when it did not.
+func (c *Client) GetInstanceFileRange(ctx context.Context, instanceUID, byteRange string) (*FileResponse, error) {
 	// Orthanc uses /instances/{id}/file endpoint
 	targetURL := fmt.Sprintf("%s/instances/%s/file", c.BaseURL, instanceUID)
-	req, err := http.NewRequest("GET", targetURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", targetURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create file request for instance %s: %w", instanceUID, err)
 	}
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.doStream(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get file for instance %s: %w", instanceUID, err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		if resp.StatusCode == http.StatusNotFound {
-			return nil, fmt.Errorf("instance %s not found (404)", instanceUID) // Consider a custom error type
-		}
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("received non-OK status code %d getting file for instance %s: %s", resp.StatusCode, instanceUID, string(bodyBytes))
-	}
 
-	// Optional: Check Content-Type if needed
-	// if resp.Header.Get("Content-Type") != contentTypeDICOM { ... }
-
-	dicomData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read file response body for instance %s: %w", instanceUID, err)
+	if resp.StatusCode != http.StatusOK && (byteRange == "" || resp.StatusCode != http.StatusPartialContent) {
+		defer resp.Body.Close()
+		return nil, statusError(ctx, resp, "file of instance "+instanceUID)
 	}
 
-	return dicomData, nil
+	return newFileResponse(resp, contentTypeDICOM), nil
 }
+
 // --- Add more methods later for other Orthanc interactions ---
 // e.g., GetStudyDetails, GetSeries, GetInstance, GetWADO, PostInstance etc.
 func (c *Client) GetStudyDetails(ctx context.Context, orthancStudyID string) (*StudyDetails, error) { // Make sure StudyDetails struct is defined (e.g., in types.go)
@@ -185,7 +184,7 @@ func (c *Client) GetStudyDetails(ctx context.Context, orthancStudyID string) (*S
 		return nil, fmt.Errorf("failed to create request to get study details: %w", err)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		slog.ErrorContext(ctx, "Orthanc client failed to execute request for study details", "url", targetURL, "error", err)
 		return nil, fmt.Errorf("failed to execute request to get study details: %w", err)
@@ -195,14 +194,7 @@ func (c *Client) GetStudyDetails(ctx context.Context, orthancStudyID string) (*S
 	logAttrs := []any{"url", targetURL, "statusCode", resp.StatusCode} // Attributes for logging
 
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
-		logAttrs = append(logAttrs, "responseBody", string(bodyBytes))
-		slog.ErrorContext(ctx, "Orthanc returned non-OK status getting study details", logAttrs...)
-        // Return specific error for not found
-        if resp.StatusCode == http.StatusNotFound {
-            return nil, fmt.Errorf("study %s not found (404)", orthancStudyID)
-        }
-		return nil, fmt.Errorf("orthanc returned non-OK status %d getting study details", resp.StatusCode)
+		return nil, statusError(ctx, resp, "study "+orthancStudyID)
 	}
 
 	var details StudyDetails // Assumes StudyDetails struct is defined in this package
@@ -221,14 +213,14 @@ func (c *Client) GetStudyInstances(ctx context.Context, orthancStudyID string) (
 		return nil, fmt.Errorf("orthancStudyID cannot be empty")
 	}
 	// Endpoint for instances within a study
-	targetURL := fmt.Sprintf("%s/studies/%s/instances", c.BaseURL, orthancStudyID)
+	targetURL := fmt.Sprintf("%s/studies/%s/instances%s", c.BaseURL, orthancStudyID, imageRequestedTags)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", targetURL, nil) // Use context
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request to get study instances: %w", err)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		slog.ErrorContext(ctx, "Orthanc client failed to execute request for study instances", "url", targetURL, "error", err)
 		return nil, fmt.Errorf("failed to execute request to get study instances: %w", err)
@@ -238,14 +230,7 @@ func (c *Client) GetStudyInstances(ctx context.Context, orthancStudyID string) (
     logAttrs := []any{"url", targetURL, "statusCode", resp.StatusCode}
 
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
-        logAttrs = append(logAttrs, "responseBody", string(bodyBytes))
-		slog.ErrorContext(ctx, "Orthanc returned non-OK status getting study instances", logAttrs...)
-         // Return specific error for not found
-        if resp.StatusCode == http.StatusNotFound {
-             return nil, fmt.Errorf("study %s not found (404) when getting instances", orthancStudyID)
-        }
-		return nil, fmt.Errorf("orthanc returned non-OK status %d getting study instances", resp.StatusCode)
+		return nil, statusError(ctx, resp, "instances of study "+orthancStudyID)
 	}
 
 	var instances []InstanceDetails // Expecting a JSON array, assumes InstanceDetails struct is defined
@@ -257,4 +242,530 @@ func (c *Client) GetStudyInstances(ctx context.Context, orthancStudyID string) (
     logAttrs = append(logAttrs, "instanceCount", len(instances))
 	slog.DebugContext(ctx, "Successfully retrieved study instances from Orthanc", logAttrs...)
 	return instances, nil
-}
\ No newline at end of file
+}
+
+// UploadInstance stores a DICOM file in Orthanc (POST /instances).
+// The body is streamed; callers keep ownership of r.
+func (c *Client) UploadInstance(ctx context.Context, r io.Reader) (*UploadResult, error) {
+	targetURL := fmt.Sprintf("%s/instances", c.BaseURL)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", targetURL, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentTypeDICOM)
+
+	resp, err := c.do(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "Orthanc client failed to upload instance", "url", targetURL, "error", err)
+		return nil, fmt.Errorf("failed to execute upload request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(ctx, resp, "instance upload")
+	}
+
+	var result UploadResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode upload response: %w", err)
+	}
+	return &result, nil
+}
+
+// DeleteStudy removes a study and all its instances from Orthanc.
+func (c *Client) DeleteStudy(ctx context.Context, orthancStudyID string) error {
+	if orthancStudyID == "" {
+		return fmt.Errorf("orthancStudyID cannot be empty")
+	}
+	targetURL := fmt.Sprintf("%s/studies/%s", c.BaseURL, orthancStudyID)
+
+	req, err := http.NewRequestWithContext(ctx, "DELETE", targetURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create delete study request: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "Orthanc client failed to delete study", "url", targetURL, "error", err)
+		return fmt.Errorf("failed to execute delete study request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return statusError(ctx, resp, "deletion of study "+orthancStudyID)
+	}
+	return nil
+}
+
+// DeleteInstance removes one instance from Orthanc.
+func (c *Client) DeleteInstance(ctx context.Context, instanceID string) error {
+	if instanceID == "" {
+		return fmt.Errorf("instanceID cannot be empty")
+	}
+	targetURL := fmt.Sprintf("%s/instances/%s", c.BaseURL, instanceID)
+
+	req, err := http.NewRequestWithContext(ctx, "DELETE", targetURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create delete instance request: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "Orthanc client failed to delete instance", "url", targetURL, "error", err)
+		return fmt.Errorf("failed to execute delete instance request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return statusError(ctx, resp, "deletion of instance "+instanceID)
+	}
+	return nil
+}
+
+// GetSeriesDetails retrieves details for a series ID from Orthanc.
+func (c *Client) GetSeriesDetails(ctx context.Context, orthancSeriesID string) (*SeriesDetails, error) {
+	if orthancSeriesID == "" {
+		return nil, fmt.Errorf("orthancSeriesID cannot be empty")
+	}
+	targetURL := fmt.Sprintf("%s/series/%s", c.BaseURL, orthancSeriesID)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", targetURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request to get series details: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "Orthanc client failed to execute request for series details", "url", targetURL, "error", err)
+		return nil, fmt.Errorf("failed to execute request to get series details: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(ctx, resp, "series "+orthancSeriesID)
+	}
+
+	var details SeriesDetails
+	if err := json.NewDecoder(resp.Body).Decode(&details); err != nil {
+		return nil, fmt.Errorf("failed to decode series details response: %w", err)
+	}
+	return &details, nil
+}
+
+// GetMediaArchive streams Orthanc's DICOMDIR media ZIP for a study or series
+// (level is "studies" or "series"). The caller must close FileResponse.Body.
+func (c *Client) GetMediaArchive(ctx context.Context, level, orthancID string) (*FileResponse, error) {
+	if level != "studies" && level != "series" {
+		return nil, fmt.Errorf("unsupported media archive level %q", level)
+	}
+	targetURL := fmt.Sprintf("%s/%s/%s/media", c.BaseURL, level, orthancID)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", targetURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create media archive request: %w", err)
+	}
+
+	resp, err := c.doStream(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "Orthanc client failed to execute media archive request", "url", targetURL, "error", err)
+		return nil, fmt.Errorf("failed to execute media archive request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, statusError(ctx, resp, "media archive of "+level+" "+orthancID)
+	}
+	return newFileResponse(resp, "application/zip"), nil
+}
+
+// AnonymizeInstance asks Orthanc to anonymize one instance and streams back the
+// resulting DICOM file. Nothing is stored in Orthanc; the caller must close Body.
+func (c *Client) AnonymizeInstance(ctx context.Context, instanceID string, anonReq AnonymizeRequest) (*FileResponse, error) {
+	targetURL := fmt.Sprintf("%s/instances/%s/anonymize", c.BaseURL, instanceID)
+
+	payload, err := json.Marshal(anonReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode anonymize request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", targetURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create anonymize request for instance %s: %w", instanceID, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.doStream(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "Orthanc client failed to anonymize instance", "url", targetURL, "error", err)
+		return nil, fmt.Errorf("failed to execute anonymize request for instance %s: %w", instanceID, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, statusError(ctx, resp, "anonymized instance "+instanceID)
+	}
+	return newFileResponse(resp, contentTypeDICOM), nil
+}
+
+// TranscodeInstance asks Orthanc to re-encode one instance in another transfer
+// syntax and streams back the result, keeping its SOP Instance UID. Nothing is
+// stored in Orthanc; the caller must close Body.
+func (c *Client) TranscodeInstance(ctx context.Context, instanceID, transferSyntax string) (*FileResponse, error) {
+	targetURL := fmt.Sprintf("%s/instances/%s/modify", c.BaseURL, instanceID)
+
+	payload, err := json.Marshal(ModifyRequest{
+		Transcode: transferSyntax,
+		Keep:      []string{"SOPInstanceUID"},
+		Force:     true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode transcode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", targetURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transcode request for instance %s: %w", instanceID, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.doStream(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "Orthanc client failed to transcode instance", "url", targetURL, "error", err)
+		return nil, fmt.Errorf("failed to execute transcode request for instance %s: %w", instanceID, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, statusError(ctx, resp, "instance "+instanceID+" transcoded to "+transferSyntax)
+	}
+	return newFileResponse(resp, contentTypeDICOM), nil
+}
+
+// getJSON fetches path (relative to the Orthanc base URL) and decodes the JSON
+// response into out. what names the resource in errors and logs.
+func (c *Client) getJSON(ctx context.Context, path, what string, out any) error {
+	targetURL := c.BaseURL + path
+
+	req, err := http.NewRequestWithContext(ctx, "GET", targetURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request to get %s: %w", what, err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "Orthanc client failed to execute request", "url", targetURL, "resource", what, "error", err)
+		return fmt.Errorf("failed to execute request to get %s: %w", what, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return statusError(ctx, resp, what)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", what, err)
+	}
+	return nil
+}
+
+// postJSON posts body to path (relative to the Orthanc base URL) and decodes
+// the JSON response into out. what names the resource in errors and logs.
+func (c *Client) postJSON(ctx context.Context, path, what, contentType string, body []byte, out any) error {
+	targetURL := c.BaseURL + path
+
+	req, err := http.NewRequestWithContext(ctx, "POST", targetURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request to post %s: %w", what, err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := c.do(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "Orthanc client failed to execute request", "url", targetURL, "resource", what, "error", err)
+		return fmt.Errorf("failed to execute request to post %s: %w", what, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return statusError(ctx, resp, what)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", what, err)
+	}
+	return nil
+}
+
+// LookupPatients returns the Orthanc IDs of patients whose DICOM PatientID
+// is patientID (/tools/lookup). None found is not an error.
+func (c *Client) LookupPatients(ctx context.Context, patientID string) ([]string, error) {
+	if patientID == "" {
+		return nil, fmt.Errorf("patientID cannot be empty")
+	}
+	var matches []LookupResult
+	if err := c.postJSON(ctx, "/tools/lookup", "lookup of patient "+patientID, "text/plain", []byte(patientID), &matches); err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, m := range matches {
+		if m.Type == "Patient" {
+			ids = append(ids, m.ID)
+		}
+	}
+	return ids, nil
+}
+
+// ModifyPatient rewrites patient-level tags of every instance of an Orthanc
+// patient (/patients/{id}/modify). The request must carry PatientID in
+// Replace, and should keep the instance UIDs so the study stays the same
+// study; with KeepSource false the original is replaced.
+func (c *Client) ModifyPatient(ctx context.Context, orthancPatientID string, modReq ModifyRequest) (*ModifyResult, error) {
+	if orthancPatientID == "" {
+		return nil, fmt.Errorf("orthancPatientID cannot be empty")
+	}
+	payload, err := json.Marshal(modReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode modify request: %w", err)
+	}
+	var result ModifyResult
+	if err := c.postJSON(ctx, "/patients/"+orthancPatientID+"/modify", "patient "+orthancPatientID, "application/json", payload, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ModifyStudy rewrites tags of every instance of an Orthanc study
+// (/studies/{id}/modify), as ModifyPatient does for a patient. Replacing
+// PatientID moves the study to that patient, under a new Orthanc ID.
+func (c *Client) ModifyStudy(ctx context.Context, orthancStudyID string, modReq ModifyRequest) (*ModifyResult, error) {
+	if orthancStudyID == "" {
+		return nil, fmt.Errorf("orthancStudyID cannot be empty")
+	}
+	payload, err := json.Marshal(modReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode modify request: %w", err)
+	}
+	var result ModifyResult
+	if err := c.postJSON(ctx, "/studies/"+orthancStudyID+"/modify", "study "+orthancStudyID, "application/json", payload, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListPatients retrieves all patients with their main tags (/patients?expand).
+func (c *Client) ListPatients(ctx context.Context) ([]PatientDetails, error) {
+	var patients []PatientDetails
+	if err := c.getJSON(ctx, "/patients?expand", "patients", &patients); err != nil {
+		return nil, err
+	}
+	return patients, nil
+}
+
+// ListStudyDetails retrieves up to limit studies with their main tags,
+// skipping the first since (/studies?expand&since=&limit=), for paging
+// through every study in Orthanc.
+func (c *Client) ListStudyDetails(ctx context.Context, since, limit int) ([]StudyDetails, error) {
+	var studies []StudyDetails
+	path := fmt.Sprintf("/studies?expand&since=%d&limit=%d", since, limit)
+	if err := c.getJSON(ctx, path, "studies", &studies); err != nil {
+		return nil, err
+	}
+	return studies, nil
+}
+
+// FindStudies returns up to limit studies, with their main tags, whose
+// DICOM tags match query (/tools/find), e.g. {"StudyDate": "20200101-20201231",
+// "ModalitiesInStudy": "CT"}, skipping the first since matches. An empty
+// query matches every study.
+func (c *Client) FindStudies(ctx context.Context, query map[string]string, since, limit int) ([]StudyDetails, error) {
+	if query == nil {
+		query = map[string]string{}
+	}
+	payload, err := json.Marshal(FindRequest{Level: "Study", Query: query, Expand: true, Since: since, Limit: limit})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode find request: %w", err)
+	}
+	var studies []StudyDetails
+	if err := c.postJSON(ctx, "/tools/find", "study search", "application/json", payload, &studies); err != nil {
+		return nil, err
+	}
+	return studies, nil
+}
+
+// GetChanges retrieves up to limit entries of Orthanc's change log after
+// sequence number since.
+func (c *Client) GetChanges(ctx context.Context, since int64, limit int) (*ChangeList, error) {
+	var changes ChangeList
+	path := fmt.Sprintf("/changes?since=%d&limit=%d", since, limit)
+	if err := c.getJSON(ctx, path, "changes", &changes); err != nil {
+		return nil, err
+	}
+	return &changes, nil
+}
+
+// GetLastChange returns the sequence number of the most recent change.
+func (c *Client) GetLastChange(ctx context.Context) (int64, error) {
+	var changes ChangeList
+	if err := c.getJSON(ctx, "/changes?last", "changes", &changes); err != nil {
+		return 0, err
+	}
+	return changes.Last, nil
+}
+
+// GetPatientDetails retrieves details for an Orthanc patient ID.
+func (c *Client) GetPatientDetails(ctx context.Context, orthancPatientID string) (*PatientDetails, error) {
+	if orthancPatientID == "" {
+		return nil, fmt.Errorf("orthancPatientID cannot be empty")
+	}
+	var details PatientDetails
+	if err := c.getJSON(ctx, "/patients/"+orthancPatientID, "patient "+orthancPatientID, &details); err != nil {
+		return nil, err
+	}
+	return &details, nil
+}
+
+// GetPatientStudies retrieves details for all studies of a patient.
+func (c *Client) GetPatientStudies(ctx context.Context, orthancPatientID string) ([]StudyDetails, error) {
+	if orthancPatientID == "" {
+		return nil, fmt.Errorf("orthancPatientID cannot be empty")
+	}
+	var studies []StudyDetails
+	if err := c.getJSON(ctx, "/patients/"+orthancPatientID+"/studies", "patient "+orthancPatientID, &studies); err != nil {
+		return nil, err
+	}
+	return studies, nil
+}
+
+// GetStudySeries retrieves details for all series of a study.
+func (c *Client) GetStudySeries(ctx context.Context, orthancStudyID string) ([]SeriesDetails, error) {
+	if orthancStudyID == "" {
+		return nil, fmt.Errorf("orthancStudyID cannot be empty")
+	}
+	var series []SeriesDetails
+	if err := c.getJSON(ctx, "/studies/"+orthancStudyID+"/series", "study "+orthancStudyID, &series); err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// GetSeriesInstances retrieves details for all instances of a series.
+func (c *Client) GetSeriesInstances(ctx context.Context, orthancSeriesID string) ([]InstanceDetails, error) {
+	if orthancSeriesID == "" {
+		return nil, fmt.Errorf("orthancSeriesID cannot be empty")
+	}
+	var instances []InstanceDetails
+	if err := c.getJSON(ctx, "/series/"+orthancSeriesID+"/instances"+imageRequestedTags, "series "+orthancSeriesID, &instances); err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
+
+// GetStatistics retrieves instance counts and disk usage for a resource
+// (level is "patients", "studies" or "series").
+func (c *Client) GetStatistics(ctx context.Context, level, orthancID string) (*Statistics, error) {
+	if orthancID == "" {
+		return nil, fmt.Errorf("orthancID cannot be empty")
+	}
+	var stats Statistics
+	path := fmt.Sprintf("/%s/%s/statistics", level, orthancID)
+	if err := c.getJSON(ctx, path, fmt.Sprintf("%s %s statistics", level, orthancID), &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// GetRenderedFrame streams an 8-bit rendering of one frame (0-based) of an
+// instance, windowed and scaled by Orthanc. The caller must close Body.
+func (c *Client) GetRenderedFrame(ctx context.Context, instanceID string, frame int, opts RenderOptions) (*FileResponse, error) {
+	query := url.Values{}
+	if opts.WindowCenter != nil && opts.WindowWidth != nil {
+		query.Set("window-center", strconv.FormatFloat(*opts.WindowCenter, 'f', -1, 64))
+		query.Set("window-width", strconv.FormatFloat(*opts.WindowWidth, 'f', -1, 64))
+	}
+	if opts.Width > 0 {
+		query.Set("width", strconv.Itoa(opts.Width))
+	}
+	if opts.Height > 0 {
+		query.Set("height", strconv.Itoa(opts.Height))
+	}
+	if opts.Width > 0 || opts.Height > 0 {
+		query.Set("smooth", "1")
+	}
+	if opts.Quality > 0 {
+		query.Set("quality", strconv.Itoa(opts.Quality))
+	}
+	targetURL := fmt.Sprintf("%s/instances/%s/frames/%d/rendered", c.BaseURL, instanceID, frame)
+	if len(query) > 0 {
+		targetURL += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", targetURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rendered frame request for instance %s: %w", instanceID, err)
+	}
+	accept := opts.Accept
+	if accept == "" {
+		accept = "image/jpeg"
+	}
+	req.Header.Set("Accept", accept)
+
+	resp, err := c.doStream(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "Orthanc client failed to render frame", "url", targetURL, "error", err)
+		return nil, fmt.Errorf("failed to execute rendered frame request for instance %s: %w", instanceID, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, statusError(ctx, resp, fmt.Sprintf("rendering of frame %d of instance %s", frame, instanceID))
+	}
+	return newFileResponse(resp, accept), nil
+}
+
+// GetInstanceMetadata retrieves the metadata Orthanc keeps for an instance,
+// such as "TransferSyntax" and "SopClassUid".
+func (c *Client) GetInstanceMetadata(ctx context.Context, instanceID string) (map[string]string, error) {
+	if instanceID == "" {
+		return nil, fmt.Errorf("instanceID cannot be empty")
+	}
+	metadata := map[string]string{}
+	if err := c.getJSON(ctx, "/instances/"+instanceID+"/metadata?expand", "instance "+instanceID+" metadata", &metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// GetStudyMetadata retrieves the metadata Orthanc keeps for a study, such as
+// "LastUpdate" and, for studies produced by /modify, "ModifiedFrom".
+func (c *Client) GetStudyMetadata(ctx context.Context, orthancStudyID string) (map[string]string, error) {
+	if orthancStudyID == "" {
+		return nil, fmt.Errorf("orthancStudyID cannot be empty")
+	}
+	metadata := map[string]string{}
+	if err := c.getJSON(ctx, "/studies/"+orthancStudyID+"/metadata?expand", "study "+orthancStudyID+" metadata", &metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// GetFrameRaw streams one frame (0-based) of an instance as stored, without
+// decoding: a compressed codestream for encapsulated transfer syntaxes, raw
+// pixel bytes otherwise. The caller must close Body.
+func (c *Client) GetFrameRaw(ctx context.Context, instanceID string, frame int) (*FileResponse, error) {
+	targetURL := fmt.Sprintf("%s/instances/%s/frames/%d/raw", c.BaseURL, instanceID, frame)
+	req, err := http.NewRequestWithContext(ctx, "GET", targetURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create raw frame request for instance %s: %w", instanceID, err)
+	}
+
+	resp, err := c.doStream(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "Orthanc client failed to fetch raw frame", "url", targetURL, "error", err)
+		return nil, fmt.Errorf("failed to execute raw frame request for instance %s: %w", instanceID, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, statusError(ctx, resp, fmt.Sprintf("frame %d of instance %s", frame, instanceID))
+	}
+	return newFileResponse(resp, "application/octet-stream"), nil
+}
@@ -0,0 +1,88 @@
+// File: backend/internal/orthanc/errors.go
+package orthanc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// Errors returned by the client; test for them with errors.Is.
+var (
+	// ErrNotFound is returned when Orthanc does not hold the requested resource.
+	ErrNotFound = errors.New("resource not found in Orthanc")
+	// ErrUnavailable is returned when Orthanc can't be reached, times out or
+	// answers 502, 503 or 504, and without calling it while its circuit is
+	// open after repeated failures.
+	ErrUnavailable = errors.New("orthanc is unavailable")
+	// ErrUpstream is returned when Orthanc answers with an unexpected
+	// status; the error is an *UpstreamError carrying it.
+	ErrUpstream = errors.New("orthanc returned an error")
+)
+
+// maxErrorBody caps how much of an error response is kept.
+const maxErrorBody = 1024
+
+// UpstreamError is an unexpected response from Orthanc.
+type UpstreamError struct {
+	What       string // The resource asked for, e.g. "instance 1234"
+	StatusCode int
+	Body       string // Start of the response body, Orthanc's explanation
+}
+
+func (e *UpstreamError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("orthanc returned status %d for %s", e.StatusCode, e.What)
+	}
+	return fmt.Sprintf("orthanc returned status %d for %s: %s", e.StatusCode, e.What, e.Body)
+}
+
+// Is makes every UpstreamError match ErrUpstream, and those with a 404 or
+// a gateway status ErrNotFound or ErrUnavailable as well.
+func (e *UpstreamError) Is(target error) bool {
+	switch target {
+	case ErrUpstream:
+		return true
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrUnavailable:
+		return unavailableStatus(e.StatusCode)
+	}
+	return false
+}
+
+func unavailableStatus(code int) bool {
+	switch code {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// statusError turns a non-OK response for what into an *UpstreamError and
+// logs it. The caller still closes resp.Body.
+func statusError(ctx context.Context, resp *http.Response, what string) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	err := &UpstreamError{What: what, StatusCode: resp.StatusCode, Body: string(body)}
+	level := slog.LevelError
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		level = slog.LevelInfo // Usually a stale or mistyped ID, not a fault
+	case http.StatusRequestedRangeNotSatisfiable:
+		level = slog.LevelInfo // A client's range past the end of the file
+	}
+	slog.Log(ctx, level, "Orthanc returned non-OK status", "url", resp.Request.URL.Redacted(), "resource", what,
+		"statusCode", resp.StatusCode, "responseBody", err.Body)
+	return err
+}
+
+// transportError marks a failure to get any response from Orthanc.
+func transportError(err error) error {
+	if errors.Is(err, ErrUnavailable) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrUnavailable, err)
+}
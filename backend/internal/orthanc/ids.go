@@ -0,0 +1,33 @@
+// File: backend/internal/orthanc/ids.go
+package orthanc
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+)
+
+// PatientResourceID returns the Orthanc ID a patient with the given DICOM
+// PatientID has, or will have once stored. Orthanc derives resource IDs by
+// hashing the identifying tags, so this holds across Orthanc instances.
+func PatientResourceID(patientID string) string {
+	return resourceID(patientID)
+}
+
+// StudyResourceID returns the Orthanc ID of a study given its patient's
+// DICOM PatientID and its StudyInstanceUID.
+func StudyResourceID(patientID, studyInstanceUID string) string {
+	return resourceID(patientID + "|" + studyInstanceUID)
+}
+
+// resourceID mirrors Orthanc's DicomInstanceHasher: the SHA-1 of the key,
+// in five dash-separated groups of eight hex digits.
+func resourceID(key string) string {
+	sum := sha1.Sum([]byte(key))
+	digest := hex.EncodeToString(sum[:])
+	groups := make([]string, 0, 5)
+	for i := 0; i < len(digest); i += 8 {
+		groups = append(groups, digest[i:i+8])
+	}
+	return strings.Join(groups, "-")
+}
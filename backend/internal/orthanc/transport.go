@@ -0,0 +1,139 @@
+// File: backend/internal/orthanc/transport.go
+package orthanc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ewag/gen-erics/backend/internal/retry"
+)
+
+// Options make the client resilient to a slow or failing Orthanc.
+type Options struct {
+	// Timeout bounds each call: the whole exchange for JSON calls, the wait
+	// for response headers for streamed ones. 0 leaves calls unbounded.
+	Timeout time.Duration
+	// Retry applies to idempotent calls (GET) failing with a network error
+	// or a 502, 503 or 504.
+	Retry retry.Policy
+	// After BreakerThreshold consecutive failures calls fail fast with
+	// ErrUnavailable for BreakerCooldown, then one probe is let through.
+	// 0 disables the breaker.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// CircuitState reports the circuit breaker state: CircuitClosed while
+// Orthanc is healthy, CircuitOpen or CircuitHalfOpen while it is degraded.
+func (c *Client) CircuitState() string {
+	return c.breaker.state()
+}
+
+// do sends req, with the per-call timeout covering the whole exchange
+// until the response body is closed.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	return c.send(req, false)
+}
+
+// doStream sends req for a response the caller streams: the per-call
+// timeout only covers the wait for the response headers, so long downloads
+// are bounded by the caller's context alone.
+func (c *Client) doStream(req *http.Request) (*http.Response, error) {
+	return c.send(req, true)
+}
+
+func (c *Client) send(req *http.Request, stream bool) (*http.Response, error) {
+	ctx := req.Context()
+	policy := c.retry
+	if req.Method != http.MethodGet || req.Body != nil {
+		policy.Attempts = 1 // Not idempotent, or the body can't be replayed
+	}
+
+	for attempt := 1; ; attempt++ {
+		if !c.breaker.allow() {
+			return nil, fmt.Errorf("%w: circuit open after repeated failures", ErrUnavailable)
+		}
+		resp, err := c.attempt(req, stream)
+		switch {
+		case ctx.Err() != nil:
+			c.breaker.release()
+		case err != nil || resp.StatusCode >= http.StatusInternalServerError:
+			c.breaker.record(false)
+		default:
+			c.breaker.record(true)
+		}
+
+		if attempt >= policy.Attempts || ctx.Err() != nil || !retryableResponse(resp, err) {
+			if err != nil && ctx.Err() == nil {
+				err = transportError(err)
+			}
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+		delay := policy.Delay(attempt)
+		slog.DebugContext(ctx, "Retrying Orthanc request", "method", req.Method, "url", req.URL.Redacted(),
+			"attempt", attempt, "delay", delay, "error", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt sends req once under the per-call timeout.
+func (c *Client) attempt(req *http.Request, stream bool) (*http.Response, error) {
+	if c.timeout <= 0 {
+		return c.httpClient.Do(req)
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	timer := time.AfterFunc(c.timeout, cancel)
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		timer.Stop()
+		cancel()
+		if errors.Is(err, context.Canceled) && req.Context().Err() == nil {
+			return nil, fmt.Errorf("orthanc did not answer within %s: %w", c.timeout, context.DeadlineExceeded)
+		}
+		return nil, err
+	}
+	if stream {
+		timer.Stop()
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: func() { timer.Stop(); cancel() }}
+	return resp, nil
+}
+
+// retryableResponse reports whether a failed exchange may succeed if repeated.
+func retryableResponse(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// cancelOnClose releases a call's context once its response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
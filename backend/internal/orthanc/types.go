@@ -1,6 +1,13 @@
 // File: internal/orthanc/types.go (or add to client.go)
 package orthanc
 
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
 // StudyDetails holds selected information about a DICOM study from Orthanc.
 // Field names match the JSON keys returned by Orthanc's REST API,
 // specifically looking at PatientMainDicomTags and MainDicomTags.
@@ -9,7 +16,8 @@ type StudyDetails struct {
 	PatientMainTags struct {
 		PatientName string `json:"PatientName,omitempty"`
 		PatientID   string `json:"PatientID,omitempty"`
-        // Add other patient tags if needed (PatientBirthDate, PatientSex)
+		PatientBirthDate string `json:"PatientBirthDate,omitempty"`
+		PatientSex       string `json:"PatientSex,omitempty"`
 	} `json:"PatientMainDicomTags"`
 	MainTags struct {
 		StudyInstanceUID string `json:"StudyInstanceUID,omitempty"`
@@ -20,11 +28,51 @@ type StudyDetails struct {
         // Add other study tags if needed (ReferringPhysicianName)
 	} `json:"MainDicomTags"`
 	Series          []string `json:"Series"` // List of Orthanc Series IDs within this study
+	ParentPatient   string   `json:"ParentPatient"` // Orthanc Patient ID
 	IsStable        bool     `json:"IsStable"` // Useful status flag from Orthanc
 	LastUpdate      string   `json:"LastUpdate"` // Timestamp of last change
 	Type            string   `json:"Type"` // Should be "Study"
 }
 
+// PatientDetails holds selected information about a patient from Orthanc (/patients/{id}).
+type PatientDetails struct {
+	ID       string `json:"ID"` // Orthanc's internal Patient ID
+	MainTags struct {
+		PatientID        string `json:"PatientID,omitempty"`
+		PatientName      string `json:"PatientName,omitempty"`
+		PatientBirthDate string `json:"PatientBirthDate,omitempty"`
+		PatientSex       string `json:"PatientSex,omitempty"`
+	} `json:"MainDicomTags"`
+	Studies    []string `json:"Studies"` // Orthanc Study IDs of this patient
+	LastUpdate string   `json:"LastUpdate"`
+	Type       string   `json:"Type"` // Should be "Patient"
+}
+
+// Statistics is Orthanc's /{level}/{id}/statistics response. Orthanc encodes
+// the sizes as strings.
+type Statistics struct {
+	CountStudies     int   `json:"CountStudies"`
+	CountSeries      int   `json:"CountSeries"`
+	CountInstances   int   `json:"CountInstances"`
+	DiskSize         int64 `json:"DiskSize,string"`
+	UncompressedSize int64 `json:"UncompressedSize,string"`
+}
+
+// SeriesDetails holds selected information about a DICOM series from Orthanc (/series/{id}).
+type SeriesDetails struct {
+	ID       string `json:"ID"` // Orthanc's internal Series ID
+	MainTags struct {
+		SeriesInstanceUID string `json:"SeriesInstanceUID,omitempty"`
+		SeriesNumber      string `json:"SeriesNumber,omitempty"`
+		SeriesDescription string `json:"SeriesDescription,omitempty"`
+		Modality          string `json:"Modality,omitempty"`
+		BodyPartExamined  string `json:"BodyPartExamined,omitempty"`
+	} `json:"MainDicomTags"`
+	Instances   []string `json:"Instances"`   // Orthanc Instance IDs in this series
+	ParentStudy string   `json:"ParentStudy"` // Orthanc Study ID
+	Type        string   `json:"Type"`        // Should be "Series"
+}
+
 // InstanceDetails holds selected information about a DICOM instance from Orthanc.
 // Field names match the JSON keys from /studies/{id}/instances or /instances/{id}.
 type InstanceDetails struct {
@@ -36,6 +84,141 @@ type InstanceDetails struct {
 	} `json:"MainDicomTags"`
 	FileSize   int64  `json:"FileSize"`   // File size in bytes
 	FileUuid   string `json:"FileUUID"`   // Orthanc internal file identifier
+	ParentSeries string `json:"ParentSeries"` // Orthanc Series ID this instance belongs to
 	IndexInSeries int  `json:"IndexInSeries"` // Order within the series
 	Type       string `json:"Type"`     // Should be "Instance"
-}
\ No newline at end of file
+	// Image Pixel tags, returned when listed with requestedTags (Orthanc >= 1.11)
+	RequestedTags struct {
+		Rows           string `json:"Rows,omitempty"`
+		Columns        string `json:"Columns,omitempty"`
+		NumberOfFrames string `json:"NumberOfFrames,omitempty"`
+	} `json:"RequestedTags"`
+}
+
+// ImageSize parses the requested Image Pixel tags. Zero means unknown;
+// a single-frame object has no NumberOfFrames and reports 1 when its
+// dimensions are known.
+func (d InstanceDetails) ImageSize() (frames, rows, columns int) {
+	rows, _ = strconv.Atoi(strings.TrimSpace(d.RequestedTags.Rows))
+	columns, _ = strconv.Atoi(strings.TrimSpace(d.RequestedTags.Columns))
+	frames, _ = strconv.Atoi(strings.TrimSpace(d.RequestedTags.NumberOfFrames))
+	if frames == 0 && rows > 0 {
+		frames = 1
+	}
+	return frames, rows, columns
+}
+
+// imageRequestedTags asks instance listings for the tags viewers need to
+// plan frame retrieval.
+const imageRequestedTags = "?requestedTags=Rows;Columns;NumberOfFrames"
+
+// UploadResult is Orthanc's response to POST /instances.
+type UploadResult struct {
+	ID          string `json:"ID"`          // Orthanc Instance ID
+	ParentStudy string `json:"ParentStudy"` // Orthanc Study ID
+	Status      string `json:"Status"`      // "Success" or "AlreadyStored"
+}
+
+// FileResponse is a streamed body from Orthanc along with the headers worth passing on.
+// The caller owns Body and must close it.
+type FileResponse struct {
+	Body          io.ReadCloser
+	ContentLength int64  // -1 if Orthanc didn't send one
+	ContentType   string
+	LastModified  string // Raw header value, empty if absent
+	ContentRange  string // Set when Orthanc answered a ranged request with 206; Body then holds only that range
+}
+
+func newFileResponse(resp *http.Response, defaultContentType string) *FileResponse {
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = defaultContentType
+	}
+	return &FileResponse{
+		Body:          resp.Body,
+		ContentLength: resp.ContentLength,
+		ContentType:   contentType,
+		LastModified:  resp.Header.Get("Last-Modified"),
+		ContentRange:  contentRange(resp),
+	}
+}
+
+func contentRange(resp *http.Response) string {
+	if resp.StatusCode != http.StatusPartialContent {
+		return ""
+	}
+	return resp.Header.Get("Content-Range")
+}
+
+// AnonymizeRequest is the body of Orthanc's /instances/{id}/anonymize call.
+// Orthanc applies the PS3.15 Basic Application Confidentiality Profile and
+// then the Replace/Keep/Remove overrides.
+type AnonymizeRequest struct {
+	Replace         map[string]string `json:"Replace,omitempty"`
+	Keep            []string          `json:"Keep,omitempty"`
+	Remove          []string          `json:"Remove,omitempty"`
+	KeepPrivateTags bool              `json:"KeepPrivateTags"`
+	Force           bool              `json:"Force"` // Required to replace UIDs and PatientID
+	DicomVersion    string            `json:"DicomVersion,omitempty"`
+}
+
+// ModifyRequest is the body of Orthanc's /{level}/{id}/modify call, used
+// here to transcode or fix demographics without changing the object's identity.
+type ModifyRequest struct {
+	Transcode  string            `json:"Transcode,omitempty"` // Target transfer syntax UID
+	Replace    map[string]string `json:"Replace,omitempty"`
+	Keep       []string          `json:"Keep,omitempty"`
+	Force      bool              `json:"Force"`                // Required to keep SOPInstanceUID
+	KeepSource *bool             `json:"KeepSource,omitempty"` // Study/patient level only; Orthanc defaults to true
+}
+
+// ModifyResult is Orthanc's response to a study or patient level modify.
+type ModifyResult struct {
+	ID        string `json:"ID"` // Orthanc ID of the modified resource
+	PatientID string `json:"PatientID"`
+	Path      string `json:"Path"`
+}
+
+// LookupResult is one match of Orthanc's /tools/lookup.
+type LookupResult struct {
+	ID   string `json:"ID"`
+	Path string `json:"Path"`
+	Type string `json:"Type"` // "Patient", "Study", "Series" or "Instance"
+}
+
+// FindRequest is the body of Orthanc's /tools/find.
+type FindRequest struct {
+	Level  string            `json:"Level"` // "Patient", "Study", "Series" or "Instance"
+	Query  map[string]string `json:"Query"`
+	Expand bool              `json:"Expand,omitempty"`
+	Since  int               `json:"Since,omitempty"` // Matches to skip, for paging; needs Limit
+	Limit  int               `json:"Limit,omitempty"`
+}
+
+// RenderOptions are the query arguments of Orthanc's /frames/{n}/rendered.
+// Zero values leave Orthanc's defaults in place.
+type RenderOptions struct {
+	WindowCenter *float64
+	WindowWidth  *float64
+	Width        int
+	Height       int
+	Quality      int    // JPEG quality 1-100
+	Accept       string // "image/jpeg" or "image/png"
+}
+
+// Change is one entry of Orthanc's /changes log.
+type Change struct {
+	ChangeType   string `json:"ChangeType"` // e.g. "NewStudy", "StableStudy"
+	ID           string `json:"ID"`         // Orthanc ID of the changed resource
+	ResourceType string `json:"ResourceType"`
+	Seq          int64  `json:"Seq"`
+	Date         string `json:"Date"`
+}
+
+// ChangeList is a page of Orthanc's /changes log. Last is the sequence
+// number to pass as since for the next page.
+type ChangeList struct {
+	Changes []Change `json:"Changes"`
+	Done    bool     `json:"Done"`
+	Last    int64    `json:"Last"`
+}
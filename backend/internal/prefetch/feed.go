@@ -0,0 +1,110 @@
+// File: backend/internal/prefetch/feed.go
+package prefetch
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ewag/gen-erics/backend/internal/auth"
+	models "github.com/ewag/gen-erics/backend/internal/models"
+	"github.com/ewag/gen-erics/backend/internal/orthanc"
+	"github.com/ewag/gen-erics/backend/internal/storage"
+)
+
+const (
+	// feedActor is recorded as the requester of prefetch jobs.
+	feedActor = "prefetch"
+	// feedCursor names this consumer's position in Orthanc's change log.
+	feedCursor = "orthanc-changes-prefetch"
+	// feedPageSize is the number of changes fetched per request.
+	feedPageSize = 100
+)
+
+// Watcher follows Orthanc's change log and prefetches priors for every newly
+// ingested study that becomes stable, whether it arrived over DIMSE or
+// STOW-RS. Studies this service put in Orthanc are skipped (see NewArrival).
+type Watcher struct {
+	orthanc    *orthanc.Client
+	cursors    storage.FeedCursorStore
+	prefetcher *Prefetcher
+	interval   time.Duration
+}
+
+// NewWatcher creates a Watcher; call Run to start it.
+func NewWatcher(orthancClient *orthanc.Client, cursors storage.FeedCursorStore, prefetcher *Prefetcher, interval time.Duration) *Watcher {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	return &Watcher{orthanc: orthancClient, cursors: cursors, prefetcher: prefetcher, interval: interval}
+}
+
+// Run follows the change log until ctx is cancelled. Its position is
+// persisted, so a restart resumes where it stopped; the first run starts
+// at the current end of the log rather than replaying Orthanc's history.
+func (w *Watcher) Run(ctx context.Context) {
+	ctx = auth.WithPrincipal(ctx, &auth.Principal{Subject: feedActor, Method: "system"})
+	slog.InfoContext(ctx, "Prefetch watcher started", "interval", w.interval)
+	for {
+		caughtUp, err := w.pollOnce(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			slog.ErrorContext(ctx, "Failed to read Orthanc change log", "error", err)
+		}
+		if !caughtUp && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("Prefetch watcher stopped")
+			return
+		case <-time.After(w.interval):
+		}
+	}
+}
+
+// pollOnce handles one page of changes and reports whether the log is
+// exhausted.
+func (w *Watcher) pollOnce(ctx context.Context) (bool, error) {
+	since, found, err := w.cursors.GetFeedCursor(ctx, feedCursor)
+	if err != nil {
+		return true, err
+	}
+	if !found {
+		last, err := w.orthanc.GetLastChange(ctx)
+		if err != nil {
+			return true, err
+		}
+		slog.InfoContext(ctx, "Starting prefetch watcher at the end of the change log", "seq", last)
+		return true, w.cursors.SetFeedCursor(ctx, feedCursor, last)
+	}
+
+	page, err := w.orthanc.GetChanges(ctx, since, feedPageSize)
+	if err != nil {
+		return true, err
+	}
+	for _, change := range page.Changes {
+		if change.ChangeType != "StableStudy" {
+			continue
+		}
+		// One study failing must not stall the feed; recalls can be requested by hand
+		isNew, why, err := w.prefetcher.NewArrival(ctx, change.ID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Prefetch failed", "studyID", change.ID, "seq", change.Seq, "error", err)
+			continue
+		}
+		if !isNew {
+			slog.DebugContext(ctx, "Not prefetching for study placed by this service", "studyID", change.ID, "seq", change.Seq, "reason", why)
+			continue
+		}
+		if _, err := w.prefetcher.PrefetchForStudy(ctx, change.ID, models.JobPriorityRoutine); err != nil {
+			slog.ErrorContext(ctx, "Prefetch failed", "studyID", change.ID, "seq", change.Seq, "error", err)
+		}
+	}
+	if page.Last > since {
+		if err := w.cursors.SetFeedCursor(ctx, feedCursor, page.Last); err != nil {
+			return true, err
+		}
+	}
+	return page.Done, nil
+}
@@ -0,0 +1,215 @@
+// File: backend/internal/prefetch/prefetcher.go
+package prefetch
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ewag/gen-erics/backend/internal/jobs"
+	models "github.com/ewag/gen-erics/backend/internal/models"
+	"github.com/ewag/gen-erics/backend/internal/mover"
+	"github.com/ewag/gen-erics/backend/internal/orthanc"
+	"github.com/ewag/gen-erics/backend/internal/retention"
+	"github.com/ewag/gen-erics/backend/internal/storage"
+)
+
+// Config controls which priors are fetched and where to.
+type Config struct {
+	MaxPriors   int    // Most recent relevant priors recalled per new study
+	DefaultEdge string // Edge for new studies with no recorded location
+}
+
+// Trigger describes a new (or scheduled) study whose priors should be
+// brought to the edge ahead of reading.
+type Trigger struct {
+	PatientResourceID string    // Orthanc patient ID
+	StudyID           string    // Orthanc study ID of the new study, excluded from priors; may be empty
+	Modalities        []string  // Modalities of the new study
+	BodyParts         []string  // Body parts of the new study, if known
+	StudyDate         time.Time // Zero means now
+	EdgeID            string    // Edge the priors go to; empty uses the new study's edge or the default
+	Reason            string    // Recorded on the jobs
+	Priority          string    // Of the jobs, models.JobPriority*; routine if empty
+}
+
+// Prefetcher enqueues recalls of a patient's relevant prior studies from
+// the cold tiers to the edge where a new study lives.
+type Prefetcher struct {
+	orthanc *orthanc.Client
+	catalog storage.CatalogStore
+	status  storage.StatusStore
+	queue   *jobs.Queue
+	rules   *Rules
+	cfg     Config
+}
+
+// NewPrefetcher creates a Prefetcher.
+func NewPrefetcher(orthancClient *orthanc.Client, catalog storage.CatalogStore, status storage.StatusStore, queue *jobs.Queue, rules *Rules, cfg Config) *Prefetcher {
+	if cfg.MaxPriors <= 0 {
+		cfg.MaxPriors = 3
+	}
+	return &Prefetcher{orthanc: orthancClient, catalog: catalog, status: status, queue: queue, rules: rules, cfg: cfg}
+}
+
+// PrefetchForStudy looks up a study that has arrived in Orthanc and
+// prefetches its priors at the given job priority.
+func (p *Prefetcher) PrefetchForStudy(ctx context.Context, studyID, priority string) ([]models.Job, error) {
+	study, err := p.orthanc.GetStudyDetails(ctx, studyID)
+	if err != nil {
+		return nil, err
+	}
+	series, err := p.orthanc.GetStudySeries(ctx, studyID)
+	if err != nil {
+		return nil, err
+	}
+	trigger := Trigger{
+		PatientResourceID: study.ParentPatient,
+		StudyID:           studyID,
+		Reason:            "prior of study " + studyID,
+		Priority:          priority,
+	}
+	for _, s := range series {
+		trigger.Modalities = appendUnique(trigger.Modalities, s.MainTags.Modality)
+		trigger.BodyParts = appendUnique(trigger.BodyParts, s.MainTags.BodyPartExamined)
+	}
+	if date, ok := parseDA(study.MainTags.StudyDate); ok {
+		trigger.StudyDate = date
+	}
+	return p.Prefetch(ctx, trigger)
+}
+
+// NewArrival reports whether a study that became stable in Orthanc was newly
+// ingested, rather than put there by this service: recalled or restored
+// from another tier (it has been catalogued) or re-keyed by a patient merge
+// (Orthanc made it by modifying another study). Only new arrivals trigger
+// prefetches; otherwise every recalled prior would prefetch its own priors
+// in turn, through the patient's whole history.
+func (p *Prefetcher) NewArrival(ctx context.Context, studyID string) (bool, string, error) {
+	if _, found, err := p.catalog.GetStudyCatalogEntry(ctx, studyID); err != nil {
+		return false, "", err
+	} else if found {
+		return false, "recalled from another tier", nil
+	}
+	metadata, err := p.orthanc.GetStudyMetadata(ctx, studyID)
+	if err != nil {
+		return false, "", err
+	}
+	if from := metadata["ModifiedFrom"]; from != "" {
+		return false, "modified from study " + from, nil
+	}
+	return true, "", nil
+}
+
+// Prefetch enqueues hot recalls of the trigger patient's relevant priors,
+// most recent first. Priors already queued or running keep their existing
+// job, which is returned in its place.
+func (p *Prefetcher) Prefetch(ctx context.Context, t Trigger) ([]models.Job, error) {
+	logAttrs := []any{"patientResourceID", t.PatientResourceID, "studyID", t.StudyID, "modalities", t.Modalities}
+	c, ok := p.rules.match(t.Modalities, t.BodyParts)
+	if !ok {
+		slog.DebugContext(ctx, "No prefetch rule matches study", logAttrs...)
+		return nil, nil
+	}
+	if t.PatientResourceID == "" {
+		return nil, fmt.Errorf("prefetch needs the patient of study %s", t.StudyID)
+	}
+
+	edgeID, err := p.edgeFor(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	reference := t.StudyDate
+	if reference.IsZero() {
+		reference = time.Now()
+	}
+	cutoff := reference.AddDate(-c.years, 0, 0)
+
+	offloaded, err := p.catalog.ListOffloadedStudies(ctx, t.PatientResourceID)
+	if err != nil {
+		return nil, err
+	}
+	var priors []models.OffloadedStudy
+	for _, prior := range offloaded {
+		if prior.StudyID == t.StudyID || prior.Tier == retention.TierDeleted {
+			continue
+		}
+		date, ok := parseDA(prior.StudyDate)
+		if !ok || date.Before(cutoff) {
+			continue
+		}
+		modalities, bodyParts, err := p.catalog.StudyModalities(ctx, prior.StudyID)
+		if err != nil {
+			return nil, err
+		}
+		if c.relevant(modalities, bodyParts) {
+			priors = append(priors, prior)
+		}
+	}
+	// Already newest first; keep that order stable for equal dates
+	sort.SliceStable(priors, func(i, j int) bool { return priors[i].StudyDate > priors[j].StudyDate })
+	if len(priors) > p.cfg.MaxPriors {
+		priors = priors[:p.cfg.MaxPriors]
+	}
+
+	var queued []models.Job
+	for _, prior := range priors {
+		job, created, err := p.queue.ScheduleMove(ctx, prior.StudyID, mover.TierHot, edgeID, jobs.MoveOptions{
+			Source:   jobs.SourcePrefetch,
+			Reason:   t.Reason,
+			Priority: t.Priority,
+		})
+		if err != nil {
+			return queued, fmt.Errorf("failed to enqueue recall of prior %s: %w", prior.StudyID, err)
+		}
+		slog.InfoContext(ctx, "Prefetching prior study", append(logAttrs, "priorStudyID", prior.StudyID,
+			"priorTier", prior.Tier, "priorStudyDate", prior.StudyDate, "edgeID", edgeID, "jobID", job.ID, "newJob", created)...)
+		queued = append(queued, *job)
+	}
+	return queued, nil
+}
+
+// edgeFor picks where priors go: the trigger's edge, else the edge the new
+// study is recorded on, else the configured default.
+func (p *Prefetcher) edgeFor(ctx context.Context, t Trigger) (string, error) {
+	if t.EdgeID != "" {
+		return t.EdgeID, nil
+	}
+	if t.StudyID != "" {
+		status, found, err := p.status.GetStatus(ctx, t.StudyID)
+		if err != nil {
+			return "", err
+		}
+		if found && status.EdgeID != nil && *status.EdgeID != "" {
+			return *status.EdgeID, nil
+		}
+	}
+	return p.cfg.DefaultEdge, nil
+}
+
+func appendUnique(values []string, v string) []string {
+	v = strings.ToUpper(strings.TrimSpace(v))
+	if v == "" {
+		return values
+	}
+	for _, existing := range values {
+		if existing == v {
+			return values
+		}
+	}
+	return append(values, v)
+}
+
+// parseDA parses a DICOM DA value (YYYYMMDD, tolerating the old
+// YYYY.MM.DD form).
+func parseDA(value string) (time.Time, bool) {
+	value = strings.ReplaceAll(strings.TrimSpace(value), ".", "")
+	t, err := time.Parse("20060102", value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,120 @@
+// File: backend/internal/prefetch/rules.go
+package prefetch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Rules decide which of a patient's prior studies are relevant to a new one.
+// A nil Rules matches nothing.
+type Rules struct {
+	rules []rule
+}
+
+type rule struct {
+	modalities     map[string]bool // Modalities of the new study; nil matches every modality
+	priors         map[string]bool // Modalities of relevant priors; nil means any
+	sameModality   bool            // Priors must share a modality with the new study
+	years          int
+	matchBodyParts bool // Priors must share a body part (when both record one)
+}
+
+// ParseRelevanceRules parses rules of the form
+// "modalities:priorModalities:years[:bodypart]", e.g. "MR:MR|CT:5:bodypart"
+// or "*:same:3". priorModalities is a "|" list, "same" for the new study's
+// own modalities or "*" for any. The first rule matching one of the new
+// study's modalities wins, so specific modalities go before "*".
+func ParseRelevanceRules(specs []string) (*Rules, error) {
+	r := &Rules{}
+	for _, spec := range specs {
+		parts := strings.Split(strings.TrimSpace(spec), ":")
+		if len(parts) < 3 || len(parts) > 4 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid prefetch rule %q (want modalities:priorModalities:years[:bodypart])", spec)
+		}
+		years, err := strconv.Atoi(parts[2])
+		if err != nil || years <= 0 {
+			return nil, fmt.Errorf("prefetch rule %q: years must be a positive integer", spec)
+		}
+		rl := rule{years: years, modalities: modalitySet(parts[0])}
+		switch strings.ToLower(parts[1]) {
+		case "same":
+			rl.sameModality = true
+		case "*":
+		default:
+			rl.priors = modalitySet(parts[1])
+		}
+		if len(parts) == 4 {
+			if !strings.EqualFold(parts[3], "bodypart") {
+				return nil, fmt.Errorf("prefetch rule %q: unknown option %q", spec, parts[3])
+			}
+			rl.matchBodyParts = true
+		}
+		r.rules = append(r.rules, rl)
+	}
+	return r, nil
+}
+
+func modalitySet(list string) map[string]bool {
+	if list == "*" {
+		return nil
+	}
+	set := make(map[string]bool)
+	for _, modality := range strings.Split(list, "|") {
+		if modality = strings.ToUpper(strings.TrimSpace(modality)); modality != "" {
+			set[modality] = true
+		}
+	}
+	return set
+}
+
+// criteria is what the winning rule asks of a prior.
+type criteria struct {
+	modalities map[string]bool // nil means any
+	bodyParts  map[string]bool // nil means any
+	years      int
+}
+
+// match returns the criteria for priors of a study with the given
+// modalities and body parts, or false if no rule applies.
+func (r *Rules) match(modalities, bodyParts []string) (criteria, bool) {
+	if r == nil {
+		return criteria{}, false
+	}
+	for _, rl := range r.rules {
+		if rl.modalities != nil && !intersects(rl.modalities, modalities) {
+			continue
+		}
+		c := criteria{years: rl.years, modalities: rl.priors}
+		if rl.sameModality {
+			c.modalities = modalitySet(strings.Join(modalities, "|"))
+		}
+		if rl.matchBodyParts && len(bodyParts) > 0 {
+			c.bodyParts = modalitySet(strings.Join(bodyParts, "|"))
+		}
+		return c, true
+	}
+	return criteria{}, false
+}
+
+// relevant reports whether a prior with the given modalities and body parts
+// meets c. A prior with no recorded body part is not ruled out by it.
+func (c criteria) relevant(modalities, bodyParts []string) bool {
+	if c.modalities != nil && !intersects(c.modalities, modalities) {
+		return false
+	}
+	if c.bodyParts != nil && len(bodyParts) > 0 && !intersects(c.bodyParts, bodyParts) {
+		return false
+	}
+	return true
+}
+
+func intersects(set map[string]bool, values []string) bool {
+	for _, v := range values {
+		if set[strings.ToUpper(strings.TrimSpace(v))] {
+			return true
+		}
+	}
+	return false
+}
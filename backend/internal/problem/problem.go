@@ -0,0 +1,66 @@
+// File: backend/internal/problem/problem.go
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContentType is the media type of problem details responses.
+const ContentType = "application/problem+json"
+
+// Details is an RFC 7807 problem details object. Extra holds extension
+// members, e.g. the job a request conflicts with; they can't override the
+// standard members.
+type Details struct {
+	Type     string // A URI identifying the problem type; "about:blank" when the status says it all
+	Title    string // Short and the same for every occurrence of the problem
+	Status   int
+	Detail   string // What went wrong this time
+	Instance string // The request path
+	Extra    map[string]any
+}
+
+// MarshalJSON implements json.Marshaler, flattening Extra into the object.
+func (d Details) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(d.Extra)+5)
+	for k, v := range d.Extra {
+		m[k] = v
+	}
+	m["type"] = d.Type
+	m["title"] = d.Title
+	m["status"] = d.Status
+	if d.Detail != "" {
+		m["detail"] = d.Detail
+	}
+	if d.Instance != "" {
+		m["instance"] = d.Instance
+	}
+	return json.Marshal(m)
+}
+
+// Write aborts the request with a problem details response. detail may be
+// empty.
+func Write(c *gin.Context, status int, title, detail string) {
+	WriteExtra(c, status, title, detail, nil)
+}
+
+// WriteExtra is Write with extension members.
+func WriteExtra(c *gin.Context, status int, title, detail string, extra map[string]any) {
+	if title == "" {
+		title = http.StatusText(status)
+	}
+	d := Details{
+		Type:     "about:blank",
+		Title:    title,
+		Status:   status,
+		Detail:   detail,
+		Instance: c.Request.URL.Path,
+		Extra:    extra,
+	}
+	// Set first; the JSON renderer keeps a content type that is already set
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(status, d)
+}
@@ -0,0 +1,273 @@
+// File: backend/internal/render/render.go
+package render
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ewag/gen-erics/backend/internal/dicom"
+)
+
+// Format is an output image format.
+type Format string
+
+// Supported output formats.
+const (
+	FormatJPEG Format = "jpeg"
+	FormatPNG  Format = "png"
+	FormatWebP Format = "webp"
+)
+
+// ContentType returns the MIME type for f.
+func (f Format) ContentType() string {
+	return "image/" + string(f)
+}
+
+// ParseFormat accepts "jpeg"/"jpg", "png", "webp" or the matching MIME types.
+func ParseFormat(s string) (Format, bool) {
+	switch strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "image/") {
+	case "jpeg", "jpg":
+		return FormatJPEG, true
+	case "png":
+		return FormatPNG, true
+	case "webp":
+		return FormatWebP, true
+	}
+	return "", false
+}
+
+// Window is a VOI window in modality units (after rescale).
+type Window struct {
+	Center float64 `json:"center"`
+	Width  float64 `json:"width"`
+}
+
+// Presets are common CT windows, selectable by name.
+var Presets = map[string]Window{
+	"brain":       {Center: 40, Width: 80},
+	"subdural":    {Center: 75, Width: 215},
+	"stroke":      {Center: 40, Width: 40},
+	"soft-tissue": {Center: 50, Width: 400},
+	"mediastinum": {Center: 50, Width: 350},
+	"abdomen":     {Center: 40, Width: 400},
+	"liver":       {Center: 30, Width: 150},
+	"lung":        {Center: -600, Width: 1500},
+	"bone":        {Center: 400, Width: 1800},
+}
+
+// ErrUnknownPreset is returned for preset names that are neither in Presets nor "voi:N".
+var ErrUnknownPreset = errors.New("unknown window preset")
+
+// Params controls rendering.
+type Params struct {
+	Frame   int     // 0-based
+	Window  *Window // Explicit window; wins over Preset
+	Preset  string  // A Presets name, or "voi:N" for the object's N-th (1-based) stored window
+	Quality int     // JPEG quality 1-100; 0 uses DefaultQuality
+	Width   int     // Viewport; the image is scaled to fit, keeping its aspect ratio
+	Height  int
+	Format  Format
+}
+
+// DefaultQuality is the JPEG quality used when Params.Quality is 0.
+const DefaultQuality = 90
+
+// PresetWindow resolves a preset name. voi:N presets need the data set and
+// are resolved by Render.
+func PresetWindow(name string) (Window, bool) {
+	w, ok := Presets[strings.ToLower(name)]
+	return w, ok
+}
+
+// Render decodes frame p.Frame of ds, applies the modality and VOI
+// transforms, and scales the result to fit the viewport.
+func Render(ds *dicom.Dataset, p Params) (image.Image, error) {
+	frame, err := ds.DecodeFrame(p.Frame)
+	if err != nil {
+		return nil, err
+	}
+	slope, intercept := rescale(ds, p.Frame)
+
+	window, err := resolveWindow(ds, p)
+	if err != nil {
+		return nil, err
+	}
+
+	var img image.Image
+	if frame.SamplesPerPixel == 1 {
+		img = renderGray(frame, slope, intercept, window)
+	} else {
+		// Stored windows describe grayscale data; only apply requested ones to color
+		if p.Window == nil && p.Preset == "" {
+			window = nil
+		}
+		img = renderColor(frame, window)
+	}
+	return Fit(img, p.Width, p.Height), nil
+}
+
+// Encode writes img in format f.
+func Encode(w io.Writer, img image.Image, f Format, quality int) error {
+	switch f {
+	case FormatJPEG:
+		if quality <= 0 {
+			quality = DefaultQuality
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: min(quality, 100)})
+	case FormatPNG:
+		return png.Encode(w, img)
+	case FormatWebP:
+		return encodeWebP(w, img)
+	}
+	return fmt.Errorf("unsupported output format %q", f)
+}
+
+// resolveWindow picks the window: explicit, then preset, then the object's
+// own first window. nil means "use the frame's min/max".
+func resolveWindow(ds *dicom.Dataset, p Params) (*Window, error) {
+	if p.Window != nil {
+		return p.Window, nil
+	}
+	if p.Preset != "" {
+		if idx, ok := strings.CutPrefix(strings.ToLower(p.Preset), "voi:"); ok {
+			n, err := strconv.Atoi(idx)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("%w: %s", ErrUnknownPreset, p.Preset)
+			}
+			windows := StoredWindows(ds, p.Frame)
+			if n > len(windows) {
+				return nil, fmt.Errorf("%w: object defines %d window(s)", ErrUnknownPreset, len(windows))
+			}
+			return &windows[n-1], nil
+		}
+		w, ok := PresetWindow(p.Preset)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownPreset, p.Preset)
+		}
+		return &w, nil
+	}
+	if windows := StoredWindows(ds, p.Frame); len(windows) > 0 {
+		return &windows[0], nil
+	}
+	return nil, nil
+}
+
+// StoredWindows lists the windows an object defines for a frame (per-frame
+// functional groups, then shared ones, then the top-level VOI LUT module).
+func StoredWindows(ds *dicom.Dataset, frame int) []Window {
+	for _, group := range functionalGroups(ds, frame) {
+		if voi := group.Sequence(dicom.TagFrameVOILUTSequence); len(voi) > 0 {
+			if w := windowsOf(voi[0]); len(w) > 0 {
+				return w
+			}
+		}
+	}
+	return windowsOf(ds)
+}
+
+func windowsOf(ds *dicom.Dataset) []Window {
+	centers, widths := ds.Floats(dicom.TagWindowCenter), ds.Floats(dicom.TagWindowWidth)
+	var out []Window
+	for i := 0; i < len(centers) && i < len(widths); i++ {
+		out = append(out, Window{Center: centers[i], Width: widths[i]})
+	}
+	return out
+}
+
+// rescale returns the modality LUT slope and intercept for a frame.
+func rescale(ds *dicom.Dataset, frame int) (float64, float64) {
+	source := ds
+	for _, group := range functionalGroups(ds, frame) {
+		if pvt := group.Sequence(dicom.TagPixelValueTransformationSq); len(pvt) > 0 {
+			source = pvt[0]
+			break
+		}
+	}
+	slope, ok := source.Float(dicom.TagRescaleSlope)
+	if !ok || slope == 0 {
+		slope = 1
+	}
+	intercept, _ := source.Float(dicom.TagRescaleIntercept)
+	return slope, intercept
+}
+
+// functionalGroups returns the per-frame then shared functional group items
+// of an enhanced multi-frame object (none for classic objects).
+func functionalGroups(ds *dicom.Dataset, frame int) []*dicom.Dataset {
+	var groups []*dicom.Dataset
+	if perFrame := ds.Sequence(dicom.TagPerFrameFunctionalGroups); frame < len(perFrame) {
+		groups = append(groups, perFrame[frame])
+	}
+	if shared := ds.Sequence(dicom.TagSharedFunctionalGroups); len(shared) > 0 {
+		groups = append(groups, shared[0])
+	}
+	return groups
+}
+
+func renderGray(f *dicom.Frame, slope, intercept float64, window *Window) *image.Gray {
+	values := make([]float64, len(f.Data))
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i, v := range f.Data {
+		x := float64(v)*slope + intercept
+		values[i] = x
+		lo, hi = math.Min(lo, x), math.Max(hi, x)
+	}
+	w := Window{Center: (lo + hi) / 2, Width: hi - lo + 1}
+	if window != nil {
+		w = *window
+	}
+
+	img := image.NewGray(image.Rect(0, 0, f.Width, f.Height))
+	invert := f.Photometric == "MONOCHROME1"
+	for i, x := range values {
+		y := applyWindow(x, w)
+		if invert {
+			y = 255 - y
+		}
+		img.Pix[i] = y
+	}
+	return img
+}
+
+func renderColor(f *dicom.Frame, window *Window) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, f.Width, f.Height))
+	maxValue := float64(int(1)<<uint(f.BitsStored) - 1)
+	for p := 0; p < f.Width*f.Height; p++ {
+		for c := 0; c < 3; c++ {
+			x := float64(f.Data[p*f.SamplesPerPixel+c])
+			var y uint8
+			if window != nil {
+				y = applyWindow(x, *window)
+			} else {
+				y = uint8(math.Round(math.Max(0, math.Min(1, x/maxValue)) * 255))
+			}
+			img.Pix[p*4+c] = y
+		}
+		img.Pix[p*4+3] = 0xff
+	}
+	return img
+}
+
+// applyWindow is the linear VOI function of PS3.3 C.11.2.1.2.1.
+func applyWindow(x float64, w Window) uint8 {
+	width := math.Max(w.Width, 1)
+	lower := w.Center - 0.5 - (width-1)/2
+	upper := w.Center - 0.5 + (width-1)/2
+	switch {
+	case x <= lower:
+		return 0
+	case x > upper:
+		return 255
+	}
+	if width == 1 {
+		return 255
+	}
+	return uint8(math.Round(((x-(w.Center-0.5))/(width-1) + 0.5) * 255))
+}
@@ -0,0 +1,98 @@
+// File: backend/internal/render/resize.go
+package render
+
+import (
+	"image"
+	"math"
+)
+
+// Fit scales img to fit within maxWidth x maxHeight, keeping the aspect
+// ratio. A zero bound is unconstrained; with both zero img is returned as is.
+// Downscaling averages source pixels (box filter); upscaling is bilinear.
+func Fit(img image.Image, maxWidth, maxHeight int) image.Image {
+	b := img.Bounds()
+	srcW, srcH := b.Dx(), b.Dy()
+	if (maxWidth <= 0 && maxHeight <= 0) || srcW == 0 || srcH == 0 {
+		return img
+	}
+	scale := math.Inf(1)
+	if maxWidth > 0 {
+		scale = float64(maxWidth) / float64(srcW)
+	}
+	if maxHeight > 0 {
+		scale = math.Min(scale, float64(maxHeight)/float64(srcH))
+	}
+	dstW := max(1, int(math.Round(float64(srcW)*scale)))
+	dstH := max(1, int(math.Round(float64(srcH)*scale)))
+	if dstW == srcW && dstH == srcH {
+		return img
+	}
+
+	switch src := img.(type) {
+	case *image.Gray:
+		dst := image.NewGray(image.Rect(0, 0, dstW, dstH))
+		resample(src.Pix, src.Stride, 1, srcW, srcH, dst.Pix, dst.Stride, dstW, dstH)
+		return dst
+	default:
+		rgba, ok := img.(*image.RGBA)
+		if !ok {
+			rgba = image.NewRGBA(image.Rect(0, 0, srcW, srcH))
+			for y := 0; y < srcH; y++ {
+				for x := 0; x < srcW; x++ {
+					rgba.Set(x, y, img.At(b.Min.X+x, b.Min.Y+y))
+				}
+			}
+		}
+		dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+		resample(rgba.Pix, rgba.Stride, 4, srcW, srcH, dst.Pix, dst.Stride, dstW, dstH)
+		return dst
+	}
+}
+
+// resample scales an 8-bit interleaved buffer with channels per pixel.
+func resample(src []uint8, srcStride, channels, srcW, srcH int, dst []uint8, dstStride, dstW, dstH int) {
+	sx, sy := float64(srcW)/float64(dstW), float64(srcH)/float64(dstH)
+	sum := make([]float64, channels)
+
+	for y := 0; y < dstH; y++ {
+		for x := 0; x < dstW; x++ {
+			out := dst[y*dstStride+x*channels:]
+			if sx > 1 || sy > 1 {
+				// Box filter over the source area covered by this pixel
+				x0, x1 := int(float64(x)*sx), max(int(float64(x)*sx)+1, int(math.Ceil(float64(x+1)*sx)))
+				y0, y1 := int(float64(y)*sy), max(int(float64(y)*sy)+1, int(math.Ceil(float64(y+1)*sy)))
+				x1, y1 = min(x1, srcW), min(y1, srcH)
+				clear(sum)
+				for yy := y0; yy < y1; yy++ {
+					row := src[yy*srcStride:]
+					for xx := x0; xx < x1; xx++ {
+						for c := 0; c < channels; c++ {
+							sum[c] += float64(row[xx*channels+c])
+						}
+					}
+				}
+				n := float64((x1 - x0) * (y1 - y0))
+				for c := 0; c < channels; c++ {
+					out[c] = uint8(math.Round(sum[c] / n))
+				}
+				continue
+			}
+
+			// Bilinear, sampling at pixel centres
+			fx := math.Max(0, (float64(x)+0.5)*sx-0.5)
+			fy := math.Max(0, (float64(y)+0.5)*sy-0.5)
+			x0, y0 := int(fx), int(fy)
+			x1, y1 := min(x0+1, srcW-1), min(y0+1, srcH-1)
+			ax, ay := fx-float64(x0), fy-float64(y0)
+			for c := 0; c < channels; c++ {
+				p00 := float64(src[y0*srcStride+x0*channels+c])
+				p10 := float64(src[y0*srcStride+x1*channels+c])
+				p01 := float64(src[y1*srcStride+x0*channels+c])
+				p11 := float64(src[y1*srcStride+x1*channels+c])
+				top := p00 + (p10-p00)*ax
+				bottom := p01 + (p11-p01)*ax
+				out[c] = uint8(math.Round(top + (bottom-top)*ay))
+			}
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// File: backend/internal/render/webp.go
+package render
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"math/bits"
+)
+
+// maxWebPDimension is the VP8L limit (14-bit width and height).
+const maxWebPDimension = 1 << 14
+
+// encodeWebP writes img as lossless WebP (VP8L). It uses the subtract-green
+// transform and fixed 8-bit prefix codes rather than libwebp's entropy
+// modelling: output is larger than cwebp's, but exact and dependency-free.
+// Grayscale renders collapse to a single coded channel after the transform.
+func encodeWebP(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width < 1 || height < 1 || width > maxWebPDimension || height > maxWebPDimension {
+		return fmt.Errorf("image size %dx%d not encodable as WebP", width, height)
+	}
+
+	// Gather channels after the subtract-green transform
+	n := width * height
+	green, red, blue := make([]byte, n), make([]byte, n), make([]byte, n)
+	i := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			g8 := byte(g >> 8)
+			green[i] = g8
+			red[i] = byte(r>>8) - g8
+			blue[i] = byte(bl>>8) - g8
+			i++
+		}
+	}
+
+	var bw bitWriter
+	bw.write(0x2f, 8) // VP8L signature
+	bw.write(uint32(width-1), 14)
+	bw.write(uint32(height-1), 14)
+	bw.write(0, 1) // alpha_is_used
+	bw.write(0, 3) // version
+
+	bw.write(1, 1) // transform present
+	bw.write(2, 2) // SUBTRACT_GREEN
+	bw.write(0, 1) // no further transforms
+	bw.write(0, 1) // no color cache
+	bw.write(0, 1) // no meta prefix codes
+
+	// Prefix code group: green (+ backward-reference lengths), red, blue, alpha, distance
+	greenConst := writeChannelCode(&bw, green, 256+24)
+	redConst := writeChannelCode(&bw, red, 256)
+	blueConst := writeChannelCode(&bw, blue, 256)
+	writeSimpleCode(&bw, 0xff) // opaque
+	writeSimpleCode(&bw, 0)    // distance codes are never used
+
+	for p := 0; p < n; p++ {
+		if !greenConst {
+			bw.write(reversedByte[green[p]], 8)
+		}
+		if !redConst {
+			bw.write(reversedByte[red[p]], 8)
+		}
+		if !blueConst {
+			bw.write(reversedByte[blue[p]], 8)
+		}
+	}
+	payload := bw.bytes()
+
+	chunkSize := len(payload)
+	padded := chunkSize + chunkSize&1
+	header := make([]byte, 20)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(4+8+padded))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(chunkSize))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return err
+	}
+	if padded != chunkSize {
+		_, err := w.Write([]byte{0})
+		return err
+	}
+	return nil
+}
+
+// writeChannelCode writes the prefix code for one channel and reports
+// whether the channel is constant (single-symbol code, zero bits per pixel).
+func writeChannelCode(bw *bitWriter, values []byte, alphabetSize int) bool {
+	first := values[0]
+	constant := true
+	for _, v := range values {
+		if v != first {
+			constant = false
+			break
+		}
+	}
+	if constant {
+		writeSimpleCode(bw, first)
+		return true
+	}
+	writeFixedCode(bw, alphabetSize)
+	return false
+}
+
+// writeSimpleCode writes a one-symbol "simple" prefix code.
+func writeSimpleCode(bw *bitWriter, symbol byte) {
+	bw.write(1, 1) // simple code
+	bw.write(0, 1) // one symbol
+	bw.write(1, 1) // 8-bit symbol
+	bw.write(uint32(symbol), 8)
+}
+
+// codeLengthCodeOrder is the order in which code length code lengths are stored.
+var codeLengthCodeOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// writeFixedCode writes a normal prefix code giving symbols 0-255 length 8
+// and any further symbols length 0. The code lengths themselves are coded
+// with a two-symbol code: "0" for length 0 and "1" for length 8.
+func writeFixedCode(bw *bitWriter, alphabetSize int) {
+	const numCodeLengths = 12 // enough to reach length 8 in codeLengthCodeOrder
+
+	bw.write(0, 1) // normal code
+	bw.write(numCodeLengths-4, 4)
+	for i := 0; i < numCodeLengths; i++ {
+		length := uint32(0)
+		if sym := codeLengthCodeOrder[i]; sym == 0 || sym == 8 {
+			length = 1
+		}
+		bw.write(length, 3)
+	}
+	bw.write(0, 1) // max_symbol = alphabet size
+	for sym := 0; sym < alphabetSize; sym++ {
+		if sym < 256 {
+			bw.write(1, 1)
+		} else {
+			bw.write(0, 1)
+		}
+	}
+}
+
+// reversedByte maps a byte to its bit-reversal; prefix codes are stored
+// most significant bit first in an LSB-first bit stream.
+var reversedByte = func() (t [256]uint32) {
+	for i := range t {
+		t[i] = uint32(bits.Reverse8(uint8(i)))
+	}
+	return t
+}()
+
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+func (w *bitWriter) write(v uint32, n uint) {
+	w.acc |= uint64(v) << w.nbits
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.nbits -= 8
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	if w.nbits > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc, w.nbits = 0, 0
+	}
+	return w.buf
+}
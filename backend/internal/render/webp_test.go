@@ -0,0 +1,274 @@
+package render
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+func TestEncodeWebP(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	tests := []struct {
+		name string
+		img  image.Image
+	}{
+		{name: "1x1", img: filled(1, 1, func(x, y int) color.Color { return color.RGBA{10, 20, 30, 255} })},
+		{name: "constant color", img: filled(7, 5, func(x, y int) color.Color { return color.RGBA{200, 100, 50, 255} })},
+		{name: "gray gradient", img: filled(64, 33, func(x, y int) color.Color { return color.Gray{uint8(x*4 + y)} })},
+		{name: "16-bit gray", img: filled(9, 9, func(x, y int) color.Color { return color.Gray16{uint16(x*y) << 10} })},
+		{name: "color noise", img: filled(31, 17, func(x, y int) color.Color {
+			return color.RGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255}
+		})},
+		{name: "sub-image with offset bounds", img: filled(20, 20, func(x, y int) color.Color {
+			return color.RGBA{uint8(x), uint8(y), uint8(x ^ y), 255}
+		}).(*image.RGBA).SubImage(image.Rect(3, 5, 14, 12))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := encodeWebP(&buf, tt.img); err != nil {
+				t.Fatalf("encodeWebP() error = %v", err)
+			}
+			got, err := decodeVP8L(buf.Bytes())
+			if err != nil {
+				t.Fatalf("output doesn't decode: %v", err)
+			}
+			b := tt.img.Bounds()
+			if got.Bounds().Dx() != b.Dx() || got.Bounds().Dy() != b.Dy() {
+				t.Fatalf("decoded size %v, want %dx%d", got.Bounds().Size(), b.Dx(), b.Dy())
+			}
+			for y := 0; y < b.Dy(); y++ {
+				for x := 0; x < b.Dx(); x++ {
+					r, g, bl, _ := tt.img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+					want := color.NRGBA{uint8(r >> 8), uint8(g >> 8), uint8(bl >> 8), 255}
+					if px := got.NRGBAAt(x, y); px != want {
+						t.Fatalf("pixel (%d,%d) = %v, want %v", x, y, px, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeWebPSize(t *testing.T) {
+	tests := []struct {
+		width, height int
+		wantErr       bool
+	}{
+		{1, 1, false},
+		{maxWebPDimension, 1, false},
+		{maxWebPDimension + 1, 1, true},
+		{1, maxWebPDimension + 1, true},
+		{0, 5, true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%dx%d", tt.width, tt.height), func(t *testing.T) {
+			err := encodeWebP(&bytes.Buffer{}, image.NewGray(image.Rect(0, 0, tt.width, tt.height)))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("encodeWebP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func filled(width, height int, at func(x, y int) color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, at(x, y))
+		}
+	}
+	return img
+}
+
+// decodeVP8L is a minimal decoder for lossless WebP following the format
+// specification, enough for what encodeWebP writes: transforms other than
+// subtract-green, color caches, meta prefix codes and backward references
+// are rejected.
+func decodeVP8L(data []byte) (*image.NRGBA, error) {
+	if len(data) < 20 || string(data[0:4]) != "RIFF" || string(data[8:16]) != "WEBPVP8L" {
+		return nil, errors.New("not a VP8L RIFF container")
+	}
+	if riffSize := binary.LittleEndian.Uint32(data[4:]); int(riffSize) != len(data)-8 {
+		return nil, fmt.Errorf("RIFF size %d, file has %d bytes after the header", riffSize, len(data)-8)
+	}
+	chunkSize := int(binary.LittleEndian.Uint32(data[16:]))
+	if 20+chunkSize+chunkSize&1 != len(data) {
+		return nil, fmt.Errorf("chunk size %d doesn't match the %d byte file", chunkSize, len(data))
+	}
+
+	br := &bitReader{data: data[20 : 20+chunkSize]}
+	if br.read(8) != 0x2f {
+		return nil, errors.New("bad VP8L signature")
+	}
+	width, height := int(br.read(14))+1, int(br.read(14))+1
+	br.read(1) // alpha_is_used is only a hint
+	if version := br.read(3); version != 0 {
+		return nil, fmt.Errorf("version %d", version)
+	}
+	subtractGreen := false
+	for br.read(1) == 1 {
+		if transform := br.read(2); transform != 2 {
+			return nil, fmt.Errorf("unsupported transform %d", transform)
+		}
+		subtractGreen = true
+	}
+	if br.read(1) == 1 {
+		return nil, errors.New("color cache not supported")
+	}
+	if br.read(1) == 1 {
+		return nil, errors.New("meta prefix codes not supported")
+	}
+
+	var codes [5]*prefixCode
+	for i, size := range []int{256 + 24, 256, 256, 256, 40} {
+		code, err := readPrefixCode(br, size)
+		if err != nil {
+			return nil, fmt.Errorf("prefix code %d: %w", i, err)
+		}
+		codes[i] = code
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			g := codes[0].decode(br)
+			if g >= 256 {
+				return nil, errors.New("backward references not supported")
+			}
+			r, b, a := codes[1].decode(br), codes[2].decode(br), codes[3].decode(br)
+			if subtractGreen {
+				r, b = (r+g)&0xff, (b+g)&0xff
+			}
+			img.SetNRGBA(x, y, color.NRGBA{uint8(r), uint8(g), uint8(b), uint8(a)})
+		}
+	}
+	if br.overrun {
+		return nil, errors.New("bitstream ends early")
+	}
+	return img, nil
+}
+
+type bitReader struct {
+	data    []byte
+	pos     uint // In bits
+	overrun bool
+}
+
+func (r *bitReader) read(n uint) int {
+	v := 0
+	for i := uint(0); i < n; i++ {
+		if r.pos/8 >= uint(len(r.data)) {
+			r.overrun = true
+			return 0
+		}
+		v |= int(r.data[r.pos/8]>>(r.pos%8)&1) << i
+		r.pos++
+	}
+	return v
+}
+
+// prefixCode is a canonical prefix code; a code with a single symbol
+// takes no bits.
+type prefixCode struct {
+	single  int
+	symbols map[[2]int]int // {length, code} -> symbol
+}
+
+func newPrefixCode(lengths []int) (*prefixCode, error) {
+	used, last := 0, 0
+	for sym, l := range lengths {
+		if l > 0 {
+			used, last = used+1, sym
+		}
+	}
+	switch used {
+	case 0:
+		return nil, errors.New("no symbols")
+	case 1:
+		return &prefixCode{single: last}, nil
+	}
+	// Canonical codes: shorter first, then by symbol
+	c := &prefixCode{single: -1, symbols: make(map[[2]int]int)}
+	code := 0
+	for length := 1; length <= 15; length++ {
+		for sym, l := range lengths {
+			if l == length {
+				c.symbols[[2]int{length, code}] = sym
+				code++
+			}
+		}
+		code <<= 1
+	}
+	return c, nil
+}
+
+func (c *prefixCode) decode(r *bitReader) int {
+	if c.single >= 0 {
+		return c.single
+	}
+	code := 0
+	for length := 1; length <= 15; length++ {
+		code = code<<1 | r.read(1)
+		if sym, ok := c.symbols[[2]int{length, code}]; ok {
+			return sym
+		}
+	}
+	r.overrun = true
+	return 0
+}
+
+func readPrefixCode(r *bitReader, alphabetSize int) (*prefixCode, error) {
+	lengths := make([]int, alphabetSize)
+	if r.read(1) == 1 { // Simple code
+		numSymbols := r.read(1) + 1
+		lengths[r.read(uint(1+7*r.read(1)))] = 1
+		if numSymbols == 2 {
+			lengths[r.read(8)] = 1
+		}
+		return newPrefixCode(lengths)
+	}
+
+	codeLengthLengths := make([]int, 19)
+	numCodeLengths := 4 + r.read(4)
+	for i := 0; i < numCodeLengths; i++ {
+		codeLengthLengths[codeLengthCodeOrder[i]] = r.read(3)
+	}
+	lengthCode, err := newPrefixCode(codeLengthLengths)
+	if err != nil {
+		return nil, err
+	}
+	maxSymbol := alphabetSize
+	if r.read(1) == 1 {
+		maxSymbol = 2 + r.read(uint(2+2*r.read(3)))
+	}
+	previous := 8
+	for sym := 0; sym < alphabetSize && maxSymbol > 0; maxSymbol-- {
+		length := lengthCode.decode(r)
+		repeat, value := 1, length
+		switch length {
+		case 16:
+			repeat, value = 3+r.read(2), previous
+		case 17:
+			repeat, value = 3+r.read(3), 0
+		case 18:
+			repeat, value = 11+r.read(7), 0
+		}
+		if sym+repeat > alphabetSize {
+			return nil, errors.New("code lengths overflow the alphabet")
+		}
+		for ; repeat > 0; repeat-- {
+			lengths[sym] = value
+			sym++
+		}
+		if length < 16 && length != 0 {
+			previous = length
+		}
+	}
+	return newPrefixCode(lengths)
+}
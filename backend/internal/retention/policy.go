@@ -0,0 +1,56 @@
+// File: backend/internal/retention/policy.go
+package retention
+
+import (
+	"strings"
+	"time"
+)
+
+// Policy computes when a study may be deleted. Adult studies are kept Years
+// after the study date; studies of patients younger than MajorityAge at the
+// study date are kept until the patient is PediatricAge+Years, if that is later.
+type Policy struct {
+	Years        int // 0 disables policy-driven deletion
+	MajorityAge  int // e.g. 18
+	PediatricAge int // e.g. 21
+}
+
+// Enabled reports whether the policy ever expires a study.
+func (p Policy) Enabled() bool {
+	return p.Years > 0
+}
+
+// ExpiresAt returns the earliest time a study may be deleted. ok is false
+// when the study date is missing or unreadable; such studies are kept. An
+// unknown birth date is treated as a newborn, the longest pediatric period.
+func (p Policy) ExpiresAt(birthDate, studyDate string) (time.Time, bool) {
+	study, ok := parseDA(studyDate)
+	if !ok || !p.Enabled() {
+		return time.Time{}, false
+	}
+	expires := study.AddDate(p.Years, 0, 0)
+
+	birth, ok := parseDA(birthDate)
+	if !ok || birth.After(study) {
+		birth = study
+	}
+	if study.Before(birth.AddDate(p.MajorityAge, 0, 0)) {
+		if pediatric := birth.AddDate(p.PediatricAge+p.Years, 0, 0); pediatric.After(expires) {
+			expires = pediatric
+		}
+	}
+	return expires, true
+}
+
+// parseDA parses a DICOM DA value (YYYYMMDD, or the old YYYY.MM.DD form).
+func parseDA(value string) (time.Time, bool) {
+	value = strings.ReplaceAll(strings.TrimSpace(value), ".", "")
+	if len(value) != 8 {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("20060102", value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
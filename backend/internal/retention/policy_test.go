@@ -0,0 +1,72 @@
+package retention
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPolicyExpiresAt(t *testing.T) {
+	standard := Policy{Years: 10, MajorityAge: 18, PediatricAge: 21}
+	tests := []struct {
+		name      string
+		policy    Policy
+		birthDate string
+		studyDate string
+		want      string // YYYYMMDD; empty when the study never expires
+	}{
+		{name: "adult", policy: standard, birthDate: "19700101", studyDate: "20100615", want: "20200615"},
+		{name: "child kept until 21 plus the period", policy: standard, birthDate: "20050301", studyDate: "20100101", want: "20360301"},
+		{name: "minor on the day before their 18th birthday", policy: standard, birthDate: "19920615", studyDate: "20100614", want: "20230615"},
+		{name: "adult on their 18th birthday", policy: standard, birthDate: "19920615", studyDate: "20100615", want: "20200615"},
+		{name: "pediatric period shorter than the adult one", policy: Policy{Years: 10, MajorityAge: 18, PediatricAge: 5},
+			birthDate: "20000101", studyDate: "20100101", want: "20200101"},
+		{name: "unknown birth date treated as newborn", policy: standard, birthDate: "", studyDate: "20100101", want: "20410101"},
+		{name: "unreadable birth date treated as newborn", policy: standard, birthDate: "1980", studyDate: "20100101", want: "20410101"},
+		{name: "birth after study treated as newborn", policy: standard, birthDate: "20200101", studyDate: "20100101", want: "20410101"},
+		{name: "old dotted DA form", policy: standard, birthDate: "1970.01.01", studyDate: "2010.06.15", want: "20200615"},
+		{name: "study on a leap day", policy: standard, birthDate: "19700101", studyDate: "20120229", want: "20220301"},
+		{name: "missing study date", policy: standard, birthDate: "19700101", studyDate: ""},
+		{name: "invalid study date", policy: standard, birthDate: "19700101", studyDate: "20101345"},
+		{name: "disabled policy", policy: Policy{MajorityAge: 18, PediatricAge: 21}, birthDate: "19700101", studyDate: "20100615"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.policy.ExpiresAt(tt.birthDate, tt.studyDate)
+			if tt.want == "" {
+				if ok {
+					t.Errorf("ExpiresAt() = %v, want no expiry", got)
+				}
+				return
+			}
+			want, err := time.Parse("20060102", tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ok || !got.Equal(want) {
+				t.Errorf("ExpiresAt() = %v, %v, want %v", got, ok, want)
+			}
+		})
+	}
+}
+
+func TestParseDA(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   string
+		wantOK bool
+	}{
+		{"20240229", "20240229", true},
+		{" 20240101 ", "20240101", true},
+		{"2024.01.31", "20240131", true},
+		{"20230229", "", false}, // Not a leap year
+		{"2024-01-01", "", false},
+		{"202401", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := parseDA(tt.value)
+		if ok != tt.wantOK || ok && got.Format("20060102") != tt.want {
+			t.Errorf("parseDA(%q) = %v, %v, want %s, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+// File: backend/internal/retention/service.go
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/ewag/gen-erics/backend/internal/auth"
+	models "github.com/ewag/gen-erics/backend/internal/models"
+	"github.com/ewag/gen-erics/backend/internal/mover"
+	"github.com/ewag/gen-erics/backend/internal/orthanc"
+	"github.com/ewag/gen-erics/backend/internal/storage"
+	"github.com/ewag/gen-erics/backend/internal/tiers"
+)
+
+// TierDeleted is the status recorded for a study once it has been deleted.
+const TierDeleted = "deleted"
+
+var (
+	// ErrLegalHold is returned when a study, or its patient, is under legal hold.
+	ErrLegalHold = errors.New("study is under legal hold")
+	// ErrStudyNotFound is returned when neither Orthanc nor the catalog knows a study.
+	ErrStudyNotFound = errors.New("study not found")
+)
+
+// Service manages legal holds and deletes studies from Orthanc and every
+// tier backend, leaving a tombstone behind.
+type Service struct {
+	store   storage.RetentionStore
+	catalog storage.CatalogStore
+	status  storage.StatusStore
+	orthanc *orthanc.Client
+	tiers   *tiers.Registry
+	policy  Policy
+}
+
+// NewService creates a retention Service.
+func NewService(store storage.RetentionStore, catalog storage.CatalogStore, status storage.StatusStore,
+	orthancClient *orthanc.Client, registry *tiers.Registry, policy Policy) *Service {
+	return &Service{store: store, catalog: catalog, status: status, orthanc: orthancClient, tiers: registry, policy: policy}
+}
+
+// Policy returns the retention policy in force.
+func (s *Service) Policy() Policy {
+	return s.policy
+}
+
+// PlaceHold puts a patient or study under legal hold.
+func (s *Service) PlaceHold(ctx context.Context, scope, targetID, reason string) (*models.LegalHold, error) {
+	if scope != models.HoldScopePatient && scope != models.HoldScopeStudy {
+		return nil, fmt.Errorf("unknown legal hold scope %q", scope)
+	}
+	hold := &models.LegalHold{
+		Scope:     scope,
+		TargetID:  targetID,
+		Reason:    reason,
+		CreatedBy: auth.ActorFromContext(ctx),
+		CreatedAt: time.Now(),
+	}
+	if err := s.store.CreateLegalHold(ctx, hold); err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Legal hold placed", "holdID", hold.ID, "scope", scope, "targetID", targetID, "by", hold.CreatedBy)
+	return hold, nil
+}
+
+// ReleaseHold ends a legal hold, returning false if it was not active.
+func (s *Service) ReleaseHold(ctx context.Context, id int64) (bool, error) {
+	released, err := s.store.ReleaseLegalHold(ctx, id, auth.ActorFromContext(ctx), time.Now())
+	if err == nil && released {
+		slog.InfoContext(ctx, "Legal hold released", "holdID", id, "by", auth.ActorFromContext(ctx))
+	}
+	return released, err
+}
+
+// ListHolds lists legal holds, newest first.
+func (s *Service) ListHolds(ctx context.Context, activeOnly bool) ([]models.LegalHold, error) {
+	return s.store.ListLegalHolds(ctx, activeOnly)
+}
+
+// Tombstone returns the tombstone of a deleted study, if it was deleted.
+func (s *Service) Tombstone(ctx context.Context, studyID string) (*models.Tombstone, bool, error) {
+	return s.store.GetTombstone(ctx, studyID)
+}
+
+// ListTombstones lists up to limit deleted studies, most recent first.
+func (s *Service) ListTombstones(ctx context.Context, limit int) ([]models.Tombstone, error) {
+	return s.store.ListTombstones(ctx, limit)
+}
+
+// HoldsForStudy returns the active holds covering a study.
+func (s *Service) HoldsForStudy(ctx context.Context, studyID string) ([]models.LegalHold, error) {
+	study, _, err := s.describe(ctx, studyID)
+	if err != nil && !errors.Is(err, ErrStudyNotFound) {
+		return nil, err
+	}
+	patientResourceID := ""
+	if study != nil {
+		patientResourceID = study.PatientResourceID
+	}
+	return s.store.ActiveHoldsForStudy(ctx, studyID, patientResourceID)
+}
+
+// CheckHold returns an error wrapping ErrLegalHold if the study may not be
+// moved or deleted. It implements mover.HoldChecker.
+func (s *Service) CheckHold(ctx context.Context, studyID string) error {
+	holds, err := s.HoldsForStudy(ctx, studyID)
+	if err != nil {
+		return fmt.Errorf("failed to check legal holds: %w", err)
+	}
+	return holdError(studyID, holds)
+}
+
+func holdError(studyID string, holds []models.LegalHold) error {
+	if len(holds) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: study %s (hold %d on %s %s: %s)", ErrLegalHold, studyID,
+		holds[0].ID, holds[0].Scope, holds[0].TargetID, holds[0].Reason)
+}
+
+// Expiry returns when the study may be deleted under the policy; ok is false
+// when it never expires (policy disabled or no study date).
+func (s *Service) Expiry(ctx context.Context, studyID string) (time.Time, bool, error) {
+	study, _, err := s.describe(ctx, studyID)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	expires, ok := s.policy.ExpiresAt(study.PatientBirthDate, study.StudyDate)
+	return expires, ok, nil
+}
+
+// DeleteStudy removes a study from every tier backend and from Orthanc, then
+// records a tombstone and marks the study deleted. Holds are checked first.
+// A failed deletion can simply be retried: every step tolerates data that is
+// already gone.
+func (s *Service) DeleteStudy(ctx context.Context, studyID, reason string) (*models.Tombstone, error) {
+	logAttrs := []any{"studyID", studyID, "reason", reason}
+	study, inOrthanc, err := s.describe(ctx, studyID)
+	if err != nil && !errors.Is(err, ErrStudyNotFound) {
+		return nil, err
+	}
+	patientResourceID := ""
+	if study != nil {
+		patientResourceID = study.PatientResourceID
+	}
+	holds, err := s.store.ActiveHoldsForStudy(ctx, studyID, patientResourceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check legal holds: %w", err)
+	}
+	if err := holdError(studyID, holds); err != nil {
+		return nil, err
+	}
+
+	t := &models.Tombstone{
+		StudyID:   studyID,
+		Reason:    reason,
+		DeletedBy: auth.ActorFromContext(ctx),
+		Tiers:     []string{},
+	}
+	if study != nil {
+		t.StudyInstanceUID = study.StudyInstanceUID
+		t.PatientResourceID = study.PatientResourceID
+		t.PatientID = study.PatientID
+		t.StudyDate = study.StudyDate
+	}
+
+	// Tier copies go first: if Orthanc's copy is deleted and the run fails,
+	// a retry can still describe the study from Orthanc or the catalog
+	tierNames := s.tiers.Tiers()
+	sort.Strings(tierNames)
+	for _, tier := range tierNames {
+		instances, bytes, err := s.deleteFromTier(ctx, studyID, tier)
+		if err != nil {
+			return nil, err
+		}
+		if instances > 0 {
+			t.Tiers = append(t.Tiers, tier)
+			t.Instances += instances
+			t.Bytes += bytes
+		}
+	}
+
+	if inOrthanc {
+		if stats, err := s.orthanc.GetStatistics(ctx, "studies", studyID); err == nil {
+			t.Instances += stats.CountInstances
+			t.Bytes += stats.DiskSize
+		}
+		if err := s.orthanc.DeleteStudy(ctx, studyID); err != nil && !errors.Is(err, orthanc.ErrNotFound) {
+			return nil, fmt.Errorf("failed to delete study from Orthanc: %w", err)
+		}
+		t.Tiers = append(t.Tiers, mover.TierHot)
+	}
+
+	if study == nil && len(t.Tiers) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrStudyNotFound, studyID)
+	}
+
+	t.DeletedAt = time.Now()
+	if err := s.store.RecordTombstone(ctx, *t); err != nil {
+		return nil, err
+	}
+	if err := s.status.SetStatus(ctx, studyID, models.LocationStatus{Tier: TierDeleted, LocationType: "none"}); err != nil {
+		slog.WarnContext(ctx, "Study deleted but failed to update its status", append(logAttrs, "error", err)...)
+	}
+	slog.InfoContext(ctx, "Study deleted", append(logAttrs, "tiers", t.Tiers, "instances", t.Instances, "bytes", t.Bytes, "by", t.DeletedBy)...)
+	return t, nil
+}
+
+// deleteFromTier removes a study's catalogued objects from one tier backend.
+func (s *Service) deleteFromTier(ctx context.Context, studyID, tier string) (int, int64, error) {
+	backend, ok := s.tiers.For(tier)
+	if !ok {
+		return 0, 0, nil
+	}
+	entries, err := s.catalog.ListCatalogEntries(ctx, studyID, tier)
+	if err != nil {
+		return 0, 0, err
+	}
+	var bytes int64
+	for _, entry := range entries {
+		if err := backend.Delete(ctx, entry.ObjectKey); err != nil {
+			return 0, 0, fmt.Errorf("failed to delete %s from %s: %w", entry.ObjectKey, tier, err)
+		}
+		bytes += entry.SizeBytes
+	}
+	if len(entries) > 0 {
+		if err := s.catalog.DeleteCatalogEntries(ctx, studyID, tier); err != nil {
+			return 0, 0, err
+		}
+	}
+	return len(entries), bytes, nil
+}
+
+// describe returns a study's patient and study tags from the study catalog,
+// or from Orthanc for studies that were never offloaded. inOrthanc reports
+// whether Orthanc holds the study.
+func (s *Service) describe(ctx context.Context, studyID string) (*models.StudyCatalogEntry, bool, error) {
+	details, err := s.orthanc.GetStudyDetails(ctx, studyID)
+	if err != nil && !errors.Is(err, orthanc.ErrNotFound) {
+		return nil, false, fmt.Errorf("failed to get study from Orthanc: %w", err)
+	}
+	if details != nil {
+		return studyFromOrthanc(details), true, nil
+	}
+	entry, found, err := s.catalog.GetStudyCatalogEntry(ctx, studyID)
+	if err != nil {
+		return nil, false, err
+	}
+	if !found {
+		return nil, false, fmt.Errorf("%w: %s", ErrStudyNotFound, studyID)
+	}
+	return entry, false, nil
+}
+
+func studyFromOrthanc(d *orthanc.StudyDetails) *models.StudyCatalogEntry {
+	return &models.StudyCatalogEntry{
+		StudyID:           d.ID,
+		StudyInstanceUID:  d.MainTags.StudyInstanceUID,
+		PatientResourceID: d.ParentPatient,
+		PatientID:         d.PatientMainTags.PatientID,
+		PatientName:       d.PatientMainTags.PatientName,
+		PatientBirthDate:  d.PatientMainTags.PatientBirthDate,
+		StudyDate:         d.MainTags.StudyDate,
+	}
+}
@@ -0,0 +1,193 @@
+// File: backend/internal/retention/sweeper.go
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ewag/gen-erics/backend/internal/audit"
+	"github.com/ewag/gen-erics/backend/internal/auth"
+	models "github.com/ewag/gen-erics/backend/internal/models"
+)
+
+// sweepActor is recorded as the deleting user for policy-driven deletions.
+const sweepActor = "retention-policy"
+
+// SweeperConfig controls policy-driven deletion.
+type SweeperConfig struct {
+	Interval   time.Duration // Pause between sweeps
+	PageSize   int           // Studies fetched from Orthanc per request
+	MaxDeletes int           // Deletions per sweep; the rest wait for the next one
+	Enforce    bool          // When false, expired studies are only reported
+}
+
+// SweepResult summarises one sweep.
+type SweepResult struct {
+	Checked  int `json:"checked"`
+	Undated  int `json:"undated"` // No usable study date; always kept
+	Expired  int `json:"expired"`
+	Held     int `json:"held"`
+	Deleted  int `json:"deleted"`
+	Failed   int `json:"failed"`
+	Deferred int `json:"deferred"` // Expired but over MaxDeletes, or not enforced
+}
+
+// Sweeper periodically deletes studies whose retention period has ended.
+type Sweeper struct {
+	service *Service
+	audit   *audit.Recorder
+	cfg     SweeperConfig
+}
+
+// NewSweeper creates a Sweeper; call Run to start it.
+func NewSweeper(service *Service, recorder *audit.Recorder, cfg SweeperConfig) *Sweeper {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 24 * time.Hour
+	}
+	if cfg.PageSize <= 0 {
+		cfg.PageSize = 500
+	}
+	if cfg.MaxDeletes <= 0 {
+		cfg.MaxDeletes = 100
+	}
+	return &Sweeper{service: service, audit: recorder, cfg: cfg}
+}
+
+// Run sweeps until ctx is cancelled.
+func (s *Sweeper) Run(ctx context.Context) {
+	slog.InfoContext(ctx, "Retention sweeper started", "policy", s.service.policy, "interval", s.cfg.Interval,
+		"maxDeletes", s.cfg.MaxDeletes, "enforce", s.cfg.Enforce)
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.SweepOnce(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			slog.ErrorContext(ctx, "Retention sweep failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("Retention sweeper stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SweepOnce checks every study in Orthanc and the tier catalog against the
+// policy and deletes (or, unless enforcing, reports) the expired ones.
+func (s *Sweeper) SweepOnce(ctx context.Context) (*SweepResult, error) {
+	ctx = auth.WithPrincipal(ctx, &auth.Principal{Subject: sweepActor, Method: "system"})
+	result := &SweepResult{}
+	now := time.Now()
+
+	candidates, err := s.candidates(ctx)
+	if err != nil {
+		return result, err
+	}
+	for _, study := range candidates {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		result.Checked++
+		expires, ok := s.service.policy.ExpiresAt(study.PatientBirthDate, study.StudyDate)
+		if !ok {
+			result.Undated++
+			continue
+		}
+		if now.Before(expires) {
+			continue
+		}
+		result.Expired++
+		logAttrs := []any{"studyID", study.StudyID, "studyDate", study.StudyDate, "expiredAt", expires.Format(time.DateOnly)}
+
+		holds, err := s.service.store.ActiveHoldsForStudy(ctx, study.StudyID, study.PatientResourceID)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to check legal holds for expired study", append(logAttrs, "error", err)...)
+			result.Failed++
+			continue
+		}
+		if len(holds) > 0 {
+			slog.InfoContext(ctx, "Expired study kept under legal hold", append(logAttrs, "holdID", holds[0].ID)...)
+			result.Held++
+			continue
+		}
+		if !s.cfg.Enforce || result.Deleted+result.Failed >= s.cfg.MaxDeletes {
+			if !s.cfg.Enforce {
+				slog.InfoContext(ctx, "Study is past retention (not enforced, keeping it)", logAttrs...)
+			}
+			result.Deferred++
+			continue
+		}
+
+		reason := fmt.Sprintf("Retention period ended %s", expires.Format(time.DateOnly))
+		t, err := s.service.DeleteStudy(ctx, study.StudyID, reason)
+		recordDeletion(ctx, s.audit, sweepActor, study.StudyID, study.PatientID, study.PatientName, err)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to delete expired study", append(logAttrs, "error", err)...)
+			result.Failed++
+			continue
+		}
+		slog.InfoContext(ctx, "Deleted expired study", append(logAttrs, "instances", t.Instances, "bytes", t.Bytes)...)
+		result.Deleted++
+	}
+	slog.InfoContext(ctx, "Retention sweep complete", "checked", result.Checked, "expired", result.Expired, "held", result.Held,
+		"deleted", result.Deleted, "failed", result.Failed, "deferred", result.Deferred, "undated", result.Undated)
+	return result, nil
+}
+
+// candidates lists every study in Orthanc followed by the offloaded ones.
+func (s *Sweeper) candidates(ctx context.Context) ([]models.StudyCatalogEntry, error) {
+	var out []models.StudyCatalogEntry
+	seen := make(map[string]bool)
+	for since := 0; ; since += s.cfg.PageSize {
+		page, err := s.service.orthanc.ListStudyDetails(ctx, since, s.cfg.PageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list studies from Orthanc: %w", err)
+		}
+		for i := range page {
+			if !seen[page[i].ID] {
+				seen[page[i].ID] = true
+				out = append(out, *studyFromOrthanc(&page[i]))
+			}
+		}
+		if len(page) < s.cfg.PageSize {
+			break
+		}
+	}
+	offloaded, err := s.service.catalog.ListOffloadedStudies(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, st := range offloaded {
+		if !seen[st.StudyID] {
+			seen[st.StudyID] = true
+			out = append(out, st.StudyCatalogEntry)
+		}
+	}
+	return out, nil
+}
+
+// recordDeletion audits a deletion made by a background worker; API
+// deletions are audited by the route middleware instead.
+func recordDeletion(ctx context.Context, recorder *audit.Recorder, actor, studyID, patientID, patientName string, err error) {
+	e := &audit.Event{
+		Identification: audit.EventIdentification{
+			EventID:    audit.EventStudyDeleted,
+			ActionCode: audit.ActionDelete,
+			Outcome:    audit.OutcomeSuccess,
+		},
+		Participants: []audit.ActiveParticipant{{UserID: actor, AlternativeUserID: "system", UserIsRequestor: true}},
+		Objects:      []audit.ParticipantObject{audit.StudyObject(studyID, "", "")},
+	}
+	if patientID != "" {
+		e.Objects = append(e.Objects, audit.PatientObject(patientID, patientName))
+	}
+	if err != nil {
+		e.Identification.Outcome = audit.OutcomeSeriousFailure
+		e.Identification.OutcomeDescription = err.Error()
+	}
+	recorder.Record(ctx, e)
+}
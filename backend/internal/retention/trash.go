@@ -0,0 +1,229 @@
+// File: backend/internal/retention/trash.go
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ewag/gen-erics/backend/internal/audit"
+	"github.com/ewag/gen-erics/backend/internal/auth"
+	models "github.com/ewag/gen-erics/backend/internal/models"
+	"github.com/ewag/gen-erics/backend/internal/mover"
+	"github.com/ewag/gen-erics/backend/internal/retry"
+	"github.com/ewag/gen-erics/backend/internal/storage"
+)
+
+// TierTrash is the tier soft-deleted studies wait in until they are purged.
+const TierTrash = "trash"
+
+// purgeActor is recorded as the deleting user when expired trash is purged.
+const purgeActor = "trash-purge"
+
+var (
+	// ErrInTrash is returned when trashing a study that is already in the trash.
+	ErrInTrash = errors.New("study is already in the trash")
+	// ErrNotInTrash is returned when restoring a study that is not in the trash.
+	ErrNotInTrash = errors.New("study is not in the trash")
+)
+
+// TrashConfig controls soft deletion.
+type TrashConfig struct {
+	GracePeriod   time.Duration // How long trashed studies can be restored
+	PurgeInterval time.Duration // Pause between purge passes
+	BatchSize     int           // Studies purged per pass
+	// Retry covers recording a study as trashed or restored once its data
+	// has moved; if that keeps failing the move is undone.
+	Retry retry.Policy
+}
+
+// TrashBin soft-deletes studies by moving them into the trash tier, restores
+// them to where they were, and purges them once the grace period is over.
+// Every step is recorded in the study's status history.
+type TrashBin struct {
+	service *Service
+	mover   *mover.Mover
+	store   storage.TrashStore
+	audit   *audit.Recorder
+	cfg     TrashConfig
+}
+
+// NewTrashBin creates a TrashBin; call Run to start purging.
+func NewTrashBin(service *Service, studyMover *mover.Mover, store storage.TrashStore, recorder *audit.Recorder, cfg TrashConfig) *TrashBin {
+	if cfg.GracePeriod <= 0 {
+		cfg.GracePeriod = 30 * 24 * time.Hour
+	}
+	if cfg.PurgeInterval <= 0 {
+		cfg.PurgeInterval = time.Hour
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.Retry.Attempts <= 0 {
+		cfg.Retry = retry.Policy{Attempts: 5, BaseDelay: 500 * time.Millisecond, MaxDelay: 10 * time.Second}
+	}
+	return &TrashBin{service: service, mover: studyMover, store: store, audit: recorder, cfg: cfg}
+}
+
+// Trash moves a study into the trash tier. Studies under legal hold are
+// refused by the mover.
+func (b *TrashBin) Trash(ctx context.Context, studyID, reason string) (*models.TrashEntry, *mover.Result, error) {
+	// Studies without a status row have never left Orthanc
+	current := models.LocationStatus{Tier: mover.TierHot, LocationType: "edge"}
+	status, found, err := b.service.status.GetStatus(ctx, studyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if found {
+		current = *status
+	}
+	switch current.Tier {
+	case TierTrash:
+		return nil, nil, fmt.Errorf("%w: %s", ErrInTrash, studyID)
+	case TierDeleted:
+		return nil, nil, fmt.Errorf("%w: %s was deleted", ErrStudyNotFound, studyID)
+	}
+
+	result, err := b.mover.TransitionOnEdge(ctx, studyID, current.Tier, TierTrash, current.Edge())
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	entry := models.TrashEntry{
+		StudyID:        studyID,
+		PreviousStatus: current,
+		Reason:         reason,
+		TrashedBy:      auth.ActorFromContext(ctx),
+		TrashedAt:      now,
+		PurgeAfter:     now.Add(b.cfg.GracePeriod),
+	}
+	trashed := models.LocationStatus{Tier: TierTrash, LocationType: "trash"}
+	if err := b.record(ctx, studyID, TierTrash, current, func(ctx context.Context) error {
+		return b.store.RecordTrashed(ctx, entry, trashed)
+	}); err != nil {
+		return nil, nil, err
+	}
+	slog.InfoContext(ctx, "Study moved to trash", "studyID", studyID, "fromTier", current.Tier,
+		"purgeAfter", entry.PurgeAfter, "by", entry.TrashedBy)
+	return &entry, result, nil
+}
+
+// Restore moves a trashed study back to the tier and location it was
+// trashed from. Legal holds don't prevent it: restoring keeps the data, and
+// a held study would otherwise sit in the trash, never purged, for as long
+// as the hold lasts.
+func (b *TrashBin) Restore(ctx context.Context, studyID string) (*models.LocationStatus, *mover.Result, error) {
+	entry, found, err := b.store.GetTrashEntry(ctx, studyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		return nil, nil, fmt.Errorf("%w: %s", ErrNotInTrash, studyID)
+	}
+
+	result, err := b.mover.TransitionIgnoringHolds(ctx, studyID, TierTrash, entry.PreviousStatus.Tier, entry.PreviousStatus.Edge())
+	if err != nil {
+		return nil, nil, err
+	}
+	if result.StudyID != "" {
+		studyID = result.StudyID // Recalled under the patient it was merged into
+	}
+	trashed := models.LocationStatus{Tier: TierTrash, LocationType: "trash"}
+	if err := b.record(ctx, studyID, entry.PreviousStatus.Tier, trashed, func(ctx context.Context) error {
+		return b.store.RecordRestored(ctx, studyID, entry.PreviousStatus)
+	}); err != nil {
+		return nil, nil, err
+	}
+	slog.InfoContext(ctx, "Study restored from trash", "studyID", studyID, "toTier", entry.PreviousStatus.Tier,
+		"by", auth.ActorFromContext(ctx))
+	return &entry.PreviousStatus, result, nil
+}
+
+// record runs write, which records that a study has moved to tier, once its
+// data has moved there from previous. The study is no longer where its
+// status says, so write is retried, whether or not the caller is still
+// waiting; if it keeps failing the move is undone, as a study whose status
+// and trash entry don't match its data can be neither restored nor purged.
+func (b *TrashBin) record(ctx context.Context, studyID, tier string, previous models.LocationStatus, write func(context.Context) error) error {
+	ctx = context.WithoutCancel(ctx)
+	err := retry.Do(ctx, b.cfg.Retry, nil, func() error { return write(ctx) })
+	if err == nil {
+		return nil
+	}
+	logAttrs := []any{"studyID", studyID, "tier", tier, "previousTier", previous.Tier, "error", err}
+	slog.ErrorContext(ctx, "Failed to record study move; moving it back", logAttrs...)
+	if _, undoErr := b.mover.TransitionIgnoringHolds(ctx, studyID, tier, previous.Tier, previous.Edge()); undoErr != nil {
+		slog.ErrorContext(ctx, "STUDY STATUS OUT OF SYNC: study could be neither recorded in nor moved back from its new tier",
+			append(logAttrs, "undoError", undoErr)...)
+		return fmt.Errorf("failed to record study %s in %s, and to move it back to %s: %w", studyID, tier, previous.Tier, errors.Join(err, undoErr))
+	}
+	return fmt.Errorf("failed to record study %s in %s; moved it back to %s: %w", studyID, tier, previous.Tier, err)
+}
+
+// Entry returns the trash entry of a study, if it is in the trash.
+func (b *TrashBin) Entry(ctx context.Context, studyID string) (*models.TrashEntry, bool, error) {
+	return b.store.GetTrashEntry(ctx, studyID)
+}
+
+// List returns trashed studies, those due for purging first.
+func (b *TrashBin) List(ctx context.Context, limit int) ([]models.TrashEntry, error) {
+	return b.store.ListTrash(ctx, time.Time{}, limit)
+}
+
+// Run purges expired trash until ctx is cancelled.
+func (b *TrashBin) Run(ctx context.Context) {
+	slog.InfoContext(ctx, "Trash purge worker started", "gracePeriod", b.cfg.GracePeriod, "interval", b.cfg.PurgeInterval)
+	ticker := time.NewTicker(b.cfg.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := b.PurgeOnce(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			slog.ErrorContext(ctx, "Trash purge failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("Trash purge worker stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeOnce hard-deletes one batch of studies whose grace period has ended.
+func (b *TrashBin) PurgeOnce(ctx context.Context) error {
+	ctx = auth.WithPrincipal(ctx, &auth.Principal{Subject: purgeActor, Method: "system"})
+	entries, err := b.store.ListTrash(ctx, time.Now(), b.cfg.BatchSize)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		slog.DebugContext(ctx, "No expired trash to purge")
+		return nil
+	}
+
+	var purged, held, failed int
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		logAttrs := []any{"studyID", entry.StudyID, "trashedAt", entry.TrashedAt, "purgeAfter", entry.PurgeAfter}
+		t, err := b.service.DeleteStudy(ctx, entry.StudyID, "Trash grace period ended")
+		switch {
+		case errors.Is(err, ErrLegalHold):
+			slog.InfoContext(ctx, "Expired trash kept under legal hold", append(logAttrs, "error", err)...)
+			held++
+			continue
+		case err != nil:
+			slog.ErrorContext(ctx, "Failed to purge trashed study", append(logAttrs, "error", err)...)
+			recordDeletion(ctx, b.audit, purgeActor, entry.StudyID, "", "", err)
+			failed++
+			continue
+		}
+		recordDeletion(ctx, b.audit, purgeActor, entry.StudyID, t.PatientID, "", nil)
+		purged++
+	}
+	slog.InfoContext(ctx, "Trash purge pass complete", "purged", purged, "held", held, "failed", failed)
+	return nil
+}
@@ -0,0 +1,82 @@
+// File: backend/internal/retry/retry.go
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// Policy controls how often and how patiently an operation is retried.
+type Policy struct {
+	Attempts  int           // Tries in total, including the first; below 2 means no retries
+	BaseDelay time.Duration // Delay before the first retry, doubled for each one after
+	MaxDelay  time.Duration // Cap on the doubled delay; 0 means no cap
+}
+
+// Delay returns the pause before retry n (1 for the first retry):
+// exponential in n, capped, with jitter spreading it over its upper half so
+// that many clients failing together don't retry in lockstep.
+func (p Policy) Delay(n int) time.Duration {
+	if p.BaseDelay <= 0 || n < 1 {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := 1; i < n; i++ {
+		d *= 2
+		if p.MaxDelay > 0 && d >= p.MaxDelay {
+			d = p.MaxDelay
+			break
+		}
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+// permanentError marks an error retrying cannot fix.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying. It returns nil for nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err, or an error it wraps, was marked Permanent.
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// Do calls fn until it succeeds, returns an error for which retryable is
+// false (nil treats every error but Permanent ones as retryable), the
+// policy's attempts are used up or ctx ends. It returns fn's last error.
+func Do(ctx context.Context, p Policy, retryable func(error) bool, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if attempt >= p.Attempts || IsPermanent(err) || (retryable != nil && !retryable(err)) || ctx.Err() != nil {
+			return err
+		}
+		timer := time.NewTimer(p.Delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
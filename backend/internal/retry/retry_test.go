@@ -0,0 +1,128 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPolicyDelay(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		n      int
+		want   time.Duration // Upper bound; jitter keeps the delay within its upper half
+	}{
+		{name: "first retry", policy: Policy{BaseDelay: 100 * time.Millisecond}, n: 1, want: 100 * time.Millisecond},
+		{name: "doubles", policy: Policy{BaseDelay: 100 * time.Millisecond}, n: 3, want: 400 * time.Millisecond},
+		{name: "capped", policy: Policy{BaseDelay: 100 * time.Millisecond, MaxDelay: 250 * time.Millisecond}, n: 3, want: 250 * time.Millisecond},
+		{name: "base above cap", policy: Policy{BaseDelay: time.Second, MaxDelay: 250 * time.Millisecond}, n: 1, want: 250 * time.Millisecond},
+		{name: "cap stops overflow", policy: Policy{BaseDelay: time.Second, MaxDelay: time.Minute}, n: 200, want: time.Minute},
+		{name: "no base delay", policy: Policy{MaxDelay: time.Second}, n: 2, want: 0},
+		{name: "not a retry", policy: Policy{BaseDelay: time.Second}, n: 0, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 50; i++ {
+				if got := tt.policy.Delay(tt.n); got < tt.want/2 || got > tt.want {
+					t.Fatalf("Delay(%d) = %v, want between %v and %v", tt.n, got, tt.want/2, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	base := errors.New("bad request")
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "plain", err: base, want: false},
+		{name: "permanent", err: Permanent(base), want: true},
+		{name: "wrapped permanent", err: fmt.Errorf("upload: %w", Permanent(base)), want: true},
+		{name: "nil", err: nil, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPermanent(tt.err); got != tt.want {
+				t.Errorf("IsPermanent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if Permanent(nil) != nil {
+		t.Error("Permanent(nil) != nil")
+	}
+	if err := Permanent(base); !errors.Is(err, base) || err.Error() != base.Error() {
+		t.Errorf("Permanent() = %v, want it to wrap %v", err, base)
+	}
+}
+
+func TestDo(t *testing.T) {
+	transient := errors.New("connection reset")
+	fatal := errors.New("not found")
+	policy := Policy{Attempts: 4, BaseDelay: time.Millisecond}
+
+	tests := []struct {
+		name      string
+		policy    Policy
+		retryable func(error) bool
+		errs      []error // Returned by successive calls; nil after the last
+		cancel    bool    // Cancel the context before the first call
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "first try", policy: policy, wantCalls: 1},
+		{name: "after transient failures", policy: policy, errs: []error{transient, transient}, wantCalls: 3},
+		{name: "attempts used up", policy: policy, errs: []error{transient, transient, transient, transient, transient}, wantCalls: 4, wantErr: transient},
+		{name: "permanent error", policy: policy, errs: []error{Permanent(fatal)}, wantCalls: 1, wantErr: fatal},
+		{name: "not retryable", policy: policy, retryable: func(err error) bool { return err != fatal },
+			errs: []error{transient, fatal}, wantCalls: 2, wantErr: fatal},
+		{name: "no retries configured", policy: Policy{Attempts: 1}, errs: []error{transient}, wantCalls: 1, wantErr: transient},
+		{name: "zero policy", errs: []error{transient}, wantCalls: 1, wantErr: transient},
+		{name: "context cancelled", policy: policy, errs: []error{transient, transient}, cancel: true, wantCalls: 1, wantErr: transient},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+			calls := 0
+			err := Do(ctx, tt.policy, tt.retryable, func() error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			if calls != tt.wantCalls {
+				t.Errorf("fn called %d times, want %d", calls, tt.wantCalls)
+			}
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Do() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDoCancelledWhileWaiting(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	calls := 0
+	err := Do(ctx, Policy{Attempts: 5, BaseDelay: time.Hour}, nil, func() error {
+		calls++
+		return errors.New("unavailable")
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("Do() = %v after %d calls, want the error after 1", err, calls)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Do() returned after %v, want it to stop waiting when the context ends", elapsed)
+	}
+}
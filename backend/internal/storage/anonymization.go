@@ -0,0 +1,65 @@
+// File: internal/storage/anonymization.go
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+
+	models "github.com/ewag/gen-erics/backend/internal/models"
+)
+
+// AnonymizationStore keeps identifier mappings so remapping is consistent
+// within a project and reversible for privileged users.
+type AnonymizationStore interface {
+	// MapValue returns the existing replacement for original in the project, or
+	// stores and returns candidate if there is none yet.
+	MapValue(ctx context.Context, project, kind, original, candidate, originalLabel string) (string, error)
+	ReverseLookup(ctx context.Context, project, anonymized string) (*models.AnonymizationMapping, bool, error)
+}
+
+// MapValue implements AnonymizationStore. Concurrent callers mapping the same
+// value all get the row that won the insert.
+func (s *Store) MapValue(ctx context.Context, project, kind, original, candidate, originalLabel string) (string, error) {
+	_, err := s.pool.Exec(ctx, `
+        INSERT INTO anonymization_map (project, kind, original_value, anonymized_value, original_label)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (project, kind, original_value) DO NOTHING`,
+		project, kind, original, candidate, nullString(originalLabel))
+	if err != nil {
+		slog.ErrorContext(ctx, "Error inserting anonymization mapping", "project", project, "kind", kind, "error", err)
+		return "", fmt.Errorf("failed to store anonymization mapping: %w", err)
+	}
+
+	var mapped string
+	err = s.pool.QueryRow(ctx, `
+        SELECT anonymized_value FROM anonymization_map
+        WHERE project = $1 AND kind = $2 AND original_value = $3`, project, kind, original).Scan(&mapped)
+	if err != nil {
+		return "", fmt.Errorf("failed to read anonymization mapping: %w", err)
+	}
+	return mapped, nil
+}
+
+// ReverseLookup finds the original identifier behind an anonymized one.
+func (s *Store) ReverseLookup(ctx context.Context, project, anonymized string) (*models.AnonymizationMapping, bool, error) {
+	var m models.AnonymizationMapping
+	var label sql.NullString
+	err := s.pool.QueryRow(ctx, `
+        SELECT project, kind, original_value, anonymized_value, original_label, created_at
+        FROM anonymization_map WHERE project = $1 AND anonymized_value = $2`, project, anonymized).
+		Scan(&m.Project, &m.Kind, &m.OriginalValue, &m.AnonymizedValue, &label, &m.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, nil
+		}
+		slog.ErrorContext(ctx, "Error querying anonymization mapping", "project", project, "error", err)
+		return nil, false, fmt.Errorf("failed to query anonymization mapping: %w", err)
+	}
+	m.OriginalLabel = label.String
+	return &m, true, nil
+}
@@ -0,0 +1,85 @@
+// File: internal/storage/apikeys.go
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ewag/gen-erics/backend/internal/auth"
+)
+
+const apiKeyColumns = `id, name, scopes, created_by, created_at, expires_at, last_used_at, revoked_at`
+
+// CreateAPIKey implements auth.APIKeyStore.
+func (s *Store) CreateAPIKey(ctx context.Context, key *auth.APIKey, secretHash string) error {
+	_, err := s.pool.Exec(ctx, `
+        INSERT INTO api_keys (id, name, secret_hash, scopes, created_by, created_at, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		key.ID, key.Name, secretHash, key.Scopes, key.CreatedBy, key.CreatedAt, key.ExpiresAt)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error inserting API key", "keyID", key.ID, "error", err)
+		return fmt.Errorf("failed to store API key: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKey implements auth.APIKeyStore.
+func (s *Store) GetAPIKey(ctx context.Context, id string) (*auth.APIKey, string, bool, error) {
+	var key auth.APIKey
+	var secretHash string
+	err := s.pool.QueryRow(ctx, `SELECT `+apiKeyColumns+`, secret_hash FROM api_keys WHERE id = $1`, id).
+		Scan(&key.ID, &key.Name, &key.Scopes, &key.CreatedBy, &key.CreatedAt,
+			&key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt, &secretHash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, "", false, nil
+		}
+		slog.ErrorContext(ctx, "Error querying API key", "keyID", id, "error", err)
+		return nil, "", false, fmt.Errorf("failed to query API key: %w", err)
+	}
+	return &key, secretHash, true, nil
+}
+
+// ListAPIKeys implements auth.APIKeyStore. Secret hashes are never returned.
+func (s *Store) ListAPIKeys(ctx context.Context) ([]auth.APIKey, error) {
+	rows, err := s.pool.Query(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY created_at DESC`)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing API keys", "error", err)
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []auth.APIKey{}
+	for rows.Next() {
+		var key auth.APIKey
+		if err := rows.Scan(&key.ID, &key.Name, &key.Scopes, &key.CreatedBy, &key.CreatedAt,
+			&key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey implements auth.APIKeyStore.
+func (s *Store) RevokeAPIKey(ctx context.Context, id string, at time.Time) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `UPDATE api_keys SET revoked_at = $2 WHERE id = $1 AND revoked_at IS NULL`, id, at)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error revoking API key", "keyID", id, "error", err)
+		return false, fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// TouchAPIKey implements auth.APIKeyStore.
+func (s *Store) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	if _, err := s.pool.Exec(ctx, `UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, id, at); err != nil {
+		return fmt.Errorf("failed to update API key last use: %w", err)
+	}
+	return nil
+}
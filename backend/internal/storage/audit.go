@@ -0,0 +1,111 @@
+// File: internal/storage/audit.go
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/ewag/gen-erics/backend/internal/audit"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// AppendAuditEvent implements audit.Store. The full message is kept as JSONB;
+// the filterable fields are copied into columns.
+func (s *Store) AppendAuditEvent(ctx context.Context, e *audit.Event) (int64, error) {
+	message, err := json.Marshal(e)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode audit event: %w", err)
+	}
+	var userID, clientIP string
+	if r := e.Requestor(); r != nil {
+		userID, clientIP = r.UserID, r.NetworkAccessPointID
+	}
+	objectIDs := make([]string, 0, len(e.Objects))
+	for _, obj := range e.Objects {
+		objectIDs = append(objectIDs, obj.ID)
+	}
+
+	var id int64
+	err = s.pool.QueryRow(ctx, `
+        INSERT INTO audit_log (event_time, event_id, action_code, outcome, user_id, client_ip, object_ids, message)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id`,
+		e.Identification.DateTime, e.Identification.EventID.Code, e.Identification.ActionCode,
+		e.Identification.Outcome, userID, nullString(clientIP), objectIDs, message).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert audit event: %w", err)
+	}
+	return id, nil
+}
+
+// QueryAuditEvents implements audit.Store, newest first.
+func (s *Store) QueryAuditEvents(ctx context.Context, f audit.Filter) ([]audit.Event, error) {
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if f.UserID != "" {
+		add("user_id = $%d", f.UserID)
+	}
+	if f.EventID != "" {
+		add("event_id = $%d", f.EventID)
+	}
+	if f.ObjectID != "" {
+		add("object_ids @> ARRAY[$%d::text]", f.ObjectID)
+	}
+	if f.Outcome != nil {
+		add("outcome = $%d", *f.Outcome)
+	}
+	if !f.From.IsZero() {
+		add("event_time >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("event_time < $%d", f.To)
+	}
+	if f.BeforeID > 0 {
+		add("id < $%d", f.BeforeID)
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+	limit = min(limit, maxAuditLimit)
+
+	query := "SELECT id, message FROM audit_log"
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT %d", limit)
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error querying audit log", "error", err)
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	events := []audit.Event{}
+	for rows.Next() {
+		var id int64
+		var message []byte
+		if err := rows.Scan(&id, &message); err != nil {
+			return nil, fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		var e audit.Event
+		if err := json.Unmarshal(message, &e); err != nil {
+			return nil, fmt.Errorf("failed to decode audit event %d: %w", id, err)
+		}
+		e.ID = id
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
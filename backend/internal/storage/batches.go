@@ -0,0 +1,208 @@
+// File: internal/storage/batches.go
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+
+	models "github.com/ewag/gen-erics/backend/internal/models"
+)
+
+// maxBatchFailures caps the failed jobs listed with a batch; the rest are
+// reachable through the jobs API.
+const maxBatchFailures = 1000
+
+// BatchStore keeps bulk moves. Their progress is read from their jobs.
+type BatchStore interface {
+	// CreateBatch stores batch and fills in its ID.
+	CreateBatch(ctx context.Context, batch *models.MoveBatch) error
+	// SetBatchSkipped records the selected studies no job was queued for.
+	SetBatchSkipped(ctx context.Context, id int64, skipped []models.BatchSkip) error
+	// GetBatch returns a batch with its progress and failed jobs.
+	GetBatch(ctx context.Context, id int64) (*models.MoveBatch, bool, error)
+	// ListBatches returns batches with their progress, newest first.
+	ListBatches(ctx context.Context, limit int) ([]models.MoveBatch, error)
+}
+
+const batchColumns = `id, target_tier, target_location, study_ids, filter, reason, requested_by, created_at, skipped`
+
+// CreateBatch implements BatchStore.
+func (s *Store) CreateBatch(ctx context.Context, b *models.MoveBatch) error {
+	var filter []byte
+	if b.Filter != nil {
+		var err error
+		if filter, err = json.Marshal(b.Filter); err != nil {
+			return fmt.Errorf("failed to encode batch filter: %w", err)
+		}
+	}
+	err := s.pool.QueryRow(ctx, `
+        INSERT INTO move_batches (target_tier, target_location, study_ids, filter, reason, requested_by, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id`,
+		b.TargetTier, nullString(b.TargetLocation), b.StudyIDs, filter, nullString(b.Reason), b.RequestedBy, b.CreatedAt).Scan(&b.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error inserting move batch", "error", err)
+		return fmt.Errorf("failed to create move batch: %w", err)
+	}
+	return nil
+}
+
+// SetBatchSkipped implements BatchStore.
+func (s *Store) SetBatchSkipped(ctx context.Context, id int64, skipped []models.BatchSkip) error {
+	raw, err := json.Marshal(skipped)
+	if err != nil {
+		return fmt.Errorf("failed to encode skipped studies: %w", err)
+	}
+	if _, err := s.pool.Exec(ctx, `UPDATE move_batches SET skipped = $2 WHERE id = $1`, id, raw); err != nil {
+		slog.ErrorContext(ctx, "Error updating move batch", "batchID", id, "error", err)
+		return fmt.Errorf("failed to record skipped studies: %w", err)
+	}
+	return nil
+}
+
+// GetBatch implements BatchStore.
+func (s *Store) GetBatch(ctx context.Context, id int64) (*models.MoveBatch, bool, error) {
+	batch, err := scanBatch(s.pool.QueryRow(ctx, `SELECT `+batchColumns+` FROM move_batches WHERE id = $1`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, nil
+		}
+		slog.ErrorContext(ctx, "Error querying move batch", "batchID", id, "error", err)
+		return nil, false, fmt.Errorf("failed to query move batch: %w", err)
+	}
+	batches := []models.MoveBatch{*batch}
+	if err := s.fillBatchProgress(ctx, batches); err != nil {
+		return nil, false, err
+	}
+	*batch = batches[0]
+
+	for _, status := range []string{models.JobStatusFailed, models.JobStatusDead} {
+		failed, err := s.ListJobs(ctx, JobFilter{BatchID: id, Status: status, Limit: maxBatchFailures})
+		if err != nil {
+			return nil, false, err
+		}
+		for _, j := range failed {
+			batch.Failures = append(batch.Failures, models.BatchFailure{JobID: j.ID, StudyID: j.StudyID, Error: j.Error})
+		}
+	}
+	return batch, true, nil
+}
+
+// ListBatches implements BatchStore.
+func (s *Store) ListBatches(ctx context.Context, limit int) ([]models.MoveBatch, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := s.pool.Query(ctx, `SELECT `+batchColumns+` FROM move_batches ORDER BY id DESC LIMIT $1`, limit)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error querying move batches", "error", err)
+		return nil, fmt.Errorf("failed to query move batches: %w", err)
+	}
+	defer rows.Close()
+
+	batches := []models.MoveBatch{}
+	for rows.Next() {
+		batch, err := scanBatch(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan move batch: %w", err)
+		}
+		batches = append(batches, *batch)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate move batches: %w", err)
+	}
+	if err := s.fillBatchProgress(ctx, batches); err != nil {
+		return nil, err
+	}
+	return batches, nil
+}
+
+// fillBatchProgress counts the jobs of each batch by state, sums what the
+// finished ones moved and derives the batches' status.
+func (s *Store) fillBatchProgress(ctx context.Context, batches []models.MoveBatch) error {
+	if len(batches) == 0 {
+		return nil
+	}
+	index := make(map[int64]int, len(batches))
+	ids := make([]int64, len(batches))
+	for i, b := range batches {
+		index[b.ID] = i
+		ids[i] = b.ID
+	}
+	rows, err := s.pool.Query(ctx, `
+        SELECT batch_id, status, COUNT(*),
+            COALESCE(SUM((result->>'instances')::int), 0), COALESCE(SUM((result->>'bytes')::bigint), 0)
+        FROM jobs WHERE batch_id = ANY($1)
+        GROUP BY batch_id, status`, ids)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error querying move batch progress", "error", err)
+		return fmt.Errorf("failed to query move batch progress: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var batchID, bytes int64
+		var status string
+		var count, instances int
+		if err := rows.Scan(&batchID, &status, &count, &instances, &bytes); err != nil {
+			return fmt.Errorf("failed to scan move batch progress: %w", err)
+		}
+		p := &batches[index[batchID]].Progress
+		p.Total += count
+		p.InstancesMoved += instances
+		p.BytesMoved += bytes
+		switch status {
+		case models.JobStatusQueued:
+			p.Queued += count
+		case models.JobStatusRunning:
+			p.Running += count
+		case models.JobStatusSucceeded:
+			p.Succeeded += count
+		case models.JobStatusFailed, models.JobStatusDead:
+			p.Failed += count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate move batch progress: %w", err)
+	}
+
+	for i := range batches {
+		p := &batches[i].Progress
+		p.Skipped = len(batches[i].Skipped)
+		if p.Total > 0 {
+			p.PercentDone = float64(p.Succeeded+p.Failed) * 100 / float64(p.Total)
+		}
+		batches[i].Status = p.Status()
+	}
+	return nil
+}
+
+func scanBatch(row pgx.Row) (*models.MoveBatch, error) {
+	var b models.MoveBatch
+	var location, reason sql.NullString
+	var filter, skipped []byte
+	if err := row.Scan(&b.ID, &b.TargetTier, &location, &b.StudyIDs, &filter, &reason,
+		&b.RequestedBy, &b.CreatedAt, &skipped); err != nil {
+		return nil, err
+	}
+	b.TargetLocation = location.String
+	b.Reason = reason.String
+	if len(filter) > 0 {
+		b.Filter = &models.StudyFilter{}
+		if err := json.Unmarshal(filter, b.Filter); err != nil {
+			return nil, fmt.Errorf("failed to decode batch filter: %w", err)
+		}
+	}
+	if len(skipped) > 0 {
+		if err := json.Unmarshal(skipped, &b.Skipped); err != nil {
+			return nil, fmt.Errorf("failed to decode skipped studies: %w", err)
+		}
+	}
+	return &b, nil
+}
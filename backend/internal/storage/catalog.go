@@ -0,0 +1,355 @@
+// File: internal/storage/catalog.go
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	models "github.com/ewag/gen-erics/backend/internal/models"
+)
+
+// CatalogStore tracks instances held in non-hot tier backends and their checksums.
+type CatalogStore interface {
+	PutCatalogEntry(ctx context.Context, entry models.CatalogEntry) error
+	GetCatalogEntry(ctx context.Context, instanceID, tier string) (*models.CatalogEntry, bool, error)
+	ListCatalogEntries(ctx context.Context, studyID, tier string) ([]models.CatalogEntry, error)
+	DeleteCatalogEntries(ctx context.Context, studyID, tier string) error
+	// ListEntriesToScrub returns entries in the given tiers that were never
+	// verified or were last verified before the cutoff, oldest first.
+	ListEntriesToScrub(ctx context.Context, tiers []string, verifiedBefore time.Time, limit int) ([]models.CatalogEntry, error)
+	RecordVerification(ctx context.Context, instanceID, tier, verifyStatus string, at time.Time) error
+	PutStudyCatalogEntry(ctx context.Context, entry models.StudyCatalogEntry) error
+	GetStudyCatalogEntry(ctx context.Context, studyID string) (*models.StudyCatalogEntry, bool, error)
+	// ListCatalogEntriesBySeries returns a series' entries in whichever tier holds them.
+	ListCatalogEntriesBySeries(ctx context.Context, seriesID string) ([]models.CatalogEntry, error)
+	// ListOffloadedStudies returns studies outside the hot tier, optionally
+	// only those of one Orthanc patient ID ("" for all). Trashed studies are
+	// left out.
+	ListOffloadedStudies(ctx context.Context, patientResourceID string) ([]models.OffloadedStudy, error)
+	// StudyModalities returns the distinct modalities and body parts
+	// catalogued for a study across all tiers.
+	StudyModalities(ctx context.Context, studyID string) (modalities, bodyParts []string, err error)
+}
+
+const catalogColumns = `instance_id, study_id, series_id, sop_instance_uid, tier, object_key,
+        size_bytes, sha256, stored_at, last_verified_at, verify_status,
+        instance_number, series_number, series_description, modality, body_part,
+        number_of_frames, rows, columns, transfer_syntax_uid, original_transfer_syntax_uid,
+        encryption_key_id`
+
+// PutCatalogEntry inserts or replaces the catalog entry for an instance in a tier.
+func (s *Store) PutCatalogEntry(ctx context.Context, e models.CatalogEntry) error {
+	query := `
+        INSERT INTO instance_catalog (instance_id, study_id, series_id, sop_instance_uid, tier,
+            object_key, size_bytes, sha256, stored_at, last_verified_at, verify_status,
+            instance_number, series_number, series_description, modality, body_part,
+            number_of_frames, rows, columns, transfer_syntax_uid, original_transfer_syntax_uid,
+            encryption_key_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, CURRENT_TIMESTAMP, $9, $10, $11, $12, $13, $14, $15,
+            $16, $17, $18, $19, $20, $21)
+        ON CONFLICT (instance_id, tier) DO UPDATE SET
+            study_id = EXCLUDED.study_id,
+            series_id = EXCLUDED.series_id,
+            sop_instance_uid = EXCLUDED.sop_instance_uid,
+            object_key = EXCLUDED.object_key,
+            size_bytes = EXCLUDED.size_bytes,
+            sha256 = EXCLUDED.sha256,
+            stored_at = CURRENT_TIMESTAMP,
+            last_verified_at = EXCLUDED.last_verified_at,
+            verify_status = EXCLUDED.verify_status,
+            instance_number = EXCLUDED.instance_number,
+            series_number = EXCLUDED.series_number,
+            series_description = EXCLUDED.series_description,
+            modality = EXCLUDED.modality,
+            body_part = EXCLUDED.body_part,
+            number_of_frames = EXCLUDED.number_of_frames,
+            rows = EXCLUDED.rows,
+            columns = EXCLUDED.columns,
+            transfer_syntax_uid = EXCLUDED.transfer_syntax_uid,
+            original_transfer_syntax_uid = EXCLUDED.original_transfer_syntax_uid,
+            encryption_key_id = EXCLUDED.encryption_key_id
+    `
+	if e.VerifyStatus == "" {
+		e.VerifyStatus = models.VerifyStatusUnverified
+	}
+	_, err := s.pool.Exec(ctx, query, e.InstanceID, e.StudyID, nullString(e.SeriesID), nullString(e.SOPInstanceUID),
+		e.Tier, e.ObjectKey, e.SizeBytes, e.SHA256, e.LastVerifiedAt, e.VerifyStatus,
+		nullString(e.InstanceNumber), nullString(e.SeriesNumber), nullString(e.SeriesDescription),
+		nullString(e.Modality), nullString(e.BodyPart),
+		nullInt(e.NumberOfFrames), nullInt(e.Rows), nullInt(e.Columns), nullString(e.TransferSyntaxUID),
+		nullString(e.OriginalTransferSyntaxUID), nullString(e.EncryptionKeyID))
+	if err != nil {
+		slog.ErrorContext(ctx, "Error upserting catalog entry", "instanceID", e.InstanceID, "tier", e.Tier, "error", err)
+		return fmt.Errorf("failed to put catalog entry: %w", err)
+	}
+	return nil
+}
+
+// GetCatalogEntry returns the entry for an instance in a tier, and whether it was found.
+func (s *Store) GetCatalogEntry(ctx context.Context, instanceID, tier string) (*models.CatalogEntry, bool, error) {
+	query := `SELECT ` + catalogColumns + ` FROM instance_catalog WHERE instance_id = $1 AND tier = $2`
+	entry, err := scanCatalogEntry(s.pool.QueryRow(ctx, query, instanceID, tier))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, nil
+		}
+		slog.ErrorContext(ctx, "Error querying catalog entry", "instanceID", instanceID, "tier", tier, "error", err)
+		return nil, false, fmt.Errorf("failed to query catalog entry: %w", err)
+	}
+	return entry, true, nil
+}
+
+// ListCatalogEntries returns all entries for a study in a tier.
+func (s *Store) ListCatalogEntries(ctx context.Context, studyID, tier string) ([]models.CatalogEntry, error) {
+	query := `SELECT ` + catalogColumns + ` FROM instance_catalog
+        WHERE study_id = $1 AND tier = $2 ORDER BY series_id, instance_id`
+	return s.queryCatalogEntries(ctx, query, studyID, tier)
+}
+
+// ListCatalogEntriesBySeries implements CatalogStore.
+func (s *Store) ListCatalogEntriesBySeries(ctx context.Context, seriesID string) ([]models.CatalogEntry, error) {
+	query := `SELECT ` + catalogColumns + ` FROM instance_catalog
+        WHERE series_id = $1 ORDER BY tier, instance_id`
+	return s.queryCatalogEntries(ctx, query, seriesID)
+}
+
+// DeleteCatalogEntries removes all entries for a study in a tier.
+func (s *Store) DeleteCatalogEntries(ctx context.Context, studyID, tier string) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM instance_catalog WHERE study_id = $1 AND tier = $2`, studyID, tier)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error deleting catalog entries", "studyID", studyID, "tier", tier, "error", err)
+		return fmt.Errorf("failed to delete catalog entries: %w", err)
+	}
+	return nil
+}
+
+// ListEntriesToScrub implements CatalogStore.
+func (s *Store) ListEntriesToScrub(ctx context.Context, tiers []string, verifiedBefore time.Time, limit int) ([]models.CatalogEntry, error) {
+	query := `SELECT ` + catalogColumns + ` FROM instance_catalog
+        WHERE tier = ANY($1) AND (last_verified_at IS NULL OR last_verified_at < $2)
+        ORDER BY last_verified_at NULLS FIRST
+        LIMIT $3`
+	return s.queryCatalogEntries(ctx, query, tiers, verifiedBefore, limit)
+}
+
+// RecordVerification stores the outcome of a checksum verification.
+func (s *Store) RecordVerification(ctx context.Context, instanceID, tier, verifyStatus string, at time.Time) error {
+	_, err := s.pool.Exec(ctx, `
+        UPDATE instance_catalog SET last_verified_at = $3, verify_status = $4
+        WHERE instance_id = $1 AND tier = $2`, instanceID, tier, at, verifyStatus)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error recording verification", "instanceID", instanceID, "tier", tier, "error", err)
+		return fmt.Errorf("failed to record verification: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) queryCatalogEntries(ctx context.Context, query string, args ...any) ([]models.CatalogEntry, error) {
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error querying catalog entries", "error", err)
+		return nil, fmt.Errorf("failed to query catalog entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []models.CatalogEntry
+	for rows.Next() {
+		entry, err := scanCatalogEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan catalog entry: %w", err)
+		}
+		entries = append(entries, *entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate catalog entries: %w", err)
+	}
+	return entries, nil
+}
+
+func scanCatalogEntry(row pgx.Row) (*models.CatalogEntry, error) {
+	var e models.CatalogEntry
+	var seriesID, sopUID, instanceNumber, seriesNumber, seriesDescription, modality, bodyPart, transferSyntax, originalSyntax, encryptionKeyID sql.NullString
+	var frames, rows, columns sql.NullInt32
+	if err := row.Scan(&e.InstanceID, &e.StudyID, &seriesID, &sopUID, &e.Tier, &e.ObjectKey,
+		&e.SizeBytes, &e.SHA256, &e.StoredAt, &e.LastVerifiedAt, &e.VerifyStatus,
+		&instanceNumber, &seriesNumber, &seriesDescription, &modality, &bodyPart,
+		&frames, &rows, &columns, &transferSyntax, &originalSyntax, &encryptionKeyID); err != nil {
+		return nil, err
+	}
+	e.NumberOfFrames = int(frames.Int32)
+	e.Rows = int(rows.Int32)
+	e.Columns = int(columns.Int32)
+	e.TransferSyntaxUID = transferSyntax.String
+	e.OriginalTransferSyntaxUID = originalSyntax.String
+	e.EncryptionKeyID = encryptionKeyID.String
+	e.SeriesID = seriesID.String
+	e.SOPInstanceUID = sopUID.String
+	e.InstanceNumber = instanceNumber.String
+	e.SeriesNumber = seriesNumber.String
+	e.SeriesDescription = seriesDescription.String
+	e.Modality = modality.String
+	e.BodyPart = bodyPart.String
+	return &e, nil
+}
+
+// PutStudyCatalogEntry inserts or replaces the patient/study tags for a study.
+func (s *Store) PutStudyCatalogEntry(ctx context.Context, e models.StudyCatalogEntry) error {
+	query := `
+        INSERT INTO study_catalog (study_id, study_instance_uid, patient_id, patient_name,
+            patient_birth_date, patient_sex, study_date, study_description, accession_number,
+            patient_resource_id, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, CURRENT_TIMESTAMP)
+        ON CONFLICT (study_id) DO UPDATE SET
+            study_instance_uid = EXCLUDED.study_instance_uid,
+            patient_resource_id = EXCLUDED.patient_resource_id,
+            patient_id = EXCLUDED.patient_id,
+            patient_name = EXCLUDED.patient_name,
+            patient_birth_date = EXCLUDED.patient_birth_date,
+            patient_sex = EXCLUDED.patient_sex,
+            study_date = EXCLUDED.study_date,
+            study_description = EXCLUDED.study_description,
+            accession_number = EXCLUDED.accession_number,
+            updated_at = CURRENT_TIMESTAMP
+    `
+	_, err := s.pool.Exec(ctx, query, e.StudyID, nullString(e.StudyInstanceUID), nullString(e.PatientID),
+		nullString(e.PatientName), nullString(e.PatientBirthDate), nullString(e.PatientSex),
+		nullString(e.StudyDate), nullString(e.StudyDescription), nullString(e.AccessionNumber),
+		nullString(e.PatientResourceID))
+	if err != nil {
+		slog.ErrorContext(ctx, "Error upserting study catalog entry", "studyID", e.StudyID, "error", err)
+		return fmt.Errorf("failed to put study catalog entry: %w", err)
+	}
+	return nil
+}
+
+const studyCatalogColumns = `study_id, study_instance_uid, patient_id, patient_name, patient_birth_date,
+        patient_sex, study_date, study_description, accession_number, updated_at, patient_resource_id`
+
+// GetStudyCatalogEntry returns the catalogued tags for a study, and whether it was found.
+func (s *Store) GetStudyCatalogEntry(ctx context.Context, studyID string) (*models.StudyCatalogEntry, bool, error) {
+	query := `SELECT ` + studyCatalogColumns + ` FROM study_catalog WHERE study_id = $1`
+	entry, err := scanStudyCatalogEntry(s.pool.QueryRow(ctx, query, studyID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, nil
+		}
+		slog.ErrorContext(ctx, "Error querying study catalog entry", "studyID", studyID, "error", err)
+		return nil, false, fmt.Errorf("failed to query study catalog entry: %w", err)
+	}
+	return entry, true, nil
+}
+
+func scanStudyCatalogEntry(row pgx.Row) (*models.StudyCatalogEntry, error) {
+	var e models.StudyCatalogEntry
+	var uid, patientID, patientName, birthDate, sex, studyDate, description, accession, patientResourceID sql.NullString
+	if err := row.Scan(&e.StudyID, &uid, &patientID, &patientName, &birthDate, &sex,
+		&studyDate, &description, &accession, &e.UpdatedAt, &patientResourceID); err != nil {
+		return nil, err
+	}
+	e.PatientResourceID = patientResourceID.String
+	e.StudyInstanceUID = uid.String
+	e.PatientID = patientID.String
+	e.PatientName = patientName.String
+	e.PatientBirthDate = birthDate.String
+	e.PatientSex = sex.String
+	e.StudyDate = studyDate.String
+	e.StudyDescription = description.String
+	e.AccessionNumber = accession.String
+	return &e, nil
+}
+
+// ListOffloadedStudies implements CatalogStore. Tier comes from study_status,
+// totals from the instance catalog of that tier.
+func (s *Store) ListOffloadedStudies(ctx context.Context, patientResourceID string) ([]models.OffloadedStudy, error) {
+	studies, err := s.queryOffloadedStudies(ctx, `$1 = '' OR c.patient_resource_id = $1`, "", patientResourceID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error querying offloaded studies", "patientResourceID", patientResourceID, "error", err)
+		return nil, err
+	}
+	return studies, nil
+}
+
+// queryOffloadedStudies returns the non-hot, untrashed studies matching
+// where (over study_catalog c and study_status st), newest first, followed
+// by the SQL in suffix (e.g. a LIMIT).
+func (s *Store) queryOffloadedStudies(ctx context.Context, where, suffix string, args ...any) ([]models.OffloadedStudy, error) {
+	query := `
+        SELECT ` + prefixColumns("c.", studyCatalogColumns) + `, st.tier,
+            COUNT(DISTINCT i.series_id), COUNT(i.instance_id), COALESCE(SUM(i.size_bytes), 0)
+        FROM study_catalog c
+        JOIN study_status st ON st.study_instance_uid = c.study_id
+        LEFT JOIN instance_catalog i ON i.study_id = c.study_id AND i.tier = st.tier
+        WHERE st.tier NOT IN ('hot', 'trash') AND (` + where + `)
+        GROUP BY c.study_id, st.tier
+        ORDER BY c.study_date DESC NULLS LAST, c.study_id ` + suffix
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query offloaded studies: %w", err)
+	}
+	defer rows.Close()
+
+	var studies []models.OffloadedStudy
+	for rows.Next() {
+		var st models.OffloadedStudy
+		var uid, patientID, patientName, birthDate, sex, studyDate, description, accession, resourceID sql.NullString
+		if err := rows.Scan(&st.StudyID, &uid, &patientID, &patientName, &birthDate, &sex,
+			&studyDate, &description, &accession, &st.UpdatedAt, &resourceID,
+			&st.Tier, &st.SeriesCount, &st.InstanceCount, &st.TotalSizeBytes); err != nil {
+			return nil, fmt.Errorf("failed to scan offloaded study: %w", err)
+		}
+		st.StudyInstanceUID = uid.String
+		st.PatientID = patientID.String
+		st.PatientName = patientName.String
+		st.PatientBirthDate = birthDate.String
+		st.PatientSex = sex.String
+		st.StudyDate = studyDate.String
+		st.StudyDescription = description.String
+		st.AccessionNumber = accession.String
+		st.PatientResourceID = resourceID.String
+		studies = append(studies, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate offloaded studies: %w", err)
+	}
+	return studies, nil
+}
+
+// StudyModalities returns the distinct non-empty modalities and body parts
+// of a study's catalogued instances.
+func (s *Store) StudyModalities(ctx context.Context, studyID string) ([]string, []string, error) {
+	query := `
+        SELECT COALESCE(array_agg(DISTINCT modality) FILTER (WHERE modality <> ''), '{}'),
+            COALESCE(array_agg(DISTINCT body_part) FILTER (WHERE body_part <> ''), '{}')
+        FROM instance_catalog WHERE study_id = $1`
+	var modalities, bodyParts []string
+	if err := s.pool.QueryRow(ctx, query, studyID).Scan(&modalities, &bodyParts); err != nil {
+		slog.ErrorContext(ctx, "Error querying study modalities", "studyID", studyID, "error", err)
+		return nil, nil, fmt.Errorf("failed to query modalities of study %s: %w", studyID, err)
+	}
+	return modalities, bodyParts, nil
+}
+
+// prefixColumns qualifies a comma-separated column list with a table alias.
+func prefixColumns(prefix, columns string) string {
+	parts := strings.Split(columns, ",")
+	for i, col := range parts {
+		parts[i] = prefix + strings.TrimSpace(col)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// nullString maps "" to SQL NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullInt(n int) sql.NullInt32 {
+	return sql.NullInt32{Int32: int32(n), Valid: n != 0}
+}
@@ -0,0 +1,103 @@
+// File: internal/storage/datakeys.go
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ewag/gen-erics/backend/internal/tiers"
+)
+
+const dataKeyColumns = `key_id, study_id, wrapped_key, master_key_id, created_at, rotated_at`
+
+// GetStudyDataKey implements tiers.DataKeyStore.
+func (s *Store) GetStudyDataKey(ctx context.Context, studyID string) (*tiers.DataKey, bool, error) {
+	return s.getDataKey(ctx, `SELECT `+dataKeyColumns+` FROM tier_data_keys WHERE study_id = $1`, studyID)
+}
+
+// GetDataKey implements tiers.DataKeyStore.
+func (s *Store) GetDataKey(ctx context.Context, id string) (*tiers.DataKey, bool, error) {
+	return s.getDataKey(ctx, `SELECT `+dataKeyColumns+` FROM tier_data_keys WHERE key_id = $1`, id)
+}
+
+func (s *Store) getDataKey(ctx context.Context, query, arg string) (*tiers.DataKey, bool, error) {
+	key, err := scanDataKey(s.pool.QueryRow(ctx, query, arg))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, nil
+		}
+		slog.ErrorContext(ctx, "Error querying data key", "key", arg, "error", err)
+		return nil, false, fmt.Errorf("failed to query data key: %w", err)
+	}
+	return key, true, nil
+}
+
+// CreateDataKey implements tiers.DataKeyStore. Concurrent callers creating a
+// key for the same study all get the row that won the insert.
+func (s *Store) CreateDataKey(ctx context.Context, key tiers.DataKey) (*tiers.DataKey, error) {
+	_, err := s.pool.Exec(ctx, `
+        INSERT INTO tier_data_keys (key_id, study_id, wrapped_key, master_key_id, created_at)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (study_id) DO NOTHING`,
+		key.ID, key.StudyID, key.Wrapped, key.MasterKeyID, key.CreatedAt)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error inserting data key", "studyID", key.StudyID, "error", err)
+		return nil, fmt.Errorf("failed to store data key: %w", err)
+	}
+	stored, found, err := s.GetStudyDataKey(ctx, key.StudyID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("data key for study %s vanished after insert", key.StudyID)
+	}
+	return stored, nil
+}
+
+// ListDataKeysToRewrap implements tiers.DataKeyStore.
+func (s *Store) ListDataKeysToRewrap(ctx context.Context, masterKeyID, afterID string, limit int) ([]tiers.DataKey, error) {
+	rows, err := s.pool.Query(ctx, `
+        SELECT `+dataKeyColumns+` FROM tier_data_keys
+        WHERE master_key_id <> $1 AND key_id > $2
+        ORDER BY key_id LIMIT $3`, masterKeyID, afterID, limit)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing data keys to re-wrap", "error", err)
+		return nil, fmt.Errorf("failed to list data keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []tiers.DataKey
+	for rows.Next() {
+		key, err := scanDataKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan data key: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// RewrapDataKey implements tiers.DataKeyStore.
+func (s *Store) RewrapDataKey(ctx context.Context, id string, wrapped []byte, masterKeyID, previousMasterKeyID string, at time.Time) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `
+        UPDATE tier_data_keys SET wrapped_key = $2, master_key_id = $3, rotated_at = $5
+        WHERE key_id = $1 AND master_key_id = $4`, id, wrapped, masterKeyID, previousMasterKeyID, at)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error re-wrapping data key", "keyID", id, "error", err)
+		return false, fmt.Errorf("failed to update data key: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func scanDataKey(row pgx.Row) (*tiers.DataKey, error) {
+	var key tiers.DataKey
+	if err := row.Scan(&key.ID, &key.StudyID, &key.Wrapped, &key.MasterKeyID, &key.CreatedAt, &key.RotatedAt); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
@@ -0,0 +1,77 @@
+// File: internal/storage/hl7.go
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	models "github.com/ewag/gen-erics/backend/internal/models"
+)
+
+// HL7MessageFilter narrows ListHL7Messages; zero values match everything.
+type HL7MessageFilter struct {
+	MessageType string
+	Status      string
+	ControlID   string
+	Limit       int
+}
+
+// HL7Store is the log of inbound HL7 messages.
+type HL7Store interface {
+	// LogHL7Message stores msg and sets its ID.
+	LogHL7Message(ctx context.Context, msg *models.HL7Message) error
+	ListHL7Messages(ctx context.Context, f HL7MessageFilter) ([]models.HL7Message, error)
+}
+
+// LogHL7Message implements HL7Store.
+func (s *Store) LogHL7Message(ctx context.Context, m *models.HL7Message) error {
+	err := s.pool.QueryRow(ctx, `
+        INSERT INTO hl7_messages (received_at, remote_addr, control_id, message_type, status,
+            ack_code, error, result, raw)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        RETURNING id`,
+		m.ReceivedAt, m.RemoteAddr, nullString(m.ControlID), nullString(m.MessageType), m.Status,
+		m.AckCode, nullString(m.Error), []byte(m.Result), m.Raw).Scan(&m.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error logging HL7 message", "controlID", m.ControlID, "error", err)
+		return fmt.Errorf("failed to log HL7 message: %w", err)
+	}
+	return nil
+}
+
+// ListHL7Messages implements HL7Store, newest first.
+func (s *Store) ListHL7Messages(ctx context.Context, f HL7MessageFilter) ([]models.HL7Message, error) {
+	rows, err := s.pool.Query(ctx, `
+        SELECT id, received_at, remote_addr, control_id, message_type, status, ack_code, error, result, raw
+        FROM hl7_messages
+        WHERE ($1 = '' OR message_type = $1) AND ($2 = '' OR status = $2) AND ($3 = '' OR control_id = $3)
+        ORDER BY id DESC
+        LIMIT $4`, f.MessageType, f.Status, f.ControlID, f.Limit)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error querying HL7 messages", "error", err)
+		return nil, fmt.Errorf("failed to query HL7 messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []models.HL7Message
+	for rows.Next() {
+		var m models.HL7Message
+		var controlID, messageType, errMsg sql.NullString
+		var result []byte
+		if err := rows.Scan(&m.ID, &m.ReceivedAt, &m.RemoteAddr, &controlID, &messageType, &m.Status,
+			&m.AckCode, &errMsg, &result, &m.Raw); err != nil {
+			return nil, fmt.Errorf("failed to scan HL7 message: %w", err)
+		}
+		m.ControlID = controlID.String
+		m.MessageType = messageType.String
+		m.Error = errMsg.String
+		m.Result = result
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate HL7 messages: %w", err)
+	}
+	return messages, nil
+}
@@ -0,0 +1,433 @@
+// File: internal/storage/jobs.go
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	models "github.com/ewag/gen-erics/backend/internal/models"
+)
+
+// JobFilter selects jobs for the jobs API. Zero values match everything.
+type JobFilter struct {
+	Status   string
+	StudyID  string
+	Source   string
+	Priority string
+	BatchID  int64
+	Limit    int
+}
+
+// ErrLeaseLost is returned when a worker updates a job it no longer holds,
+// e.g. because its lease expired and another worker took the job over.
+var ErrLeaseLost = errors.New("job lease lost")
+
+// ErrJobNotRetryable is returned when requeueing a job that hasn't failed or
+// whose study has another active job.
+var ErrJobNotRetryable = errors.New("job can't be retried")
+
+// ClaimOptions say who claims a job and what caps apply.
+type ClaimOptions struct {
+	WorkerID string
+	// Limits caps the running jobs per resource ("edge:edge-a"); "edge:*"
+	// and "tier:*" apply to each edge or tier without a cap of its own.
+	Limits map[string]int
+}
+
+// JobStore is the persistent job queue, shared by every replica.
+type JobStore interface {
+	// EnqueueJob queues job unless its study already has a queued or running
+	// job; either way job is filled in from the stored row and created says
+	// which happened.
+	EnqueueJob(ctx context.Context, job *models.Job) (created bool, err error)
+	// ClaimJob marks the next due queued job running under opts.WorkerID and
+	// returns it: most urgent first, then from the batch with the fewest
+	// running jobs, then oldest, skipping jobs whose resources are at their cap.
+	ClaimJob(ctx context.Context, opts ClaimOptions) (*models.Job, bool, error)
+	// HeartbeatJob renews the lease of a running job, or returns ErrLeaseLost.
+	HeartbeatJob(ctx context.Context, id int64, workerID string) error
+	// DeferJob puts a claimed job back in the queue until notBefore.
+	DeferJob(ctx context.Context, id int64, workerID string, notBefore time.Time) error
+	// FinishJob records the outcome of a claimed job, or returns ErrLeaseLost.
+	// failure, nil for a success, is counted as an attempt and added to the
+	// job's error history.
+	FinishJob(ctx context.Context, id int64, workerID, status string, result []byte, failure *models.JobAttempt) error
+	// RetryJob records a failed attempt of a claimed job and puts it back in
+	// the queue until notBefore.
+	RetryJob(ctx context.Context, id int64, workerID string, notBefore time.Time, failure models.JobAttempt) error
+	// RequeueJob queues a failed or dead job again with a fresh set of
+	// attempts, keeping its error history.
+	RequeueJob(ctx context.Context, id int64) (*models.Job, bool, error)
+	// RequeueExpiredJobs puts running jobs whose last heartbeat is older
+	// than staleBefore back in the queue; their worker is presumed dead.
+	// Each counts as a failed attempt, so a job that keeps crashing or
+	// hanging its worker is dead-lettered once it has had maxAttempts. It
+	// returns the expired jobs as they now are.
+	RequeueExpiredJobs(ctx context.Context, staleBefore time.Time, maxAttempts int) ([]models.Job, error)
+	GetJob(ctx context.Context, id int64) (*models.Job, bool, error)
+	ListJobs(ctx context.Context, f JobFilter) ([]models.Job, error)
+}
+
+// FeedCursorStore remembers how far a change feed has been read.
+type FeedCursorStore interface {
+	GetFeedCursor(ctx context.Context, name string) (int64, bool, error)
+	SetFeedCursor(ctx context.Context, name string, position int64) error
+}
+
+const jobColumns = `id, kind, study_id, target_tier, target_location, status, source, reason,
+        requested_by, created_at, started_at, finished_at, error, result, not_before, window_required, batch_id,
+        priority, resources, claimed_by, heartbeat_at, attempts, error_history`
+
+// claimLockKey serializes claims across replicas (pg_advisory_xact_lock), so
+// two of them can't both take the last free slot under a concurrency cap.
+const claimLockKey = 0x6a6f6273 // "jobs"
+
+// EnqueueJob implements JobStore.
+func (s *Store) EnqueueJob(ctx context.Context, job *models.Job) (bool, error) {
+	stored, err := scanJob(s.pool.QueryRow(ctx, `
+        INSERT INTO jobs (kind, study_id, target_tier, target_location, status, source, reason, requested_by, created_at,
+            not_before, window_required, batch_id, priority, resources)
+        VALUES ($1, $2, $3, $4, 'queued', $5, $6, $7, $8, $9, $10, $11, $12, $13)
+        ON CONFLICT (study_id) WHERE status IN ('queued', 'running') DO NOTHING
+        RETURNING `+jobColumns,
+		job.Kind, job.StudyID, job.TargetTier, nullString(job.TargetLocation), job.Source, nullString(job.Reason),
+		job.RequestedBy, job.CreatedAt, job.NotBefore, job.WindowRequired, job.BatchID, job.Priority, job.Resources))
+	if err == nil {
+		*job = *stored
+		return true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		slog.ErrorContext(ctx, "Error inserting job", "studyID", job.StudyID, "error", err)
+		return false, fmt.Errorf("failed to enqueue job: %w", err)
+	}
+
+	stored, err = scanJob(s.pool.QueryRow(ctx, `
+        SELECT `+jobColumns+` FROM jobs
+        WHERE study_id = $1 AND status IN ('queued', 'running')`, job.StudyID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// The active job finished in between; the caller may simply retry
+			return false, fmt.Errorf("active job for study %s finished while enqueueing", job.StudyID)
+		}
+		return false, fmt.Errorf("failed to query active job: %w", err)
+	}
+	*job = *stored
+	return false, nil
+}
+
+// ClaimJob implements JobStore. Claims run one at a time across replicas
+// under an advisory lock; SKIP LOCKED keeps them clear of rows other
+// transactions hold.
+func (s *Store) ClaimJob(ctx context.Context, opts ClaimOptions) (*models.Job, bool, error) {
+	names := make([]string, 0, len(opts.Limits))
+	limits := make([]int32, 0, len(opts.Limits))
+	for name, limit := range opts.Limits {
+		names = append(names, name)
+		limits = append(limits, int32(limit))
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin claim transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) // No-op after commit
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, int64(claimLockKey)); err != nil {
+		return nil, false, fmt.Errorf("failed to lock job queue: %w", err)
+	}
+	job, err := scanJob(tx.QueryRow(ctx, `
+        WITH running AS (
+            SELECT r, COUNT(*) AS n FROM jobs, unnest(resources) AS r
+            WHERE status = 'running' GROUP BY r),
+        batch_running AS (
+            SELECT batch_id, COUNT(*) AS n FROM jobs
+            WHERE status = 'running' AND batch_id IS NOT NULL GROUP BY batch_id),
+        limits AS (
+            SELECT * FROM unnest($2::text[], $3::int[]) AS l(name, max_running)),
+        next AS (
+            SELECT j.id FROM jobs j
+            LEFT JOIN batch_running b ON b.batch_id = j.batch_id
+            WHERE j.status = 'queued' AND (j.not_before IS NULL OR j.not_before <= $1)
+              AND NOT EXISTS (
+                SELECT 1 FROM unnest(j.resources) AS jr(r)
+                JOIN running ON running.r = jr.r
+                WHERE running.n >= COALESCE(
+                    (SELECT max_running FROM limits WHERE name = jr.r),
+                    (SELECT max_running FROM limits WHERE name = split_part(jr.r, ':', 1) || ':*')))
+            ORDER BY CASE j.priority WHEN 'stat' THEN 0 WHEN 'routine' THEN 1 ELSE 2 END,
+                COALESCE(b.n, 0), j.id
+            LIMIT 1
+            FOR UPDATE OF j SKIP LOCKED)
+        UPDATE jobs SET status = 'running', started_at = $1, claimed_by = $4, heartbeat_at = $1
+        WHERE id = (SELECT id FROM next)
+        RETURNING `+jobColumns, time.Now(), names, limits, opts.WorkerID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, nil
+		}
+		slog.ErrorContext(ctx, "Error claiming job", "error", err)
+		return nil, false, fmt.Errorf("failed to claim job: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, false, fmt.Errorf("failed to commit job claim: %w", err)
+	}
+	return job, true, nil
+}
+
+// HeartbeatJob implements JobStore.
+func (s *Store) HeartbeatJob(ctx context.Context, id int64, workerID string) error {
+	tag, err := s.pool.Exec(ctx, `
+        UPDATE jobs SET heartbeat_at = $3
+        WHERE id = $1 AND status = 'running' AND claimed_by = $2`, id, workerID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to renew job lease: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: job %d", ErrLeaseLost, id)
+	}
+	return nil
+}
+
+// DeferJob implements JobStore.
+func (s *Store) DeferJob(ctx context.Context, id int64, workerID string, notBefore time.Time) error {
+	tag, err := s.pool.Exec(ctx, `
+        UPDATE jobs SET status = 'queued', started_at = NULL, claimed_by = NULL, heartbeat_at = NULL, not_before = $3
+        WHERE id = $1 AND status = 'running' AND claimed_by = $2`, id, workerID, notBefore)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error deferring job", "jobID", id, "error", err)
+		return fmt.Errorf("failed to defer job: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: job %d", ErrLeaseLost, id)
+	}
+	return nil
+}
+
+// FinishJob implements JobStore.
+func (s *Store) FinishJob(ctx context.Context, id int64, workerID, status string, result []byte, failure *models.JobAttempt) error {
+	var errMsg string
+	var history []byte
+	if failure != nil {
+		errMsg = failure.Error
+		var err error
+		if history, err = json.Marshal([]models.JobAttempt{*failure}); err != nil {
+			return fmt.Errorf("failed to encode job attempt: %w", err)
+		}
+	}
+	tag, err := s.pool.Exec(ctx, `
+        UPDATE jobs SET status = $3, finished_at = $4, result = $5, error = $6,
+            attempts = attempts + CASE WHEN $7::jsonb IS NULL THEN 0 ELSE 1 END,
+            error_history = CASE WHEN $7::jsonb IS NULL THEN error_history
+                ELSE COALESCE(error_history, '[]'::jsonb) || $7::jsonb END
+        WHERE id = $1 AND status = 'running' AND claimed_by = $2`,
+		id, workerID, status, time.Now(), result, nullString(errMsg), history)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finishing job", "jobID", id, "error", err)
+		return fmt.Errorf("failed to finish job: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: job %d", ErrLeaseLost, id)
+	}
+	return nil
+}
+
+// RetryJob implements JobStore.
+func (s *Store) RetryJob(ctx context.Context, id int64, workerID string, notBefore time.Time, failure models.JobAttempt) error {
+	history, err := json.Marshal([]models.JobAttempt{failure})
+	if err != nil {
+		return fmt.Errorf("failed to encode job attempt: %w", err)
+	}
+	tag, err := s.pool.Exec(ctx, `
+        UPDATE jobs SET status = 'queued', started_at = NULL, claimed_by = NULL, heartbeat_at = NULL, not_before = $3,
+            error = $4, attempts = attempts + 1, error_history = COALESCE(error_history, '[]'::jsonb) || $5::jsonb
+        WHERE id = $1 AND status = 'running' AND claimed_by = $2`, id, workerID, notBefore, failure.Error, history)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error requeueing failed job", "jobID", id, "error", err)
+		return fmt.Errorf("failed to requeue job for retry: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: job %d", ErrLeaseLost, id)
+	}
+	return nil
+}
+
+// RequeueJob implements JobStore.
+func (s *Store) RequeueJob(ctx context.Context, id int64) (*models.Job, bool, error) {
+	job, err := scanJob(s.pool.QueryRow(ctx, `
+        UPDATE jobs j SET status = 'queued', attempts = 0, not_before = NULL, started_at = NULL, finished_at = NULL,
+            result = NULL, error = NULL
+        WHERE id = $1 AND status IN ('failed', 'dead')
+          AND NOT EXISTS (SELECT 1 FROM jobs a WHERE a.study_id = j.study_id AND a.status IN ('queued', 'running'))
+        RETURNING `+jobColumns, id))
+	if err == nil {
+		return job, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		slog.ErrorContext(ctx, "Error requeueing job", "jobID", id, "error", err)
+		return nil, false, fmt.Errorf("failed to requeue job: %w", err)
+	}
+
+	job, found, err := s.GetJob(ctx, id)
+	switch {
+	case err != nil || !found:
+		return nil, found, err
+	case job.Status != models.JobStatusFailed && job.Status != models.JobStatusDead:
+		return nil, true, fmt.Errorf("%w: it is %s", ErrJobNotRetryable, job.Status)
+	default:
+		return nil, true, fmt.Errorf("%w: study %s has another active job", ErrJobNotRetryable, job.StudyID)
+	}
+}
+
+// RequeueExpiredJobs implements JobStore. Jobs running from before leases
+// existed have no heartbeat and count as expired.
+func (s *Store) RequeueExpiredJobs(ctx context.Context, staleBefore time.Time, maxAttempts int) ([]models.Job, error) {
+	const failure = "lease expired: the worker stopped sending heartbeats"
+	rows, err := s.pool.Query(ctx, `
+        UPDATE jobs SET
+            status = CASE WHEN attempts + 1 >= $2 THEN 'dead' ELSE 'queued' END,
+            started_at = CASE WHEN attempts + 1 >= $2 THEN started_at END,
+            finished_at = CASE WHEN attempts + 1 >= $2 THEN $3::timestamptz END,
+            claimed_by = NULL, heartbeat_at = NULL, error = $4, attempts = attempts + 1,
+            error_history = COALESCE(error_history, '[]'::jsonb) ||
+                jsonb_build_array(jsonb_build_object('attempt', attempts + 1, 'at', $3::timestamptz, 'error', $4::text))
+        WHERE status = 'running' AND (heartbeat_at IS NULL OR heartbeat_at < $1)
+        RETURNING `+jobColumns, staleBefore, maxAttempts, time.Now(), failure)
+	if err != nil {
+		return nil, fmt.Errorf("failed to requeue expired jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []models.Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan expired job: %w", err)
+		}
+		jobs = append(jobs, *job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to requeue expired jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// GetJob implements JobStore.
+func (s *Store) GetJob(ctx context.Context, id int64) (*models.Job, bool, error) {
+	job, err := scanJob(s.pool.QueryRow(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = $1`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, nil
+		}
+		slog.ErrorContext(ctx, "Error querying job", "jobID", id, "error", err)
+		return nil, false, fmt.Errorf("failed to query job: %w", err)
+	}
+	return job, true, nil
+}
+
+// ListJobs implements JobStore, newest first.
+func (s *Store) ListJobs(ctx context.Context, f JobFilter) ([]models.Job, error) {
+	var where []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if f.Status != "" {
+		add("status = $%d", f.Status)
+	}
+	if f.StudyID != "" {
+		add("study_id = $%d", f.StudyID)
+	}
+	if f.Source != "" {
+		add("source = $%d", f.Source)
+	}
+	if f.Priority != "" {
+		add("priority = $%d", f.Priority)
+	}
+	if f.BatchID != 0 {
+		add("batch_id = $%d", f.BatchID)
+	}
+	query := `SELECT ` + jobColumns + ` FROM jobs`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+	args = append(args, f.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error querying jobs", "error", err)
+		return nil, fmt.Errorf("failed to query jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []models.Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, *job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+func scanJob(row pgx.Row) (*models.Job, error) {
+	var j models.Job
+	var location, reason, errMsg, claimedBy sql.NullString
+	var result, history []byte
+	if err := row.Scan(&j.ID, &j.Kind, &j.StudyID, &j.TargetTier, &location, &j.Status, &j.Source, &reason,
+		&j.RequestedBy, &j.CreatedAt, &j.StartedAt, &j.FinishedAt, &errMsg, &result, &j.NotBefore, &j.WindowRequired, &j.BatchID,
+		&j.Priority, &j.Resources, &claimedBy, &j.HeartbeatAt, &j.Attempts, &history); err != nil {
+		return nil, err
+	}
+	if len(history) > 0 {
+		if err := json.Unmarshal(history, &j.ErrorHistory); err != nil {
+			return nil, fmt.Errorf("failed to decode job error history: %w", err)
+		}
+	}
+	j.ClaimedBy = claimedBy.String
+	j.TargetLocation = location.String
+	j.Reason = reason.String
+	j.Error = errMsg.String
+	j.Result = result
+	return &j, nil
+}
+
+// GetFeedCursor implements FeedCursorStore.
+func (s *Store) GetFeedCursor(ctx context.Context, name string) (int64, bool, error) {
+	var position int64
+	err := s.pool.QueryRow(ctx, `SELECT position FROM feed_cursors WHERE name = $1`, name).Scan(&position)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("failed to query feed cursor: %w", err)
+	}
+	return position, true, nil
+}
+
+// SetFeedCursor implements FeedCursorStore.
+func (s *Store) SetFeedCursor(ctx context.Context, name string, position int64) error {
+	_, err := s.pool.Exec(ctx, `
+        INSERT INTO feed_cursors (name, position, updated_at) VALUES ($1, $2, CURRENT_TIMESTAMP)
+        ON CONFLICT (name) DO UPDATE SET position = EXCLUDED.position, updated_at = EXCLUDED.updated_at`, name, position)
+	if err != nil {
+		return fmt.Errorf("failed to store feed cursor: %w", err)
+	}
+	return nil
+}
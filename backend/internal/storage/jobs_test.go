@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	models "github.com/ewag/gen-erics/backend/internal/models"
+)
+
+// testStore connects to the database in TEST_DATABASE_URL and creates the
+// schema in a scratch Postgres schema dropped after the test. Tests using it
+// are skipped without that variable.
+func testStore(t *testing.T) (*Store, *pgxpool.Pool) {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+	admin, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		admin.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+		admin.Close()
+	})
+
+	cfg, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	if err := EnsureSchema(ctx, pool); err != nil {
+		t.Fatal(err)
+	}
+	return NewStore(pool), pool
+}
+
+func TestClaimJobOrderAndLimits(t *testing.T) {
+	store, pool := testStore(t)
+	ctx := context.Background()
+
+	type queued struct {
+		study     string
+		priority  string
+		resources []string
+		batch     int  // 1-based batch of the test, 0 for none
+		running   bool // Already claimed by another worker
+		later     bool // Scheduled an hour from now
+	}
+	tests := []struct {
+		name   string
+		limits map[string]int
+		jobs   []queued
+		want   []string // Studies in claim order, until nothing is claimable
+	}{
+		{name: "priority before age", jobs: []queued{
+			{study: "routine", priority: "routine"},
+			{study: "background", priority: "background"},
+			{study: "stat", priority: "stat"},
+		}, want: []string{"stat", "routine", "background"}},
+		{name: "oldest first within a priority", jobs: []queued{
+			{study: "a", priority: "routine"},
+			{study: "b", priority: "routine"},
+		}, want: []string{"a", "b"}},
+		{name: "edge wildcard cap", limits: map[string]int{"edge:*": 1}, jobs: []queued{
+			{study: "a", priority: "routine", resources: []string{"tier:hot", "edge:e1"}},
+			{study: "b", priority: "routine", resources: []string{"tier:hot", "edge:e1"}},
+			{study: "c", priority: "routine", resources: []string{"tier:hot", "edge:e2"}},
+		}, want: []string{"a", "c"}},
+		{name: "exact cap overrides the wildcard", limits: map[string]int{"edge:*": 1, "edge:e1": 2}, jobs: []queued{
+			{study: "a", priority: "routine", resources: []string{"edge:e1"}},
+			{study: "b", priority: "routine", resources: []string{"edge:e1"}},
+			{study: "c", priority: "routine", resources: []string{"edge:e1"}},
+		}, want: []string{"a", "b"}},
+		{name: "any capped resource blocks", limits: map[string]int{"tier:archive": 1}, jobs: []queued{
+			{study: "a", priority: "routine", resources: []string{"tier:cold", "tier:archive"}},
+			{study: "b", priority: "routine", resources: []string{"tier:archive", "tier:hot"}},
+			{study: "c", priority: "routine", resources: []string{"tier:cold"}},
+		}, want: []string{"a", "c"}},
+		{name: "stat waits for a full edge", limits: map[string]int{"edge:*": 1}, jobs: []queued{
+			{study: "busy", priority: "routine", resources: []string{"edge:e1"}, running: true},
+			{study: "stat", priority: "stat", resources: []string{"edge:e1"}},
+			{study: "routine", priority: "routine", resources: []string{"edge:e2"}},
+		}, want: []string{"routine"}},
+		{name: "uncapped resources", jobs: []queued{
+			{study: "busy", priority: "routine", resources: []string{"edge:e1"}, running: true},
+			{study: "a", priority: "routine", resources: []string{"edge:e1"}},
+		}, want: []string{"a"}},
+		{name: "scheduled jobs wait", jobs: []queued{
+			{study: "later", priority: "stat", later: true},
+			{study: "now", priority: "background"},
+		}, want: []string{"now"}},
+		{name: "batches take turns", jobs: []queued{
+			{study: "a1", priority: "routine", batch: 1},
+			{study: "a2", priority: "routine", batch: 1},
+			{study: "b1", priority: "routine", batch: 2},
+		}, want: []string{"a1", "b1", "a2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := pool.Exec(ctx, "TRUNCATE jobs, move_batches"); err != nil {
+				t.Fatal(err)
+			}
+			var batches []int64
+			for _, q := range tt.jobs {
+				for len(batches) < q.batch {
+					b := &models.MoveBatch{TargetTier: "cold", RequestedBy: "test", CreatedAt: time.Now()}
+					if err := store.CreateBatch(ctx, b); err != nil {
+						t.Fatal(err)
+					}
+					batches = append(batches, b.ID)
+				}
+				job := &models.Job{Kind: "move", StudyID: q.study, TargetTier: "cold", Source: "test", Priority: q.priority,
+					Resources: q.resources, RequestedBy: "test", CreatedAt: time.Now()}
+				if job.Resources == nil {
+					job.Resources = []string{}
+				}
+				if q.batch > 0 {
+					job.BatchID = &batches[q.batch-1]
+				}
+				if q.later {
+					notBefore := time.Now().Add(time.Hour)
+					job.NotBefore = &notBefore
+				}
+				if _, err := store.EnqueueJob(ctx, job); err != nil {
+					t.Fatal(err)
+				}
+				if q.running {
+					if _, err := pool.Exec(ctx, `UPDATE jobs SET status = 'running', claimed_by = 'other', started_at = now(),
+                        heartbeat_at = now() WHERE id = $1`, job.ID); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			var got []string
+			for {
+				job, ok, err := store.ClaimJob(ctx, ClaimOptions{WorkerID: "test", Limits: tt.limits})
+				if err != nil {
+					t.Fatalf("ClaimJob() error = %v", err)
+				}
+				if !ok {
+					break
+				}
+				if job.Status != "running" || job.ClaimedBy != "test" {
+					t.Errorf("claimed job %s has status %q, claimed by %q", job.StudyID, job.Status, job.ClaimedBy)
+				}
+				got = append(got, job.StudyID)
+				if len(got) > len(tt.jobs) {
+					t.Fatalf("claimed %v, more jobs than were queued", got)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("claimed %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
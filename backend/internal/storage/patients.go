@@ -0,0 +1,105 @@
+// File: internal/storage/patients.go
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+
+	models "github.com/ewag/gen-erics/backend/internal/models"
+)
+
+// PatientStore reconciles catalogued patients with updates from the RIS.
+type PatientStore interface {
+	// PatientResourceIDs returns the Orthanc patient IDs catalogued for a
+	// DICOM PatientID.
+	PatientResourceIDs(ctx context.Context, patientID string) ([]string, error)
+	// UpdatePatientDemographics applies the non-empty fields of d to every
+	// catalogued study of the patient and returns how many were updated.
+	UpdatePatientDemographics(ctx context.Context, patientID string, d models.PatientDemographics) (int64, error)
+	// MergePatient moves every catalogued study of priorPatientID to the
+	// surviving patient, applying d as UpdatePatientDemographics does.
+	MergePatient(ctx context.Context, priorPatientID, survivingPatientID, survivingResourceID string, d models.PatientDemographics) (int64, error)
+	// RekeyStudy moves a study's status, status history, catalog entry and
+	// trash entry to a new Orthanc study ID, e.g. after its PatientID was
+	// rewritten.
+	RekeyStudy(ctx context.Context, oldStudyID, newStudyID string) error
+}
+
+// PatientResourceIDs implements PatientStore.
+func (s *Store) PatientResourceIDs(ctx context.Context, patientID string) ([]string, error) {
+	rows, err := s.pool.Query(ctx, `
+        SELECT DISTINCT patient_resource_id FROM study_catalog
+        WHERE patient_id = $1 AND patient_resource_id IS NOT NULL AND patient_resource_id <> ''`, patientID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error querying patient resource IDs", "patientID", patientID, "error", err)
+		return nil, fmt.Errorf("failed to query patient resource IDs: %w", err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read patient resource IDs: %w", err)
+	}
+	return ids, nil
+}
+
+// UpdatePatientDemographics implements PatientStore.
+func (s *Store) UpdatePatientDemographics(ctx context.Context, patientID string, d models.PatientDemographics) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `
+        UPDATE study_catalog SET
+            patient_name = COALESCE(NULLIF($2, ''), patient_name),
+            patient_birth_date = COALESCE(NULLIF($3, ''), patient_birth_date),
+            patient_sex = COALESCE(NULLIF($4, ''), patient_sex),
+            updated_at = CURRENT_TIMESTAMP
+        WHERE patient_id = $1`, patientID, d.PatientName, d.PatientBirthDate, d.PatientSex)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error updating patient demographics", "patientID", patientID, "error", err)
+		return 0, fmt.Errorf("failed to update patient demographics: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// MergePatient implements PatientStore.
+func (s *Store) MergePatient(ctx context.Context, priorPatientID, survivingPatientID, survivingResourceID string, d models.PatientDemographics) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `
+        UPDATE study_catalog SET
+            patient_id = $2,
+            patient_resource_id = $3,
+            patient_name = COALESCE(NULLIF($4, ''), patient_name),
+            patient_birth_date = COALESCE(NULLIF($5, ''), patient_birth_date),
+            patient_sex = COALESCE(NULLIF($6, ''), patient_sex),
+            updated_at = CURRENT_TIMESTAMP
+        WHERE patient_id = $1`, priorPatientID, survivingPatientID, survivingResourceID,
+		d.PatientName, d.PatientBirthDate, d.PatientSex)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error merging patient", "priorPatientID", priorPatientID, "survivingPatientID", survivingPatientID, "error", err)
+		return 0, fmt.Errorf("failed to merge patient: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// RekeyStudy implements PatientStore.
+func (s *Store) RekeyStudy(ctx context.Context, oldStudyID, newStudyID string) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, stmt := range []string{
+		`UPDATE study_status SET study_instance_uid = $2 WHERE study_instance_uid = $1`,
+		`UPDATE study_status_history SET study_instance_uid = $2 WHERE study_instance_uid = $1`,
+		`UPDATE study_catalog SET study_id = $2, updated_at = CURRENT_TIMESTAMP WHERE study_id = $1`,
+		`UPDATE study_trash SET study_id = $2 WHERE study_id = $1`,
+	} {
+		if _, err := tx.Exec(ctx, stmt, oldStudyID, newStudyID); err != nil {
+			slog.ErrorContext(ctx, "Error rekeying study", "oldStudyID", oldStudyID, "newStudyID", newStudyID, "error", err)
+			return fmt.Errorf("failed to rekey study %s: %w", oldStudyID, err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit study rekey: %w", err)
+	}
+	return nil
+}
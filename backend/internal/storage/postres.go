@@ -11,6 +11,7 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	// Import your models package
+	"github.com/ewag/gen-erics/backend/internal/auth"
 	models "github.com/ewag/gen-erics/backend/internal/models" // Adjust import path
 )
 
@@ -18,6 +19,7 @@ import (
 type StatusStore interface {
 	GetStatus(ctx context.Context, studyUID string) (*models.LocationStatus, bool, error) // Returns status, found boolean, error
 	SetStatus(ctx context.Context, studyUID string, status models.LocationStatus) error
+	GetStatusHistory(ctx context.Context, studyUID string) ([]models.StatusHistoryEntry, error)
 	Ping(ctx context.Context) error
 }
 
@@ -70,6 +72,28 @@ func (s *Store) GetStatus(ctx context.Context, studyUID string) (*models.Locatio
 
 // SetStatus inserts or updates the LocationStatus for a given studyUID (Upsert).
 func (s *Store) SetStatus(ctx context.Context, studyUID string, status models.LocationStatus) error {
+	slog.DebugContext(ctx, "Setting study status in DB", "studyUID", studyUID, "status", status)
+
+	// Status and its history row are written together
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin status transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) // No-op after commit
+
+	if err := setStatusTx(ctx, tx, studyUID, status); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit study status: %w", err)
+	}
+
+	slog.DebugContext(ctx, "Successfully set study status", "studyUID", studyUID)
+	return nil
+}
+
+// setStatusTx upserts a study's status and records it in the history within tx.
+func setStatusTx(ctx context.Context, tx pgx.Tx, studyUID string, status models.LocationStatus) error {
 	query := `
         INSERT INTO study_status (study_instance_uid, tier, location_type, edge_id, last_updated)
         VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
@@ -79,8 +103,6 @@ func (s *Store) SetStatus(ctx context.Context, studyUID string, status models.Lo
             edge_id = EXCLUDED.edge_id,
             last_updated = CURRENT_TIMESTAMP
     `
-	slog.DebugContext(ctx, "Setting study status in DB", "studyUID", studyUID, "status", status)
-
     // Use pgx.NullString for nullable edge_id
     var nullableEdgeID sql.NullString
     if status.EdgeID != nil {
@@ -89,16 +111,50 @@ func (s *Store) SetStatus(ctx context.Context, studyUID string, status models.Lo
          nullableEdgeID = sql.NullString{Valid: false}
     }
 
-	commandTag, err := s.pool.Exec(ctx, query, studyUID, status.Tier, status.LocationType, nullableEdgeID)
-	if err != nil {
+	if _, err := tx.Exec(ctx, query, studyUID, status.Tier, status.LocationType, nullableEdgeID); err != nil {
 		slog.ErrorContext(ctx, "Error executing upsert study status in DB", "studyUID", studyUID, "error", err)
 		return fmt.Errorf("failed to set study status: %w", err)
 	}
 
-	slog.DebugContext(ctx, "Successfully set study status", "studyUID", studyUID, "rowsAffected", commandTag.RowsAffected())
+	_, err := tx.Exec(ctx, `
+        INSERT INTO study_status_history (study_instance_uid, tier, location_type, edge_id, changed_by)
+        VALUES ($1, $2, $3, $4, $5)`,
+		studyUID, status.Tier, status.LocationType, nullableEdgeID, auth.ActorFromContext(ctx))
+	if err != nil {
+		slog.ErrorContext(ctx, "Error recording study status history", "studyUID", studyUID, "error", err)
+		return fmt.Errorf("failed to record study status history: %w", err)
+	}
 	return nil
 }
 
+// GetStatusHistory returns every recorded status change for a study, newest first.
+func (s *Store) GetStatusHistory(ctx context.Context, studyUID string) ([]models.StatusHistoryEntry, error) {
+	rows, err := s.pool.Query(ctx, `
+        SELECT tier, location_type, edge_id, changed_by, changed_at
+        FROM study_status_history
+        WHERE study_instance_uid = $1
+        ORDER BY changed_at DESC, id DESC`, studyUID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error querying study status history", "studyUID", studyUID, "error", err)
+		return nil, fmt.Errorf("failed to query study status history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []models.StatusHistoryEntry{}
+	for rows.Next() {
+		var entry models.StatusHistoryEntry
+		var nullableEdgeID sql.NullString
+		if err := rows.Scan(&entry.Tier, &entry.LocationType, &nullableEdgeID, &entry.ChangedBy, &entry.ChangedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan study status history: %w", err)
+		}
+		if nullableEdgeID.Valid {
+			entry.EdgeID = &nullableEdgeID.String
+		}
+		history = append(history, entry)
+	}
+	return history, rows.Err()
+}
+
 func (s *Store) Ping(ctx context.Context) error {
     return s.pool.Ping(ctx)
 }
\ No newline at end of file
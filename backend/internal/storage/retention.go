@@ -0,0 +1,205 @@
+// File: internal/storage/retention.go
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	models "github.com/ewag/gen-erics/backend/internal/models"
+)
+
+// RetentionStore keeps legal holds and the tombstones of deleted studies.
+type RetentionStore interface {
+	// CreateLegalHold stores hold and fills in its ID.
+	CreateLegalHold(ctx context.Context, hold *models.LegalHold) error
+	// ReleaseLegalHold ends an active hold, returning false if there was none.
+	ReleaseLegalHold(ctx context.Context, id int64, releasedBy string, at time.Time) (bool, error)
+	ListLegalHolds(ctx context.Context, activeOnly bool) ([]models.LegalHold, error)
+	// ActiveHoldsForStudy returns the active holds on a study or on its
+	// patient, given directly or through the study catalog.
+	ActiveHoldsForStudy(ctx context.Context, studyID, patientResourceID string) ([]models.LegalHold, error)
+	// RecordTombstone stores the tombstone and removes what the database still
+	// knows about the study: catalog rows, its tier data key and trash entry.
+	RecordTombstone(ctx context.Context, t models.Tombstone) error
+	GetTombstone(ctx context.Context, studyID string) (*models.Tombstone, bool, error)
+	ListTombstones(ctx context.Context, limit int) ([]models.Tombstone, error)
+}
+
+const legalHoldColumns = `id, scope, target_id, reason, created_by, created_at, released_by, released_at`
+
+const tombstoneColumns = `study_id, study_instance_uid, patient_resource_id, patient_id, study_date,
+        reason, deleted_by, deleted_at, tiers, instances, bytes`
+
+// CreateLegalHold implements RetentionStore.
+func (s *Store) CreateLegalHold(ctx context.Context, hold *models.LegalHold) error {
+	err := s.pool.QueryRow(ctx, `
+        INSERT INTO legal_holds (scope, target_id, reason, created_by, created_at)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id`,
+		hold.Scope, hold.TargetID, hold.Reason, hold.CreatedBy, hold.CreatedAt).Scan(&hold.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error inserting legal hold", "scope", hold.Scope, "targetID", hold.TargetID, "error", err)
+		return fmt.Errorf("failed to store legal hold: %w", err)
+	}
+	return nil
+}
+
+// ReleaseLegalHold implements RetentionStore.
+func (s *Store) ReleaseLegalHold(ctx context.Context, id int64, releasedBy string, at time.Time) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `
+        UPDATE legal_holds SET released_by = $2, released_at = $3
+        WHERE id = $1 AND released_at IS NULL`, id, releasedBy, at)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error releasing legal hold", "holdID", id, "error", err)
+		return false, fmt.Errorf("failed to release legal hold: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ListLegalHolds implements RetentionStore, newest first.
+func (s *Store) ListLegalHolds(ctx context.Context, activeOnly bool) ([]models.LegalHold, error) {
+	return s.queryLegalHolds(ctx, `
+        SELECT `+legalHoldColumns+` FROM legal_holds
+        WHERE NOT $1 OR released_at IS NULL
+        ORDER BY created_at DESC, id DESC`, activeOnly)
+}
+
+// ActiveHoldsForStudy implements RetentionStore.
+func (s *Store) ActiveHoldsForStudy(ctx context.Context, studyID, patientResourceID string) ([]models.LegalHold, error) {
+	return s.queryLegalHolds(ctx, `
+        SELECT `+legalHoldColumns+` FROM legal_holds
+        WHERE released_at IS NULL AND (
+            (scope = 'study' AND target_id = $1) OR
+            (scope = 'patient' AND (target_id = $2 OR target_id IN (
+                SELECT patient_resource_id FROM study_catalog WHERE study_id = $1))))
+        ORDER BY id`, studyID, patientResourceID)
+}
+
+func (s *Store) queryLegalHolds(ctx context.Context, query string, args ...any) ([]models.LegalHold, error) {
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error querying legal holds", "error", err)
+		return nil, fmt.Errorf("failed to query legal holds: %w", err)
+	}
+	defer rows.Close()
+
+	holds := []models.LegalHold{}
+	for rows.Next() {
+		var h models.LegalHold
+		var releasedBy sql.NullString
+		if err := rows.Scan(&h.ID, &h.Scope, &h.TargetID, &h.Reason, &h.CreatedBy, &h.CreatedAt, &releasedBy, &h.ReleasedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan legal hold: %w", err)
+		}
+		h.ReleasedBy = releasedBy.String
+		holds = append(holds, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate legal holds: %w", err)
+	}
+	return holds, nil
+}
+
+// RecordTombstone implements RetentionStore. Deleting the study's data key
+// also makes any tier copy that escaped deletion unreadable.
+func (s *Store) RecordTombstone(ctx context.Context, t models.Tombstone) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin tombstone transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// A study can be sent again after deletion and deleted again
+	_, err = tx.Exec(ctx, `
+        INSERT INTO study_tombstones (`+tombstoneColumns+`)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+        ON CONFLICT (study_id) DO UPDATE SET
+            study_instance_uid = EXCLUDED.study_instance_uid,
+            patient_resource_id = EXCLUDED.patient_resource_id,
+            patient_id = EXCLUDED.patient_id,
+            study_date = EXCLUDED.study_date,
+            reason = EXCLUDED.reason,
+            deleted_by = EXCLUDED.deleted_by,
+            deleted_at = EXCLUDED.deleted_at,
+            tiers = EXCLUDED.tiers,
+            instances = EXCLUDED.instances,
+            bytes = EXCLUDED.bytes`,
+		t.StudyID, nullString(t.StudyInstanceUID), nullString(t.PatientResourceID), nullString(t.PatientID),
+		nullString(t.StudyDate), t.Reason, t.DeletedBy, t.DeletedAt, t.Tiers, t.Instances, t.Bytes)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error inserting study tombstone", "studyID", t.StudyID, "error", err)
+		return fmt.Errorf("failed to store study tombstone: %w", err)
+	}
+	for _, stmt := range []string{
+		`DELETE FROM instance_catalog WHERE study_id = $1`,
+		`DELETE FROM study_catalog WHERE study_id = $1`,
+		`DELETE FROM tier_data_keys WHERE study_id = $1`,
+		`DELETE FROM study_trash WHERE study_id = $1`,
+	} {
+		if _, err := tx.Exec(ctx, stmt, t.StudyID); err != nil {
+			slog.ErrorContext(ctx, "Error removing deleted study records", "studyID", t.StudyID, "error", err)
+			return fmt.Errorf("failed to remove deleted study records: %w", err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit study tombstone: %w", err)
+	}
+	return nil
+}
+
+// GetTombstone implements RetentionStore.
+func (s *Store) GetTombstone(ctx context.Context, studyID string) (*models.Tombstone, bool, error) {
+	t, err := scanTombstone(s.pool.QueryRow(ctx, `SELECT `+tombstoneColumns+` FROM study_tombstones WHERE study_id = $1`, studyID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, nil
+		}
+		slog.ErrorContext(ctx, "Error querying study tombstone", "studyID", studyID, "error", err)
+		return nil, false, fmt.Errorf("failed to query study tombstone: %w", err)
+	}
+	return t, true, nil
+}
+
+// ListTombstones implements RetentionStore, most recently deleted first.
+func (s *Store) ListTombstones(ctx context.Context, limit int) ([]models.Tombstone, error) {
+	rows, err := s.pool.Query(ctx, `
+        SELECT `+tombstoneColumns+` FROM study_tombstones
+        ORDER BY deleted_at DESC LIMIT $1`, limit)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error querying study tombstones", "error", err)
+		return nil, fmt.Errorf("failed to query study tombstones: %w", err)
+	}
+	defer rows.Close()
+
+	tombstones := []models.Tombstone{}
+	for rows.Next() {
+		t, err := scanTombstone(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan study tombstone: %w", err)
+		}
+		tombstones = append(tombstones, *t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate study tombstones: %w", err)
+	}
+	return tombstones, nil
+}
+
+func scanTombstone(row pgx.Row) (*models.Tombstone, error) {
+	var t models.Tombstone
+	var uid, patientResourceID, patientID, studyDate sql.NullString
+	if err := row.Scan(&t.StudyID, &uid, &patientResourceID, &patientID, &studyDate,
+		&t.Reason, &t.DeletedBy, &t.DeletedAt, &t.Tiers, &t.Instances, &t.Bytes); err != nil {
+		return nil, err
+	}
+	t.StudyInstanceUID = uid.String
+	t.PatientResourceID = patientResourceID.String
+	t.PatientID = patientID.String
+	t.StudyDate = studyDate.String
+	return &t, nil
+}
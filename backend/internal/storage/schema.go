@@ -0,0 +1,244 @@
+// File: internal/storage/schema.go
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// schemaStatements are applied in order on startup. Every statement must be
+// idempotent (IF NOT EXISTS) since they run on each boot.
+var schemaStatements = []string{
+	`CREATE TABLE IF NOT EXISTS instance_catalog (
+        instance_id TEXT NOT NULL,
+        tier TEXT NOT NULL,
+        study_id TEXT NOT NULL,
+        series_id TEXT,
+        sop_instance_uid TEXT,
+        object_key TEXT NOT NULL,
+        size_bytes BIGINT NOT NULL,
+        sha256 TEXT NOT NULL,
+        stored_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        last_verified_at TIMESTAMP WITH TIME ZONE,
+        verify_status TEXT NOT NULL DEFAULT 'unverified',
+        PRIMARY KEY (instance_id, tier)
+    )`,
+	`CREATE INDEX IF NOT EXISTS instance_catalog_study_idx ON instance_catalog (study_id, tier)`,
+	`CREATE INDEX IF NOT EXISTS instance_catalog_scrub_idx ON instance_catalog (tier, last_verified_at NULLS FIRST)`,
+	`ALTER TABLE instance_catalog
+        ADD COLUMN IF NOT EXISTS instance_number TEXT,
+        ADD COLUMN IF NOT EXISTS series_number TEXT,
+        ADD COLUMN IF NOT EXISTS series_description TEXT,
+        ADD COLUMN IF NOT EXISTS modality TEXT,
+        ADD COLUMN IF NOT EXISTS body_part TEXT`,
+	`CREATE TABLE IF NOT EXISTS study_catalog (
+        study_id TEXT PRIMARY KEY,
+        study_instance_uid TEXT,
+        patient_id TEXT,
+        patient_name TEXT,
+        patient_birth_date TEXT,
+        patient_sex TEXT,
+        study_date TEXT,
+        study_description TEXT,
+        accession_number TEXT,
+        updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+    )`,
+	`CREATE INDEX IF NOT EXISTS study_catalog_patient_idx ON study_catalog (patient_id)`,
+	`ALTER TABLE study_catalog ADD COLUMN IF NOT EXISTS patient_resource_id TEXT`,
+	`CREATE INDEX IF NOT EXISTS study_catalog_patient_resource_idx ON study_catalog (patient_resource_id)`,
+	`CREATE TABLE IF NOT EXISTS anonymization_map (
+        project TEXT NOT NULL,
+        kind TEXT NOT NULL,
+        original_value TEXT NOT NULL,
+        anonymized_value TEXT NOT NULL,
+        original_label TEXT,
+        created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (project, kind, original_value),
+        UNIQUE (project, anonymized_value)
+    )`,
+	`CREATE TABLE IF NOT EXISTS study_status_history (
+        id BIGSERIAL PRIMARY KEY,
+        study_instance_uid TEXT NOT NULL,
+        tier TEXT NOT NULL,
+        location_type TEXT NOT NULL,
+        edge_id TEXT,
+        changed_by TEXT NOT NULL,
+        changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+    )`,
+	`CREATE INDEX IF NOT EXISTS study_status_history_study_idx ON study_status_history (study_instance_uid, changed_at)`,
+	`CREATE TABLE IF NOT EXISTS api_keys (
+        id TEXT PRIMARY KEY,
+        name TEXT NOT NULL,
+        secret_hash TEXT NOT NULL,
+        scopes TEXT[] NOT NULL DEFAULT '{}',
+        created_by TEXT NOT NULL,
+        created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        expires_at TIMESTAMP WITH TIME ZONE,
+        last_used_at TIMESTAMP WITH TIME ZONE,
+        revoked_at TIMESTAMP WITH TIME ZONE
+    )`,
+	`CREATE TABLE IF NOT EXISTS audit_log (
+        id BIGSERIAL PRIMARY KEY,
+        event_time TIMESTAMP WITH TIME ZONE NOT NULL,
+        event_id TEXT NOT NULL,
+        action_code TEXT NOT NULL,
+        outcome INTEGER NOT NULL,
+        user_id TEXT NOT NULL,
+        client_ip TEXT,
+        object_ids TEXT[] NOT NULL DEFAULT '{}',
+        message JSONB NOT NULL
+    )`,
+	`CREATE INDEX IF NOT EXISTS audit_log_time_idx ON audit_log (event_time)`,
+	`CREATE INDEX IF NOT EXISTS audit_log_user_idx ON audit_log (user_id, event_time)`,
+	`CREATE INDEX IF NOT EXISTS audit_log_objects_idx ON audit_log USING GIN (object_ids)`,
+	// The audit trail is append-only: reject UPDATE and DELETE for every role
+	`CREATE OR REPLACE FUNCTION audit_log_immutable() RETURNS trigger AS $$
+    BEGIN
+        RAISE EXCEPTION 'audit_log is append-only';
+    END;
+    $$ LANGUAGE plpgsql`,
+	`DROP TRIGGER IF EXISTS audit_log_no_modify ON audit_log`,
+	`CREATE TRIGGER audit_log_no_modify BEFORE UPDATE OR DELETE ON audit_log
+        FOR EACH ROW EXECUTE FUNCTION audit_log_immutable()`,
+	`DROP TRIGGER IF EXISTS audit_log_no_truncate ON audit_log`,
+	`CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log
+        FOR EACH STATEMENT EXECUTE FUNCTION audit_log_immutable()`,
+	`ALTER TABLE instance_catalog
+        ADD COLUMN IF NOT EXISTS number_of_frames INTEGER,
+        ADD COLUMN IF NOT EXISTS rows INTEGER,
+        ADD COLUMN IF NOT EXISTS columns INTEGER,
+        ADD COLUMN IF NOT EXISTS transfer_syntax_uid TEXT`,
+	`ALTER TABLE instance_catalog ADD COLUMN IF NOT EXISTS original_transfer_syntax_uid TEXT`,
+	// Per-study data keys for tier encryption, wrapped by a master key
+	`CREATE TABLE IF NOT EXISTS tier_data_keys (
+        key_id TEXT PRIMARY KEY,
+        study_id TEXT NOT NULL UNIQUE,
+        wrapped_key BYTEA NOT NULL,
+        master_key_id TEXT NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        rotated_at TIMESTAMPTZ
+    )`,
+	`CREATE INDEX IF NOT EXISTS idx_tier_data_keys_master ON tier_data_keys (master_key_id)`,
+	`ALTER TABLE instance_catalog ADD COLUMN IF NOT EXISTS encryption_key_id TEXT`,
+	`CREATE TABLE IF NOT EXISTS legal_holds (
+        id BIGSERIAL PRIMARY KEY,
+        scope TEXT NOT NULL,
+        target_id TEXT NOT NULL,
+        reason TEXT NOT NULL,
+        created_by TEXT NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        released_by TEXT,
+        released_at TIMESTAMPTZ
+    )`,
+	`CREATE INDEX IF NOT EXISTS legal_holds_active_idx ON legal_holds (scope, target_id) WHERE released_at IS NULL`,
+	`CREATE TABLE IF NOT EXISTS study_tombstones (
+        study_id TEXT PRIMARY KEY,
+        study_instance_uid TEXT,
+        patient_resource_id TEXT,
+        patient_id TEXT,
+        study_date TEXT,
+        reason TEXT NOT NULL,
+        deleted_by TEXT NOT NULL,
+        deleted_at TIMESTAMPTZ NOT NULL,
+        tiers TEXT[] NOT NULL DEFAULT '{}',
+        instances INTEGER NOT NULL DEFAULT 0,
+        bytes BIGINT NOT NULL DEFAULT 0
+    )`,
+	`CREATE INDEX IF NOT EXISTS study_tombstones_deleted_idx ON study_tombstones (deleted_at)`,
+	`CREATE TABLE IF NOT EXISTS study_trash (
+        study_id TEXT PRIMARY KEY,
+        previous_tier TEXT NOT NULL,
+        previous_location_type TEXT NOT NULL,
+        previous_edge_id TEXT,
+        reason TEXT,
+        trashed_by TEXT NOT NULL,
+        trashed_at TIMESTAMPTZ NOT NULL,
+        purge_after TIMESTAMPTZ NOT NULL
+    )`,
+	`CREATE INDEX IF NOT EXISTS study_trash_purge_idx ON study_trash (purge_after)`,
+	`CREATE TABLE IF NOT EXISTS jobs (
+        id BIGSERIAL PRIMARY KEY,
+        kind TEXT NOT NULL,
+        study_id TEXT NOT NULL,
+        target_tier TEXT NOT NULL,
+        target_location TEXT,
+        status TEXT NOT NULL DEFAULT 'queued',
+        source TEXT NOT NULL,
+        reason TEXT,
+        requested_by TEXT NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        started_at TIMESTAMPTZ,
+        finished_at TIMESTAMPTZ,
+        error TEXT,
+        result JSONB
+    )`,
+	`CREATE INDEX IF NOT EXISTS jobs_queue_idx ON jobs (id) WHERE status = 'queued'`,
+	// At most one queued or running job per study, so moves never race
+	`CREATE UNIQUE INDEX IF NOT EXISTS jobs_active_study_idx ON jobs (study_id) WHERE status IN ('queued', 'running')`,
+	`CREATE INDEX IF NOT EXISTS jobs_study_idx ON jobs (study_id, created_at)`,
+	// Resume points for change feeds such as Orthanc's /changes
+	`CREATE TABLE IF NOT EXISTS feed_cursors (
+        name TEXT PRIMARY KEY,
+        position BIGINT NOT NULL,
+        updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+    )`,
+	// Inbound HL7 v2 messages (MLLP) and their outcome
+	`CREATE TABLE IF NOT EXISTS hl7_messages (
+        id BIGSERIAL PRIMARY KEY,
+        received_at TIMESTAMPTZ NOT NULL,
+        remote_addr TEXT NOT NULL,
+        control_id TEXT,
+        message_type TEXT,
+        status TEXT NOT NULL,
+        ack_code TEXT NOT NULL,
+        error TEXT,
+        result JSONB,
+        raw TEXT NOT NULL
+    )`,
+	`CREATE INDEX IF NOT EXISTS hl7_messages_control_idx ON hl7_messages (control_id)`,
+	`CREATE INDEX IF NOT EXISTS hl7_messages_type_idx ON hl7_messages (message_type, id)`,
+	// Scheduled moves, and moves that may only run in a maintenance window
+	`ALTER TABLE jobs
+        ADD COLUMN IF NOT EXISTS not_before TIMESTAMPTZ,
+        ADD COLUMN IF NOT EXISTS window_required BOOLEAN NOT NULL DEFAULT false`,
+	// Bulk moves; each study is moved by a job pointing back at its batch
+	`CREATE TABLE IF NOT EXISTS move_batches (
+        id BIGSERIAL PRIMARY KEY,
+        target_tier TEXT NOT NULL,
+        target_location TEXT,
+        study_ids TEXT[],
+        filter JSONB,
+        reason TEXT,
+        requested_by TEXT NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        skipped JSONB
+    )`,
+	`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS batch_id BIGINT REFERENCES move_batches (id)`,
+	`CREATE INDEX IF NOT EXISTS jobs_batch_idx ON jobs (batch_id, status) WHERE batch_id IS NOT NULL`,
+	// Priorities, concurrency caps per edge and tier, and leases kept alive by
+	// the worker running a job so replicas can take over from a dead one
+	`ALTER TABLE jobs
+        ADD COLUMN IF NOT EXISTS priority TEXT NOT NULL DEFAULT 'routine',
+        ADD COLUMN IF NOT EXISTS resources TEXT[] NOT NULL DEFAULT '{}',
+        ADD COLUMN IF NOT EXISTS claimed_by TEXT,
+        ADD COLUMN IF NOT EXISTS heartbeat_at TIMESTAMPTZ`,
+	`CREATE INDEX IF NOT EXISTS jobs_running_idx ON jobs (heartbeat_at) WHERE status = 'running'`,
+	// Retries: failed runs so far and their errors; jobs out of attempts are 'dead'
+	`ALTER TABLE jobs
+        ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 0,
+        ADD COLUMN IF NOT EXISTS error_history JSONB`,
+}
+
+// EnsureSchema creates any tables and indexes that don't exist yet.
+func EnsureSchema(ctx context.Context, pool *pgxpool.Pool) error {
+	for i, stmt := range schemaStatements {
+		if _, err := pool.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to apply schema statement %d: %w", i, err)
+		}
+	}
+	slog.InfoContext(ctx, "Database schema is up to date", "statements", len(schemaStatements))
+	return nil
+}
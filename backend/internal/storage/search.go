@@ -0,0 +1,113 @@
+// File: internal/storage/search.go
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	models "github.com/ewag/gen-erics/backend/internal/models"
+)
+
+// SearchStore finds studies by where they are, for bulk moves.
+type SearchStore interface {
+	// SearchOffloadedStudies returns up to limit studies outside the hot
+	// tier matching f, newest first. f.EdgeID is ignored; edges only hold
+	// hot studies.
+	SearchOffloadedStudies(ctx context.Context, f models.StudyFilter, limit int) ([]models.OffloadedStudy, error)
+	// HotStudiesOnEdge returns the IDs of hot studies recorded on an edge.
+	HotStudiesOnEdge(ctx context.Context, edgeID string) ([]string, error)
+	// GetStatuses returns the status rows of the given studies; studies
+	// without one are missing from the map.
+	GetStatuses(ctx context.Context, studyIDs []string) (map[string]models.LocationStatus, error)
+}
+
+// SearchOffloadedStudies implements SearchStore.
+func (s *Store) SearchOffloadedStudies(ctx context.Context, f models.StudyFilter, limit int) ([]models.OffloadedStudy, error) {
+	where := []string{"true"}
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if f.Tier != "" {
+		add("st.tier = $%d", f.Tier)
+	}
+	if f.DateFrom != "" {
+		add("c.study_date >= $%d", f.DateFrom)
+	}
+	if f.DateTo != "" {
+		add("c.study_date <= $%d", f.DateTo)
+	}
+	if f.PatientID != "" {
+		add("c.patient_id = $%d", f.PatientID)
+	}
+	if f.Modality != "" {
+		add("EXISTS (SELECT 1 FROM instance_catalog m WHERE m.study_id = c.study_id AND m.modality = $%d)", f.Modality)
+	}
+	args = append(args, limit)
+	studies, err := s.queryOffloadedStudies(ctx, strings.Join(where, " AND "), fmt.Sprintf("LIMIT $%d", len(args)), args...)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error searching offloaded studies", "filter", f, "error", err)
+		return nil, err
+	}
+	return studies, nil
+}
+
+// HotStudiesOnEdge implements SearchStore.
+func (s *Store) HotStudiesOnEdge(ctx context.Context, edgeID string) ([]string, error) {
+	rows, err := s.pool.Query(ctx, `
+        SELECT study_instance_uid FROM study_status
+        WHERE tier = 'hot' AND edge_id = $1
+        ORDER BY study_instance_uid`, edgeID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error querying studies on edge", "edgeID", edgeID, "error", err)
+		return nil, fmt.Errorf("failed to query studies on edge %s: %w", edgeID, err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan study ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate studies on edge: %w", err)
+	}
+	return ids, nil
+}
+
+// GetStatuses implements SearchStore.
+func (s *Store) GetStatuses(ctx context.Context, studyIDs []string) (map[string]models.LocationStatus, error) {
+	rows, err := s.pool.Query(ctx, `
+        SELECT study_instance_uid, tier, location_type, edge_id
+        FROM study_status WHERE study_instance_uid = ANY($1)`, studyIDs)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error querying study statuses", "studies", len(studyIDs), "error", err)
+		return nil, fmt.Errorf("failed to query study statuses: %w", err)
+	}
+	defer rows.Close()
+
+	statuses := make(map[string]models.LocationStatus, len(studyIDs))
+	for rows.Next() {
+		var id string
+		var status models.LocationStatus
+		var edgeID sql.NullString
+		if err := rows.Scan(&id, &status.Tier, &status.LocationType, &edgeID); err != nil {
+			return nil, fmt.Errorf("failed to scan study status: %w", err)
+		}
+		if edgeID.Valid {
+			status.EdgeID = &edgeID.String
+		}
+		statuses[id] = status
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate study statuses: %w", err)
+	}
+	return statuses, nil
+}
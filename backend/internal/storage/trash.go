@@ -0,0 +1,143 @@
+// File: internal/storage/trash.go
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	models "github.com/ewag/gen-erics/backend/internal/models"
+)
+
+// TrashStore tracks soft-deleted studies waiting in the trash tier.
+type TrashStore interface {
+	// RecordTrashed stores a study's trash entry and sets its status to
+	// status, both or neither.
+	RecordTrashed(ctx context.Context, entry models.TrashEntry, status models.LocationStatus) error
+	GetTrashEntry(ctx context.Context, studyID string) (*models.TrashEntry, bool, error)
+	// RecordRestored removes a study's trash entry and sets its status to
+	// status, both or neither.
+	RecordRestored(ctx context.Context, studyID string, status models.LocationStatus) error
+	// ListTrash returns trashed studies, those due for purging first. With a
+	// non-zero purgeBefore only entries due before it are returned.
+	ListTrash(ctx context.Context, purgeBefore time.Time, limit int) ([]models.TrashEntry, error)
+}
+
+const trashColumns = `study_id, previous_tier, previous_location_type, previous_edge_id, reason,
+        trashed_by, trashed_at, purge_after`
+
+// RecordTrashed implements TrashStore.
+func (s *Store) RecordTrashed(ctx context.Context, e models.TrashEntry, status models.LocationStatus) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin trash transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+        INSERT INTO study_trash (`+trashColumns+`)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        ON CONFLICT (study_id) DO UPDATE SET
+            previous_tier = EXCLUDED.previous_tier,
+            previous_location_type = EXCLUDED.previous_location_type,
+            previous_edge_id = EXCLUDED.previous_edge_id,
+            reason = EXCLUDED.reason,
+            trashed_by = EXCLUDED.trashed_by,
+            trashed_at = EXCLUDED.trashed_at,
+            purge_after = EXCLUDED.purge_after`,
+		e.StudyID, e.PreviousStatus.Tier, e.PreviousStatus.LocationType, e.PreviousStatus.EdgeID,
+		nullString(e.Reason), e.TrashedBy, e.TrashedAt, e.PurgeAfter)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error upserting trash entry", "studyID", e.StudyID, "error", err)
+		return fmt.Errorf("failed to store trash entry: %w", err)
+	}
+	if err := setStatusTx(ctx, tx, e.StudyID, status); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit trash entry: %w", err)
+	}
+	return nil
+}
+
+// GetTrashEntry implements TrashStore.
+func (s *Store) GetTrashEntry(ctx context.Context, studyID string) (*models.TrashEntry, bool, error) {
+	e, err := scanTrashEntry(s.pool.QueryRow(ctx, `SELECT `+trashColumns+` FROM study_trash WHERE study_id = $1`, studyID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, nil
+		}
+		slog.ErrorContext(ctx, "Error querying trash entry", "studyID", studyID, "error", err)
+		return nil, false, fmt.Errorf("failed to query trash entry: %w", err)
+	}
+	return e, true, nil
+}
+
+// RecordRestored implements TrashStore.
+func (s *Store) RecordRestored(ctx context.Context, studyID string, status models.LocationStatus) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin restore transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM study_trash WHERE study_id = $1`, studyID); err != nil {
+		slog.ErrorContext(ctx, "Error deleting trash entry", "studyID", studyID, "error", err)
+		return fmt.Errorf("failed to delete trash entry: %w", err)
+	}
+	if err := setStatusTx(ctx, tx, studyID, status); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit restore: %w", err)
+	}
+	return nil
+}
+
+// ListTrash implements TrashStore.
+func (s *Store) ListTrash(ctx context.Context, purgeBefore time.Time, limit int) ([]models.TrashEntry, error) {
+	rows, err := s.pool.Query(ctx, `
+        SELECT `+trashColumns+` FROM study_trash
+        WHERE $1::timestamptz IS NULL OR purge_after < $1
+        ORDER BY purge_after, study_id
+        LIMIT $2`, nullTime(purgeBefore), limit)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error querying trash", "error", err)
+		return nil, fmt.Errorf("failed to query trash: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.TrashEntry{}
+	for rows.Next() {
+		e, err := scanTrashEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan trash entry: %w", err)
+		}
+		entries = append(entries, *e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate trash: %w", err)
+	}
+	return entries, nil
+}
+
+func scanTrashEntry(row pgx.Row) (*models.TrashEntry, error) {
+	var e models.TrashEntry
+	var reason sql.NullString
+	if err := row.Scan(&e.StudyID, &e.PreviousStatus.Tier, &e.PreviousStatus.LocationType, &e.PreviousStatus.EdgeID,
+		&reason, &e.TrashedBy, &e.TrashedAt, &e.PurgeAfter); err != nil {
+		return nil, err
+	}
+	e.Reason = reason.String
+	return &e, nil
+}
+
+// nullTime maps the zero time to SQL NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
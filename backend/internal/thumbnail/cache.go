@@ -0,0 +1,213 @@
+// File: backend/internal/thumbnail/cache.go
+package thumbnail
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiskCache keeps thumbnails as files below a directory and evicts the least
+// recently used ones once their total size exceeds a budget. Keys are
+// slash-separated relative paths; the first element groups a study's files
+// so they can be dropped together.
+type DiskCache struct {
+	dir      string
+	maxBytes int64
+
+	mu    sync.Mutex
+	lru   *list.List               // Front is most recently used
+	items map[string]*list.Element // By key
+	size  int64
+}
+
+type cacheItem struct {
+	key  string
+	size int64
+}
+
+// NewDiskCache opens (creating if needed) a cache in dir. Files left by a
+// previous run are indexed in modification-time order, so the cache stays
+// warm across restarts.
+func NewDiskCache(dir string, maxBytes int64) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create thumbnail cache directory %s: %w", dir, err)
+	}
+	c := &DiskCache{dir: dir, maxBytes: maxBytes, lru: list.New(), items: make(map[string]*list.Element)}
+
+	type found struct {
+		key     string
+		size    int64
+		modTime time.Time
+	}
+	var files []found
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") { // Interrupted write
+			os.Remove(path)
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, found{key: filepath.ToSlash(rel), size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to index thumbnail cache %s: %w", dir, err)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+	for _, f := range files {
+		c.items[f.key] = c.lru.PushBack(&cacheItem{key: f.key, size: f.size})
+		c.size += f.size
+	}
+	c.mu.Lock()
+	c.evict()
+	c.mu.Unlock()
+	slog.Info("Thumbnail cache ready", "path", dir, "files", len(c.items), "bytes", c.size, "maxBytes", maxBytes)
+	return c, nil
+}
+
+// path resolves a key below the cache directory, rejecting anything that escapes it.
+func (c *DiskCache) path(key string) (string, error) {
+	if key == "" || strings.Contains(key, "..") || strings.HasPrefix(key, "/") {
+		return "", fmt.Errorf("invalid cache key %q", key)
+	}
+	return filepath.Join(c.dir, filepath.FromSlash(key)), nil
+}
+
+// Get returns the cached bytes for key.
+func (c *DiskCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	elem, ok := c.items[key]
+	if ok {
+		c.lru.MoveToFront(elem)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	path, err := c.path(key)
+	if err != nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("Failed to read cached thumbnail", "key", key, "error", err)
+		}
+		c.remove(func(k string) bool { return k == key })
+		return nil, false
+	}
+	// Keeps the order right for the next restart; failures only cost accuracy
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return data, true
+}
+
+// Put stores data under key, replacing any previous value, and evicts least
+// recently used entries until the cache fits its budget again.
+func (c *DiskCache) Put(key string, data []byte) error {
+	path, err := c.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to create cache directory for %s: %w", key, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".thumb-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for %s: %w", key, err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cached thumbnail %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close cached thumbnail %s: %w", key, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to move cached thumbnail %s into place: %w", key, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		item := elem.Value.(*cacheItem)
+		c.size -= item.size
+		item.size = int64(len(data))
+		c.lru.MoveToFront(elem)
+	} else {
+		c.items[key] = c.lru.PushFront(&cacheItem{key: key, size: int64(len(data))})
+	}
+	c.size += int64(len(data))
+	c.evict()
+	return nil
+}
+
+// RemovePrefix deletes every entry whose key starts with prefix.
+func (c *DiskCache) RemovePrefix(prefix string) int {
+	return c.remove(func(k string) bool { return strings.HasPrefix(k, prefix) })
+}
+
+// Stats returns the number of cached files and their total size.
+func (c *DiskCache) Stats() (int, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items), c.size
+}
+
+func (c *DiskCache) remove(match func(key string) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := 0
+	for key, elem := range c.items {
+		if match(key) {
+			c.drop(elem)
+			removed++
+		}
+	}
+	return removed
+}
+
+// evict drops entries from the back of the LRU list until the cache fits.
+// Callers hold c.mu.
+func (c *DiskCache) evict() {
+	for c.maxBytes > 0 && c.size > c.maxBytes && c.lru.Len() > 0 {
+		c.drop(c.lru.Back())
+	}
+}
+
+// drop removes one entry and its file. Callers hold c.mu.
+func (c *DiskCache) drop(elem *list.Element) {
+	item := elem.Value.(*cacheItem)
+	c.lru.Remove(elem)
+	delete(c.items, item.key)
+	c.size -= item.size
+	if path, err := c.path(item.key); err == nil {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("Failed to remove cached thumbnail", "key", item.key, "error", err)
+		}
+		// Drop the study directory once it is empty; fails harmlessly otherwise
+		if dir := filepath.Dir(path); dir != filepath.Clean(c.dir) {
+			os.Remove(dir)
+		}
+	}
+}
@@ -0,0 +1,471 @@
+// File: backend/internal/thumbnail/service.go
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ewag/gen-erics/backend/internal/dicom"
+	"github.com/ewag/gen-erics/backend/internal/integrity"
+	models "github.com/ewag/gen-erics/backend/internal/models"
+	"github.com/ewag/gen-erics/backend/internal/mover"
+	"github.com/ewag/gen-erics/backend/internal/orthanc"
+	"github.com/ewag/gen-erics/backend/internal/render"
+	"github.com/ewag/gen-erics/backend/internal/storage"
+	"github.com/ewag/gen-erics/backend/internal/tiers"
+)
+
+// Levels a thumbnail can be requested for.
+const (
+	LevelStudy  = "study"
+	LevelSeries = "series"
+)
+
+// maxRenderedBytes bounds what is read back from Orthanc for one thumbnail.
+const maxRenderedBytes = 8 << 20
+
+// ErrNoImage is returned when a study or series has nothing renderable
+// (e.g. only structured reports).
+var ErrNoImage = errors.New("no renderable image")
+
+// ErrNeedsRecall is returned when the representative image is stored in a
+// transfer syntax only Orthanc decodes, so its study must be recalled first.
+var ErrNeedsRecall = errors.New("image can only be rendered once its study is recalled")
+
+// nonImageModalities never make useful thumbnails.
+var nonImageModalities = map[string]bool{
+	"SR": true, "PR": true, "KO": true, "DOC": true, "REG": true, "FID": true,
+	"RTSTRUCT": true, "RTPLAN": true, "RTRECORD": true, "PLAN": true, "AU": true, "ECG": true,
+}
+
+// Config controls thumbnail generation.
+type Config struct {
+	Size    int // Longest edge in pixels
+	Quality int // JPEG quality
+	// VersionTTL is how long a hot study's Orthanc LastUpdate is trusted before
+	// it is fetched again; it bounds how stale a thumbnail of a modified study can be.
+	VersionTTL time.Duration
+}
+
+// Ref identifies one thumbnail at the current version of its study.
+type Ref struct {
+	Level   string
+	ID      string // Orthanc study or series ID
+	StudyID string
+	Tier    string
+	Key     string // Cache key
+	ETag    string // Quoted strong ETag
+
+	entries []models.CatalogEntry // Series entries found while resolving a non-hot series
+}
+
+// Service generates representative-image thumbnails for studies and series
+// and caches them on disk. Hot studies are rendered by Orthanc, other tiers
+// by the internal renderer.
+type Service struct {
+	orthanc *orthanc.Client
+	status  storage.StatusStore
+	catalog storage.CatalogStore
+	tiers   *tiers.Registry
+	cache   *DiskCache
+	cfg     Config
+
+	mu       sync.Mutex
+	versions map[string]studyVersion // Hot study ID -> Orthanc LastUpdate
+	parents  map[string]string       // Hot series ID -> study ID
+	inflight map[string]*call        // Cache key -> generation in progress
+}
+
+type studyVersion struct {
+	lastUpdate string
+	fetchedAt  time.Time
+}
+
+type call struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// maxMemoEntries caps the version and parent memos; they are simply reset when full.
+const maxMemoEntries = 10000
+
+// NewService creates a Service.
+func NewService(orthancClient *orthanc.Client, status storage.StatusStore, catalog storage.CatalogStore, registry *tiers.Registry, cache *DiskCache, cfg Config) *Service {
+	if cfg.Size <= 0 {
+		cfg.Size = 256
+	}
+	if cfg.Quality <= 0 || cfg.Quality > 100 {
+		cfg.Quality = 80
+	}
+	if cfg.VersionTTL <= 0 {
+		cfg.VersionTTL = time.Minute
+	}
+	return &Service{
+		orthanc:  orthancClient,
+		status:   status,
+		catalog:  catalog,
+		tiers:    registry,
+		cache:    cache,
+		cfg:      cfg,
+		versions: make(map[string]studyVersion),
+		parents:  make(map[string]string),
+		inflight: make(map[string]*call),
+	}
+}
+
+// MaxAge is how long clients may reuse a thumbnail without revalidating.
+func (s *Service) MaxAge() time.Duration { return s.cfg.VersionTTL }
+
+// Resolve finds the study, tier and version behind a study or series
+// thumbnail without generating it, so conditional requests stay cheap.
+func (s *Service) Resolve(ctx context.Context, level, id string) (*Ref, error) {
+	ref := &Ref{Level: level, ID: id}
+	switch level {
+	case LevelStudy:
+		ref.StudyID = id
+		ref.Tier = mover.TierHot
+		status, found, err := s.status.GetStatus(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			ref.Tier = status.Tier
+		}
+	case LevelSeries:
+		entries, err := s.catalog.ListCatalogEntriesBySeries(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) > 0 {
+			ref.StudyID, ref.Tier, ref.entries = entries[0].StudyID, entries[0].Tier, entries
+			break
+		}
+		if ref.StudyID, err = s.seriesParent(ctx, id); err != nil {
+			return nil, err
+		}
+		ref.Tier = mover.TierHot
+	default:
+		return nil, fmt.Errorf("unknown thumbnail level %q", level)
+	}
+
+	version := ref.Tier
+	if ref.Tier == mover.TierHot {
+		lastUpdate, err := s.hotVersion(ctx, ref.StudyID)
+		if err != nil {
+			return nil, err
+		}
+		version += "|" + lastUpdate
+	}
+	sum := sha256.Sum256([]byte(version))
+	hash := hex.EncodeToString(sum[:6])
+	ref.Key = fmt.Sprintf("%s/%s-%s-%d-%s.jpg", ref.StudyID, level, id, s.cfg.Size, hash)
+	ref.ETag = fmt.Sprintf("\"%s-thumbnail-%d-%s\"", id, s.cfg.Size, hash)
+	return ref, nil
+}
+
+// Fetch returns the thumbnail for ref from the cache, generating it on a
+// miss. Concurrent misses for the same thumbnail share one generation.
+func (s *Service) Fetch(ctx context.Context, ref *Ref) ([]byte, error) {
+	if s.cache != nil {
+		if data, ok := s.cache.Get(ref.Key); ok {
+			return data, nil
+		}
+	}
+
+	s.mu.Lock()
+	if c, ok := s.inflight[ref.Key]; ok {
+		s.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.data, c.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	c := &call{done: make(chan struct{})}
+	s.inflight[ref.Key] = c
+	s.mu.Unlock()
+
+	// Detached so a client hanging up doesn't fail the callers waiting on it
+	genCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+	c.data, c.err = s.generate(genCtx, ref)
+	cancel()
+	if c.err == nil && s.cache != nil {
+		// Older versions of the same thumbnail are unreachable now
+		prefix := fmt.Sprintf("%s/%s-%s-%d-", ref.StudyID, ref.Level, ref.ID, s.cfg.Size)
+		s.cache.RemovePrefix(prefix)
+		if err := s.cache.Put(ref.Key, c.data); err != nil {
+			slog.WarnContext(ctx, "Failed to cache thumbnail", "key", ref.Key, "error", err)
+		}
+	}
+
+	s.mu.Lock()
+	delete(s.inflight, ref.Key)
+	s.mu.Unlock()
+	close(c.done)
+	return c.data, c.err
+}
+
+// InvalidateStudy drops every cached thumbnail of a study (its own and its
+// series') and forgets its Orthanc version. Call it after the study is
+// moved or modified.
+func (s *Service) InvalidateStudy(studyID string) {
+	s.mu.Lock()
+	delete(s.versions, studyID)
+	s.mu.Unlock()
+	if s.cache == nil {
+		return
+	}
+	if n := s.cache.RemovePrefix(studyID + "/"); n > 0 {
+		slog.Info("Invalidated cached thumbnails", "studyID", studyID, "count", n)
+	}
+}
+
+// hotVersion returns Orthanc's LastUpdate for a study, memoised for VersionTTL.
+func (s *Service) hotVersion(ctx context.Context, studyID string) (string, error) {
+	s.mu.Lock()
+	v, ok := s.versions[studyID]
+	s.mu.Unlock()
+	if ok && time.Since(v.fetchedAt) < s.cfg.VersionTTL {
+		return v.lastUpdate, nil
+	}
+
+	details, err := s.orthanc.GetStudyDetails(ctx, studyID)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	if len(s.versions) >= maxMemoEntries {
+		s.versions = make(map[string]studyVersion)
+	}
+	s.versions[studyID] = studyVersion{lastUpdate: details.LastUpdate, fetchedAt: time.Now()}
+	s.mu.Unlock()
+	return details.LastUpdate, nil
+}
+
+// seriesParent returns the study of a hot series. A series never changes study.
+func (s *Service) seriesParent(ctx context.Context, seriesID string) (string, error) {
+	s.mu.Lock()
+	parent, ok := s.parents[seriesID]
+	s.mu.Unlock()
+	if ok {
+		return parent, nil
+	}
+
+	details, err := s.orthanc.GetSeriesDetails(ctx, seriesID)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	if len(s.parents) >= maxMemoEntries {
+		s.parents = make(map[string]string)
+	}
+	s.parents[seriesID] = details.ParentStudy
+	s.mu.Unlock()
+	return details.ParentStudy, nil
+}
+
+// candidate is one series to choose a representative image from.
+type candidate struct {
+	seriesNumber string
+	modality     string
+	instances    []instance
+}
+
+type instance struct {
+	id     string
+	number string
+	entry  *models.CatalogEntry // Set for non-hot instances
+}
+
+func (s *Service) generate(ctx context.Context, ref *Ref) ([]byte, error) {
+	var candidates []candidate
+	var err error
+	if ref.Tier == mover.TierHot {
+		candidates, err = s.hotCandidates(ctx, ref)
+	} else {
+		candidates, err = s.tierCandidates(ctx, ref)
+	}
+	if err != nil {
+		return nil, err
+	}
+	inst, ok := representative(candidates)
+	if !ok {
+		return nil, ErrNoImage
+	}
+
+	start := time.Now()
+	var data []byte
+	if inst.entry == nil {
+		data, err = s.renderHot(ctx, inst.id)
+	} else {
+		data, err = s.renderTier(ctx, inst.entry)
+	}
+	if err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Generated thumbnail", "level", ref.Level, "id", ref.ID, "instanceID", inst.id,
+		"tier", ref.Tier, "bytes", len(data), "duration", time.Since(start))
+	return data, nil
+}
+
+func (s *Service) hotCandidates(ctx context.Context, ref *Ref) ([]candidate, error) {
+	if ref.Level == LevelSeries {
+		hot, err := s.orthanc.GetSeriesInstances(ctx, ref.ID)
+		if err != nil {
+			return nil, err
+		}
+		c := candidate{}
+		for _, inst := range hot {
+			c.instances = append(c.instances, instance{id: inst.ID, number: inst.MainTags.InstanceNumber})
+		}
+		return []candidate{c}, nil
+	}
+
+	series, err := s.orthanc.GetStudySeries(ctx, ref.StudyID)
+	if err != nil {
+		return nil, err
+	}
+	instances, err := s.orthanc.GetStudyInstances(ctx, ref.StudyID)
+	if err != nil {
+		return nil, err
+	}
+	bySeries := make(map[string]*candidate, len(series))
+	candidates := make([]candidate, len(series))
+	for i, se := range series {
+		candidates[i] = candidate{seriesNumber: se.MainTags.SeriesNumber, modality: se.MainTags.Modality}
+		bySeries[se.ID] = &candidates[i]
+	}
+	for _, inst := range instances {
+		if c, ok := bySeries[inst.ParentSeries]; ok {
+			c.instances = append(c.instances, instance{id: inst.ID, number: inst.MainTags.InstanceNumber})
+		}
+	}
+	return candidates, nil
+}
+
+func (s *Service) tierCandidates(ctx context.Context, ref *Ref) ([]candidate, error) {
+	entries := ref.entries
+	if entries == nil {
+		var err error
+		if entries, err = s.catalog.ListCatalogEntries(ctx, ref.StudyID, ref.Tier); err != nil {
+			return nil, err
+		}
+	}
+	bySeries := make(map[string]int)
+	var candidates []candidate
+	for i := range entries {
+		e := &entries[i]
+		if e.Tier != ref.Tier {
+			continue
+		}
+		idx, ok := bySeries[e.SeriesID]
+		if !ok {
+			idx = len(candidates)
+			bySeries[e.SeriesID] = idx
+			candidates = append(candidates, candidate{seriesNumber: e.SeriesNumber, modality: e.Modality})
+		}
+		candidates[idx].instances = append(candidates[idx].instances, instance{id: e.InstanceID, number: e.InstanceNumber, entry: e})
+	}
+	return candidates, nil
+}
+
+// representative picks the middle instance (by instance number) of the
+// largest image series, preferring lower series numbers on ties.
+func representative(candidates []candidate) (instance, bool) {
+	var best *candidate
+	for _, imagesOnly := range []bool{true, false} {
+		for i := range candidates {
+			c := &candidates[i]
+			if len(c.instances) == 0 || (imagesOnly && nonImageModalities[c.modality]) {
+				continue
+			}
+			if best == nil || len(c.instances) > len(best.instances) ||
+				(len(c.instances) == len(best.instances) && number(c.seriesNumber) < number(best.seriesNumber)) {
+				best = c
+			}
+		}
+		if best != nil {
+			break
+		}
+	}
+	if best == nil {
+		return instance{}, false
+	}
+	instances := best.instances
+	sort.SliceStable(instances, func(i, j int) bool { return number(instances[i].number) < number(instances[j].number) })
+	return instances[len(instances)/2], true
+}
+
+// number parses a DICOM IS value for ordering; missing values sort last.
+func number(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return int(^uint(0) >> 1)
+	}
+	return n
+}
+
+func (s *Service) renderHot(ctx context.Context, instanceID string) ([]byte, error) {
+	rendered, err := s.orthanc.GetRenderedFrame(ctx, instanceID, 0, orthanc.RenderOptions{
+		Width:   s.cfg.Size,
+		Height:  s.cfg.Size,
+		Quality: s.cfg.Quality,
+		Accept:  render.FormatJPEG.ContentType(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer rendered.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(rendered.Body, maxRenderedBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rendered thumbnail for instance %s: %w", instanceID, err)
+	}
+	return data, nil
+}
+
+func (s *Service) renderTier(ctx context.Context, entry *models.CatalogEntry) ([]byte, error) {
+	if !dicom.CanDecode(entry.TransferSyntaxUID) {
+		return nil, fmt.Errorf("%w: instance %s is stored as %s", ErrNeedsRecall, entry.InstanceID, entry.TransferSyntaxUID)
+	}
+	backend, ok := s.tiers.For(entry.Tier)
+	if !ok {
+		return nil, fmt.Errorf("no backend configured for tier %s", entry.Tier)
+	}
+	rc, _, err := tiers.OpenEntry(ctx, backend, entry.ObjectKey, entry.EncryptionKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open instance %s in tier %s: %w", entry.InstanceID, entry.Tier, err)
+	}
+	defer rc.Close()
+
+	ds, err := dicom.Parse(integrity.NewVerifyingReader(rc, entry.SHA256, entry.SizeBytes), dicom.ParseOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read instance %s: %w", entry.InstanceID, err)
+	}
+	if !dicom.CanDecode(ds.TransferSyntax) {
+		return nil, fmt.Errorf("%w: instance %s is stored as %s", ErrNeedsRecall, entry.InstanceID, ds.TransferSyntax)
+	}
+	img, err := render.Render(ds, render.Params{Width: s.cfg.Size, Height: s.cfg.Size})
+	if err != nil {
+		if errors.Is(err, dicom.ErrNoPixelData) {
+			return nil, ErrNoImage
+		}
+		return nil, fmt.Errorf("failed to render instance %s: %w", entry.InstanceID, err)
+	}
+	var buf bytes.Buffer
+	if err := render.Encode(&buf, img, render.FormatJPEG, s.cfg.Quality); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
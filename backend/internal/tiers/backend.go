@@ -0,0 +1,61 @@
+// File: backend/internal/tiers/backend.go
+package tiers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrObjectNotFound is returned by a Backend when the requested key does not exist.
+var ErrObjectNotFound = errors.New("object not found in tier backend")
+
+// Backend is the storage behind a non-hot tier (cold, archive).
+// Hot data lives in Orthanc; everything else goes through a Backend.
+type Backend interface {
+	// Name identifies the backend in logs, metrics and the catalog.
+	Name() string
+	// Put writes the object under key, replacing any existing object.
+	// Returns the number of bytes written.
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	// Open returns a reader for the object and its size in bytes.
+	Open(ctx context.Context, key string) (io.ReadCloser, int64, error)
+	// Delete removes the object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Registry maps tier names (e.g. "cold", "archive") to their backends.
+type Registry struct {
+	backends map[string]Backend
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{backends: make(map[string]Backend)}
+}
+
+// Register attaches a backend to a tier, replacing any previous one.
+func (r *Registry) Register(tier string, b Backend) {
+	r.backends[tier] = b
+}
+
+// For returns the backend for a tier, if one is configured.
+func (r *Registry) For(tier string) (Backend, bool) {
+	b, ok := r.backends[tier]
+	return b, ok
+}
+
+// Tiers returns the names of all tiers with a configured backend.
+func (r *Registry) Tiers() []string {
+	names := make([]string, 0, len(r.backends))
+	for name := range r.backends {
+		names = append(names, name)
+	}
+	return names
+}
+
+// ObjectKey builds the key an instance is stored under in a tier backend.
+func ObjectKey(studyID, instanceID string) string {
+	return fmt.Sprintf("%s/%s.dcm", studyID, instanceID)
+}
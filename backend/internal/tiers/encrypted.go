@@ -0,0 +1,313 @@
+// File: backend/internal/tiers/encrypted.go
+package tiers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Encrypted object layout:
+//
+//	magic "GENCRYPT" | version (1) | key ID length (1) | key ID | nonce prefix (8)
+//	chunk 0 | chunk 1 | ... each AES-256-GCM sealed, encChunkSize plaintext bytes
+//	                        except the last, which may be shorter (or empty)
+//
+// Chunk nonces are the prefix followed by the big-endian chunk index; the
+// additional data is the object key plus a final-chunk flag, so chunks can't
+// be reordered, truncated or moved to another object.
+const (
+	encMagic       = "GENCRYPT"
+	encVersion     = 1
+	encChunkSize   = 64 * 1024
+	encNoncePrefix = 8
+	encTagSize     = 16
+)
+
+// ErrDecrypt is returned when an encrypted object fails authentication.
+var ErrDecrypt = errors.New("object failed authenticated decryption")
+
+// ErrNotEncrypted is returned when an encrypted backend holds an object
+// without an encryption header and plaintext reads aren't allowed.
+var ErrNotEncrypted = errors.New("object is not encrypted")
+
+// KeyIdentifier is implemented by backends that encrypt objects, so callers
+// can record which data key protects an object.
+type KeyIdentifier interface {
+	// KeyID returns the ID of the data key new objects under key are encrypted with.
+	KeyID(ctx context.Context, key string) (string, error)
+}
+
+// EncryptedBackend adds envelope encryption to another Backend. Each study
+// has its own data key (see Keys); the wrapped backend only ever sees
+// ciphertext. Sizes reported by Put and Open are plaintext sizes, so
+// catalogued sizes and checksums describe the DICOM object itself. Objects
+// without an encryption header are refused, since anyone able to write to
+// the underlying store could otherwise swap in plaintext; allowPlaintext
+// reads them as they are while objects written before encryption was
+// enabled are migrated.
+type EncryptedBackend struct {
+	Backend
+	keys           *Keys
+	allowPlaintext bool
+}
+
+// NewEncryptedBackend wraps b.
+func NewEncryptedBackend(b Backend, keys *Keys, allowPlaintext bool) *EncryptedBackend {
+	return &EncryptedBackend{Backend: b, keys: keys, allowPlaintext: allowPlaintext}
+}
+
+// KeyID implements KeyIdentifier.
+func (b *EncryptedBackend) KeyID(ctx context.Context, key string) (string, error) {
+	id, _, err := b.keys.ForStudy(ctx, studyOfKey(key))
+	return id, err
+}
+
+// Put implements Backend, returning the number of plaintext bytes stored.
+func (b *EncryptedBackend) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	keyID, dataKey, err := b.keys.ForStudy(ctx, studyOfKey(key))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get data key for %s: %w", key, err)
+	}
+	if len(keyID) > 255 {
+		return 0, fmt.Errorf("data key id %q too long", keyID)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return 0, err
+	}
+
+	header := make([]byte, 0, len(encMagic)+2+len(keyID)+encNoncePrefix)
+	header = append(header, encMagic...)
+	header = append(header, encVersion, byte(len(keyID)))
+	header = append(header, keyID...)
+	prefix := make([]byte, encNoncePrefix)
+	if _, err := rand.Read(prefix); err != nil {
+		return 0, fmt.Errorf("failed to generate nonce prefix: %w", err)
+	}
+	header = append(header, prefix...)
+
+	enc := &encryptingReader{src: bufio.NewReaderSize(r, encChunkSize), aead: aead, prefix: prefix, objectKey: key}
+	if _, err := b.Backend.Put(ctx, key, io.MultiReader(bytes.NewReader(header), enc)); err != nil {
+		return enc.plain, err
+	}
+	return enc.plain, nil
+}
+
+// Open implements Backend, returning a decrypting reader and the plaintext size.
+func (b *EncryptedBackend) Open(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	return b.open(ctx, key, b.allowPlaintext)
+}
+
+// OpenEntry opens a catalogued object. An object catalogued with a data key
+// (keyID) must still carry its encryption header, whatever the backend's
+// plaintext setting, and can't be read from a tier without encryption.
+func OpenEntry(ctx context.Context, b Backend, key, keyID string) (io.ReadCloser, int64, error) {
+	if keyID == "" {
+		return b.Open(ctx, key)
+	}
+	eb, ok := b.(*EncryptedBackend)
+	if !ok {
+		return nil, 0, fmt.Errorf("object %s is catalogued as encrypted with data key %s but tier %s has no encryption", key, keyID, b.Name())
+	}
+	return eb.open(ctx, key, false)
+}
+
+func (b *EncryptedBackend) open(ctx context.Context, key string, allowPlaintext bool) (io.ReadCloser, int64, error) {
+	rc, size, err := b.Backend.Open(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	br := bufio.NewReaderSize(rc, encChunkSize+encTagSize)
+	magic, err := br.Peek(len(encMagic) + 2)
+	if err != nil && err != io.EOF {
+		rc.Close()
+		return nil, 0, fmt.Errorf("object %s: failed to read encryption header: %w", key, err)
+	}
+	if len(magic) < len(encMagic)+2 || string(magic[:len(encMagic)]) != encMagic {
+		if !allowPlaintext {
+			rc.Close()
+			return nil, 0, fmt.Errorf("object %s: %w", key, ErrNotEncrypted)
+		}
+		// Stored before encryption was enabled
+		return readCloser{Reader: br, Closer: rc}, size, nil
+	}
+	if magic[len(encMagic)] != encVersion {
+		rc.Close()
+		return nil, 0, fmt.Errorf("object %s: unsupported encryption version %d", key, magic[len(encMagic)])
+	}
+	headerLen := len(encMagic) + 2 + int(magic[len(encMagic)+1]) + encNoncePrefix
+	header := make([]byte, headerLen)
+	if _, err := io.ReadFull(br, header); err != nil {
+		rc.Close()
+		return nil, 0, fmt.Errorf("object %s: failed to read encryption header: %w", key, err)
+	}
+	keyID := string(header[len(encMagic)+2 : headerLen-encNoncePrefix])
+	dataKey, err := b.keys.ByID(ctx, keyID)
+	if err != nil {
+		rc.Close()
+		return nil, 0, fmt.Errorf("object %s: %w", key, err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		rc.Close()
+		return nil, 0, err
+	}
+
+	plainSize, ok := plaintextSize(size - int64(headerLen))
+	if !ok {
+		rc.Close()
+		return nil, 0, fmt.Errorf("object %s: %w: invalid ciphertext length", key, ErrDecrypt)
+	}
+	dec := &decryptingReader{src: br, aead: aead, prefix: header[headerLen-encNoncePrefix:], objectKey: key}
+	return readCloser{Reader: dec, Closer: rc}, plainSize, nil
+}
+
+// plaintextSize derives the plaintext length from the chunked ciphertext length.
+func plaintextSize(ciphertext int64) (int64, bool) {
+	if ciphertext < encTagSize {
+		return 0, false
+	}
+	chunks := (ciphertext + encChunkSize + encTagSize - 1) / (encChunkSize + encTagSize)
+	last := ciphertext - (chunks-1)*(encChunkSize+encTagSize)
+	if last < encTagSize {
+		return 0, false
+	}
+	return ciphertext - chunks*encTagSize, true
+}
+
+// studyOfKey returns the study an object key belongs to (see ObjectKey).
+func studyOfKey(key string) string {
+	study, _, _ := strings.Cut(key, "/")
+	return study
+}
+
+func chunkNonce(prefix []byte, index uint32) []byte {
+	nonce := make([]byte, encNoncePrefix+4)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[encNoncePrefix:], index)
+	return nonce
+}
+
+func chunkAAD(objectKey string, final bool) []byte {
+	flag := byte(0)
+	if final {
+		flag = 1
+	}
+	return append([]byte(objectKey), flag)
+}
+
+// encryptingReader seals its source chunk by chunk as it is read.
+type encryptingReader struct {
+	src       *bufio.Reader
+	aead      cipher.AEAD
+	prefix    []byte
+	objectKey string
+
+	index uint32
+	buf   []byte // Sealed bytes not yet returned
+	plain int64
+	done  bool
+}
+
+func (e *encryptingReader) Read(p []byte) (int, error) {
+	for len(e.buf) == 0 {
+		if e.done {
+			return 0, io.EOF
+		}
+		if err := e.sealNext(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, e.buf)
+	e.buf = e.buf[n:]
+	return n, nil
+}
+
+func (e *encryptingReader) sealNext() error {
+	chunk := make([]byte, encChunkSize)
+	n, err := io.ReadFull(e.src, chunk)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	final := n < encChunkSize
+	if !final {
+		// A full chunk is the last one if nothing follows it
+		if _, err := e.src.Peek(1); err == io.EOF {
+			final = true
+		} else if err != nil {
+			return err
+		}
+	}
+	if e.index == ^uint32(0) {
+		return fmt.Errorf("object %s too large to encrypt", e.objectKey)
+	}
+	e.buf = e.aead.Seal(nil, chunkNonce(e.prefix, e.index), chunk[:n], chunkAAD(e.objectKey, final))
+	e.plain += int64(n)
+	e.index++
+	e.done = final
+	return nil
+}
+
+// decryptingReader opens chunks as they are read, failing with ErrDecrypt on
+// any tampering, truncation or trailing data.
+type decryptingReader struct {
+	src       *bufio.Reader
+	aead      cipher.AEAD
+	prefix    []byte
+	objectKey string
+
+	index uint32
+	buf   []byte // Opened bytes not yet returned
+	done  bool
+}
+
+func (d *decryptingReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.openNext(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+func (d *decryptingReader) openNext() error {
+	sealed := make([]byte, encChunkSize+encTagSize)
+	n, err := io.ReadFull(d.src, sealed)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	final := n < len(sealed)
+	if !final {
+		if _, err := d.src.Peek(1); err == io.EOF {
+			final = true
+		} else if err != nil {
+			return err
+		}
+	}
+	plain, err := d.aead.Open(sealed[:0], chunkNonce(d.prefix, d.index), sealed[:n], chunkAAD(d.objectKey, final))
+	if err != nil {
+		return fmt.Errorf("object %s chunk %d: %w", d.objectKey, d.index, ErrDecrypt)
+	}
+	d.buf = plain
+	d.index++
+	d.done = final
+	return nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
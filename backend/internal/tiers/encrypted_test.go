@@ -0,0 +1,199 @@
+package tiers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+// memBackend keeps objects in memory.
+type memBackend struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newMemBackend() *memBackend { return &memBackend{objects: make(map[string][]byte)} }
+
+func (b *memBackend) Name() string { return "mem" }
+
+func (b *memBackend) Put(_ context.Context, key string, r io.Reader) (int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.objects[key] = data
+	return int64(len(data)), nil
+}
+
+func (b *memBackend) Open(_ context.Context, key string) (io.ReadCloser, int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	data, ok := b.objects[key]
+	if !ok {
+		return nil, 0, ErrObjectNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), int64(len(data)), nil
+}
+
+func (b *memBackend) Delete(_ context.Context, key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.objects, key)
+	return nil
+}
+
+// testPlaintext returns n bytes that differ from chunk to chunk.
+func testPlaintext(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i*7 + i/encChunkSize)
+	}
+	return data
+}
+
+// readObject opens key and reads it whole.
+func readObject(ctx context.Context, b Backend, key string) ([]byte, int64, error) {
+	rc, size, err := b.Open(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	return data, size, err
+}
+
+func TestEncryptedBackendRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	for _, size := range []int{0, 1, 1000, encChunkSize - 1, encChunkSize, encChunkSize + 1, 3*encChunkSize + 5} {
+		t.Run(fmt.Sprintf("%d bytes", size), func(t *testing.T) {
+			inner := newMemBackend()
+			b := NewEncryptedBackend(inner, NewKeys(newMemKeyStore(), testKeyring(t)), false)
+			plain := testPlaintext(size)
+			const key = "study-1/series-1/instance-1"
+
+			n, err := b.Put(ctx, key, bytes.NewReader(plain))
+			if err != nil || n != int64(size) {
+				t.Fatalf("Put() = %d, %v, want %d", n, err, size)
+			}
+			if stored := inner.objects[key]; size > 16 && bytes.Contains(stored, plain[:16]) {
+				t.Fatal("stored object contains plaintext")
+			}
+			got, gotSize, err := readObject(ctx, b, key)
+			if err != nil {
+				t.Fatalf("read error = %v", err)
+			}
+			if gotSize != int64(size) || !bytes.Equal(got, plain) {
+				t.Errorf("read %d bytes, size %d, want %d matching bytes", len(got), gotSize, size)
+			}
+		})
+	}
+}
+
+func TestEncryptedBackendTampering(t *testing.T) {
+	ctx := context.Background()
+	const key = "study-1/series-1/instance-1"
+	sealedChunk := encChunkSize + encTagSize
+	tests := []struct {
+		name   string
+		key    string // Where the modified object is stored; defaults to key
+		modify func(stored []byte, header int) []byte
+	}{
+		{name: "flipped byte in the first chunk", modify: func(s []byte, h int) []byte {
+			s[h+10] ^= 1
+			return s
+		}},
+		{name: "flipped byte in the last tag", modify: func(s []byte, h int) []byte {
+			s[len(s)-1] ^= 1
+			return s
+		}},
+		{name: "flipped nonce prefix", modify: func(s []byte, h int) []byte {
+			s[h-1] ^= 1
+			return s
+		}},
+		{name: "last chunk dropped", modify: func(s []byte, h int) []byte {
+			return s[:h+2*sealedChunk]
+		}},
+		{name: "truncated inside a chunk", modify: func(s []byte, h int) []byte {
+			return s[:h+sealedChunk+100]
+		}},
+		{name: "trailing data", modify: func(s []byte, h int) []byte {
+			return append(s, make([]byte, encTagSize+1)...)
+		}},
+		{name: "chunks swapped", modify: func(s []byte, h int) []byte {
+			first := bytes.Clone(s[h : h+sealedChunk])
+			copy(s[h:], s[h+sealedChunk:h+2*sealedChunk])
+			copy(s[h+sealedChunk:], first)
+			return s
+		}},
+		{name: "moved to another object", key: "study-1/series-1/instance-2", modify: func(s []byte, h int) []byte {
+			return s
+		}},
+		{name: "shorter than a tag", modify: func(s []byte, h int) []byte {
+			return s[:h+encTagSize-1]
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := newMemBackend()
+			b := NewEncryptedBackend(inner, NewKeys(newMemKeyStore(), testKeyring(t)), false)
+			if _, err := b.Put(ctx, key, bytes.NewReader(testPlaintext(2*encChunkSize+500))); err != nil {
+				t.Fatal(err)
+			}
+			stored := bytes.Clone(inner.objects[key])
+			header := len(encMagic) + 2 + int(stored[len(encMagic)+1]) + encNoncePrefix
+			target := key
+			if tt.key != "" {
+				target = tt.key
+			}
+			inner.objects[target] = tt.modify(stored, header)
+
+			if _, _, err := readObject(ctx, b, target); !errors.Is(err, ErrDecrypt) {
+				t.Errorf("read error = %v, want ErrDecrypt", err)
+			}
+		})
+	}
+}
+
+func TestEncryptedBackendPlaintext(t *testing.T) {
+	ctx := context.Background()
+	const key = "study-1/series-1/instance-1"
+	plain := []byte("DICM plaintext stored before encryption")
+	tests := []struct {
+		name           string
+		allowPlaintext bool
+		keyID          string // Catalogued data key, read through OpenEntry
+		wantErr        error
+	}{
+		{name: "refused", wantErr: ErrNotEncrypted},
+		{name: "allowed while migrating", allowPlaintext: true},
+		{name: "catalogued as encrypted", allowPlaintext: true, keyID: "dk-1", wantErr: ErrNotEncrypted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := newMemBackend()
+			inner.objects[key] = plain
+			b := NewEncryptedBackend(inner, NewKeys(newMemKeyStore(), testKeyring(t)), tt.allowPlaintext)
+
+			rc, _, err := OpenEntry(ctx, b, key, tt.keyID)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("OpenEntry() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("OpenEntry() error = %v", err)
+			}
+			defer rc.Close()
+			if got, err := io.ReadAll(rc); err != nil || !bytes.Equal(got, plain) {
+				t.Errorf("read %q, %v, want the plaintext", got, err)
+			}
+		})
+	}
+}
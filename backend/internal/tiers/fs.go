@@ -0,0 +1,121 @@
+// File: backend/internal/tiers/fs.go
+package tiers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FSBackend stores objects as files below a root directory.
+// Good enough for a mounted volume (NFS, PVC) or local development.
+type FSBackend struct {
+	name string
+	root string
+}
+
+// NewFSBackend creates a filesystem backend rooted at dir, creating it if needed.
+func NewFSBackend(name, dir string) (*FSBackend, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create tier directory %s: %w", dir, err)
+	}
+	return &FSBackend{name: name, root: dir}, nil
+}
+
+// Name implements Backend.
+func (b *FSBackend) Name() string { return b.name }
+
+// path resolves a key below the root, rejecting anything that escapes it.
+func (b *FSBackend) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return filepath.Join(b.root, clean), nil
+}
+
+// Put implements Backend. The object is written to a temp file and renamed
+// into place so readers never see a partial object.
+func (b *FSBackend) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	target, err := b.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o750); err != nil {
+		return 0, fmt.Errorf("failed to create directory for %s: %w", key, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temp file for %s: %w", key, err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	n, err := io.Copy(tmp, contextReader{ctx: ctx, r: r})
+	if err != nil {
+		tmp.Close()
+		return n, fmt.Errorf("failed to write object %s: %w", key, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return n, fmt.Errorf("failed to sync object %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return n, fmt.Errorf("failed to close object %s: %w", key, err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return n, fmt.Errorf("failed to move object %s into place: %w", key, err)
+	}
+	return n, nil
+}
+
+// Open implements Backend.
+func (b *FSBackend) Open(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	target, err := b.path(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := os.Open(target)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, 0, fmt.Errorf("%s: %w", key, ErrObjectNotFound)
+		}
+		return nil, 0, fmt.Errorf("failed to open object %s: %w", key, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, fmt.Errorf("failed to stat object %s: %w", key, err)
+	}
+	return f, info.Size(), nil
+}
+
+// Delete implements Backend.
+func (b *FSBackend) Delete(ctx context.Context, key string) error {
+	target, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete object %s: %w", key, err)
+	}
+	return nil
+}
+
+// contextReader stops a long copy once the context is cancelled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
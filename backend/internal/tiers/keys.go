@@ -0,0 +1,318 @@
+// File: backend/internal/tiers/keys.go
+package tiers
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// dataKeySize is the AES-256 key length.
+const dataKeySize = 32
+
+// maxCachedKeys bounds the unwrapped data keys kept in memory.
+const maxCachedKeys = 4096
+
+// DataKey is a study's data encryption key as stored: wrapped by a master key.
+type DataKey struct {
+	ID          string     `json:"id"`
+	StudyID     string     `json:"studyId"`
+	Wrapped     []byte     `json:"-"`
+	MasterKeyID string     `json:"masterKeyId"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	RotatedAt   *time.Time `json:"rotatedAt,omitempty"`
+}
+
+// DataKeyStore persists wrapped data keys. It is implemented by the storage
+// package; the interface lives here so tiers does not depend on storage.
+type DataKeyStore interface {
+	// GetStudyDataKey returns the study's data key, found=false if it has none yet.
+	GetStudyDataKey(ctx context.Context, studyID string) (key *DataKey, found bool, err error)
+	GetDataKey(ctx context.Context, id string) (key *DataKey, found bool, err error)
+	// CreateDataKey stores key unless its study already has one, and returns
+	// the study's key either way, so concurrent writers agree on one key.
+	CreateDataKey(ctx context.Context, key DataKey) (*DataKey, error)
+	// ListDataKeysToRewrap returns up to limit keys with IDs after afterID
+	// that are not wrapped by masterKeyID, in ID order.
+	ListDataKeysToRewrap(ctx context.Context, masterKeyID, afterID string, limit int) ([]DataKey, error)
+	// RewrapDataKey replaces a key's wrapping if it is still wrapped by
+	// previousMasterKeyID, returning false if it was not.
+	RewrapDataKey(ctx context.Context, id string, wrapped []byte, masterKeyID, previousMasterKeyID string, at time.Time) (bool, error)
+}
+
+// KeyWrapper wraps data keys with a master key. FileKeyring keeps master keys
+// in a local file; a KMS client can implement it so they never enter the process.
+type KeyWrapper interface {
+	// CurrentKeyID names the master key new data keys are wrapped with.
+	CurrentKeyID() string
+	Wrap(ctx context.Context, masterKeyID string, dataKey []byte) ([]byte, error)
+	Unwrap(ctx context.Context, masterKeyID string, wrapped []byte) ([]byte, error)
+}
+
+// FileKeyring is a KeyWrapper over AES-256 master keys read from a JSON file:
+//
+//	{"current": "2026-01", "keys": {"2025-06": "<base64>", "2026-01": "<base64>"}}
+//
+// To rotate, add a key, make it current, restart, and run rotate-keys; keep
+// old keys in the file until rotation has finished.
+type FileKeyring struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// NewFileKeyring loads a keyring file.
+func NewFileKeyring(path string) (*FileKeyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring %s: %w", path, err)
+	}
+	var doc struct {
+		Current string            `json:"current"`
+		Keys    map[string]string `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse keyring %s: %w", path, err)
+	}
+	k := &FileKeyring{current: doc.Current, keys: make(map[string]cipher.AEAD, len(doc.Keys))}
+	for id, encoded := range doc.Keys {
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(raw) != dataKeySize {
+			return nil, fmt.Errorf("keyring %s: key %q must be %d base64-encoded bytes", path, id, dataKeySize)
+		}
+		if k.keys[id], err = newGCM(raw); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := k.keys[k.current]; !ok {
+		return nil, fmt.Errorf("keyring %s: current key %q is not in keys", path, k.current)
+	}
+	return k, nil
+}
+
+// CurrentKeyID implements KeyWrapper.
+func (k *FileKeyring) CurrentKeyID() string { return k.current }
+
+// Wrap implements KeyWrapper. The output is nonce || AES-GCM ciphertext, with
+// the master key ID as additional data.
+func (k *FileKeyring) Wrap(ctx context.Context, masterKeyID string, dataKey []byte) ([]byte, error) {
+	aead, ok := k.keys[masterKeyID]
+	if !ok {
+		return nil, fmt.Errorf("master key %q is not in the keyring", masterKeyID)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, dataKey, []byte(masterKeyID)), nil
+}
+
+// Unwrap implements KeyWrapper.
+func (k *FileKeyring) Unwrap(ctx context.Context, masterKeyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := k.keys[masterKeyID]
+	if !ok {
+		return nil, fmt.Errorf("master key %q is not in the keyring", masterKeyID)
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, fmt.Errorf("wrapped key too short")
+	}
+	nonce, ciphertext := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	dataKey, err := aead.Open(nil, nonce, ciphertext, []byte(masterKeyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key with master key %q: %w", masterKeyID, err)
+	}
+	return dataKey, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// Keys hands out per-study data keys, creating and wrapping them on first
+// use and keeping unwrapped copies in memory.
+type Keys struct {
+	store   DataKeyStore
+	wrapper KeyWrapper
+
+	mu      sync.Mutex
+	byID    map[string][]byte // Unwrapped keys
+	byStudy map[string]string // Study ID -> key ID
+}
+
+// NewKeys creates a data key service.
+func NewKeys(store DataKeyStore, wrapper KeyWrapper) *Keys {
+	return &Keys{store: store, wrapper: wrapper, byID: make(map[string][]byte), byStudy: make(map[string]string)}
+}
+
+// ForStudy returns the study's data key, creating one if it has none.
+func (k *Keys) ForStudy(ctx context.Context, studyID string) (string, []byte, error) {
+	k.mu.Lock()
+	if id, ok := k.byStudy[studyID]; ok {
+		if key, ok := k.byID[id]; ok {
+			k.mu.Unlock()
+			return id, key, nil
+		}
+	}
+	k.mu.Unlock()
+
+	stored, found, err := k.store.GetStudyDataKey(ctx, studyID)
+	if err != nil {
+		return "", nil, err
+	}
+	if !found {
+		if stored, err = k.create(ctx, studyID); err != nil {
+			return "", nil, err
+		}
+	}
+	key, err := k.unwrap(ctx, stored)
+	if err != nil {
+		return "", nil, err
+	}
+	k.remember(studyID, stored.ID, key)
+	return stored.ID, key, nil
+}
+
+// ByID returns the data key with the given ID.
+func (k *Keys) ByID(ctx context.Context, id string) ([]byte, error) {
+	k.mu.Lock()
+	key, ok := k.byID[id]
+	k.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	stored, found, err := k.store.GetDataKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("data key %s not found", id)
+	}
+	if key, err = k.unwrap(ctx, stored); err != nil {
+		return nil, err
+	}
+	k.remember("", stored.ID, key)
+	return key, nil
+}
+
+func (k *Keys) create(ctx context.Context, studyID string) (*DataKey, error) {
+	raw := make([]byte, dataKeySize)
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, fmt.Errorf("failed to generate data key id: %w", err)
+	}
+	masterKeyID := k.wrapper.CurrentKeyID()
+	wrapped, err := k.wrapper.Wrap(ctx, masterKeyID, raw)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := k.store.CreateDataKey(ctx, DataKey{
+		ID:          "dk-" + hex.EncodeToString(idBytes),
+		StudyID:     studyID,
+		Wrapped:     wrapped,
+		MasterKeyID: masterKeyID,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Created study data key", "studyID", studyID, "keyID", stored.ID, "masterKeyID", stored.MasterKeyID)
+	return stored, nil
+}
+
+func (k *Keys) unwrap(ctx context.Context, stored *DataKey) ([]byte, error) {
+	key, err := k.wrapper.Unwrap(ctx, stored.MasterKeyID, stored.Wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("data key %s: %w", stored.ID, err)
+	}
+	if len(key) != dataKeySize {
+		return nil, fmt.Errorf("data key %s has %d bytes, want %d", stored.ID, len(key), dataKeySize)
+	}
+	return key, nil
+}
+
+// remember caches an unwrapped key, and the study it belongs to unless
+// studyID is empty. Both are set under one lock, so a cache reset can't
+// leave a study pointing at a key that is no longer cached.
+func (k *Keys) remember(studyID, id string, key []byte) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if len(k.byID) >= maxCachedKeys {
+		k.byID = make(map[string][]byte)
+		k.byStudy = make(map[string]string)
+	}
+	k.byID[id] = key
+	if studyID != "" {
+		k.byStudy[studyID] = id
+	}
+}
+
+// RotationResult summarises a Rotate run.
+type RotationResult struct {
+	Rewrapped int `json:"rewrapped"`
+	Failed    int `json:"failed"`
+}
+
+// Rotate re-wraps every data key not yet wrapped by the current master key.
+// Objects are untouched: they are encrypted with the data keys, which don't
+// change. Keys that fail to re-wrap are logged, counted and skipped.
+func (k *Keys) Rotate(ctx context.Context, batchSize int) (*RotationResult, error) {
+	current := k.wrapper.CurrentKeyID()
+	result := &RotationResult{}
+	after := ""
+	for {
+		batch, err := k.store.ListDataKeysToRewrap(ctx, current, after, batchSize)
+		if err != nil {
+			return result, err
+		}
+		if len(batch) == 0 {
+			return result, nil
+		}
+		for _, key := range batch {
+			after = key.ID
+			if err := k.rewrap(ctx, key, current); err != nil {
+				if errors.Is(err, context.Canceled) {
+					return result, err
+				}
+				slog.ErrorContext(ctx, "Failed to re-wrap data key", "keyID", key.ID, "masterKeyID", key.MasterKeyID, "error", err)
+				result.Failed++
+				continue
+			}
+			result.Rewrapped++
+		}
+	}
+}
+
+func (k *Keys) rewrap(ctx context.Context, key DataKey, masterKeyID string) error {
+	raw, err := k.wrapper.Unwrap(ctx, key.MasterKeyID, key.Wrapped)
+	if err != nil {
+		return err
+	}
+	wrapped, err := k.wrapper.Wrap(ctx, masterKeyID, raw)
+	if err != nil {
+		return err
+	}
+	ok, err := k.store.RewrapDataKey(ctx, key.ID, wrapped, masterKeyID, key.MasterKeyID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		slog.InfoContext(ctx, "Data key changed during rotation, leaving it", "keyID", key.ID)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package tiers
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memKeyStore is an in-memory DataKeyStore.
+type memKeyStore struct {
+	mu   sync.Mutex
+	keys map[string]DataKey
+}
+
+func newMemKeyStore() *memKeyStore { return &memKeyStore{keys: make(map[string]DataKey)} }
+
+func (s *memKeyStore) GetStudyDataKey(_ context.Context, studyID string) (*DataKey, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.keys {
+		if k.StudyID == studyID {
+			return &k, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func (s *memKeyStore) GetDataKey(_ context.Context, id string) (*DataKey, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.keys[id]
+	return &k, ok, nil
+}
+
+func (s *memKeyStore) CreateDataKey(_ context.Context, key DataKey) (*DataKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.keys {
+		if k.StudyID == key.StudyID {
+			return &k, nil
+		}
+	}
+	s.keys[key.ID] = key
+	return &key, nil
+}
+
+func (s *memKeyStore) ListDataKeysToRewrap(context.Context, string, string, int) ([]DataKey, error) {
+	return nil, nil
+}
+
+func (s *memKeyStore) RewrapDataKey(context.Context, string, []byte, string, string, time.Time) (bool, error) {
+	return false, nil
+}
+
+// testKeyring returns a FileKeyring with one random master key.
+func testKeyring(t *testing.T) *FileKeyring {
+	t.Helper()
+	raw := make([]byte, dataKeySize)
+	if _, err := rand.Read(raw); err != nil {
+		t.Fatal(err)
+	}
+	aead, err := newGCM(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &FileKeyring{current: "m1", keys: map[string]cipher.AEAD{"m1": aead}}
+}
+
+func TestKeysForStudy(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name  string
+		setup func(k *Keys, id string) // Runs after the study's key was first handed out
+	}{
+		{name: "cached", setup: func(*Keys, string) {}},
+		{name: "cache reset", setup: func(k *Keys, _ string) {
+			k.byID = make(map[string][]byte)
+			k.byStudy = make(map[string]string)
+		}},
+		{name: "study cached without its key", setup: func(k *Keys, _ string) {
+			// What a reset racing with ForStudy used to leave behind
+			k.byID = make(map[string][]byte)
+		}},
+		{name: "key evicted while the study stays", setup: func(k *Keys, id string) {
+			delete(k.byID, id)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := NewKeys(newMemKeyStore(), testKeyring(t))
+			id, key, err := k.ForStudy(ctx, "study-1")
+			if err != nil {
+				t.Fatalf("ForStudy() error = %v", err)
+			}
+			tt.setup(k, id)
+
+			gotID, gotKey, err := k.ForStudy(ctx, "study-1")
+			if err != nil {
+				t.Fatalf("second ForStudy() error = %v", err)
+			}
+			if gotID != id || !bytes.Equal(gotKey, key) || len(gotKey) != dataKeySize {
+				t.Errorf("second ForStudy() = %s, %d-byte key, want %s and the same key", gotID, len(gotKey), id)
+			}
+			byID, err := k.ByID(ctx, id)
+			if err != nil || !bytes.Equal(byID, key) {
+				t.Errorf("ByID() = %x, %v, want the study's key", byID, err)
+			}
+		})
+	}
+}
+
+func TestKeysCacheBound(t *testing.T) {
+	ctx := context.Background()
+	k := NewKeys(newMemKeyStore(), testKeyring(t))
+	for i := 0; i <= maxCachedKeys; i++ {
+		if _, _, err := k.ForStudy(ctx, fmt.Sprintf("study-%d", i)); err != nil {
+			t.Fatalf("ForStudy(%d) error = %v", i, err)
+		}
+	}
+	if len(k.byID) > maxCachedKeys || len(k.byStudy) > maxCachedKeys {
+		t.Errorf("cache holds %d keys for %d studies, want at most %d", len(k.byID), len(k.byStudy), maxCachedKeys)
+	}
+	for study, id := range k.byStudy {
+		if _, ok := k.byID[id]; !ok {
+			t.Errorf("study %s points at uncached key %s", study, id)
+		}
+	}
+}
+
+func TestFileKeyringWrap(t *testing.T) {
+	ctx := context.Background()
+	kr := testKeyring(t)
+	dataKey := bytes.Repeat([]byte{7}, dataKeySize)
+	wrapped, err := kr.Wrap(ctx, "m1", dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := bytes.Clone(wrapped)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name        string
+		masterKeyID string
+		wrapped     []byte
+		wantErr     bool
+	}{
+		{name: "round trip", masterKeyID: "m1", wrapped: wrapped},
+		{name: "unknown master key", masterKeyID: "m2", wrapped: wrapped, wantErr: true},
+		{name: "tampered", masterKeyID: "m1", wrapped: tampered, wantErr: true},
+		{name: "too short", masterKeyID: "m1", wrapped: wrapped[:4], wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := kr.Unwrap(ctx, tt.masterKeyID, tt.wrapped)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unwrap() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !bytes.Equal(got, dataKey) {
+				t.Errorf("Unwrap() = %x, want %x", got, dataKey)
+			}
+		})
+	}
+}
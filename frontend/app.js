@@ -1,11 +1,9 @@
 // File: frontend/app.js
 
 // --- Configuration ---
-// Use Kubernetes Service DNS name and port for backend API when running inside K8s
-// IMPORTANT: Verify 'derp-gen-erics-backend' is the correct service name
-//            and '80' is the correct service port using 'kubectl get svc --namespace default'
-const API_BASE_URL = 'http://derp-gen-erics-backend.default.svc.cluster.local:80/api/v1';
-// NOTE: For production, make this configurable (e.g., via injected config)
+// The ingress routes /api to the backend on the same host as the frontend, so
+// requests stay same-origin and need no CORS origins
+const API_BASE_URL = '/api/v1';
 
 // Mock data - *** REPLACE THESE WITH ACTUAL UIDs FROM YOUR UPLOADED TEST DATA ***
 const studies = [
@@ -21,13 +19,13 @@ const studiesListElement = document.getElementById('studies-list');
 
 async function fetchLocation(studyUID) {
     try {
-        const response = await fetch(`<span class="math-inline">\{API\_BASE\_URL\}/studies/</span>{studyUID}/location`);
+        const response = await fetch(`${API_BASE_URL}/studies/${studyUID}/location`);
         if (!response.ok) {
              // Try to parse error json from backend
              let errorMsg = `HTTP error! status: ${response.status}`;
              try {
                  const errData = await response.json();
-                 errorMsg = errData.error || errData.message || errorMsg;
+                 errorMsg = errData.detail || errData.title || errData.error || errData.message || errorMsg;
              } catch(e) {}
             throw new Error(errorMsg);
         }
@@ -41,7 +39,7 @@ async function fetchLocation(studyUID) {
 async function moveStudy(studyUID, targetTier) {
     console.log(`Requesting move for ${studyUID} to ${targetTier}`);
     try {
-        const response = await fetch(`<span class="math-inline">\{API\_BASE\_URL\}/studies/</span>{studyUID}/move`, {
+        const response = await fetch(`${API_BASE_URL}/studies/${studyUID}/move`, {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({ targetTier: targetTier })
@@ -50,7 +48,7 @@ async function moveStudy(studyUID, targetTier) {
             let errorMsg = `HTTP error! status: ${response.status}`;
             try {
                 const errData = await response.json();
-                errorMsg = errData.error || errData.message || errorMsg;
+                errorMsg = errData.detail || errData.title || errData.error || errData.message || errorMsg;
             } catch (e) {}
             throw new Error(errorMsg);
         }
@@ -91,7 +89,7 @@ async function updateStudyDisplay(studyElement, study) {
             if (study.instanceUID && !study.instanceUID.startsWith("INSTANCE_UID_FOR_")) {
                 const img = document.createElement('img');
                 // Construct the absolute URL for the image source
-                const previewUrl = `<span class="math-inline">\{API\_BASE\_URL\}/studies/</span>{study.studyUID}/instances/${study.instanceUID}/preview`;
+                const previewUrl = `${API_BASE_URL}/studies/${study.studyUID}/instances/${study.instanceUID}/preview`;
                 img.src = previewUrl;
                 img.alt = `Preview for instance ${study.instanceUID}`;
                 img.onerror = () => { previewDiv.textContent = 'Preview load failed.'; };
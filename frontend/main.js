@@ -106,11 +106,27 @@ async function initializeCornerstone() {
 }
 
 // --- API Fetch Functions ---
+
+// Builds an Error from a failed response, using the problem+json body's
+// detail or title when the backend sent one
+async function responseError(response) {
+    let message = `HTTP error! status: ${response.status}`;
+    try {
+        const problem = await response.json();
+        if (problem.detail || problem.title) {
+            message = problem.detail ? `${problem.title}: ${problem.detail}` : problem.title;
+        }
+    } catch (parseError) {
+        // Not JSON; keep the status
+    }
+    return new Error(message);
+}
+
 async function fetchLocation(studyUID) {
     try {
         const response = await fetch(`${API_BASE_URL}/studies/${studyUID}/location`);
         if (!response.ok) { 
-            throw new Error(`HTTP error! status: ${response.status}`); 
+            throw await responseError(response); 
         }
         return await response.json();
     } catch (error) { 
@@ -134,7 +150,7 @@ async function moveStudy(studyUID, targetTier, targetLocation = '') {
         });
         
         if (!response.ok) { 
-            throw new Error(`HTTP error! status: ${response.status}`); 
+            throw await responseError(response); 
         }
         
         const result = await response.json();
@@ -147,20 +163,19 @@ async function moveStudy(studyUID, targetTier, targetLocation = '') {
     }
 }
 
-// Fetch preview image instead of trying to load full DICOM
-async function fetchInstancePreview(studyUID, instanceUID) {
+// Fetch the study's cached thumbnail instead of trying to load full DICOM
+async function fetchStudyThumbnail(studyUID) {
     try {
-        // Use preview endpoint instead of file endpoint
-        const response = await fetch(`${API_BASE_URL}/studies/${studyUID}/instances/${instanceUID}/preview`);
+        const response = await fetch(`${API_BASE_URL}/studies/${studyUID}/thumbnail`);
         
-        // Special handling for 412 Precondition Failed (study not in hot tier)
-        if (response.status === 412) {
-            console.log(`Preview not available for instance ${instanceUID} due to tier status`);
-            return { error: 'tier', message: 'Preview not available for this tier' };
+        // The study has no image to render (e.g. only structured reports)
+        if (response.status === 404) {
+            console.log(`No thumbnail available for study ${studyUID}`);
+            return { error: 'noimage', message: 'No image to preview in this study' };
         }
         
         if (!response.ok) {
-            throw new Error(`HTTP error! status: ${response.status}`);
+            throw await responseError(response);
         }
         
         // Get blob from response
@@ -169,7 +184,7 @@ async function fetchInstancePreview(studyUID, instanceUID) {
         // Create object URL for the image
         return { url: URL.createObjectURL(imageBlob) };
     } catch (error) {
-        console.error(`Error fetching preview for instance ${instanceUID}:`, error);
+        console.error(`Error fetching thumbnail for study ${studyUID}:`, error);
         return { error: 'general', message: error.message };
     }
 }
@@ -224,29 +239,23 @@ async function updateStudyDisplay(study, studyElement) {
         actionButton.textContent = 'Move to Cold';
         actionButton.onclick = () => moveStudy(study.ID, 'cold', ''); // Use Orthanc ID
 
-        // --- Load DICOM using Cornerstone with fallback ---
-        if (!study.SampleInstanceID) {
-            previewElement.textContent = 'No instance available for this study';
-            return;
-        }
-
         // Show loading state
         previewElement.textContent = 'Loading DICOM...';
         previewElement.style.width = "256px";
         previewElement.style.height = "256px";
         
         try {
-            // Try to use a simple image approach first
-            const preview = await fetchInstancePreview(study.ID, study.SampleInstanceID);
+            // Try the study thumbnail first
+            const preview = await fetchStudyThumbnail(study.ID);
             if (preview.url) {
-                // Display preview image as fallback
+                // Display the thumbnail
                 previewElement.innerHTML = '';
                 const img = document.createElement('img');
                 img.src = preview.url;
                 img.style.maxWidth = '100%';
                 img.style.maxHeight = '100%';
                 previewElement.appendChild(img);
-                console.log('Displayed preview image as fallback');
+                console.log('Displayed study thumbnail');
                 return; // Exit early, we have a working preview
             }
         } catch (previewError) {
@@ -254,6 +263,12 @@ async function updateStudyDisplay(study, studyElement) {
             // Continue to Cornerstone approach
         }
 
+        // --- Fall back to loading DICOM using Cornerstone ---
+        if (!study.SampleInstanceID) {
+            previewElement.textContent = 'No instance available for this study';
+            return;
+        }
+
         // Try Cornerstone approach
         try {
             // Construct the image ID using wadouri scheme
@@ -30,5 +30,10 @@ export default defineConfig({
   worker: {
     format: 'es',
   },
-  // ... server proxy etc ...
+  server: {
+    // Serve the API same-origin as in the cluster, so the backend needs no CORS origins
+    proxy: {
+      '/api': 'http://localhost:8080',
+    },
+  },
 });
\ No newline at end of file